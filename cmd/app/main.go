@@ -2,6 +2,8 @@ package main
 
 import (
 	"log"
+	// часовые пояса для шаблонов, если в образе нет системной базы
+	_ "time/tzdata"
 	"wn/config"
 	"wn/internal/app"
 )
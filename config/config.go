@@ -20,6 +20,9 @@ type (
 		Cache    CacheConfig     `yaml:"Cache"`
 		Email    EmailSmtpConfig `yaml:"email"`
 		Jwt      JwtConfig       `yaml:"jwt"`
+		Socket   SocketConfig    `yaml:"socket"`
+		Storage  StorageConfig   `yaml:"storage"`
+		Sync     SyncConfig      `yaml:"sync"`
 	}
 
 	InternalConfig struct {
@@ -27,6 +30,8 @@ type (
 		Environment        string `yaml:"environment" env:"ENVIRONMENT"`
 		LogInputParamOnErr bool   `yaml:"logInputParamOnErr" env:"LOG_INPUT_PARAM_ON_ERR"`
 		EncryptKey         string `yaml:"encryptKey" env:"ENCRYPT_KEY"`
+		// SearchKey ключ слепого поискового индекса, по умолчанию internal.encryptKey
+		SearchKey string `env:"SEARCH_KEY"`
 	}
 
 	JwtConfig struct {
@@ -68,7 +73,58 @@ type (
 	}
 
 	CronConfig struct {
-		GenerateStatics string `yaml:"generateStatics"`
+		ProcessImages  string `yaml:"processImages"`
+		CollectGarbage string `yaml:"collectGarbage"`
+		ExpireUploads  string `yaml:"expireUploads"`
+		ScanFiles      string `yaml:"scanFiles"`
+		FireReminders  string `yaml:"fireReminders"`
+		PruneChanges   string `yaml:"pruneChanges"`
+	}
+
+	SyncConfig struct {
+		// ChangesRetention сколько хранятся изменения в ленте синхронизации, 0 - бессрочно.
+		// Клиент с курсором старше получает resync
+		ChangesRetention time.Duration `yaml:"changesRetention" env:"SYNC_CHANGES_RETENTION"`
+	}
+
+	StorageConfig struct {
+		// Backend local или s3
+		Backend   string   `yaml:"backend" env:"STORAGE_BACKEND"`
+		LocalPath string   `yaml:"localPath" env:"STORAGE_LOCAL_PATH"`
+		S3        S3Config `yaml:"s3"`
+
+		MaxAvatarSize     int64 `yaml:"maxAvatarSize" env:"STORAGE_MAX_AVATAR_SIZE"`
+		MaxAttachmentSize int64 `yaml:"maxAttachmentSize" env:"STORAGE_MAX_ATTACHMENT_SIZE"`
+		ThumbnailSizes    []int `yaml:"thumbnailSizes" env:"STORAGE_THUMBNAIL_SIZES"`
+		MaxImagePixels    int   `yaml:"maxImagePixels" env:"STORAGE_MAX_IMAGE_PIXELS"`
+
+		// SignKey ключ подписи ссылок на закрытые файлы, по умолчанию internal.encryptKey
+		SignKey      string        `env:"STORAGE_SIGN_KEY"`
+		SignedUrlTTL time.Duration `yaml:"signedUrlTtl" env:"STORAGE_SIGNED_URL_TTL"`
+		// GCGracePeriod сколько неиспользуемый файл хранится до удаления
+		GCGracePeriod time.Duration `yaml:"gcGracePeriod" env:"STORAGE_GC_GRACE_PERIOD"`
+		// DefaultQuota лимит места на пользователя в байтах, 0 без ограничения
+		DefaultQuota int64 `yaml:"defaultQuota" env:"STORAGE_DEFAULT_QUOTA"`
+		// UploadExpiry сколько живет незавершенная загрузка по частям с последнего куска
+		UploadExpiry time.Duration `yaml:"uploadExpiry" env:"STORAGE_UPLOAD_EXPIRY"`
+		Scanner      ScannerConfig `yaml:"scanner"`
+	}
+
+	ScannerConfig struct {
+		// Backend none или clamd. При none вложения не проверяются
+		Backend string `yaml:"backend" env:"SCANNER_BACKEND"`
+		// Address адрес clamd: tcp://host:3310 или unix:///path/clamd.ctl
+		Address string        `yaml:"address" env:"SCANNER_ADDRESS"`
+		Timeout time.Duration `yaml:"timeout" env:"SCANNER_TIMEOUT"`
+	}
+
+	S3Config struct {
+		Endpoint  string `yaml:"endpoint" env:"S3_ENDPOINT"`
+		Region    string `yaml:"region" env:"S3_REGION"`
+		Bucket    string `yaml:"bucket" env:"S3_BUCKET"`
+		AccessKey string `env:"S3_ACCESS_KEY"`
+		SecretKey string `env:"S3_SECRET_KEY"`
+		PathStyle bool   `yaml:"pathStyle" env:"S3_PATH_STYLE"`
 	}
 
 	HTTPConfig struct {
@@ -79,6 +135,21 @@ type (
 		MaxHeaderMegabytes int           `yaml:"maxHeaderBytes"`
 	}
 
+	SocketConfig struct {
+		HandlerTimeout  time.Duration `yaml:"handlerTimeout" env:"SOCKET_HANDLER_TIMEOUT"`
+		SendQueueSize   int           `yaml:"sendQueueSize" env:"SOCKET_SEND_QUEUE_SIZE"`
+		WriteTimeout    time.Duration `yaml:"writeTimeout" env:"SOCKET_WRITE_TIMEOUT"`
+		PingPeriod      time.Duration `yaml:"pingPeriod" env:"SOCKET_PING_PERIOD"`
+		PongTimeout     time.Duration `yaml:"pongTimeout" env:"SOCKET_PONG_TIMEOUT"`
+		MaxMessageBytes int64         `yaml:"maxMessageBytes" env:"SOCKET_MAX_MESSAGE_BYTES"`
+		OverflowPolicy  string        `yaml:"overflowPolicy" env:"SOCKET_OVERFLOW_POLICY"`
+
+		MoveTick            time.Duration `yaml:"moveTick" env:"SOCKET_MOVE_TICK"`
+		MovePersistDelay    time.Duration `yaml:"movePersistDelay" env:"SOCKET_MOVE_PERSIST_DELAY"`
+		MoveMaxPersistDelay time.Duration `yaml:"moveMaxPersistDelay" env:"SOCKET_MOVE_MAX_PERSIST_DELAY"`
+		MoveGrantTTL        time.Duration `yaml:"moveGrantTtl" env:"SOCKET_MOVE_GRANT_TTL"`
+	}
+
 	EmailSmtpConfig struct {
 		OwnerEmail    string        `yaml:"ownerEmail"`
 		OwnerPassword string        `env:"EMAIL_SMTP_PASSWORD"`
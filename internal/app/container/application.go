@@ -2,10 +2,15 @@ package container
 
 import (
 	"wn/internal/application/auth"
+	"wn/internal/application/changes"
 	"wn/internal/application/file"
+	"wn/internal/application/journal"
 	"wn/internal/application/layout"
 	"wn/internal/application/note"
 	"wn/internal/application/permissions"
+	"wn/internal/application/reminder"
+	"wn/internal/application/tag"
+	"wn/internal/application/template"
 	userApp "wn/internal/application/user"
 )
 
@@ -25,6 +30,11 @@ type applications struct {
 	layout      *layout.Service
 	file        *file.Service
 	permissions *permissions.Application
+	changes     *changes.Application
+	tag         *tag.Application
+	template    *template.Application
+	journal     *journal.Application
+	reminder    *reminder.Application
 }
 
 func (s *applications) getUserApplicationService() *userApp.Service {
@@ -36,6 +46,7 @@ func (s *applications) getUserApplicationService() *userApp.Service {
 			s.c.getServices().getUserService(),
 			s.c.getServices().getFileService(),
 			s.c.getServices().getLayoutService(),
+			s.c.getConfig().Storage.ThumbnailSizes,
 		)
 	}
 	return s.user
@@ -65,6 +76,11 @@ func (s *applications) getNoteApplicationService() *note.Service {
 			s.c.getServices().getNoteService(),
 			s.c.getServices().getPermissionsService(),
 			s.c.getRepositories().getLayoutRepository(),
+			s.c.getRepositories().getNoteRepository(),
+			s.c.getServices().getSocketService(),
+			s.c.getServices().getMovementService(),
+			s.c.getServices().getTemplateService(),
+			s.c.getConfig().Socket.MoveGrantTTL,
 		)
 	}
 	return s.note
@@ -90,6 +106,10 @@ func (s *applications) getFileApplciationService() *file.Service {
 			s.c.getLogger(),
 
 			s.c.getServices().getFileService(),
+			s.c.getServices().getPermissionsService(),
+			s.c.getServices().getUserService(),
+			s.c.getServices().getUploadService(),
+			s.c.getUrlSigner(),
 		)
 	}
 	return s.file
@@ -106,7 +126,77 @@ func (s *applications) getPermissionsApplicationService() *permissions.Applicati
 			s.c.getCaches().getPermissionsCache(),
 			s.c.getRepositories().getLayoutRepository(),
 			s.c.getRepositories().getNoteRepository(),
+			s.c.getRepositories().getChangesRepository(),
 		)
 	}
 	return s.permissions
 }
+
+func (s *applications) getChangesApplicationService() *changes.Application {
+	if s.changes == nil {
+		s.changes = changes.NewApplication(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+
+			s.c.getServices().getChangesService(),
+			s.c.getServices().getNoteService(),
+			s.c.getServices().getLayoutService(),
+			s.c.getServices().getPermissionsService(),
+			s.c.getRepositories().getNoteRepository(),
+			s.c.getRepositories().getLayoutRepository(),
+		)
+	}
+	return s.changes
+}
+
+func (s *applications) getTagApplicationService() *tag.Application {
+	if s.tag == nil {
+		s.tag = tag.NewApplication(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+
+			s.c.getServices().getTagService(),
+			s.c.getServices().getPermissionsService(),
+		)
+	}
+	return s.tag
+}
+
+func (s *applications) getTemplateApplicationService() *template.Application {
+	if s.template == nil {
+		s.template = template.NewApplication(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+
+			s.c.getServices().getTemplateService(),
+			s.c.getRepositories().getLayoutRepository(),
+			s.c.getServices().getPermissionsService(),
+		)
+	}
+	return s.template
+}
+
+func (s *applications) getJournalApplicationService() *journal.Application {
+	if s.journal == nil {
+		s.journal = journal.NewApplication(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+
+			s.c.getServices().getJournalService(),
+		)
+	}
+	return s.journal
+}
+
+func (s *applications) getReminderApplicationService() *reminder.Application {
+	if s.reminder == nil {
+		s.reminder = reminder.NewApplication(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+
+			s.c.getServices().getReminderService(),
+			s.c.getServices().getPermissionsService(),
+		)
+	}
+	return s.reminder
+}
@@ -1,18 +1,23 @@
 package container
 
 import (
+	"context"
 	"wn/config"
 	"wn/internal/domain/dto"
 	"wn/internal/domain/services/crypto"
 	"wn/internal/endpoint/controller/http"
 	v1 "wn/internal/endpoint/controller/http/api/v1"
 	"wn/pkg/applogger"
+	"wn/pkg/blobstore"
 	"wn/pkg/database/dragonfly"
 	"wn/pkg/database/postgres"
 	"wn/pkg/httpserver"
 	"wn/pkg/response"
 	"wn/pkg/restclient"
+	"wn/pkg/scanner"
+	"wn/pkg/textindex"
 	"wn/pkg/trx"
+	"wn/pkg/urlsign"
 
 	"github.com/google/uuid"
 )
@@ -30,6 +35,10 @@ type Container struct {
 	httpKernel         *http.Kernel
 	restClient         restclient.RestClient
 	encryptor          *crypto.Encryptor
+	blobStore          blobstore.BlobStore
+	scanner            scanner.Scanner
+	urlSigner          *urlsign.Signer
+	indexer            *textindex.Indexer
 
 	repositories *repositories
 	applications *applications
@@ -59,9 +68,14 @@ func (c *Container) Start() error {
 	if err := c.getWorkers().start(); err != nil {
 		return err
 	}
-	c.getServices().getSocketService().RegisterHandler("UPDATE_DRAFT_REQUEST", c.getServices().getNoteService().HandleCreateDraft)
-	c.getServices().getSocketService().RegisterHandler("COMMIT_DRAFT_REQUEST", c.getServices().getNoteService().HandleCommitDraft)
-	c.getServices().getSocketService().RegisterHandler("PONG", func(msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	socketService := c.getServices().getSocketService()
+	socketService.RegisterHandler(dto.UpdateDraftRequestEvent, c.getApplication().getNoteApplicationService().HandleUpdateDraft)
+	socketService.RegisterHandler(dto.CommitDraftRequestEvent, c.getApplication().getNoteApplicationService().HandleCommitDraft)
+	socketService.RegisterHandler(dto.SubscribeLayoutRequestEvent, c.getApplication().getNoteApplicationService().HandleSubscribeLayout)
+	socketService.RegisterHandler(dto.UnsubscribeLayoutRequestEvent, c.getApplication().getNoteApplicationService().HandleUnsubscribeLayout)
+	socketService.RegisterHandler(dto.MoveNoteEvent, c.getApplication().getNoteApplicationService().HandleMoveNote)
+	// Пинг теперь идет control фреймом, но старые клиенты еще отвечают json ивентом PONG
+	socketService.RegisterHandler(dto.PongEvent, func(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
 		return nil, nil
 	})
 	return nil
@@ -71,5 +85,9 @@ func (c *Container) Stop() error {
 	if err := c.getHTTPServer().Shutdown(); err != nil {
 		return err
 	}
+	// досохраняем позиции, которые еще не дошли до базы
+	if c.services != nil && c.services.movement != nil {
+		c.services.movement.Stop()
+	}
 	return nil
 }
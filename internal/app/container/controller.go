@@ -3,11 +3,17 @@ package container
 import (
 	v1 "wn/internal/endpoint/controller/http/api/v1"
 	"wn/internal/endpoint/controller/http/api/v1/auth"
+	"wn/internal/endpoint/controller/http/api/v1/changes"
 	"wn/internal/endpoint/controller/http/api/v1/file"
+	"wn/internal/endpoint/controller/http/api/v1/journal"
 	"wn/internal/endpoint/controller/http/api/v1/layout"
 	"wn/internal/endpoint/controller/http/api/v1/note"
 	"wn/internal/endpoint/controller/http/api/v1/permissions"
+	"wn/internal/endpoint/controller/http/api/v1/reminder"
 	"wn/internal/endpoint/controller/http/api/v1/socket"
+	"wn/internal/endpoint/controller/http/api/v1/tag"
+	"wn/internal/endpoint/controller/http/api/v1/task"
+	"wn/internal/endpoint/controller/http/api/v1/template"
 	"wn/internal/endpoint/controller/http/api/v1/user"
 )
 
@@ -59,6 +65,42 @@ func (c *Container) getHTTPDispatcher() *v1.Dispatcher {
 				c.getResponseBuilder(),
 				c.applications.getPermissionsApplicationService(),
 			),
+
+			changes.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getChangesApplicationService(),
+			),
+
+			tag.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getTagApplicationService(),
+			),
+
+			template.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getTemplateApplicationService(),
+			),
+
+			journal.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getJournalApplicationService(),
+			),
+
+			task.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getNoteApplicationService(),
+			),
+
+			reminder.NewController(
+				c.getLogger(),
+				c.getResponseBuilder(),
+				c.getApplication().getReminderApplicationService(),
+			),
 		)
 	}
 	return c.httpDispatcher
@@ -5,13 +5,17 @@ import (
 	"wn/internal/domain/services/crypto"
 	"wn/internal/endpoint/controller/http"
 	"wn/pkg/applogger"
+	"wn/pkg/blobstore"
 	"wn/pkg/database/dragonfly"
 	"wn/pkg/database/postgres"
 	"wn/pkg/httpserver"
 	"wn/pkg/migrator"
 	"wn/pkg/response"
 	"wn/pkg/restclient"
+	"wn/pkg/scanner"
+	"wn/pkg/textindex"
 	"wn/pkg/trx"
+	"wn/pkg/urlsign"
 )
 
 func (c *Container) Migrate() error {
@@ -56,6 +60,75 @@ func (c *Container) getEncryptor() *crypto.Encryptor {
 	return c.encryptor
 }
 
+func (c *Container) getUrlSigner() *urlsign.Signer {
+	if c.urlSigner == nil {
+		cfg := c.getConfig()
+		key := cfg.Storage.SignKey
+		if key == "" {
+			key = cfg.Internal.EncryptKey
+		}
+		c.urlSigner = urlsign.NewSigner(key, cfg.Storage.SignedUrlTTL)
+	}
+	return c.urlSigner
+}
+
+func (c *Container) getIndexer() *textindex.Indexer {
+	if c.indexer == nil {
+		cfg := c.getConfig()
+		key := cfg.Internal.SearchKey
+		if key == "" {
+			key = cfg.Internal.EncryptKey
+		}
+		c.indexer = textindex.NewIndexer(key)
+	}
+	return c.indexer
+}
+
+func (c *Container) getBlobStore() blobstore.BlobStore {
+	if c.blobStore == nil {
+		cfg := c.getConfig().Storage
+		switch cfg.Backend {
+		case "s3":
+			store, err := blobstore.NewS3(blobstore.S3Config{
+				Endpoint:  cfg.S3.Endpoint,
+				Region:    cfg.S3.Region,
+				Bucket:    cfg.S3.Bucket,
+				AccessKey: cfg.S3.AccessKey,
+				SecretKey: cfg.S3.SecretKey,
+				PathStyle: cfg.S3.PathStyle,
+			}, nil)
+			if err != nil {
+				log.Fatalf("getBlobStore: %v", err)
+			}
+			c.blobStore = store
+		case "local", "":
+			store, err := blobstore.NewLocal(cfg.LocalPath)
+			if err != nil {
+				log.Fatalf("getBlobStore: %v", err)
+			}
+			c.blobStore = store
+		default:
+			log.Fatalf("getBlobStore: unknown storage backend %q", cfg.Backend)
+		}
+	}
+	return c.blobStore
+}
+
+func (c *Container) getScanner() scanner.Scanner {
+	if c.scanner == nil {
+		cfg := c.getConfig().Storage.Scanner
+		switch cfg.Backend {
+		case "clamd":
+			c.scanner = scanner.NewClamd(cfg.Address, cfg.Timeout)
+		case "none", "":
+			c.scanner = scanner.Noop{}
+		default:
+			log.Fatalf("getScanner: unknown scanner backend %q", cfg.Backend)
+		}
+	}
+	return c.scanner
+}
+
 func (c *Container) getKernel() *http.Kernel {
 	if c.httpKernel == nil {
 		c.httpKernel = http.NewKernel(
@@ -1,13 +1,22 @@
 package container
 
 import (
+	"wn/internal/infrastructure/repository/changes"
 	"wn/internal/infrastructure/repository/file"
+	"wn/internal/infrastructure/repository/filerefs"
+	"wn/internal/infrastructure/repository/journal"
 	"wn/internal/infrastructure/repository/layout"
 	"wn/internal/infrastructure/repository/links"
 	"wn/internal/infrastructure/repository/note"
 	"wn/internal/infrastructure/repository/permissions"
 	"wn/internal/infrastructure/repository/positions"
+	"wn/internal/infrastructure/repository/reminder"
+	"wn/internal/infrastructure/repository/search"
+	"wn/internal/infrastructure/repository/tags"
+	"wn/internal/infrastructure/repository/tasks"
+	"wn/internal/infrastructure/repository/templates"
 	tokensRepo "wn/internal/infrastructure/repository/tokens"
+	"wn/internal/infrastructure/repository/upload"
 	userRepo "wn/internal/infrastructure/repository/user"
 )
 
@@ -29,6 +38,15 @@ type repositories struct {
 	links       *links.Repository
 	positions   *positions.Repository
 	permissions *permissions.Repository
+	changes     *changes.Repository
+	upload      *upload.Repository
+	fileRefs    *filerefs.Repository
+	tags        *tags.Repository
+	search      *search.Repository
+	templates   *templates.Repository
+	journal     *journal.Repository
+	tasks       *tasks.Repository
+	reminder    *reminder.Repository
 }
 
 func (r *repositories) getUserRepository() *userRepo.Repository {
@@ -52,6 +70,27 @@ func (r *repositories) getFileRepository() *file.Repository {
 	return r.file
 }
 
+func (r *repositories) getUploadRepository() *upload.Repository {
+	if r.upload == nil {
+		r.upload = upload.NewRepository(r.c.getDBPool())
+	}
+	return r.upload
+}
+
+func (r *repositories) getFileRefsRepository() *filerefs.Repository {
+	if r.fileRefs == nil {
+		r.fileRefs = filerefs.NewRepository(r.c.getDBPool())
+	}
+	return r.fileRefs
+}
+
+func (r *repositories) getSearchRepository() *search.Repository {
+	if r.search == nil {
+		r.search = search.NewRepository(r.c.getDBPool())
+	}
+	return r.search
+}
+
 func (r *repositories) getNoteRepository() *note.Repository {
 	if r.note == nil {
 		r.note = note.NewRepository(r.c.getDBPool())
@@ -86,3 +125,45 @@ func (r *repositories) getPermissionsRepository() *permissions.Repository {
 	}
 	return r.permissions
 }
+
+func (r *repositories) getChangesRepository() *changes.Repository {
+	if r.changes == nil {
+		r.changes = changes.NewRepository(r.c.getDBPool())
+	}
+	return r.changes
+}
+
+func (r *repositories) getTemplatesRepository() *templates.Repository {
+	if r.templates == nil {
+		r.templates = templates.NewRepository(r.c.getDBPool())
+	}
+	return r.templates
+}
+
+func (r *repositories) getTagsRepository() *tags.Repository {
+	if r.tags == nil {
+		r.tags = tags.NewRepository(r.c.getDBPool())
+	}
+	return r.tags
+}
+
+func (r *repositories) getJournalRepository() *journal.Repository {
+	if r.journal == nil {
+		r.journal = journal.NewRepository(r.c.getDBPool())
+	}
+	return r.journal
+}
+
+func (r *repositories) getTasksRepository() *tasks.Repository {
+	if r.tasks == nil {
+		r.tasks = tasks.NewRepository(r.c.getDBPool())
+	}
+	return r.tasks
+}
+
+func (r *repositories) getReminderRepository() *reminder.Repository {
+	if r.reminder == nil {
+		r.reminder = reminder.NewRepository(r.c.getDBPool())
+	}
+	return r.reminder
+}
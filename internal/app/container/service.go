@@ -1,15 +1,25 @@
 package container
 
 import (
+	"log"
+	"wn/internal/domain/services/changes"
 	"wn/internal/domain/services/file"
+	"wn/internal/domain/services/journal"
 	"wn/internal/domain/services/layout"
+	"wn/internal/domain/services/movement"
 	"wn/internal/domain/services/multyplayer"
 	"wn/internal/domain/services/note"
 	"wn/internal/domain/services/permission"
+	"wn/internal/domain/services/reminder"
 	smtpSrv "wn/internal/domain/services/smtp"
 	"wn/internal/domain/services/socket"
+	"wn/internal/domain/services/tag"
+	"wn/internal/domain/services/template"
 	tokenSrv "wn/internal/domain/services/token"
+	"wn/internal/domain/services/upload"
 	userSrv "wn/internal/domain/services/user"
+
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 func (c *Container) getServices() *services {
@@ -31,6 +41,14 @@ type services struct {
 	socketManager      *socket.Service
 	multyplayerManager *multyplayer.Service
 	permissionsService *permission.Service
+	socketMetrics      *socket.Metrics
+	changes            *changes.Service
+	movement           *movement.Service
+	upload             *upload.Service
+	tag                *tag.Service
+	template           *template.Service
+	journal            *journal.Service
+	reminder           *reminder.Service
 }
 
 func (s *services) getUserService() *userSrv.Service {
@@ -79,24 +97,55 @@ func (s *services) getTokenService() *tokenSrv.Service {
 func (s *services) getFileService() *file.Service {
 	if s.file == nil {
 		s.file = file.NewService(
+			s.c.getTransactionManager(),
 			s.c.getLogger(),
+			file.NewConfig(
+				s.c.getConfig().Storage.MaxAvatarSize,
+				s.c.getConfig().Storage.MaxAttachmentSize,
+				s.c.getConfig().Storage.ThumbnailSizes,
+				s.c.getConfig().Storage.MaxImagePixels,
+				s.c.getConfig().Storage.GCGracePeriod,
+				s.c.getConfig().Storage.DefaultQuota,
+				s.c.getConfig().Storage.Scanner.Backend == "clamd",
+			),
 			s.c.getRepositories().getFileRepository(),
+			s.c.getBlobStore(),
+			s.c.getScanner(),
 		)
 
 	}
 	return s.file
 }
 
+func (s *services) getUploadService() *upload.Service {
+	if s.upload == nil {
+		s.upload = upload.NewService(
+			s.c.getLogger(),
+			upload.NewConfig(s.c.getConfig().Storage.UploadExpiry),
+			s.c.getRepositories().getUploadRepository(),
+			s.getFileService(),
+			s.c.getBlobStore(),
+		)
+	}
+	return s.upload
+}
+
 func (s *services) getNoteService() *note.Service {
 	if s.note == nil {
 		s.note = note.NewService(
 			s.c.getTransactionManager(),
 			s.c.getLogger(),
 			s.c.getEncryptor(),
+			s.c.getIndexer(),
 			s.c.getRepositories().getNoteRepository(),
 			s.c.getRepositories().getLayoutRepository(),
 			s.c.getRepositories().getLinksRepository(),
 			s.c.getRepositories().getPositionsRepository(),
+			s.c.getRepositories().getChangesRepository(),
+			s.c.getRepositories().getFileRefsRepository(),
+			s.c.getRepositories().getTagsRepository(),
+			s.c.getRepositories().getSearchRepository(),
+			s.c.getRepositories().getTasksRepository(),
 		)
 
 	}
@@ -114,6 +163,8 @@ func (s *services) getLayoutService() *layout.Service {
 			s.c.getRepositories().getPositionsRepository(),
 			s.c.getServices().getNoteService(),
 			s.c.getRepositories().getPermissionsRepository(),
+			s.c.getRepositories().getChangesRepository(),
+			s.c.getRepositories().getTagsRepository(),
 		)
 	}
 	return s.layout
@@ -121,11 +172,50 @@ func (s *services) getLayoutService() *layout.Service {
 
 func (s *services) getSocketService() *socket.Service {
 	if s.socketManager == nil {
-		s.socketManager = socket.NewService(s.c.getLogger())
+		connectionConfig, err := socket.NewConnectionConfig(
+			s.c.getConfig().Socket.SendQueueSize,
+			s.c.getConfig().Socket.WriteTimeout,
+			s.c.getConfig().Socket.PingPeriod,
+			s.c.getConfig().Socket.PongTimeout,
+			s.c.getConfig().Socket.MaxMessageBytes,
+			s.c.getConfig().Socket.OverflowPolicy,
+		)
+		if err != nil {
+			log.Fatalf("getSocketService: %v", err)
+		}
+		s.socketManager = socket.NewService(
+			s.c.getLogger(),
+			s.c.getConfig().Socket.HandlerTimeout,
+			connectionConfig,
+			s.getSocketMetrics(),
+		)
 	}
 	return s.socketManager
 }
 
+func (s *services) getMovementService() *movement.Service {
+	if s.movement == nil {
+		s.movement = movement.NewService(
+			s.c.getLogger(),
+			movement.NewConfig(
+				s.c.getConfig().Socket.MoveTick,
+				s.c.getConfig().Socket.MovePersistDelay,
+				s.c.getConfig().Socket.MoveMaxPersistDelay,
+			),
+			s.getSocketService(),
+			s.getNoteService(),
+		)
+	}
+	return s.movement
+}
+
+func (s *services) getSocketMetrics() *socket.Metrics {
+	if s.socketMetrics == nil {
+		s.socketMetrics = socket.NewMetrics(prometheus.DefaultRegisterer)
+	}
+	return s.socketMetrics
+}
+
 func (s *services) getMultyplayerService() *multyplayer.Service {
 	if s.multyplayerManager == nil {
 		s.multyplayerManager = multyplayer.NewService()
@@ -143,3 +233,69 @@ func (s *services) getPermissionsService() *permission.Service {
 	}
 	return s.permissionsService
 }
+
+func (s *services) getChangesService() *changes.Service {
+	if s.changes == nil {
+		s.changes = changes.NewService(
+			s.c.getLogger(),
+			changes.NewConfig(s.c.getConfig().Sync.ChangesRetention),
+			s.c.getRepositories().getChangesRepository(),
+			s.c.getRepositories().getLayoutRepository(),
+			s.getNoteService(),
+		)
+	}
+	return s.changes
+}
+
+func (s *services) getTagService() *tag.Service {
+	if s.tag == nil {
+		s.tag = tag.NewService(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+			s.c.getRepositories().getTagsRepository(),
+		)
+	}
+	return s.tag
+}
+
+func (s *services) getTemplateService() *template.Service {
+	if s.template == nil {
+		s.template = template.NewService(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+			s.c.getEncryptor(),
+			s.c.getRepositories().getTemplatesRepository(),
+			s.c.getRepositories().getLayoutRepository(),
+			s.getUserService(),
+			s.getPermissionsService(),
+		)
+	}
+	return s.template
+}
+
+func (s *services) getJournalService() *journal.Service {
+	if s.journal == nil {
+		s.journal = journal.NewService(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+			s.c.getRepositories().getJournalRepository(),
+			s.getNoteService(),
+			s.getTemplateService(),
+			s.getPermissionsService(),
+		)
+	}
+	return s.journal
+}
+
+func (s *services) getReminderService() *reminder.Service {
+	if s.reminder == nil {
+		s.reminder = reminder.NewService(
+			s.c.getTransactionManager(),
+			s.c.getLogger(),
+			s.c.getRepositories().getReminderRepository(),
+			s.getSMTPService(),
+			s.getSocketService(),
+		)
+	}
+	return s.reminder
+}
@@ -2,7 +2,11 @@ package container
 
 import (
 	"fmt"
+	"wn/internal/endpoint/worker/changes"
 	"wn/internal/endpoint/worker/file"
+	"wn/internal/endpoint/worker/note"
+	"wn/internal/endpoint/worker/reminder"
+	"wn/internal/endpoint/worker/upload"
 	"wn/pkg/cron"
 )
 
@@ -10,7 +14,11 @@ type workers struct {
 	c  *Container
 	cr *cron.Cron
 
-	file *file.Cron
+	file     *file.Cron
+	upload   *upload.Cron
+	note     *note.Cron
+	reminder *reminder.Cron
+	changes  *changes.Cron
 }
 
 func (c *Container) getWorkers() *workers {
@@ -30,9 +38,55 @@ func (w *workers) getFileJob() *file.Cron {
 	return w.file
 }
 
+func (w *workers) getUploadJob() *upload.Cron {
+	if w.upload == nil {
+		w.upload = upload.NewCron(w.c.getLogger(), w.c.getServices().getUploadService())
+	}
+	return w.upload
+}
+
+func (w *workers) getNoteJob() *note.Cron {
+	if w.note == nil {
+		w.note = note.NewCron(w.c.getLogger(), w.c.getServices().getNoteService())
+	}
+	return w.note
+}
+
+func (w *workers) getReminderJob() *reminder.Cron {
+	if w.reminder == nil {
+		w.reminder = reminder.NewCron(w.c.getLogger(), w.c.getServices().getReminderService())
+	}
+	return w.reminder
+}
+
+func (w *workers) getChangesJob() *changes.Cron {
+	if w.changes == nil {
+		w.changes = changes.NewCron(w.c.getLogger(), w.c.getServices().getChangesService())
+	}
+	return w.changes
+}
+
 func (w *workers) start() error {
-	if err := w.cr.AddFunc(w.c.getConfig().Cron.GenerateStatics, w.getFileJob().GenerateStatics); err != nil {
-		return fmt.Errorf("GenerateStatics: %v", err)
+	go w.getFileJob().MoveLegacyFiles()
+	go w.getNoteJob().IndexFileRefs()
+	go w.getNoteJob().IndexSearch()
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.ProcessImages, w.getFileJob().ProcessImages); err != nil {
+		return fmt.Errorf("ProcessImages: %v", err)
+	}
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.ScanFiles, w.getFileJob().ScanFiles); err != nil {
+		return fmt.Errorf("ScanFiles: %v", err)
+	}
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.CollectGarbage, w.getFileJob().CollectGarbage); err != nil {
+		return fmt.Errorf("CollectGarbage: %v", err)
+	}
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.ExpireUploads, w.getUploadJob().ExpireUploads); err != nil {
+		return fmt.Errorf("ExpireUploads: %v", err)
+	}
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.FireReminders, w.getReminderJob().FireReminders); err != nil {
+		return fmt.Errorf("FireReminders: %v", err)
+	}
+	if err := w.cr.AddFunc(w.c.getConfig().Cron.PruneChanges, w.getChangesJob().PruneChanges); err != nil {
+		return fmt.Errorf("PruneChanges: %v", err)
 	}
 	w.cr.Start()
 	return nil
@@ -0,0 +1,287 @@
+package changes
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/trx"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type changesService interface {
+	GetChanges(ctx context.Context, userId uuid.UUID, since dto.SyncCursor, limit int) (*dto.SyncChangesResponse, error)
+	GetLatestForeignSeq(ctx context.Context, key *entity.Change) (int64, error)
+	GetLatestSeq(ctx context.Context, key *entity.Change) (int64, error)
+	GetCurrent(ctx context.Context, userId uuid.UUID, key *entity.Change) (*dto.SyncChange, error)
+}
+
+type noteService interface {
+	CreateNoteWithId(ctx context.Context, noteId uuid.UUID, title, payload string, ownerId, layoutId uuid.UUID) (uuid.UUID, []string, error)
+	UpdateNote(ctx context.Context, noteId, userId uuid.UUID, title, payload *string, version *int64) (int64, []string, error)
+	DeleteNoteById(ctx context.Context, noteId uuid.UUID) error
+	DragNote(ctx context.Context, noteId, toLayout uuid.UUID, version *int64) (int64, error)
+	UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error
+	CreateLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error
+	DeleteLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error
+}
+
+type layoutService interface {
+	CreateLayoutWithId(ctx context.Context, layoutId uuid.UUID, title, color string, ownerId uuid.UUID, isMain bool) (uuid.UUID, error)
+	UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) (int64, error)
+	DeleteLayoutById(ctx context.Context, layoutId, ownerId uuid.UUID) error
+}
+
+type permissionsService interface {
+	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+	CheckPermissionByNoteId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type noteRepository interface {
+	GetById(ctx context.Context, noteId uuid.UUID) (*entity.Note, error)
+}
+
+type layoutRepository interface {
+	GetById(ctx context.Context, layoutId uuid.UUID) (*entity.Layout, error)
+}
+
+type Application struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	changesService     changesService
+	noteService        noteService
+	layoutService      layoutService
+	permissionsService permissionsService
+
+	noteRepository   noteRepository
+	layoutRepository layoutRepository
+}
+
+func NewApplication(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	changesService changesService,
+	noteService noteService,
+	layoutService layoutService,
+	permissionsService permissionsService,
+	noteRepository noteRepository,
+	layoutRepository layoutRepository,
+) *Application {
+	return &Application{
+		tx:                 tx,
+		logger:             logger,
+		changesService:     changesService,
+		noteService:        noteService,
+		layoutService:      layoutService,
+		permissionsService: permissionsService,
+		noteRepository:     noteRepository,
+		layoutRepository:   layoutRepository,
+	}
+}
+
+func (srv *Application) GetChanges(ctx context.Context, userId uuid.UUID, since string) (*dto.SyncChangesResponse, error) {
+	cursor, err := dto.DecodeSyncCursor(since)
+	if err != nil || cursor.Txid < 0 || cursor.Seq < 0 {
+		return nil, apperrors.BadCursor
+	}
+	return srv.changesService.GetChanges(ctx, userId, cursor, constants.SyncPageSize)
+}
+
+// Push применяет офлайн изменения по одному, каждое в своей транзакции.
+// Ошибка одного элемента не откатывает остальные
+func (srv *Application) Push(ctx context.Context, userId uuid.UUID, req *dto.SyncPushRequest) (*dto.SyncPushResponse, error) {
+	output := dto.SyncPushResponse{
+		Results: make([]dto.SyncPushResult, 0, len(req.Items)),
+	}
+	for i := range req.Items {
+		output.Results = append(output.Results, srv.pushItem(ctx, userId, i, &req.Items[i]))
+	}
+	return &output, nil
+}
+
+func (srv *Application) pushItem(ctx context.Context, userId uuid.UUID, index int, item *dto.SyncPushItem) dto.SyncPushResult {
+	result := dto.SyncPushResult{
+		Index:  index,
+		Status: enum.SyncPushStatusApplied,
+	}
+
+	key, err := changeKey(item)
+	if err == nil {
+		err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+			// сначала запись: она берет блокировки строк сущности, и конкурентный писатель
+			// либо уже закоммитил свое изменение, либо ждет нас. Конфликт откатывает транзакцию
+			if err := srv.apply(ctx, userId, item); err != nil {
+				return err
+			}
+			foreign, err := srv.changesService.GetLatestForeignSeq(ctx, key)
+			if err != nil {
+				return err
+			}
+			if foreign > item.BaseSeq {
+				return apperrors.SyncConflict
+			}
+			result.Seq, err = srv.changesService.GetLatestSeq(ctx, key)
+			return err
+		})
+	}
+	if err == nil {
+		return result
+	}
+
+	result.Seq = 0
+	result.Status = enum.SyncPushStatusRejected
+	if errors.Is(err, apperrors.SyncConflict) {
+		result.Status = enum.SyncPushStatusConflict
+		current, currentErr := srv.changesService.GetCurrent(ctx, userId, key)
+		if currentErr != nil {
+			srv.logger.WithCtx(ctx).Errorf("Push GetCurrent: %s", currentErr.Error())
+		}
+		result.Current = current
+	}
+
+	appErr, ok := errors.Cause(err).(*apperror.AppError)
+	if !ok {
+		srv.logger.WithCtx(ctx).Errorf("Push item %d: %s", index, err.Error())
+		appErr = apperror.NewInternalError(err)
+	}
+	result.Code = appErr.Code
+	result.Message = appErr.Message
+	return result
+}
+
+func (srv *Application) apply(ctx context.Context, userId uuid.UUID, item *dto.SyncPushItem) error {
+	switch item.Kind {
+	case enum.SyncEntityKindNote:
+		return srv.applyNote(ctx, userId, item.Operation, item.Note)
+	case enum.SyncEntityKindPosition:
+		p := item.Position
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, p.NoteId, userId, true, true, false); err != nil {
+			return err
+		}
+		if item.Operation == enum.SyncOperationDelete {
+			return srv.noteService.UpdateNotePosition(ctx, p.NoteId, nil, nil)
+		}
+		return srv.noteService.UpdateNotePosition(ctx, p.NoteId, p.XPos, p.YPos)
+	case enum.SyncEntityKindLink:
+		l := item.Link
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, l.FirstNoteId, userId, true, true, false); err != nil {
+			return err
+		}
+		if item.Operation == enum.SyncOperationDelete {
+			return srv.noteService.DeleteLink(ctx, l.FirstNoteId, l.SecondNoteId)
+		}
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, l.SecondNoteId, userId, true, false, false); err != nil {
+			return err
+		}
+		return srv.noteService.CreateLink(ctx, l.FirstNoteId, l.SecondNoteId)
+	case enum.SyncEntityKindLayout:
+		return srv.applyLayout(ctx, userId, item.Operation, item.Layout)
+	default:
+		return apperrors.BadKind
+	}
+}
+
+func (srv *Application) applyNote(ctx context.Context, userId uuid.UUID, operation enum.SyncOperation, n *dto.SyncPushNote) error {
+	if operation == enum.SyncOperationDelete {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, n.Id, userId, true, true, false); err != nil {
+			return err
+		}
+		return srv.noteService.DeleteNoteById(ctx, n.Id)
+	}
+
+	existing, err := srv.noteRepository.GetById(ctx, n.Id)
+	if errors.Is(err, apperrors.RecordNotFound) {
+		if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, n.LayoutId, userId, true, true, false); err != nil {
+			return err
+		}
+		_, _, err = srv.noteService.CreateNoteWithId(ctx, n.Id, deref(n.Title), deref(n.Payload), userId, n.LayoutId)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, n.Id, userId, true, true, false); err != nil {
+		return err
+	}
+	if n.LayoutId != uuid.Nil && n.LayoutId != existing.LayoutId {
+		if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, n.LayoutId, userId, true, true, false); err != nil {
+			return err
+		}
+		if _, err := srv.noteService.DragNote(ctx, n.Id, n.LayoutId, nil); err != nil {
+			return err
+		}
+	}
+	if n.Title == nil && n.Payload == nil {
+		return nil
+	}
+	_, _, err = srv.noteService.UpdateNote(ctx, n.Id, userId, n.Title, n.Payload, nil)
+	return err
+}
+
+func (srv *Application) applyLayout(ctx context.Context, userId uuid.UUID, operation enum.SyncOperation, l *dto.SyncPushLayout) error {
+	existing, err := srv.layoutRepository.GetById(ctx, l.Id)
+	if errors.Is(err, apperrors.RecordNotFound) {
+		if operation == enum.SyncOperationDelete {
+			return apperrors.LayoutNotFound
+		}
+		_, err = srv.layoutService.CreateLayoutWithId(ctx, l.Id, deref(l.Title), deref(l.Color), userId, false)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, l.Id, userId, true, false, true); err != nil {
+		return err
+	}
+	if operation == enum.SyncOperationDelete {
+		if existing.IsMain {
+			return apperrors.CantApply
+		}
+		return srv.layoutService.DeleteLayoutById(ctx, l.Id, userId)
+	}
+	if l.Title == nil && l.Color == nil {
+		return nil
+	}
+	_, err = srv.layoutService.UpdateLayout(ctx, request.UpdateLayout{
+		LayoutId: l.Id,
+		Title:    l.Title,
+		Color:    l.Color,
+	}, userId)
+	return err
+}
+
+// changeKey ключ сущности в ленте изменений, заодно проверяет, что передан нужный объект
+func changeKey(item *dto.SyncPushItem) (*entity.Change, error) {
+	if item.Operation != enum.SyncOperationUpsert && item.Operation != enum.SyncOperationDelete {
+		return nil, apperrors.BadOperation
+	}
+	switch {
+	case item.Kind == enum.SyncEntityKindNote && item.Note != nil:
+		return entity.NewChange(item.Kind, item.Operation, item.Note.Id, item.Note.LayoutId), nil
+	case item.Kind == enum.SyncEntityKindPosition && item.Position != nil:
+		return entity.NewChange(item.Kind, item.Operation, item.Position.NoteId, uuid.Nil), nil
+	case item.Kind == enum.SyncEntityKindLink && item.Link != nil:
+		return entity.NewLinkChange(item.Operation, item.Link.FirstNoteId, item.Link.SecondNoteId, uuid.Nil), nil
+	case item.Kind == enum.SyncEntityKindLayout && item.Layout != nil:
+		return entity.NewChange(item.Kind, item.Operation, item.Layout.Id, item.Layout.Id), nil
+	default:
+		return nil, apperrors.BadKind
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,185 @@
+package changes_test
+
+import (
+	"context"
+	"testing"
+	"wn/internal/application/changes"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore одна заметка и журнал вызовов сервисов, foreign - seq чужого изменения
+type memoryStore struct {
+	note    entity.Note
+	foreign int64
+	calls   []string
+	title   *string
+	payload *string
+}
+
+func (s *memoryStore) GetChanges(context.Context, uuid.UUID, dto.SyncCursor, int) (*dto.SyncChangesResponse, error) {
+	return &dto.SyncChangesResponse{}, nil
+}
+
+func (s *memoryStore) GetLatestForeignSeq(context.Context, *entity.Change) (int64, error) {
+	return s.foreign, nil
+}
+
+func (s *memoryStore) GetLatestSeq(context.Context, *entity.Change) (int64, error) {
+	return s.foreign + 1, nil
+}
+
+func (s *memoryStore) GetCurrent(_ context.Context, _ uuid.UUID, key *entity.Change) (*dto.SyncChange, error) {
+	return &dto.SyncChange{Seq: s.foreign, Kind: key.Kind, EntityId: key.EntityId}, nil
+}
+
+func (s *memoryStore) CreateNoteWithId(context.Context, uuid.UUID, string, string, uuid.UUID, uuid.UUID) (uuid.UUID, []string, error) {
+	s.calls = append(s.calls, "create")
+	return uuid.Nil, nil, nil
+}
+
+func (s *memoryStore) UpdateNote(_ context.Context, _, _ uuid.UUID, title, payload *string, _ *int64) (int64, []string, error) {
+	s.calls = append(s.calls, "update")
+	s.title, s.payload = title, payload
+	return 0, nil, nil
+}
+
+func (s *memoryStore) DeleteNoteById(context.Context, uuid.UUID) error {
+	s.calls = append(s.calls, "delete")
+	return nil
+}
+
+func (s *memoryStore) DragNote(_ context.Context, _, toLayout uuid.UUID, _ *int64) (int64, error) {
+	s.calls = append(s.calls, "drag")
+	s.note.LayoutId = toLayout
+	return 0, nil
+}
+
+func (s *memoryStore) UpdateNotePosition(context.Context, uuid.UUID, *float64, *float64) error {
+	s.calls = append(s.calls, "position")
+	return nil
+}
+
+func (s *memoryStore) CreateLink(context.Context, uuid.UUID, uuid.UUID) error { return nil }
+
+func (s *memoryStore) DeleteLink(context.Context, uuid.UUID, uuid.UUID) error { return nil }
+
+func (s *memoryStore) CreateLayoutWithId(context.Context, uuid.UUID, string, string, uuid.UUID, bool) (uuid.UUID, error) {
+	return uuid.Nil, nil
+}
+
+func (s *memoryStore) UpdateLayout(context.Context, request.UpdateLayout, uuid.UUID) (int64, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) DeleteLayoutById(context.Context, uuid.UUID, uuid.UUID) error { return nil }
+
+func (s *memoryStore) CheckPermissionByLayoutId(context.Context, uuid.UUID, uuid.UUID, bool, bool, bool) error {
+	return nil
+}
+
+func (s *memoryStore) CheckPermissionByNoteId(context.Context, uuid.UUID, uuid.UUID, bool, bool, bool) error {
+	return nil
+}
+
+func (s *memoryStore) GetById(_ context.Context, noteId uuid.UUID) (*entity.Note, error) {
+	if noteId != s.note.Id {
+		return nil, apperrors.RecordNotFound
+	}
+	n := s.note
+	return &n, nil
+}
+
+type layouts struct{}
+
+func (layouts) GetById(context.Context, uuid.UUID) (*entity.Layout, error) {
+	return nil, apperrors.RecordNotFound
+}
+
+// rollbackTx откатывает изменения хранилища, если функция вернула ошибку
+type rollbackTx struct {
+	store *memoryStore
+}
+
+func (tx rollbackTx) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	saved := *tx.store
+	saved.calls = append([]string(nil), tx.store.calls...)
+	if err := fn(ctx); err != nil {
+		*tx.store = saved
+		return err
+	}
+	return nil
+}
+
+func TestPush(t *testing.T) {
+	ctx := context.Background()
+	lgr := testutil.Logger(t)
+	userId, from, to := uuid.New(), uuid.New(), uuid.New()
+	store := &memoryStore{
+		note:    entity.Note{Id: uuid.New(), LayoutId: from},
+		foreign: 5,
+	}
+	app := changes.NewApplication(rollbackTx{store}, lgr, store, store, store, store, store, layouts{})
+	title := "renamed"
+
+	push := func(item dto.SyncPushItem) dto.SyncPushResult {
+		t.Helper()
+		resp, err := app.Push(ctx, userId, &dto.SyncPushRequest{Items: []dto.SyncPushItem{item}})
+		if err != nil || len(resp.Results) != 1 {
+			t.Fatalf("Push() = %+v, %v", resp, err)
+		}
+		return resp.Results[0]
+	}
+
+	// клиент не видел изменение 5: запись откатывается, в ответе текущее состояние
+	result := push(dto.SyncPushItem{
+		Kind:      enum.SyncEntityKindNote,
+		Operation: enum.SyncOperationUpsert,
+		BaseSeq:   4,
+		Note:      &dto.SyncPushNote{Id: store.note.Id, Title: &title},
+	})
+	if result.Status != enum.SyncPushStatusConflict || result.Code != apperrors.SyncConflict.Code ||
+		result.Current == nil || result.Current.Seq != 5 || result.Seq != 0 {
+		t.Fatalf("conflict result = %+v", result)
+	}
+	if len(store.calls) != 0 {
+		t.Fatalf("conflict not rolled back: %v", store.calls)
+	}
+
+	// только заголовок: текст не передается в UpdateNote
+	result = push(dto.SyncPushItem{
+		Kind:      enum.SyncEntityKindNote,
+		Operation: enum.SyncOperationUpsert,
+		BaseSeq:   5,
+		Note:      &dto.SyncPushNote{Id: store.note.Id, Title: &title},
+	})
+	if result.Status != enum.SyncPushStatusApplied || result.Seq != 6 {
+		t.Fatalf("title result = %+v", result)
+	}
+	if store.title == nil || *store.title != title || store.payload != nil {
+		t.Fatalf("UpdateNote(title = %v, payload = %v)", store.title, store.payload)
+	}
+
+	// только перенос: заголовок и текст не трогаются
+	store.calls = nil
+	result = push(dto.SyncPushItem{
+		Kind:      enum.SyncEntityKindNote,
+		Operation: enum.SyncOperationUpsert,
+		BaseSeq:   5,
+		Note:      &dto.SyncPushNote{Id: store.note.Id, LayoutId: to},
+	})
+	if result.Status != enum.SyncPushStatusApplied || len(store.calls) != 1 || store.calls[0] != "drag" || store.note.LayoutId != to {
+		t.Fatalf("move result = %+v, calls = %v", result, store.calls)
+	}
+
+	result = push(dto.SyncPushItem{Kind: enum.SyncEntityKindNote, Operation: enum.SyncOperationUpsert})
+	if result.Status != enum.SyncPushStatusRejected || result.Code != apperrors.BadKind.Code {
+		t.Fatalf("bad item result = %+v", result)
+	}
+}
@@ -2,44 +2,205 @@ package file
 
 import (
 	"context"
-	"mime/multipart"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
 	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
+	"wn/internal/domain/dto/user"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
 	"wn/pkg/applogger"
+	"wn/pkg/constants"
 	"wn/pkg/trx"
+	"wn/pkg/urlsign"
 
 	"github.com/google/uuid"
 )
 
 type fileService interface {
-	NewFile(ctx context.Context, file *multipart.FileHeader) (string, error)
+	NewFile(ctx context.Context, purpose enum.FilePurpose, ownerId, noteId uuid.UUID, originalName string, r io.Reader) (*entity.File, error)
+	GetFile(ctx context.Context, name string) (*entity.File, error)
+	OpenContent(ctx context.Context, item *entity.File) (io.ReadSeekCloser, error)
+	GetNoteAttachments(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error)
+	AttachFile(ctx context.Context, name string, noteId, userId uuid.UUID) error
+	DetachFile(ctx context.Context, name string, noteId uuid.UUID) error
+	GarbageReport(ctx context.Context) (*dto.GarbageReport, error)
+	MaxSize(purpose enum.FilePurpose) int64
+	GetOwnerFiles(ctx context.Context, ownerId uuid.UUID, page int) ([]*entity.File, int, error)
+	DeleteFile(ctx context.Context, name string, ownerId uuid.UUID, force bool) (*entity.File, error)
+}
+
+type permissionsService interface {
+	CheckPermissionByNoteId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+	GetReadableNoteIds(ctx context.Context, userId uuid.UUID, noteIds []uuid.UUID) (map[uuid.UUID]bool, error)
+}
+
+type userService interface {
+	GetUserById(ctx context.Context, userId uuid.UUID, password string) (*user.User, error)
 }
 
 type Service struct {
 	tx     trx.TransactionManager
 	logger applogger.Logger
 
-	fileService fileService
+	fileService        fileService
+	permissionsService permissionsService
+	userService        userService
+	uploadService      uploadService
+	signer             *urlsign.Signer
 }
 
 func NewService(
 	tx trx.TransactionManager,
 	logger applogger.Logger,
 	fileService fileService,
+	permissionsService permissionsService,
+	userService userService,
+	uploadService uploadService,
+	signer *urlsign.Signer,
 ) *Service {
 	return &Service{
-		tx:          tx,
-		logger:      logger,
-		fileService: fileService,
+		tx:                 tx,
+		logger:             logger,
+		fileService:        fileService,
+		permissionsService: permissionsService,
+		userService:        userService,
+		uploadService:      uploadService,
+		signer:             signer,
 	}
 }
 
-func (srv *Service) UploadFile(ctx context.Context, userId uuid.UUID, req request.UploadFileRequest, host string) (*dto.UploadFileResponse, error) {
-	filename, err := srv.fileService.NewFile(ctx, req.File)
+// UploadFile загружает вложение. С noteId файл сразу прикрепляется к заметке
+func (srv *Service) UploadFile(ctx context.Context, userId, noteId uuid.UUID, originalName string, r io.Reader, host string) (*dto.UploadFileResponse, error) {
+	if noteId != uuid.Nil {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, noteId, userId, true, true, false); err != nil {
+			return nil, err
+		}
+	}
+	item, err := srv.fileService.NewFile(ctx, enum.FilePurposeAttachment, userId, noteId, originalName, r)
 	if err != nil {
 		return nil, err
 	}
+	imgUrl, variants := srv.urls(host, item)
 	return &dto.UploadFileResponse{
-		ImgUrl: host + "/statics/images/" + filename,
-	}, err
+		ImgUrl:    imgUrl,
+		Variants:  variants,
+		ScanState: item.ScanState.String(),
+	}, nil
+}
+
+// OpenFile отдает файл, если он публичный, ссылка подписана или у пользователя есть доступ.
+// Непроверенные антивирусом и зараженные файлы не отдаются никому, включая владельца.
+// userId пустой, если запрос без авторизации
+func (srv *Service) OpenFile(ctx context.Context, name string, userId uuid.UUID, query url.Values) (*entity.File, io.ReadSeekCloser, error) {
+	item, err := srv.fileService.GetFile(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := srv.checkRead(ctx, item, userId, query); err != nil {
+		return nil, nil, err
+	}
+	switch item.ScanState {
+	case enum.ScanStatePending, enum.ScanStateScanning:
+		return nil, nil, apperrors.FileScanPending
+	case enum.ScanStateInfected, enum.ScanStateFailed:
+		return nil, nil, apperrors.FileQuarantined
+	}
+	content, err := srv.fileService.OpenContent(ctx, item)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, content, nil
+}
+
+func (srv *Service) checkRead(ctx context.Context, item *entity.File, userId uuid.UUID, query url.Values) error {
+	if item.Public() || srv.signer.Verify(item.Name, query, time.Now()) {
+		return nil
+	}
+	if userId == uuid.Nil {
+		return apperrors.FileAccessDenied
+	}
+	if item.OwnerId == userId {
+		return nil
+	}
+	if item.NoteId == uuid.Nil {
+		return apperrors.FileAccessDenied
+	}
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, item.NoteId, userId, true, false, false); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("OpenFile checkPerms: %s", err.Error())
+		return apperrors.FileAccessDenied
+	}
+	return nil
+}
+
+func (srv *Service) GetAttachments(ctx context.Context, userId, noteId uuid.UUID, host string) ([]dto.Attachment, error) {
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, noteId, userId, true, false, false); err != nil {
+		return nil, err
+	}
+	items, err := srv.fileService.GetNoteAttachments(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]dto.Attachment, 0, len(items))
+	for _, item := range items {
+		fileUrl, variants := srv.urls(host, item)
+		attachments = append(attachments, dto.Attachment{
+			Name:        item.Name,
+			Url:         fileUrl,
+			Variants:    variants,
+			ContentType: item.ContentType,
+			Size:        item.Size,
+			CreatedAt:   item.CreatedAt,
+			ScanState:   item.ScanState.String(),
+		})
+	}
+	return attachments, nil
+}
+
+func (srv *Service) Attach(ctx context.Context, userId uuid.UUID, req request.AttachmentRequest) error {
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
+		return err
+	}
+	return srv.fileService.AttachFile(ctx, req.FileName, req.NoteId, userId)
+}
+
+func (srv *Service) Detach(ctx context.Context, userId uuid.UUID, req request.AttachmentRequest) error {
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
+		return err
+	}
+	return srv.fileService.DetachFile(ctx, req.FileName, req.NoteId)
+}
+
+// GarbageReport отчет сборщика мусора без удаления, только для администраторов.
+// Роль берется из базы, а не из токена
+func (srv *Service) GarbageReport(ctx context.Context, userId uuid.UUID) (*dto.GarbageReport, error) {
+	u, err := srv.userService.GetUserById(ctx, userId, "")
+	if err != nil {
+		return nil, err
+	}
+	if u.Role != constants.AdminRole {
+		return nil, apperrors.AdminOnly
+	}
+	return srv.fileService.GarbageReport(ctx)
+}
+
+// urls адреса файла и миниатюр, у закрытых файлов подписанные
+func (srv *Service) urls(host string, item *entity.File) (string, map[string]string) {
+	fileUrl := func(name string) string {
+		if item.Public() {
+			return dto.FileUrl(host, name)
+		}
+		return dto.FileUrl(host, name) + "?" + srv.signer.Sign(name, time.Now()).Encode()
+	}
+	var variants map[string]string
+	if len(item.Variants) > 0 {
+		variants = make(map[string]string, len(item.Variants))
+		for _, variant := range item.Variants {
+			variants[strconv.Itoa(variant.Variant)] = fileUrl(variant.Name)
+		}
+	}
+	return fileUrl(item.Name), variants
 }
@@ -0,0 +1,92 @@
+package file
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+// GetMyFiles страница файлов пользователя, второе значение - всего файлов
+func (srv *Service) GetMyFiles(ctx context.Context, userId uuid.UUID, page int, host string) ([]dto.OwnFile, int, error) {
+	items, count, err := srv.fileService.GetOwnerFiles(ctx, userId, page)
+	if err != nil {
+		return nil, 0, err
+	}
+	refs := make([]*entity.FileReferences, 0, len(items))
+	for _, item := range items {
+		refs = append(refs, item.References)
+	}
+	readable, err := srv.readableNotes(ctx, userId, refs...)
+	if err != nil {
+		return nil, 0, err
+	}
+	files := make([]dto.OwnFile, 0, len(items))
+	for _, item := range items {
+		fileUrl, variants := srv.urls(host, item)
+		files = append(files, dto.OwnFile{
+			Name:          item.Name,
+			Url:           fileUrl,
+			Variants:      variants,
+			ContentType:   item.ContentType,
+			Purpose:       item.Purpose.String(),
+			Size:          item.Size,
+			CreatedAt:     item.CreatedAt,
+			References:    references(readable, item.References),
+			ScanState:     item.ScanState.String(),
+			ScanSignature: item.ScanSignature,
+		})
+	}
+	return files, count, nil
+}
+
+// DeleteFile удаляет файл пользователя. Если на файл ссылались заметки (force), они возвращаются в ответе
+func (srv *Service) DeleteFile(ctx context.Context, userId uuid.UUID, req request.DeleteFileRequest) (*dto.DeleteFileResponse, error) {
+	item, err := srv.fileService.DeleteFile(ctx, req.FileName, userId, req.Force)
+	if err != nil {
+		return nil, err
+	}
+	readable, err := srv.readableNotes(ctx, userId, item.References)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.DeleteFileResponse{
+		Name:             item.Name,
+		BrokenReferences: references(readable, item.References),
+	}, nil
+}
+
+// readableNotes какие из заметок в ссылках пользователь может читать, одной проверкой на все файлы
+func (srv *Service) readableNotes(ctx context.Context, userId uuid.UUID, refs ...*entity.FileReferences) (map[uuid.UUID]bool, error) {
+	noteIds := make([]uuid.UUID, 0)
+	for _, r := range refs {
+		if r.AttachedTo != uuid.Nil {
+			noteIds = append(noteIds, r.AttachedTo)
+		}
+		noteIds = append(noteIds, r.Notes...)
+	}
+	return srv.permissionsService.GetReadableNoteIds(ctx, userId, noteIds)
+}
+
+// references ссылки на файл, id заметок показываются только тем, кто может их читать
+func references(readable map[uuid.UUID]bool, refs *entity.FileReferences) dto.FileReferences {
+	out := dto.FileReferences{Avatar: refs.Avatar, Notes: []uuid.UUID{}}
+	if refs.AttachedTo != uuid.Nil {
+		if readable[refs.AttachedTo] {
+			attachedTo := refs.AttachedTo
+			out.AttachedTo = &attachedTo
+		} else {
+			out.OtherNotes++
+		}
+	}
+	for _, noteId := range refs.Notes {
+		if readable[noteId] {
+			out.Notes = append(out.Notes, noteId)
+		} else {
+			out.OtherNotes++
+		}
+	}
+	return out
+}
@@ -0,0 +1,91 @@
+package file
+
+import (
+	"context"
+	"io"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+type uploadService interface {
+	CreateUpload(ctx context.Context, ownerId, noteId uuid.UUID, fileName string, length int64) (*entity.Upload, error)
+	GetUpload(ctx context.Context, id, ownerId uuid.UUID) (*entity.Upload, error)
+	WriteChunk(ctx context.Context, id, ownerId uuid.UUID, offset int64, r io.Reader) (*entity.Upload, *entity.File, error)
+	GetResult(ctx context.Context, item *entity.Upload) (*entity.File, error)
+	DeleteUpload(ctx context.Context, item *entity.Upload) error
+}
+
+// MaxUploadSize наибольший размер вложения, отдается клиентам tus в Tus-Max-Size
+func (srv *Service) MaxUploadSize() int64 {
+	return srv.fileService.MaxSize(enum.FilePurposeAttachment)
+}
+
+// CreateUpload заводит загрузку по частям. С noteId файл после загрузки прикрепится к заметке
+func (srv *Service) CreateUpload(ctx context.Context, userId uuid.UUID, req request.CreateUploadRequest) (*dto.UploadStatus, error) {
+	if req.NoteId != uuid.Nil {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
+			return nil, err
+		}
+	}
+	item, err := srv.uploadService.CreateUpload(ctx, userId, req.NoteId, req.FileName, req.Length)
+	if err != nil {
+		return nil, err
+	}
+	return srv.uploadStatus(item, nil, ""), nil
+}
+
+func (srv *Service) GetUpload(ctx context.Context, userId, id uuid.UUID, host string) (*dto.UploadStatus, error) {
+	item, err := srv.uploadService.GetUpload(ctx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	result, err := srv.uploadService.GetResult(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	return srv.uploadStatus(item, result, host), nil
+}
+
+// WriteUpload дописывает кусок. Права на заметку перепроверяются, их могли отозвать во время загрузки
+func (srv *Service) WriteUpload(ctx context.Context, userId, id uuid.UUID, offset int64, r io.Reader, host string) (*dto.UploadStatus, error) {
+	item, err := srv.uploadService.GetUpload(ctx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if item.NoteId != uuid.Nil {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, item.NoteId, userId, true, true, false); err != nil {
+			return nil, err
+		}
+	}
+	item, result, err := srv.uploadService.WriteChunk(ctx, id, userId, offset, r)
+	if err != nil {
+		return nil, err
+	}
+	return srv.uploadStatus(item, result, host), nil
+}
+
+func (srv *Service) DeleteUpload(ctx context.Context, userId, id uuid.UUID) error {
+	item, err := srv.uploadService.GetUpload(ctx, id, userId)
+	if err != nil {
+		return err
+	}
+	return srv.uploadService.DeleteUpload(ctx, item)
+}
+
+func (srv *Service) uploadStatus(item *entity.Upload, result *entity.File, host string) *dto.UploadStatus {
+	status := &dto.UploadStatus{
+		Id:        item.Id,
+		Length:    item.Length,
+		Offset:    item.Offset,
+		ExpiresAt: item.ExpiresAt,
+	}
+	if result != nil {
+		fileUrl, variants := srv.urls(host, result)
+		status.File = &dto.UploadFileResponse{ImgUrl: fileUrl, Variants: variants}
+	}
+	return status
+}
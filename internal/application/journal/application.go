@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+)
+
+// monthFormat формат месяца календаря
+const monthFormat = "2006-01"
+
+type journalService interface {
+	GetJournal(ctx context.Context, userId uuid.UUID) (*dto.Journal, error)
+	SetJournal(ctx context.Context, userId, layoutId uuid.UUID, titleFormat string, templateId *uuid.UUID, now time.Time) error
+	GetDay(ctx context.Context, userId uuid.UUID, now time.Time, values map[string]string) (*dto.JournalDay, error)
+	GetCalendar(ctx context.Context, userId uuid.UUID, month time.Time) (*dto.JournalCalendar, error)
+}
+
+type Application struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	journalService journalService
+}
+
+func NewApplication(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	journalService journalService,
+) *Application {
+	return &Application{
+		tx:             tx,
+		logger:         logger,
+		journalService: journalService,
+	}
+}
+
+func (app *Application) GetJournal(ctx context.Context, userId uuid.UUID) (*dto.Journal, error) {
+	return app.journalService.GetJournal(ctx, userId)
+}
+
+func (app *Application) SetJournal(ctx context.Context, userId uuid.UUID, req request.JournalSettingsRequest) error {
+	now, err := util.GetCurrentLocalTime(req.Timezone)
+	if err != nil {
+		return apperrors.BadTimezone
+	}
+	return app.journalService.SetJournal(ctx, userId, req.LayoutId, req.TitleFormat, req.TemplateId, now)
+}
+
+// GetDay для прошедших и будущих дней время в подстановках берется текущее
+func (app *Application) GetDay(ctx context.Context, userId uuid.UUID, req request.JournalDayRequest) (*dto.JournalDay, error) {
+	now, err := util.GetCurrentLocalTime(req.Timezone)
+	if err != nil {
+		return nil, apperrors.BadTimezone
+	}
+	if req.Date != "" {
+		day, err := time.Parse(dto.DayFormat, req.Date)
+		if err != nil {
+			return nil, apperrors.BadDate
+		}
+		now = time.Date(day.Year(), day.Month(), day.Day(), now.Hour(), now.Minute(), now.Second(), 0, now.Location())
+	}
+	return app.journalService.GetDay(ctx, userId, now, req.TemplateValues)
+}
+
+// GetCalendar пустой month - текущий месяц в часовом поясе timezone
+func (app *Application) GetCalendar(ctx context.Context, userId uuid.UUID, month, timezone string) (*dto.JournalCalendar, error) {
+	now, err := util.GetCurrentLocalTime(timezone)
+	if err != nil {
+		return nil, apperrors.BadTimezone
+	}
+	if month != "" {
+		now, err = time.Parse(monthFormat, month)
+		if err != nil {
+			return nil, apperrors.BadMonth
+		}
+	}
+	return app.journalService.GetCalendar(ctx, userId, now)
+}
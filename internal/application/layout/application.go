@@ -15,8 +15,9 @@ type layoutService interface {
 	DeleteLayoutById(ctx context.Context, layoutId, ownerId uuid.UUID) error
 	GetAvailableLayouts(ctx context.Context, userId uuid.UUID) ([]dto.Layout, error)
 	ExportLayouts(ctx context.Context, userId uuid.UUID) (*dto.ExportInfo, error)
-	UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) error
+	UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) (int64, error)
 	ImportLayouts(ctx context.Context, userId uuid.UUID, info *dto.ExportInfo) error
+	DuplicateLayout(ctx context.Context, layoutId, userId uuid.UUID, title string) (uuid.UUID, error)
 }
 
 type permissionsService interface {
@@ -62,10 +63,10 @@ func (srv *Service) DeleteLayout(ctx context.Context, req request.LayoutIdReques
 	return srv.layoutService.DeleteLayoutById(ctx, req.LayoutId, userId)
 }
 
-func (srv *Service) UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) error {
+func (srv *Service) UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) (int64, error) {
 	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, req.LayoutId, userId, true, false, true); err != nil {
 		srv.logger.Warnf("DeleteLayout checkPerms: %s", err.Error())
-		return err
+		return 0, err
 	}
 	return srv.layoutService.UpdateLayout(ctx, req, userId)
 }
@@ -77,3 +78,12 @@ func (srv *Service) ExportInfo(ctx context.Context, req dto.ExportInfoRequest) (
 func (srv *Service) ImportLayouts(ctx context.Context, userId uuid.UUID, req *dto.ImportInfoRequest) error {
 	return srv.layoutService.ImportLayouts(ctx, userId, &req.Info)
 }
+
+// DuplicateLayout скопировать можно любой лейаут, который пользователь может читать
+func (srv *Service) DuplicateLayout(ctx context.Context, req request.DuplicateLayoutRequest, userId uuid.UUID) (uuid.UUID, error) {
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, req.LayoutId, userId, true, false, false); err != nil {
+		srv.logger.Warnf("DuplicateLayout checkPerms: %s", err.Error())
+		return uuid.Nil, err
+	}
+	return srv.layoutService.DuplicateLayout(ctx, req.LayoutId, userId, req.Title)
+}
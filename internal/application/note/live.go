@@ -0,0 +1,165 @@
+package note
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/socket"
+	"wn/pkg/apperror"
+	"wn/pkg/constants"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// moveGrantsSweepSize при таком размере кэша из него вычищаются протухшие записи
+const moveGrantsSweepSize = 10000
+
+type moveGrant struct {
+	layoutId  uuid.UUID
+	expiresAt time.Time
+}
+
+// moveGrants запоминает право двигать заметку, чтобы MOVE_NOTE не ходил в базу
+// на каждое сообщение. Отзыв прав начинает действовать не позже чем через ttl,
+// перенос заметки через этот сервис - сразу
+type moveGrants struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	size  int
+	items map[uuid.UUID]map[uuid.UUID]moveGrant // noteId -> userId -> право
+}
+
+func newMoveGrants(ttl time.Duration) *moveGrants {
+	return &moveGrants{
+		ttl:   ttl,
+		items: map[uuid.UUID]map[uuid.UUID]moveGrant{},
+	}
+}
+
+func (g *moveGrants) get(userId, noteId uuid.UUID) (uuid.UUID, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	grant, ok := g.items[noteId][userId]
+	if !ok {
+		return uuid.Nil, false
+	}
+	if time.Now().After(grant.expiresAt) {
+		g.delete(noteId, userId)
+		return uuid.Nil, false
+	}
+	return grant.layoutId, true
+}
+
+func (g *moveGrants) put(userId, noteId, layoutId uuid.UUID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if g.size >= moveGrantsSweepSize {
+		for id, users := range g.items {
+			for user, grant := range users {
+				if now.After(grant.expiresAt) {
+					g.delete(id, user)
+				}
+			}
+		}
+	}
+	if g.items[noteId] == nil {
+		g.items[noteId] = map[uuid.UUID]moveGrant{}
+	}
+	if _, ok := g.items[noteId][userId]; !ok {
+		g.size++
+	}
+	g.items[noteId][userId] = moveGrant{
+		layoutId:  layoutId,
+		expiresAt: now.Add(g.ttl),
+	}
+}
+
+// forget сбрасывает права всех пользователей на заметку, следующий MOVE_NOTE перечитает лейаут
+func (g *moveGrants) forget(noteId uuid.UUID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.size -= len(g.items[noteId])
+	delete(g.items, noteId)
+}
+
+func (g *moveGrants) delete(noteId, userId uuid.UUID) {
+	if _, ok := g.items[noteId][userId]; !ok {
+		return
+	}
+	delete(g.items[noteId], userId)
+	g.size--
+	if len(g.items[noteId]) == 0 {
+		delete(g.items, noteId)
+	}
+}
+
+// HandleSubscribeLayout подписывает соединение на живые изменения лейаута (NOTES_MOVED)
+func (srv *Service) HandleSubscribeLayout(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	var item dto.LayoutSubscription
+	if err := json.Unmarshal(msg.Payload, &item); err != nil {
+		err = apperror.NewBadRequestError(err.Error(), constants.BindBodyError)
+		return dto.NewSocketStatusMessage(dto.SubscribeLayoutResponseEvent, err), err
+	}
+
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, item.LayoutId, userId, true, false, false); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("HandleSubscribeLayout checkPerms: %s", err.Error())
+		return dto.NewSocketStatusMessage(dto.SubscribeLayoutResponseEvent, err), err
+	}
+
+	connId, ok := socket.ConnectionIDFromContext(ctx)
+	if !ok {
+		err := errors.New("no connection id in context")
+		return dto.NewSocketStatusMessage(dto.SubscribeLayoutResponseEvent, err), err
+	}
+	err := srv.socketHub.Subscribe(connId, socket.LayoutTopic(item.LayoutId))
+	return dto.NewSocketStatusMessage(dto.SubscribeLayoutResponseEvent, err), err
+}
+
+func (srv *Service) HandleUnsubscribeLayout(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	var item dto.LayoutSubscription
+	if err := json.Unmarshal(msg.Payload, &item); err != nil {
+		err = apperror.NewBadRequestError(err.Error(), constants.BindBodyError)
+		return dto.NewSocketStatusMessage(dto.UnsubscribeLayoutResponseEvent, err), err
+	}
+
+	if connId, ok := socket.ConnectionIDFromContext(ctx); ok {
+		srv.socketHub.Unsubscribe(connId, socket.LayoutTopic(item.LayoutId))
+	}
+	return dto.NewSocketStatusMessage(dto.UnsubscribeLayoutResponseEvent, nil), nil
+}
+
+// HandleMoveNote принимает позицию во время перетаскивания. Успех не подтверждается,
+// ответ MOVE_NOTE_RESPONSE приходит только при ошибке
+func (srv *Service) HandleMoveNote(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	var item dto.MoveNote
+	if err := json.Unmarshal(msg.Payload, &item); err != nil {
+		err = apperror.NewBadRequestError(err.Error(), constants.BindBodyError)
+		return dto.NewSocketStatusMessage(dto.MoveNoteResponseEvent, err), err
+	}
+
+	layoutId, ok := srv.moveGrants.get(userId, item.NoteId)
+	if !ok {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, item.NoteId, userId, true, true, false); err != nil {
+			srv.logger.WithCtx(ctx).Warnf("HandleMoveNote checkPerms: %s", err.Error())
+			return dto.NewSocketStatusMessage(dto.MoveNoteResponseEvent, err), err
+		}
+		note, err := srv.noteRepository.GetById(ctx, item.NoteId)
+		if err != nil {
+			return dto.NewSocketStatusMessage(dto.MoveNoteResponseEvent, err), err
+		}
+		layoutId = note.LayoutId
+		srv.moveGrants.put(userId, item.NoteId, layoutId)
+	}
+
+	srv.movementService.Move(layoutId, dto.NoteMove{
+		NoteId: item.NoteId,
+		UserId: userId,
+		XPos:   item.XPos,
+		YPos:   item.YPos,
+	})
+	return nil, nil
+}
@@ -2,34 +2,67 @@ package note
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
 	"wn/internal/domain/dto"
 	req "wn/internal/domain/dto/request"
+	"wn/internal/domain/enum"
+	"wn/internal/domain/services/socket"
 	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
 	"wn/pkg/applogger"
+	"wn/pkg/constants"
 	"wn/pkg/trx"
+	"wn/pkg/util"
 
 	"github.com/google/uuid"
 )
 
 type noteService interface {
 	DeleteNoteById(ctx context.Context, noteId uuid.UUID) error
-	CreateNote(ctx context.Context, title, payload string, ownerId, layoutId, mainLayoutId uuid.UUID) (uuid.UUID, error)
-	UpdateNote(ctx context.Context, title, payload string, noteId uuid.UUID) error
-	GetNotesWithPagination(ctx context.Context, page int, layoutId, userId uuid.UUID) ([]dto.Note, int, error)
+	CreateNote(ctx context.Context, title, payload string, ownerId, layoutId, mainLayoutId uuid.UUID) (uuid.UUID, []string, error)
+	UpdateNote(ctx context.Context, noteId, userId uuid.UUID, title, payload *string, version *int64) (int64, []string, error)
+	GetNotesWithPagination(ctx context.Context, page int, layoutId, userId uuid.UUID, filter *dto.TagFilter) ([]dto.Note, int, error)
 	GetNotesWithPosition(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]dto.Note, error)
 	GetNotesWithoutPosition(ctx context.Context, layoutId, userId uuid.UUID) ([]dto.Note, error)
 	UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error
 	CreateLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error
 	DeleteLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error
-	SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]dto.Note, error)
+	SearchNotes(ctx context.Context, userId uuid.UUID, params *dto.NoteSearch) (*dto.SearchNotesResponse, error)
 	GenerateCluster(notes []dto.Note) []dto.Note
-	DragNote(ctx context.Context, noteId, toLayout uuid.UUID) error
+	DragNote(ctx context.Context, noteId, toLayout uuid.UUID, version *int64) (int64, error)
+	DuplicateNotes(ctx context.Context, noteIds []uuid.UUID, userId, layoutId uuid.UUID, offset dto.Position) (map[uuid.UUID]uuid.UUID, error)
+	GetTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) (*dto.TasksResponse, error)
+	ToggleTask(ctx context.Context, noteId, userId uuid.UUID, line int, version *int64) (int64, bool, error)
+	UpdateDraft(ctx context.Context, noteId uuid.UUID, draft string, version *int64) (int64, error)
+	CommitDraft(ctx context.Context, noteId, userId uuid.UUID, version *int64) (int64, []string, error)
+	GetBacklinks(ctx context.Context, noteId, userId uuid.UUID) ([]dto.Backlink, error)
 }
 
 type layoutRepository interface {
 	GetAvailableLayouts(ctx context.Context, userId uuid.UUID) ([]entity.Layout, error)
 }
 
+type noteRepository interface {
+	GetById(ctx context.Context, noteId uuid.UUID) (*entity.Note, error)
+}
+
+type socketHub interface {
+	Subscribe(connID socket.ConnectionID, topic string) error
+	Unsubscribe(connID socket.ConnectionID, topic string)
+}
+
+type movementService interface {
+	Move(layoutId uuid.UUID, move dto.NoteMove)
+}
+
+type templateService interface {
+	RenderTemplate(ctx context.Context, templateId, userId, layoutId uuid.UUID, values map[string]string, now time.Time) (string, string, error)
+}
+
 type permissionsService interface {
 	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
 	CheckPermissionByNoteId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
@@ -42,6 +75,12 @@ type Service struct {
 	noteService        noteService
 	permissionsService permissionsService
 	layoutRepository   layoutRepository
+	noteRepository     noteRepository
+	socketHub          socketHub
+	movementService    movementService
+	templateService    templateService
+
+	moveGrants *moveGrants
 }
 
 func NewService(
@@ -50,6 +89,11 @@ func NewService(
 	noteService noteService,
 	permissionsService permissionsService,
 	layoutRepository layoutRepository,
+	noteRepository noteRepository,
+	socketHub socketHub,
+	movementService movementService,
+	templateService templateService,
+	moveGrantTTL time.Duration,
 ) *Service {
 	return &Service{
 		tx:                 tx,
@@ -57,24 +101,47 @@ func NewService(
 		noteService:        noteService,
 		permissionsService: permissionsService,
 		layoutRepository:   layoutRepository,
+		noteRepository:     noteRepository,
+		socketHub:          socketHub,
+		movementService:    movementService,
+		templateService:    templateService,
+		moveGrants:         newMoveGrants(moveGrantTTL),
 	}
 }
 
-func (srv *Service) CreateNote(ctx context.Context, req req.NoteRequest, userId uuid.UUID, mainLayoutId uuid.UUID) (uuid.UUID, error) {
+func (srv *Service) CreateNote(ctx context.Context, req req.NoteRequest, userId uuid.UUID, mainLayoutId uuid.UUID) (uuid.UUID, []string, error) {
 	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, req.LayoutId, userId, true, true, false); err != nil {
 		srv.logger.Warnf("CreateNote checkPerms: %s", err.Error())
-		return uuid.Nil, err
+		return uuid.Nil, nil, err
 	}
-	return srv.noteService.CreateNote(ctx, req.Title, req.Payload, userId, req.LayoutId, mainLayoutId)
+	title, payload := req.Title, req.Payload
+	if req.TemplateId != nil {
+		now, err := util.GetCurrentLocalTime(req.Timezone)
+		if err != nil {
+			return uuid.Nil, nil, apperrors.BadTimezone
+		}
+		// явно переданные title и payload важнее шаблона
+		renderedTitle, renderedPayload, err := srv.templateService.RenderTemplate(ctx, *req.TemplateId, userId, req.LayoutId, req.TemplateValues, now)
+		if err != nil {
+			return uuid.Nil, nil, err
+		}
+		if title == "" {
+			title = renderedTitle
+		}
+		if payload == "" {
+			payload = renderedPayload
+		}
+	}
+	return srv.noteService.CreateNote(ctx, title, payload, userId, req.LayoutId, mainLayoutId)
 }
 
-func (srv *Service) UpdateNote(ctx context.Context, req req.NoteWithIdRequest, userId uuid.UUID) error {
+func (srv *Service) UpdateNote(ctx context.Context, req req.NoteWithIdRequest, userId uuid.UUID) (int64, []string, error) {
 	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
 		srv.logger.Warnf("UpdateNote checkPerms: %s", err.Error())
-		return err
+		return 0, nil, err
 	}
 
-	return srv.noteService.UpdateNote(ctx, req.Title, req.Payload, req.NoteId)
+	return srv.noteService.UpdateNote(ctx, req.NoteId, userId, req.Title, req.Payload, req.Version)
 }
 
 func (srv *Service) DeleteNote(ctx context.Context, req req.NoteId, userId, mainLayoutId uuid.UUID) error {
@@ -91,7 +158,11 @@ func (srv *Service) GetNotesFromLayout(ctx context.Context, req req.GetNotesFrom
 		srv.logger.Warnf("GetNotesFromLayout checkPerms: %s", err.Error())
 		return nil, 0, err
 	}
-	return srv.noteService.GetNotesWithPagination(ctx, req.Page, req.LayoutId, userId)
+	filter, err := tagFilter(userId, req.TagsFilter)
+	if err != nil {
+		return nil, 0, err
+	}
+	return srv.noteService.GetNotesWithPagination(ctx, req.Page, req.LayoutId, userId, filter)
 }
 
 func (srv *Service) GetNotesWithPosition(ctx context.Context, userId, mainLayoutId uuid.UUID, req req.GetNotesFromLayoutWithoutPagRequest) ([]dto.Note, error) {
@@ -108,7 +179,7 @@ func (srv *Service) GetNotesWithPosition(ctx context.Context, userId, mainLayout
 		if err != nil {
 			return nil, err
 		}
-		notes, err = srv.noteService.GetNotesWithPosition(ctx, mainLayoutId, layoutIds)
+		notes, err = srv.noteService.GetNotesWithPosition(ctx, userId, layoutIds)
 	} else {
 		notes, err = srv.noteService.GetNotesWithPosition(ctx, userId, []uuid.UUID{req.LayoutId})
 	}
@@ -136,7 +207,11 @@ func (srv *Service) UpdateNotePosition(ctx context.Context, userId uuid.UUID, re
 		srv.logger.Warnf("GetNotesFromLayout checkPerms: %s", err.Error())
 		return err
 	}
-	return srv.noteService.UpdateNotePosition(ctx, req.NoteId, req.XPos, req.YPos)
+	if err := srv.noteService.UpdateNotePosition(ctx, req.NoteId, req.XPos, req.YPos); err != nil {
+		return err
+	}
+	srv.moveGrants.forget(req.NoteId)
+	return nil
 }
 
 func (srv *Service) CreateLink(ctx context.Context, userId uuid.UUID, req req.LinkBetweenNotesRequest) error {
@@ -155,21 +230,182 @@ func (srv *Service) DeleteLink(ctx context.Context, userId uuid.UUID, req req.Li
 	return srv.noteService.DeleteLink(ctx, req.FirstNoteId, req.SecondNoteId)
 }
 
-func (srv *Service) DragNote(ctx context.Context, userId uuid.UUID, req req.DragNoteRequest) error {
+func (srv *Service) DragNote(ctx context.Context, userId uuid.UUID, req req.DragNoteRequest) (int64, error) {
 	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
 		srv.logger.Warnf("CheckPermissionByNoteId: %s", err.Error())
-		return err
+		return 0, err
 	}
 	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, req.ToLayoutId, userId, true, true, false); err != nil {
 		srv.logger.Warnf("CheckPermissionByLayoutId: %s", err.Error())
-		return err
+		return 0, err
+	}
+
+	version, err := srv.noteService.DragNote(ctx, req.NoteId, req.ToLayoutId, req.Version)
+	if err != nil {
+		return 0, err
+	}
+	// иначе MOVE_NOTE продолжит рассылать позиции подписчикам старого лейаута
+	srv.moveGrants.forget(req.NoteId)
+	return version, nil
+}
+
+// duplicateOffset сдвиг копий в том же лейауте по умолчанию, чтобы они не закрывали оригиналы
+const duplicateOffset = 40
+
+// DuplicateNotes оригиналы нужно уметь читать, а писать - в лейаут, куда попадут копии
+func (srv *Service) DuplicateNotes(ctx context.Context, userId uuid.UUID, req req.DuplicateNotesRequest) (map[uuid.UUID]uuid.UUID, error) {
+	noteIds := util.UniqueUUIDs(req.NoteIds)
+	for _, noteId := range noteIds {
+		if err := srv.permissionsService.CheckPermissionByNoteId(ctx, noteId, userId, true, req.LayoutId == nil, false); err != nil {
+			srv.logger.Warnf("DuplicateNotes checkPerms: %s", err.Error())
+			return nil, err
+		}
+	}
+
+	layoutId := uuid.Nil
+	offset := dto.Position{XPos: duplicateOffset, YPos: duplicateOffset}
+	if req.LayoutId != nil {
+		if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, *req.LayoutId, userId, true, true, false); err != nil {
+			srv.logger.Warnf("DuplicateNotes checkPerms: %s", err.Error())
+			return nil, err
+		}
+		layoutId = *req.LayoutId
+		offset = dto.Position{}
+	}
+	if req.XOffset != nil {
+		offset.XPos = *req.XOffset
+	}
+	if req.YOffset != nil {
+		offset.YPos = *req.YOffset
+	}
+	return srv.noteService.DuplicateNotes(ctx, noteIds, userId, layoutId, offset)
+}
+
+// GetTasks задачи из всех заметок, которые пользователь может читать
+func (srv *Service) GetTasks(ctx context.Context, userId uuid.UUID, req req.TasksRequest) (*dto.TasksResponse, error) {
+	params := dto.TaskFilter{
+		LayoutIds: req.LayoutIds,
+		NoteId:    req.NoteId,
+		Assignee:  strings.ToLower(strings.TrimPrefix(strings.TrimSpace(req.Assignee), "@")),
+		Limit:     constants.PageSize,
+	}
+	switch req.Status {
+	case "", "open":
+		done := false
+		params.Done = &done
+	case "done":
+		done := true
+		params.Done = &done
+	case "all":
+	default:
+		return nil, apperrors.BadTaskStatus
+	}
+	for _, due := range []struct {
+		value string
+		dst   **time.Time
+	}{{req.DueFrom, &params.DueFrom}, {req.DueTo, &params.DueTo}} {
+		if due.value == "" {
+			continue
+		}
+		t, err := time.Parse(dto.DayFormat, due.value)
+		if err != nil {
+			return nil, apperrors.BadDate
+		}
+		*due.dst = &t
+	}
+	if req.Limit > 0 {
+		params.Limit = uint64(min(req.Limit, constants.TasksMaxPageSize))
+	}
+	if req.Offset > 0 {
+		params.Offset = uint64(req.Offset)
+	}
+	return srv.noteService.GetTasks(ctx, userId, &params)
+}
+
+func (srv *Service) ToggleTask(ctx context.Context, userId uuid.UUID, req req.ToggleTaskRequest) (*dto.TaskToggled, error) {
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, true, false); err != nil {
+		srv.logger.Warnf("ToggleTask checkPerms: %s", err.Error())
+		return nil, err
+	}
+	version, done, err := srv.noteService.ToggleTask(ctx, req.NoteId, userId, req.Line, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TaskToggled{Version: version, Done: done}, nil
+}
+
+func (srv *Service) SearchNotes(ctx context.Context, userId uuid.UUID, req req.SearchNotesRequest) (*dto.SearchNotesResponse, error) {
+	filter, err := tagFilter(userId, req.TagsFilter)
+	if err != nil {
+		return nil, err
+	}
+	sort := enum.NoteSortFromString(req.Sort)
+	if sort == enum.NoteSortUnspecified {
+		return nil, apperrors.BadSort
+	}
+	after, err := searchCursor(req.Cursor, sort)
+	if err != nil {
+		return nil, err
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = constants.PageSize
+	}
+	return srv.noteService.SearchNotes(ctx, userId, &dto.NoteSearch{
+		Search:      req.Search,
+		LayoutIds:   req.LayoutIds,
+		OwnerId:     req.OwnerId,
+		CreatedFrom: req.CreatedFrom,
+		CreatedTo:   req.CreatedTo,
+		UpdatedFrom: req.UpdatedFrom,
+		UpdatedTo:   req.UpdatedTo,
+		HasDraft:    req.HasDraft,
+		LinkedTo:    req.LinkedTo,
+		Tags:        filter,
+		Sort:        sort,
+		After:       after,
+		Limit:       uint64(min(limit, constants.SearchMaxPageSize)),
+	})
+}
+
+// searchCursor курсор от прошлой страницы должен быть выдан для той же сортировки
+func searchCursor(s string, sort enum.NoteSort) (*dto.SearchCursor, error) {
+	cursor, err := dto.DecodeSearchCursor(s)
+	if err != nil {
+		return nil, apperrors.BadCursor
+	}
+	if cursor == nil {
+		return nil, nil
+	}
+	if cursor.Sort != sort || cursor.Id == uuid.Nil {
+		return nil, apperrors.BadCursor
+	}
+	if sort == enum.NoteSortRelevance {
+		if _, err := strconv.ParseFloat(cursor.Rank, 64); err != nil {
+			return nil, apperrors.BadCursor
+		}
 	}
+	return cursor, nil
+}
 
-	return srv.noteService.DragNote(ctx, req.NoteId, req.ToLayoutId)
+func (srv *Service) GetBacklinks(ctx context.Context, userId, noteId uuid.UUID) ([]dto.Backlink, error) {
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, noteId, userId, true, false, false); err != nil {
+		srv.logger.Warnf("GetBacklinks checkPerms: %s", err.Error())
+		return nil, err
+	}
+	return srv.noteService.GetBacklinks(ctx, noteId, userId)
 }
 
-func (srv *Service) SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]dto.Note, error) {
-	return srv.noteService.SearchNotes(ctx, userId, search)
+// tagFilter по умолчанию заметка должна иметь все перечисленные метки
+func tagFilter(userId uuid.UUID, f req.TagsFilter) (*dto.TagFilter, error) {
+	switch f.TagMode {
+	case "", "and":
+		return dto.NewTagFilter(userId, f.Tags, true), nil
+	case "or":
+		return dto.NewTagFilter(userId, f.Tags, false), nil
+	default:
+		return nil, apperrors.BadTagMode
+	}
 }
 
 func (srv *Service) getLayoutIds(ctx context.Context, userId uuid.UUID) ([]uuid.UUID, error) {
@@ -186,3 +422,35 @@ func (srv *Service) getLayoutIds(ctx context.Context, userId uuid.UUID) ([]uuid.
 	}
 	return layoutIds, nil
 }
+
+func (srv *Service) HandleUpdateDraft(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	var item dto.DraftNote
+	if err := json.Unmarshal(msg.Payload, &item); err != nil {
+		err = apperror.NewBadRequestError(err.Error(), constants.BindBodyError)
+		return dto.NewDraftStatusMessage(0, err), err
+	}
+
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, item.NoteId, userId, true, true, false); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("HandleUpdateDraft checkPerms: %s", err.Error())
+		return dto.NewDraftStatusMessage(0, err), err
+	}
+
+	version, err := srv.noteService.UpdateDraft(ctx, item.NoteId, item.NewDraft, item.Version)
+	return dto.NewDraftStatusMessage(version, err), err
+}
+
+func (srv *Service) HandleCommitDraft(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
+	var item dto.CommitDraftNote
+	if err := json.Unmarshal(msg.Payload, &item); err != nil {
+		err = apperror.NewBadRequestError(err.Error(), constants.BindBodyError)
+		return dto.NewCommitDraftStatusMessage(0, nil, err), err
+	}
+
+	if err := srv.permissionsService.CheckPermissionByNoteId(ctx, item.NoteId, userId, true, true, false); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("HandleCommitDraft checkPerms: %s", err.Error())
+		return dto.NewCommitDraftStatusMessage(0, nil, err), err
+	}
+
+	version, unresolved, err := srv.noteService.CommitDraft(ctx, item.NoteId, userId, item.Version)
+	return dto.NewCommitDraftStatusMessage(version, unresolved, err), err
+}
@@ -0,0 +1,243 @@
+package note_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+	"wn/internal/application/note"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/socket"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore одна заметка, которую может писать только writer, и журнал вызовов сервисов
+type memoryStore struct {
+	note   entity.Note
+	writer uuid.UUID
+	fail   error
+
+	drafts     []string
+	committers []uuid.UUID
+	moves      []dto.NoteMove
+	topics     []string
+}
+
+func (s *memoryStore) CheckPermissionByLayoutId(_ context.Context, layoutId, userId uuid.UUID, _, _, _ bool) error {
+	if layoutId != s.note.LayoutId || userId != s.writer {
+		return apperrors.PermissionsNotEnough
+	}
+	return nil
+}
+
+func (s *memoryStore) CheckPermissionByNoteId(_ context.Context, noteId, userId uuid.UUID, _, _, _ bool) error {
+	if noteId != s.note.Id || userId != s.writer {
+		return apperrors.PermissionsNotEnough
+	}
+	return nil
+}
+
+func (s *memoryStore) UpdateDraft(_ context.Context, _ uuid.UUID, draft string, _ *int64) (int64, error) {
+	if s.fail != nil {
+		return 0, s.fail
+	}
+	s.drafts = append(s.drafts, draft)
+	return int64(len(s.drafts)), nil
+}
+
+func (s *memoryStore) CommitDraft(_ context.Context, _, userId uuid.UUID, _ *int64) (int64, []string, error) {
+	if s.fail != nil {
+		return 0, nil, s.fail
+	}
+	s.committers = append(s.committers, userId)
+	return int64(len(s.drafts) + len(s.committers)), []string{"Missing"}, nil
+}
+
+func (s *memoryStore) GetById(_ context.Context, noteId uuid.UUID) (*entity.Note, error) {
+	if noteId != s.note.Id {
+		return nil, apperrors.RecordNotFound
+	}
+	n := s.note
+	return &n, nil
+}
+
+func (s *memoryStore) Move(_ uuid.UUID, move dto.NoteMove) {
+	s.moves = append(s.moves, move)
+}
+
+func (s *memoryStore) Subscribe(_ socket.ConnectionID, topic string) error {
+	s.topics = append(s.topics, topic)
+	return nil
+}
+
+func (s *memoryStore) Unsubscribe(socket.ConnectionID, string) {}
+
+func (s *memoryStore) DeleteNoteById(context.Context, uuid.UUID) error { return nil }
+
+func (s *memoryStore) CreateNote(context.Context, string, string, uuid.UUID, uuid.UUID, uuid.UUID) (uuid.UUID, []string, error) {
+	return uuid.Nil, nil, nil
+}
+
+func (s *memoryStore) UpdateNote(context.Context, uuid.UUID, uuid.UUID, *string, *string, *int64) (int64, []string, error) {
+	return 0, nil, nil
+}
+
+func (s *memoryStore) GetNotesWithPagination(context.Context, int, uuid.UUID, uuid.UUID, *dto.TagFilter) ([]dto.Note, int, error) {
+	return nil, 0, nil
+}
+
+func (s *memoryStore) GetNotesWithPosition(context.Context, uuid.UUID, []uuid.UUID) ([]dto.Note, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GetNotesWithoutPosition(context.Context, uuid.UUID, uuid.UUID) ([]dto.Note, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) UpdateNotePosition(context.Context, uuid.UUID, *float64, *float64) error {
+	return nil
+}
+
+func (s *memoryStore) CreateLink(context.Context, uuid.UUID, uuid.UUID) error { return nil }
+
+func (s *memoryStore) DeleteLink(context.Context, uuid.UUID, uuid.UUID) error { return nil }
+
+func (s *memoryStore) SearchNotes(context.Context, uuid.UUID, *dto.NoteSearch) (*dto.SearchNotesResponse, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GenerateCluster(notes []dto.Note) []dto.Note { return notes }
+
+func (s *memoryStore) DragNote(context.Context, uuid.UUID, uuid.UUID, *int64) (int64, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) DuplicateNotes(context.Context, []uuid.UUID, uuid.UUID, uuid.UUID, dto.Position) (map[uuid.UUID]uuid.UUID, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GetTasks(context.Context, uuid.UUID, *dto.TaskFilter) (*dto.TasksResponse, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) ToggleTask(context.Context, uuid.UUID, uuid.UUID, int, *int64) (int64, bool, error) {
+	return 0, false, nil
+}
+
+func (s *memoryStore) GetBacklinks(context.Context, uuid.UUID, uuid.UUID) ([]dto.Backlink, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GetAvailableLayouts(context.Context, uuid.UUID) ([]entity.Layout, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) RenderTemplate(context.Context, uuid.UUID, uuid.UUID, uuid.UUID, map[string]string, time.Time) (string, string, error) {
+	return "", "", nil
+}
+
+func newService(t *testing.T, store *memoryStore) *note.Service {
+	lgr := testutil.Logger(t)
+	return note.NewService(testutil.NoTx{}, lgr, store, store, store, store, store, store, store, time.Minute)
+}
+
+func socketMessage(t *testing.T, event string, payload any) *dto.SocketMessage {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	return &dto.SocketMessage{Event: event, Payload: data}
+}
+
+func status(t *testing.T, msg *dto.SocketMessage) dto.CommitDraftStatus {
+	t.Helper()
+	var s dto.CommitDraftStatus
+	if msg == nil {
+		t.Fatalf("no response")
+	}
+	if err := json.Unmarshal(msg.Payload, &s); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	return s
+}
+
+func TestDraftPermissions(t *testing.T) {
+	ctx := context.Background()
+	writer, stranger := uuid.New(), uuid.New()
+	store := &memoryStore{note: entity.Note{Id: uuid.New(), LayoutId: uuid.New()}, writer: writer}
+	srv := newService(t, store)
+	update := socketMessage(t, dto.UpdateDraftRequestEvent, dto.DraftNote{NoteId: store.note.Id, NewDraft: "draft"})
+	commit := socketMessage(t, dto.CommitDraftRequestEvent, dto.CommitDraftNote{NoteId: store.note.Id})
+
+	// чужой черновик не пишется и не коммитится
+	resp, err := srv.HandleUpdateDraft(ctx, update, stranger)
+	if s := status(t, resp); err == nil || s.Status != "false" || s.Code != apperrors.PermissionsNotEnough.Code {
+		t.Fatalf("HandleUpdateDraft() by stranger = %+v, %v", s, err)
+	}
+	resp, err = srv.HandleCommitDraft(ctx, commit, stranger)
+	if s := status(t, resp); err == nil || s.Status != "false" || s.Code != apperrors.PermissionsNotEnough.Code {
+		t.Fatalf("HandleCommitDraft() by stranger = %+v, %v", s, err)
+	}
+	if len(store.drafts) != 0 || len(store.committers) != 0 {
+		t.Fatalf("stranger wrote drafts = %v, commits = %v", store.drafts, store.committers)
+	}
+
+	resp, err = srv.HandleUpdateDraft(ctx, update, writer)
+	if s := status(t, resp); err != nil || s.Status != "true" || s.Version != 1 || len(store.drafts) != 1 || store.drafts[0] != "draft" {
+		t.Fatalf("HandleUpdateDraft() = %+v, %v, drafts = %v", s, err, store.drafts)
+	}
+	resp, err = srv.HandleCommitDraft(ctx, commit, writer)
+	s := status(t, resp)
+	if err != nil || s.Status != "true" || s.Version != 2 || len(s.UnresolvedLinks) != 1 ||
+		len(store.committers) != 1 || store.committers[0] != writer {
+		t.Fatalf("HandleCommitDraft() = %+v, %v, committers = %v", s, err, store.committers)
+	}
+
+	// ошибка хранилища доходит до клиента
+	store.fail = errors.New("connection reset")
+	resp, err = srv.HandleUpdateDraft(ctx, update, writer)
+	if s := status(t, resp); err == nil || s.Status != "false" {
+		t.Fatalf("HandleUpdateDraft() with failing store = %+v, %v", s, err)
+	}
+	resp, err = srv.HandleCommitDraft(ctx, commit, writer)
+	if s := status(t, resp); err == nil || s.Status != "false" {
+		t.Fatalf("HandleCommitDraft() with failing store = %+v, %v", s, err)
+	}
+
+	resp, err = srv.HandleUpdateDraft(ctx, &dto.SocketMessage{Event: dto.UpdateDraftRequestEvent, Payload: []byte(`{`)}, writer)
+	if s := status(t, resp); err == nil || s.Status != "false" || s.Code == "" {
+		t.Fatalf("HandleUpdateDraft() with bad payload = %+v, %v", s, err)
+	}
+}
+
+func TestLivePermissions(t *testing.T) {
+	ctx := context.Background()
+	writer, stranger := uuid.New(), uuid.New()
+	store := &memoryStore{note: entity.Note{Id: uuid.New(), LayoutId: uuid.New()}, writer: writer}
+	srv := newService(t, store)
+
+	subscribe := socketMessage(t, dto.SubscribeLayoutRequestEvent, dto.LayoutSubscription{LayoutId: store.note.LayoutId})
+	resp, err := srv.HandleSubscribeLayout(ctx, subscribe, stranger)
+	if s := status(t, resp); err == nil || s.Code != apperrors.PermissionsNotEnough.Code || len(store.topics) != 0 {
+		t.Fatalf("HandleSubscribeLayout() by stranger = %+v, %v, topics = %v", s, err, store.topics)
+	}
+
+	move := socketMessage(t, dto.MoveNoteEvent, dto.MoveNote{NoteId: store.note.Id, XPos: 1, YPos: 2})
+	resp, err = srv.HandleMoveNote(ctx, move, stranger)
+	if s := status(t, resp); err == nil || s.Code != apperrors.PermissionsNotEnough.Code || len(store.moves) != 0 {
+		t.Fatalf("HandleMoveNote() by stranger = %+v, %v, moves = %v", s, err, store.moves)
+	}
+	// успешное перемещение не подтверждается
+	if resp, err := srv.HandleMoveNote(ctx, move, writer); resp != nil || err != nil {
+		t.Fatalf("HandleMoveNote() = %+v, %v", resp, err)
+	}
+	if len(store.moves) != 1 || store.moves[0].UserId != writer || store.moves[0].XPos != 1 {
+		t.Fatalf("moves = %+v", store.moves)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
 	"wn/internal/entity"
 	apperrors "wn/internal/errors"
 	"wn/pkg/apperror"
@@ -39,6 +40,10 @@ type layoutRepository interface {
 	GetByOwnerId(ctx context.Context, ownerId, layoutId uuid.UUID) (*entity.Layout, error)
 }
 
+type changesRepository interface {
+	RecordChange(ctx context.Context, item *entity.Change) error
+}
+
 type permissionsService interface {
 	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
 	ApplyUpdateRequest(req *dto.UpdatePermissionRequest, e *entity.Permission) *entity.Permission
@@ -54,6 +59,7 @@ type Application struct {
 	permissionsLinkRepository permissionsLinkRepository
 	layoutRepository          layoutRepository
 	noteRepository            noteRepository
+	changesRepository         changesRepository
 }
 
 func NewApplication(
@@ -64,6 +70,7 @@ func NewApplication(
 	permissionsLinkRepository permissionsLinkRepository,
 	layoutRepository layoutRepository,
 	noteRepository noteRepository,
+	changesRepository changesRepository,
 ) *Application {
 	return &Application{
 		tx:                        tx,
@@ -73,6 +80,7 @@ func NewApplication(
 		permissionsLinkRepository: permissionsLinkRepository,
 		layoutRepository:          layoutRepository,
 		noteRepository:            noteRepository,
+		changesRepository:         changesRepository,
 	}
 }
 
@@ -132,16 +140,26 @@ func (srv *Application) ApplyPermissionsLink(ctx context.Context, userId uuid.UU
 		return apperrors.AlreadyExist
 	}
 
-	return srv.permissionsRepository.CreatePermissions(ctx, &entity.Permission{
-		Id:         uuid.New(),
-		ToUserId:   userId,
-		FromUserId: perm.FromUserId,
-		TargetId:   perm.TargetId,
-		Kind:       perm.Kind,
-		CanRead:    perm.CanRead,
-		CanWrite:   perm.CanWrite,
-		CanEdit:    perm.CanEdit,
-		CreatedAt:  util.GetCurrentUTCTime(),
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		err := srv.permissionsRepository.CreatePermissions(ctx, &entity.Permission{
+			Id:         uuid.New(),
+			ToUserId:   userId,
+			FromUserId: perm.FromUserId,
+			TargetId:   perm.TargetId,
+			Kind:       perm.Kind,
+			CanRead:    perm.CanRead,
+			CanWrite:   perm.CanWrite,
+			CanEdit:    perm.CanEdit,
+			CreatedAt:  util.GetCurrentUTCTime(),
+		})
+		if err != nil {
+			return err
+		}
+		if perm.Kind != enum.PermissionsKindLayout {
+			return nil
+		}
+		// новый участник узнает о лейауте из ленты изменений
+		return srv.changesRepository.RecordChange(ctx, entity.NewChange(enum.SyncEntityKindLayout, enum.SyncOperationUpsert, perm.TargetId, perm.TargetId))
 	})
 }
 
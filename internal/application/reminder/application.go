@@ -0,0 +1,81 @@
+package reminder
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+)
+
+type reminderService interface {
+	CreateReminder(ctx context.Context, userId, noteId uuid.UUID, remindAt time.Time, rule string, now time.Time) (*dto.Reminder, error)
+	DeleteReminder(ctx context.Context, reminderId, userId uuid.UUID) error
+	GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]dto.Reminder, error)
+	GetFeedToken(ctx context.Context, userId uuid.UUID, reset bool) (string, error)
+	GetFeed(ctx context.Context, token string, now time.Time) ([]byte, error)
+}
+
+type permissionsService interface {
+	CheckPermissionByNoteId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type Application struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	reminderService    reminderService
+	permissionsService permissionsService
+}
+
+func NewApplication(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	reminderService reminderService,
+	permissionsService permissionsService,
+) *Application {
+	return &Application{
+		tx:                 tx,
+		logger:             logger,
+		reminderService:    reminderService,
+		permissionsService: permissionsService,
+	}
+}
+
+// CreateReminder напомнить можно о любой заметке, которую пользователь может читать
+func (app *Application) CreateReminder(ctx context.Context, userId uuid.UUID, req request.CreateReminderRequest) (*dto.Reminder, error) {
+	now, err := util.GetCurrentLocalTime(req.Timezone)
+	if err != nil {
+		return nil, apperrors.BadTimezone
+	}
+	if err := app.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, false, false); err != nil {
+		app.logger.Warnf("CreateReminder checkPerms: %s", err.Error())
+		return nil, err
+	}
+	return app.reminderService.CreateReminder(ctx, userId, req.NoteId, req.RemindAt, req.RRule, now)
+}
+
+func (app *Application) DeleteReminder(ctx context.Context, userId uuid.UUID, req request.ReminderIdRequest) error {
+	return app.reminderService.DeleteReminder(ctx, req.ReminderId, userId)
+}
+
+func (app *Application) GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]dto.Reminder, error) {
+	return app.reminderService.GetReminders(ctx, userId, noteId)
+}
+
+func (app *Application) GetFeedUrl(ctx context.Context, userId uuid.UUID, req request.ReminderFeedRequest, host string) (*dto.ReminderFeed, error) {
+	token, err := app.reminderService.GetFeedToken(ctx, userId, req.Reset)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.ReminderFeed{Url: dto.ReminderFeedUrl(host, token)}, nil
+}
+
+func (app *Application) GetFeed(ctx context.Context, token string) ([]byte, error) {
+	return app.reminderService.GetFeed(ctx, token, util.GetCurrentUTCTime())
+}
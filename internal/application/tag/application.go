@@ -0,0 +1,99 @@
+package tag
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/entity"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+
+	"github.com/google/uuid"
+)
+
+type tagService interface {
+	CreateTag(ctx context.Context, ownerId uuid.UUID, name, color string) (uuid.UUID, error)
+	UpdateTag(ctx context.Context, tagId, ownerId uuid.UUID, name, color *string) error
+	DeleteTag(ctx context.Context, tagId, ownerId uuid.UUID) error
+	GetTags(ctx context.Context, ownerId uuid.UUID) ([]entity.TagCount, error)
+	GetLayoutTagCounts(ctx context.Context, ownerId, layoutId uuid.UUID) ([]entity.TagCount, error)
+	AttachTag(ctx context.Context, noteId, tagId, ownerId uuid.UUID) error
+	DetachTag(ctx context.Context, noteId, tagId, ownerId uuid.UUID) error
+}
+
+type permissionsService interface {
+	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+	CheckPermissionByNoteId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type Application struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	tagService         tagService
+	permissionsService permissionsService
+}
+
+func NewApplication(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	tagService tagService,
+	permissionsService permissionsService,
+) *Application {
+	return &Application{
+		tx:                 tx,
+		logger:             logger,
+		tagService:         tagService,
+		permissionsService: permissionsService,
+	}
+}
+
+func (app *Application) GetTags(ctx context.Context, userId uuid.UUID) ([]dto.TagCount, error) {
+	items, err := app.tagService.GetTags(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return dto.TagsFromEntities(items), nil
+}
+
+func (app *Application) CreateTag(ctx context.Context, userId uuid.UUID, req request.NewTagRequest) (uuid.UUID, error) {
+	return app.tagService.CreateTag(ctx, userId, req.Name, req.Color)
+}
+
+func (app *Application) UpdateTag(ctx context.Context, userId uuid.UUID, req request.UpdateTagRequest) error {
+	return app.tagService.UpdateTag(ctx, req.TagId, userId, req.Name, req.Color)
+}
+
+func (app *Application) DeleteTag(ctx context.Context, userId uuid.UUID, req request.TagIdRequest) error {
+	return app.tagService.DeleteTag(ctx, req.TagId, userId)
+}
+
+// GetLayoutTags метки пользователя на заметках лейаута со счетчиками
+func (app *Application) GetLayoutTags(ctx context.Context, userId, layoutId uuid.UUID) ([]dto.TagCount, error) {
+	if err := app.permissionsService.CheckPermissionByLayoutId(ctx, layoutId, userId, true, false, false); err != nil {
+		app.logger.WithCtx(ctx).Warnf("GetLayoutTags checkPerms: %s", err.Error())
+		return nil, err
+	}
+	items, err := app.tagService.GetLayoutTagCounts(ctx, userId, layoutId)
+	if err != nil {
+		return nil, err
+	}
+	return dto.TagsFromEntities(items), nil
+}
+
+// AttachTag метки личные, поэтому отмечать можно любую заметку, которую пользователь может читать
+func (app *Application) AttachTag(ctx context.Context, userId uuid.UUID, req request.NoteTagRequest) error {
+	if err := app.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, false, false); err != nil {
+		app.logger.WithCtx(ctx).Warnf("AttachTag checkPerms: %s", err.Error())
+		return err
+	}
+	return app.tagService.AttachTag(ctx, req.NoteId, req.TagId, userId)
+}
+
+func (app *Application) DetachTag(ctx context.Context, userId uuid.UUID, req request.NoteTagRequest) error {
+	if err := app.permissionsService.CheckPermissionByNoteId(ctx, req.NoteId, userId, true, false, false); err != nil {
+		app.logger.WithCtx(ctx).Warnf("DetachTag checkPerms: %s", err.Error())
+		return err
+	}
+	return app.tagService.DetachTag(ctx, req.NoteId, req.TagId, userId)
+}
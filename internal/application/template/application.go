@@ -0,0 +1,86 @@
+package template
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	"wn/internal/entity"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+
+	"github.com/google/uuid"
+)
+
+type templateService interface {
+	CreateTemplate(ctx context.Context, ownerId uuid.UUID, layoutId *uuid.UUID, name, title, payload string) (uuid.UUID, error)
+	UpdateTemplate(ctx context.Context, templateId, userId uuid.UUID, name, title, payload *string) error
+	DeleteTemplate(ctx context.Context, templateId, userId uuid.UUID) error
+	GetTemplates(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]dto.Template, error)
+}
+
+type layoutRepository interface {
+	GetAvailableLayouts(ctx context.Context, userId uuid.UUID) ([]entity.Layout, error)
+}
+
+type permissionsService interface {
+	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type Application struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	templateService    templateService
+	layoutRepository   layoutRepository
+	permissionsService permissionsService
+}
+
+func NewApplication(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	templateService templateService,
+	layoutRepository layoutRepository,
+	permissionsService permissionsService,
+) *Application {
+	return &Application{
+		tx:                 tx,
+		logger:             logger,
+		templateService:    templateService,
+		layoutRepository:   layoutRepository,
+		permissionsService: permissionsService,
+	}
+}
+
+// GetTemplates библиотека: личные шаблоны и шаблоны лейаута layoutId,
+// без layoutId - шаблоны всех лейаутов, доступных пользователю
+func (app *Application) GetTemplates(ctx context.Context, userId uuid.UUID, layoutId *uuid.UUID) ([]dto.Template, error) {
+	var layoutIds []uuid.UUID
+	if layoutId != nil {
+		if err := app.permissionsService.CheckPermissionByLayoutId(ctx, *layoutId, userId, true, false, false); err != nil {
+			app.logger.WithCtx(ctx).Warnf("GetTemplates checkPerms: %s", err.Error())
+			return nil, err
+		}
+		layoutIds = []uuid.UUID{*layoutId}
+	} else {
+		layouts, err := app.layoutRepository.GetAvailableLayouts(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		for _, l := range layouts {
+			layoutIds = append(layoutIds, l.Id)
+		}
+	}
+	return app.templateService.GetTemplates(ctx, userId, layoutIds)
+}
+
+func (app *Application) CreateTemplate(ctx context.Context, userId uuid.UUID, req request.NewTemplateRequest) (uuid.UUID, error) {
+	return app.templateService.CreateTemplate(ctx, userId, req.LayoutId, req.Name, req.Title, req.Payload)
+}
+
+func (app *Application) UpdateTemplate(ctx context.Context, userId uuid.UUID, req request.UpdateTemplateRequest) error {
+	return app.templateService.UpdateTemplate(ctx, req.TemplateId, userId, req.Name, req.Title, req.Payload)
+}
+
+func (app *Application) DeleteTemplate(ctx context.Context, userId uuid.UUID, req request.TemplateIdRequest) error {
+	return app.templateService.DeleteTemplate(ctx, req.TemplateId, userId)
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"slices"
+	"strconv"
+
+	respDto "wn/internal/domain/dto/response"
+	apperrors "wn/internal/errors"
+	"wn/internal/infrastructure/repository/user"
+	"wn/pkg/constants"
+	"wn/pkg/identicon"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// generatedAvatar у пользователя нет своей аватарки
+func generatedAvatar(imgUrl string) bool {
+	return imgUrl == "" || imgUrl == constants.LegacyDefaultAvatar
+}
+
+// RemoveProfilePicture возвращает сгенерированную аватарку. Старая картинка удалится сборщиком мусора
+func (srv *Service) RemoveProfilePicture(ctx context.Context, userId uuid.UUID, host string) (*respDto.ChangePictureResponse, error) {
+	empty := ""
+	if err := srv.userService.UpdateUser(ctx, userId, &user.UserUpdateParams{ImgUrl: &empty}); err != nil {
+		return nil, err
+	}
+	imgUrl, variants := srv.generatedAvatarUrls(host, userId)
+	return &respDto.ChangePictureResponse{
+		NewImgurl: imgUrl,
+		Variants:  variants,
+	}, nil
+}
+
+// GeneratedAvatar png аватарка по умолчанию, одинаковая для одного пользователя.
+// size 0 - самый большой размер, другие размеры только из storage.thumbnailSizes
+func (srv *Service) GeneratedAvatar(userId uuid.UUID, size int) ([]byte, error) {
+	if size == 0 {
+		size = slices.Max(srv.avatarSizes)
+	}
+	if !slices.Contains(srv.avatarSizes, size) {
+		return nil, apperrors.FileNotFound
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, identicon.Render(userId[:], size)); err != nil {
+		return nil, errors.Wrap(err, "png.Encode")
+	}
+	return buf.Bytes(), nil
+}
+
+func (srv *Service) generatedAvatarUrls(host string, userId uuid.UUID) (string, map[string]string) {
+	base := host + constants.GeneratedAvatarsPath + userId.String()
+	variants := make(map[string]string, len(srv.avatarSizes))
+	for _, size := range srv.avatarSizes {
+		variants[strconv.Itoa(size)] = base + "_" + strconv.Itoa(size) + ".png"
+	}
+	return base + ".png", variants
+}
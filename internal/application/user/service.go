@@ -2,16 +2,22 @@ package auth
 
 import (
 	"context"
-	"mime/multipart"
+	"io"
 
+	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
 	respDto "wn/internal/domain/dto/response"
 	userDto "wn/internal/domain/dto/user"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
 	"wn/internal/infrastructure/repository/user"
 	"wn/pkg/applogger"
+	"wn/pkg/constants"
 	"wn/pkg/trx"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 type userService interface {
@@ -21,7 +27,10 @@ type userService interface {
 }
 
 type fileService interface {
-	NewFile(ctx context.Context, file *multipart.FileHeader) (string, error)
+	NewFile(ctx context.Context, purpose enum.FilePurpose, ownerId, noteId uuid.UUID, originalName string, r io.Reader) (*entity.File, error)
+	GetStorageUsage(ctx context.Context, userId uuid.UUID) (*dto.StorageUsage, error)
+	SetStorageQuota(ctx context.Context, userId uuid.UUID, quota *int64) error
+	GetFileWithVariants(ctx context.Context, name string) (*entity.File, error)
 }
 
 type layoutService interface {
@@ -35,6 +44,7 @@ type Service struct {
 	userService   userService
 	fileService   fileService
 	layoutService layoutService
+	avatarSizes   []int
 }
 
 func NewService(
@@ -43,6 +53,7 @@ func NewService(
 	userService userService,
 	fileService fileService,
 	layoutService layoutService,
+	avatarSizes []int,
 ) *Service {
 	return &Service{
 		tx:            tx,
@@ -50,6 +61,7 @@ func NewService(
 		userService:   userService,
 		fileService:   fileService,
 		layoutService: layoutService,
+		avatarSizes:   avatarSizes,
 	}
 }
 
@@ -77,15 +89,22 @@ func (srv *Service) RegisterUser(ctx context.Context, credentials request.Regist
 }
 
 func (srv *Service) ChangeProfilePicture(ctx context.Context, req request.ChangeProfilePicture, host string) (*respDto.ChangePictureResponse, error) {
-	filename, err := srv.fileService.NewFile(ctx, req.File)
+	f, err := req.File.Open()
+	if err != nil {
+		return nil, errors.Wrap(err, "req.File.Open")
+	}
+	defer f.Close()
+	item, err := srv.fileService.NewFile(ctx, enum.FilePurposeAvatar, req.UserId, uuid.Nil, req.File.Filename, f)
 	if err != nil {
 		return nil, err
 	}
+	filename := item.Name
 	err = srv.userService.UpdateUser(ctx, req.UserId, &user.UserUpdateParams{
 		ImgUrl: &filename,
 	})
 	return &respDto.ChangePictureResponse{
-		NewImgurl: host + "/statics/images/" + filename,
+		NewImgurl: dto.FileUrl(host, filename),
+		Variants:  dto.VariantUrls(host, item),
 	}, err
 }
 
@@ -94,6 +113,41 @@ func (srv *Service) GetUserById(ctx context.Context, userId uuid.UUID, host stri
 	if err != nil {
 		return nil, err
 	}
-	u.ImgUrl = host + "/statics/images/" + u.ImgUrl
+	if generatedAvatar(u.ImgUrl) {
+		u.DefaultAvatar = true
+		u.ImgUrl, u.Avatars = srv.generatedAvatarUrls(host, u.Id)
+		return u, nil
+	}
+	item, err := srv.fileService.GetFileWithVariants(ctx, u.ImgUrl)
+	if err != nil && !errors.Is(err, apperrors.FileNotFound) {
+		return nil, err
+	}
+	if item != nil {
+		u.Avatars = dto.VariantUrls(host, item)
+	}
+	u.ImgUrl = dto.FileUrl(host, u.ImgUrl)
 	return u, nil
 }
+
+func (srv *Service) GetStorageUsage(ctx context.Context, userId uuid.UUID) (*dto.StorageUsage, error) {
+	return srv.fileService.GetStorageUsage(ctx, userId)
+}
+
+// SetStorageQuota индивидуальный лимит пользователя, только для администраторов.
+// Роль берется из базы, а не из токена
+func (srv *Service) SetStorageQuota(ctx context.Context, adminId uuid.UUID, req request.SetStorageQuotaRequest) (*dto.StorageUsage, error) {
+	admin, err := srv.userService.GetUserById(ctx, adminId, "")
+	if err != nil {
+		return nil, err
+	}
+	if admin.Role != constants.AdminRole {
+		return nil, apperrors.AdminOnly
+	}
+	if _, err := srv.userService.GetUserById(ctx, req.UserId, ""); err != nil {
+		return nil, err
+	}
+	if err := srv.fileService.SetStorageQuota(ctx, req.UserId, req.Quota); err != nil {
+		return nil, err
+	}
+	return srv.fileService.GetStorageUsage(ctx, req.UserId)
+}
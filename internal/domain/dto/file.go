@@ -0,0 +1,119 @@
+package dto
+
+import (
+	"strconv"
+	"time"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+const staticsPath = "/statics/images/"
+
+func FileUrl(host, name string) string {
+	return host + staticsPath + name
+}
+
+// VariantUrls адреса миниатюр файла по размеру, nil если миниатюр нет
+func VariantUrls(host string, item *entity.File) map[string]string {
+	if len(item.Variants) == 0 {
+		return nil
+	}
+	urls := make(map[string]string, len(item.Variants))
+	for _, variant := range item.Variants {
+		urls[strconv.Itoa(variant.Variant)] = FileUrl(host, variant.Name)
+	}
+	return urls
+}
+
+type Attachment struct {
+	Name        string            `json:"name"`
+	Url         string            `json:"url"`
+	Variants    map[string]string `json:"variants,omitempty"`
+	ContentType string            `json:"contentType"`
+	Size        int64             `json:"size"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	// ScanState PENDING и SCANNING пока файл проверяется, INFECTED и FAILED в карантине
+	ScanState string `json:"scanState"`
+}
+
+// OwnFile файл пользователя и где он используется
+type OwnFile struct {
+	Name        string            `json:"name"`
+	Url         string            `json:"url"`
+	Variants    map[string]string `json:"variants,omitempty"`
+	ContentType string            `json:"contentType"`
+	Purpose     string            `json:"purpose"`
+	Size        int64             `json:"size"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	References  FileReferences    `json:"references"`
+	ScanState   string            `json:"scanState"`
+	// ScanSignature что нашел антивирус у файла в карантине
+	ScanSignature string `json:"scanSignature,omitempty"`
+}
+
+// FileReferences где используется файл. Заметки, к которым у пользователя нет доступа, только считаются в OtherNotes
+type FileReferences struct {
+	Avatar     bool        `json:"avatar"`
+	AttachedTo *uuid.UUID  `json:"attachedTo"`
+	Notes      []uuid.UUID `json:"notes"`
+	OtherNotes int         `json:"otherNotes"`
+}
+
+// DeleteFileResponse ссылки, которые остались без удаленного файла
+type DeleteFileResponse struct {
+	Name             string         `json:"name"`
+	BrokenReferences FileReferences `json:"brokenReferences"`
+}
+
+// GarbageReport что удалит сборка мусора. Due отмечает то, что удалится при следующем запуске
+type GarbageReport struct {
+	GracePeriod string        `json:"gracePeriod"`
+	Files       []GarbageFile `json:"files"`
+	Blobs       []GarbageBlob `json:"blobs"`
+	DueFiles    int           `json:"dueFiles"`
+	DueBlobs    int           `json:"dueBlobs"`
+	DueBytes    int64         `json:"dueBytes"`
+}
+
+type GarbageFile struct {
+	Name        string    `json:"name"`
+	OwnerId     uuid.UUID `json:"ownerId"`
+	Purpose     string    `json:"purpose"`
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt"`
+	DeleteAfter time.Time `json:"deleteAfter"`
+	Due         bool      `json:"due"`
+}
+
+type GarbageBlob struct {
+	StorageKey  string    `json:"storageKey"`
+	Size        int64     `json:"size"`
+	DeleteAfter time.Time `json:"deleteAfter"`
+	Due         bool      `json:"due"`
+}
+
+// StorageUsage занятое место в байтах. Quota и Available null, если квота не ограничена
+type StorageUsage struct {
+	Quota       *int64           `json:"quota"`
+	CustomQuota bool             `json:"customQuota"`
+	Used        int64            `json:"used"`
+	Available   *int64           `json:"available"`
+	Avatars     StorageUsagePart `json:"avatars"`
+	Attachments StorageUsagePart `json:"attachments"`
+	Exports     StorageUsagePart `json:"exports"`
+}
+
+type StorageUsagePart struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// UploadStatus состояние загрузки по частям, File заполнен, когда загрузка завершена
+type UploadStatus struct {
+	Id        uuid.UUID           `json:"id"`
+	Length    int64               `json:"length"`
+	Offset    int64               `json:"offset"`
+	ExpiresAt time.Time           `json:"expiresAt"`
+	File      *UploadFileResponse `json:"file,omitempty"`
+}
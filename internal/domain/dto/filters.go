@@ -1,6 +1,10 @@
 package dto
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
 	"wn/internal/domain/enum"
 
 	"github.com/google/uuid"
@@ -15,3 +19,108 @@ type GetPermissionsFilter struct {
 
 	Limit uint64
 }
+
+// UpdateNoteParams обновляются только заданные поля. Если задан Version,
+// обновление пройдет только при совпадении с версией в базе
+type UpdateNoteParams struct {
+	Title    *string
+	Payload  *string
+	LayoutId *uuid.UUID
+
+	Version *int64
+}
+
+type UpdateLayoutParams struct {
+	Title *string
+	Color *string
+
+	Version *int64
+}
+
+// TagFilter заметки с метками пользователя UserId: со всеми из Names (MatchAll) или хотя бы с одной
+type TagFilter struct {
+	UserId   uuid.UUID
+	Names    []string
+	MatchAll bool
+}
+
+// NewTagFilter имена приводятся к нижнему регистру, пустые и повторы отбрасываются. nil, если фильтровать нечего
+func NewTagFilter(userId uuid.UUID, names []string, matchAll bool) *TagFilter {
+	seen := make(map[string]bool, len(names))
+	filter := TagFilter{UserId: userId, MatchAll: matchAll}
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		filter.Names = append(filter.Names, name)
+	}
+	if len(filter.Names) == 0 {
+		return nil
+	}
+	return &filter
+}
+
+// NoteSearch параметры поиска заметок, пустые поля не фильтруют. Интервалы дат полуоткрытые: [From, To)
+type NoteSearch struct {
+	Search      string
+	LayoutIds   []uuid.UUID
+	OwnerId     *uuid.UUID
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	UpdatedFrom *time.Time
+	UpdatedTo   *time.Time
+	HasDraft    *bool
+	// LinkedTo заметки, связанные с данной в любую сторону
+	LinkedTo *uuid.UUID
+	Tags     *TagFilter
+
+	Sort  enum.NoteSort
+	After *SearchCursor
+	Limit uint64
+}
+
+// SearchCursor ключ сортировки последней отданной заметки, следующая страница начинается после него
+type SearchCursor struct {
+	Sort enum.NoteSort `json:"s"`
+	// Rank округленная релевантность, строкой, чтобы сравнение в базе было точным
+	Rank string    `json:"r,omitempty"`
+	Time time.Time `json:"t"`
+	Id   uuid.UUID `json:"id"`
+}
+
+func (c SearchCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeSearchCursor nil для пустой строки
+func DecodeSearchCursor(s string) (*SearchCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c SearchCursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// TaskFilter задачи из заметок, которые пользователь может читать. nil поля не фильтруют.
+// Done false - открытые задачи. DueFrom включительно, DueTo нет
+type TaskFilter struct {
+	LayoutIds []uuid.UUID
+	NoteId    *uuid.UUID
+	Done      *bool
+	DueFrom   *time.Time
+	DueTo     *time.Time
+	Assignee  string
+
+	Limit  uint64
+	Offset uint64
+}
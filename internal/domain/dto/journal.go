@@ -0,0 +1,44 @@
+package dto
+
+import (
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+// DayFormat формат дней журнала в запросах и ответах
+const DayFormat = "2006-01-02"
+
+// Journal настройки журнала. StartedOn - день, от которого записи раскладываются по графу слева направо
+type Journal struct {
+	LayoutId    uuid.UUID  `json:"layoutId"`
+	TitleFormat string     `json:"titleFormat"`
+	TemplateId  *uuid.UUID `json:"templateId,omitempty"`
+	StartedOn   string     `json:"startedOn"`
+}
+
+func JournalFromEntity(item *entity.Journal) Journal {
+	return Journal{
+		LayoutId:    item.LayoutId,
+		TitleFormat: item.TitleFormat,
+		TemplateId:  item.TemplateId,
+		StartedOn:   item.StartedOn.Format(DayFormat),
+	}
+}
+
+// JournalDay запись журнала за день. Created - заметка создана этим запросом
+type JournalDay struct {
+	Date    string `json:"date"`
+	Created bool   `json:"created"`
+	Note    Note   `json:"note"`
+}
+
+// JournalDate день месяца, за который есть запись
+type JournalDate struct {
+	Date   string    `json:"date"`
+	NoteId uuid.UUID `json:"noteId"`
+}
+
+type JournalCalendar struct {
+	Days []JournalDate `json:"days"`
+}
@@ -18,6 +18,9 @@ type Note struct {
 	LinkedWithOut []uuid.UUID `json:"linkedWithOut,omitempty"`
 	Draft         string      `json:"draft"`
 	LayoutId      uuid.UUID   `json:"layoutId"`
+	Version       int64       `json:"version"`
+	// Tags метки текущего пользователя
+	Tags []string `json:"tags,omitempty"`
 }
 
 func NotesFromEntities(entities []entity.Note, links []entity.Link) []Note {
@@ -34,6 +37,7 @@ func NotesFromEntities(entities []entity.Note, links []entity.Link) []Note {
 			LinkedWithIn:  in[item.Id],
 			Draft:         item.Draft,
 			LayoutId: item.LayoutId,
+			Version:       item.Version,
 		})
 	}
 	return output
@@ -57,6 +61,7 @@ func NotesFromEntitiesWithPosition(entities []entity.NoteWithPosition, links []e
 			LinkedWithIn:  in[item.Id],
 			Draft:         item.Draft,
 			LayoutId:      item.LayoutId,
+			Version:       item.Version,
 		})
 	}
 	return output
@@ -67,17 +72,35 @@ func TransformLinks(links []entity.Link) (map[uuid.UUID][]uuid.UUID, map[uuid.UU
 	in := make(map[uuid.UUID][]uuid.UUID, len(links))
 	for _, item := range links {
 		out[item.FirstNoteId] = append(out[item.FirstNoteId], item.SecondNoteId)
-		in[item.SecondNoteId] = append(out[item.SecondNoteId], item.FirstNoteId)
+		in[item.SecondNoteId] = append(in[item.SecondNoteId], item.FirstNoteId)
 	}
 	return out, in
 }
 
+// Backlink заметка, которая ссылается на данную. Snippet - текст вокруг первой wiki-ссылки,
+// у ручных связей без ссылки в тексте пустой
+type Backlink struct {
+	NoteId   uuid.UUID `json:"noteId"`
+	Title    string    `json:"title"`
+	LayoutId uuid.UUID `json:"layoutId"`
+	Auto     bool      `json:"auto"`
+	Snippet  string    `json:"snippet"`
+}
+
+// SearchNotesResponse страница поиска. NextCursor пустой на последней странице
+type SearchNotesResponse struct {
+	Notes      []Note `json:"notes"`
+	Total      int    `json:"total"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
 type Layout struct {
 	Id         uuid.UUID   `json:"id"`
 	Title      string      `json:"title"`
 	OwnerId    uuid.UUID   `json:"ownerId"`
 	IsMain     bool        `json:"isMain"`
 	Color      string      `json:"color"`
+	Version    int64       `json:"version"`
 	Permission *Permission `json:"permission,omitempty"`
 }
 
@@ -91,4 +114,28 @@ type ExportInfo struct {
 	CreatedAt time.Time            `json:"createdAt"`
 	Layouts   []Layout             `json:"layouts"`
 	Notes     map[uuid.UUID][]Note `json:"notes"`
+	Tags      []Tag                `json:"tags,omitempty"`
+}
+
+type Tag struct {
+	Id    uuid.UUID `json:"id"`
+	Name  string    `json:"name"`
+	Color string    `json:"color"`
+}
+
+// TagCount метка и сколько заметок ею отмечено
+type TagCount struct {
+	Tag
+	Notes int `json:"notes"`
+}
+
+func TagsFromEntities(entities []entity.TagCount) []TagCount {
+	output := make([]TagCount, 0, len(entities))
+	for _, item := range entities {
+		output = append(output, TagCount{
+			Tag:   Tag{Id: item.Id, Name: item.Name, Color: item.Color},
+			Notes: item.Notes,
+		})
+	}
+	return output
 }
@@ -0,0 +1,28 @@
+package dto_test
+
+import (
+	"reflect"
+	"testing"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+func TestTransformLinks(t *testing.T) {
+	a, b, c := uuid.New(), uuid.New(), uuid.New()
+	out, in := dto.TransformLinks([]entity.Link{
+		{FirstNoteId: a, SecondNoteId: b},
+		{FirstNoteId: b, SecondNoteId: c},
+		{FirstNoteId: a, SecondNoteId: c},
+	})
+
+	wantOut := map[uuid.UUID][]uuid.UUID{a: {b, c}, b: {c}}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Fatalf("out = %v, want %v", out, wantOut)
+	}
+	wantIn := map[uuid.UUID][]uuid.UUID{b: {a}, c: {b, a}}
+	if !reflect.DeepEqual(in, wantIn) {
+		t.Fatalf("in = %v, want %v", in, wantIn)
+	}
+}
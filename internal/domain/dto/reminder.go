@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"time"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+const reminderFeedPath = "/statics/reminders/"
+
+// ReminderFeedExt расширение файла календаря в ссылке
+const ReminderFeedExt = ".ics"
+
+func ReminderFeedUrl(host, token string) string {
+	return host + reminderFeedPath + token + ReminderFeedExt
+}
+
+// Reminder напоминание о заметке. Время в часовом поясе напоминания, NextFireAt нет, когда напоминание отработало
+type Reminder struct {
+	Id          uuid.UUID  `json:"id"`
+	NoteId      uuid.UUID  `json:"noteId"`
+	NoteTitle   string     `json:"noteTitle"`
+	LayoutId    uuid.UUID  `json:"layoutId"`
+	RemindAt    time.Time  `json:"remindAt"`
+	RRule       string     `json:"rrule,omitempty"`
+	Timezone    string     `json:"timezone"`
+	NextFireAt  *time.Time `json:"nextFireAt,omitempty"`
+	LastFiredAt *time.Time `json:"lastFiredAt,omitempty"`
+}
+
+func ReminderFromEntity(item *entity.ReminderWithNote) Reminder {
+	loc, err := time.LoadLocation(item.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	in := func(t *time.Time) *time.Time {
+		if t == nil {
+			return nil
+		}
+		local := t.In(loc)
+		return &local
+	}
+	return Reminder{
+		Id:          item.Id,
+		NoteId:      item.NoteId,
+		NoteTitle:   item.NoteTitle,
+		LayoutId:    item.LayoutId,
+		RemindAt:    item.RemindAt.In(loc),
+		RRule:       item.RRule,
+		Timezone:    item.Timezone,
+		NextFireAt:  in(item.NextFireAt),
+		LastFiredAt: in(item.LastFiredAt),
+	}
+}
+
+// ReminderFired событие REMINDER в сокет владельцу напоминания
+type ReminderFired struct {
+	ReminderId uuid.UUID `json:"reminderId"`
+	NoteId     uuid.UUID `json:"noteId"`
+	NoteTitle  string    `json:"noteTitle"`
+	LayoutId   uuid.UUID `json:"layoutId"`
+	FiredAt    time.Time `json:"firedAt"`
+}
+
+// ReminderFeed ссылка на календарь напоминаний в формате iCalendar
+type ReminderFeed struct {
+	Url string `json:"url"`
+}
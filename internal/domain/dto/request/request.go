@@ -2,6 +2,7 @@ package request
 
 import (
 	"mime/multipart"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -42,10 +43,19 @@ type ChangeProfilePicture struct {
 	UserId uuid.UUID
 }
 
-// UploadFileRequest
+// SetStorageQuotaRequest
 // @Schema
-type UploadFileRequest struct {
-	File *multipart.FileHeader `form:"file" binding:"required"`
+type SetStorageQuotaRequest struct {
+	UserId uuid.UUID `json:"userId" binding:"required"`
+	// Quota в байтах, null возвращает лимит по умолчанию
+	Quota *int64 `json:"quota"`
+}
+
+// CreateUploadRequest собирается из заголовков tus
+type CreateUploadRequest struct {
+	FileName string
+	NoteId   uuid.UUID
+	Length   int64
 }
 
 // NoteRequest
@@ -54,14 +64,21 @@ type NoteRequest struct {
 	Title    string    `json:"title"`
 	Payload  string    `json:"payload"`
 	LayoutId uuid.UUID `json:"layoutId"`
+	// TemplateId шаблон, из которого берутся пустые title и payload
+	TemplateId *uuid.UUID `json:"templateId"`
+	// TemplateValues ответы на вопросы шаблона
+	TemplateValues map[string]string `json:"templateValues"`
+	// Timezone часовой пояс IANA для {{date}} и {{time}}, по умолчанию UTC
+	Timezone string `json:"timezone"`
 }
 
 // NoteWithIdRequest
 // @Schema
 type NoteWithIdRequest struct {
 	NoteId  uuid.UUID `json:"noteId" binding:"required"`
-	Title   string    `json:"title"`
-	Payload string    `json:"payload"`
+	Title   *string   `json:"title"`
+	Payload *string   `json:"payload"`
+	Version *int64    `json:"version"`
 }
 
 // NoteId
@@ -81,8 +98,9 @@ type NewLayoutRequest struct {
 // @Schema
 type UpdateLayout struct {
 	LayoutId uuid.UUID `json:"layoutId" binding:"required"`
-	Title    string    `json:"title"`
-	Color    string    `json:"color"`
+	Title    *string   `json:"title"`
+	Color    *string   `json:"color"`
+	Version  *int64    `json:"version"`
 }
 
 // LayoutIdRequest
@@ -96,6 +114,32 @@ type LayoutIdRequest struct {
 type GetNotesFromLayoutRequest struct {
 	LayoutId uuid.UUID `json:"layoutId"`
 	Page     int       `json:"page"`
+	TagsFilter
+}
+
+// TagsFilter имена меток через запятую. TagMode and - заметки со всеми метками, or - хотя бы с одной
+type TagsFilter struct {
+	Tags    []string `json:"tags"`
+	TagMode string   `json:"tagMode"`
+}
+
+// SearchNotesRequest пустые поля не фильтруют. Sort: relevance (по умолчанию), created или updated.
+// Cursor - nextCursor из прошлой страницы
+// @Schema
+type SearchNotesRequest struct {
+	Search      string      `json:"search"`
+	LayoutIds   []uuid.UUID `json:"layoutIds"`
+	OwnerId     *uuid.UUID  `json:"ownerId"`
+	CreatedFrom *time.Time  `json:"createdFrom"`
+	CreatedTo   *time.Time  `json:"createdTo"`
+	UpdatedFrom *time.Time  `json:"updatedFrom"`
+	UpdatedTo   *time.Time  `json:"updatedTo"`
+	HasDraft    *bool       `json:"hasDraft"`
+	LinkedTo    *uuid.UUID  `json:"linkedTo"`
+	Sort        string      `json:"sort"`
+	Cursor      string      `json:"cursor"`
+	Limit       int         `json:"limit"`
+	TagsFilter
 }
 
 // GetNotesFromLayoutWithoutPagRequest
@@ -120,6 +164,153 @@ type LinkBetweenNotesRequest struct {
 }
 
 type DragNoteRequest struct {
-	NoteId       uuid.UUID `json:"noteId"`
-	ToLayoutId   uuid.UUID `json:"toLayoutId"`
+	NoteId     uuid.UUID `json:"noteId"`
+	ToLayoutId uuid.UUID `json:"toLayoutId"`
+	Version    *int64    `json:"version"`
+}
+
+// DuplicateNotesRequest без LayoutId копии остаются в лейаутах оригиналов.
+// Сдвиг позиций копий XOffset/YOffset одинаковый для всех, взаимное расположение сохраняется
+// @Schema
+type DuplicateNotesRequest struct {
+	NoteIds  []uuid.UUID `json:"noteIds" binding:"required,min=1,max=100"`
+	LayoutId *uuid.UUID  `json:"layoutId"`
+	XOffset  *float64    `json:"xOffset"`
+	YOffset  *float64    `json:"yOffset"`
+}
+
+// DuplicateLayoutRequest пустой Title - название исходного лейаута с пометкой копии
+// @Schema
+type DuplicateLayoutRequest struct {
+	LayoutId uuid.UUID `json:"layoutId" binding:"required"`
+	Title    string    `json:"title"`
+}
+
+// DeleteFileRequest Force удаляет файл, даже если на него ссылаются заметки
+// @Schema
+type DeleteFileRequest struct {
+	FileName string `json:"fileName" binding:"required"`
+	Force    bool   `json:"force"`
+}
+
+// AttachmentRequest
+// @Schema
+type AttachmentRequest struct {
+	NoteId   uuid.UUID `json:"noteId" binding:"required"`
+	FileName string    `json:"fileName" binding:"required"`
+}
+
+// NewTagRequest
+// @Schema
+type NewTagRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
+
+// UpdateTagRequest пустые поля не меняются
+// @Schema
+type UpdateTagRequest struct {
+	TagId uuid.UUID `json:"tagId" binding:"required"`
+	Name  *string   `json:"name"`
+	Color *string   `json:"color"`
+}
+
+// TagIdRequest
+// @Schema
+type TagIdRequest struct {
+	TagId uuid.UUID `json:"tagId" binding:"required"`
+}
+
+// NoteTagRequest
+// @Schema
+type NoteTagRequest struct {
+	NoteId uuid.UUID `json:"noteId" binding:"required"`
+	TagId  uuid.UUID `json:"tagId" binding:"required"`
+}
+
+// NewTemplateRequest без layoutId шаблон личный
+// @Schema
+type NewTemplateRequest struct {
+	LayoutId *uuid.UUID `json:"layoutId"`
+	Name     string     `json:"name" binding:"required"`
+	Title    string     `json:"title"`
+	Payload  string     `json:"payload"`
+}
+
+// UpdateTemplateRequest пустые поля не меняются
+// @Schema
+type UpdateTemplateRequest struct {
+	TemplateId uuid.UUID `json:"templateId" binding:"required"`
+	Name       *string   `json:"name"`
+	Title      *string   `json:"title"`
+	Payload    *string   `json:"payload"`
+}
+
+// TemplateIdRequest
+// @Schema
+type TemplateIdRequest struct {
+	TemplateId uuid.UUID `json:"templateId" binding:"required"`
+}
+
+// JournalSettingsRequest пустой titleFormat - {{date}}, без templateId записи создаются пустыми
+// @Schema
+type JournalSettingsRequest struct {
+	LayoutId    uuid.UUID  `json:"layoutId" binding:"required"`
+	TitleFormat string     `json:"titleFormat"`
+	TemplateId  *uuid.UUID `json:"templateId"`
+	// Timezone часовой пояс IANA, в котором наступает первый день журнала
+	Timezone string `json:"timezone"`
+}
+
+// JournalDayRequest пустой Date - сегодня в часовом поясе Timezone
+// @Schema
+type JournalDayRequest struct {
+	Date           string            `json:"date"`
+	Timezone       string            `json:"timezone"`
+	TemplateValues map[string]string `json:"templateValues"`
+}
+
+// TasksRequest Status: open (по умолчанию), done или all. Assignee - имя пользователя без @,
+// DueFrom (включительно) и DueTo (не включая) в формате YYYY-MM-DD
+// @Schema
+type TasksRequest struct {
+	LayoutIds []uuid.UUID `json:"layoutIds"`
+	NoteId    *uuid.UUID  `json:"noteId"`
+	Status    string      `json:"status"`
+	Assignee  string      `json:"assignee"`
+	DueFrom   string      `json:"dueFrom"`
+	DueTo     string      `json:"dueTo"`
+	Limit     int         `json:"limit"`
+	Offset    int         `json:"offset"`
+}
+
+// ToggleTaskRequest Line - номер строки задачи из /tasks, Version - версия заметки, для которой он получен
+// @Schema
+type ToggleTaskRequest struct {
+	NoteId  uuid.UUID `json:"noteId" binding:"required"`
+	Line    int       `json:"line" binding:"min=0"`
+	Version *int64    `json:"version"`
+}
+
+// CreateReminderRequest RemindAt в RFC3339 - первое срабатывание. RRule - повторение по RFC 5545:
+// FREQ (DAILY, WEEKLY, MONTHLY, YEARLY), INTERVAL, COUNT, UNTIL и BYDAY без номеров для WEEKLY
+// @Schema
+type CreateReminderRequest struct {
+	NoteId   uuid.UUID `json:"noteId" binding:"required"`
+	RemindAt time.Time `json:"remindAt" binding:"required"`
+	RRule    string    `json:"rrule"`
+	// Timezone часовой пояс IANA, в котором считаются повторения, по умолчанию UTC
+	Timezone string `json:"timezone"`
+}
+
+// ReminderIdRequest
+// @Schema
+type ReminderIdRequest struct {
+	ReminderId uuid.UUID `json:"reminderId" binding:"required"`
+}
+
+// ReminderFeedRequest Reset выдает новую ссылку на календарь, старая перестает работать
+// @Schema
+type ReminderFeedRequest struct {
+	Reset bool `json:"reset"`
 }
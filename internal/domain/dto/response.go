@@ -6,6 +6,10 @@ import (
 
 type UploadFileResponse struct {
 	ImgUrl string `json:"imgUrl"`
+	// Variants адреса миниатюр по размеру большей стороны, пока они строятся - отдается оригинал
+	Variants map[string]string `json:"variants,omitempty"`
+	// ScanState PENDING, пока вложение не проверено антивирусом, по ссылке оно недоступно
+	ScanState string `json:"scanState"`
 }
 
 type ExportInfoRequest struct {
@@ -15,3 +19,14 @@ type ExportInfoRequest struct {
 type ImportInfoRequest struct {
 	Info ExportInfo `json:"info"`
 }
+
+type VersionResponse struct {
+	Version int64 `json:"version"`
+	// UnresolvedLinks wiki-ссылки из нового текста, для которых не нашлось заметки
+	UnresolvedLinks []string `json:"unresolvedLinks,omitempty"`
+}
+
+// VersionConflict тело ответа 409 version_conflict
+type VersionConflict struct {
+	CurrentVersion int64 `json:"currentVersion"`
+}
@@ -15,11 +15,14 @@ type SendCodeResponse struct {
 }
 
 type ChangePictureResponse struct {
-	NewImgurl string `json:"newImgUrl"`
+	NewImgurl string            `json:"newImgUrl"`
+	Variants  map[string]string `json:"variants,omitempty"`
 }
 
 type NoteId struct {
 	Id uuid.UUID `json:"id"`
+	// UnresolvedLinks wiki-ссылки из текста, для которых не нашлось заметки
+	UnresolvedLinks []string `json:"unresolvedLinks,omitempty"`
 }
 
 type LoginResponse struct {
@@ -27,3 +30,16 @@ type LoginResponse struct {
 	Refresh string    `json:"refreshToken"`
 	UserId  uuid.UUID `json:"userId"`
 }
+
+// DuplicatedNotes id копии по id оригинала
+type DuplicatedNotes struct {
+	Ids map[uuid.UUID]uuid.UUID `json:"ids"`
+}
+
+type TagId struct {
+	Id uuid.UUID `json:"id"`
+}
+
+type TemplateId struct {
+	Id uuid.UUID `json:"id"`
+}
@@ -1,9 +1,31 @@
 package dto
 
 import (
+	"context"
 	"encoding/json"
+	"wn/pkg/apperror"
 
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	UpdateDraftRequestEvent  = "UPDATE_DRAFT_REQUEST"
+	UpdateDraftResponseEvent = "UPDATE_DRAFT_RESPONSE"
+	CommitDraftRequestEvent  = "COMMIT_DRAFT_REQUEST"
+	CommitDraftResponseEvent = "COMMIT_DRAFT_RESPONSE"
+	PongEvent                = "PONG"
+
+	SubscribeLayoutRequestEvent    = "SUBSCRIBE_LAYOUT_REQUEST"
+	SubscribeLayoutResponseEvent   = "SUBSCRIBE_LAYOUT_RESPONSE"
+	UnsubscribeLayoutRequestEvent  = "UNSUBSCRIBE_LAYOUT_REQUEST"
+	UnsubscribeLayoutResponseEvent = "UNSUBSCRIBE_LAYOUT_RESPONSE"
+	MoveNoteEvent                  = "MOVE_NOTE"
+	MoveNoteResponseEvent          = "MOVE_NOTE_RESPONSE"
+	NotesMovedEvent                = "NOTES_MOVED"
+	ReminderEvent                  = "REMINDER"
+
+	socketTimeoutCode = "timeout"
 )
 
 type SocketMessage struct {
@@ -11,11 +33,109 @@ type SocketMessage struct {
 	Payload json.RawMessage `json:"payload"`
 }
 
+// DraftNote Version - ожидаемая версия заметки, как If-Match в /notes/update
 type DraftNote struct {
 	NoteId   uuid.UUID `json:"noteId"`
 	NewDraft string    `json:"newDraft"`
+	Version  *int64    `json:"version"`
 }
 
 type CommitDraftNote struct {
+	NoteId  uuid.UUID `json:"noteId"`
+	Version *int64    `json:"version"`
+}
+
+type LayoutSubscription struct {
+	LayoutId uuid.UUID `json:"layoutId"`
+}
+
+type MoveNote struct {
+	NoteId uuid.UUID `json:"noteId"`
+	XPos   float64   `json:"xPos"`
+	YPos   float64   `json:"yPos"`
+}
+
+type NoteMove struct {
 	NoteId uuid.UUID `json:"noteId"`
+	UserId uuid.UUID `json:"userId"`
+	XPos   float64   `json:"xPos"`
+	YPos   float64   `json:"yPos"`
+}
+
+// NotesMoved последние позиции заметок лейаута за один тик
+type NotesMoved struct {
+	LayoutId uuid.UUID  `json:"layoutId"`
+	Moves    []NoteMove `json:"moves"`
+}
+
+// SocketStatus ответ на ивенты, которые ничего не возвращают кроме результата.
+// Data - данные apperror, например VersionConflict
+type SocketStatus struct {
+	Status  string `json:"status"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// DraftStatus ответ на изменение черновика, Version - новая версия заметки
+type DraftStatus struct {
+	SocketStatus
+	Version int64 `json:"version,omitempty"`
+}
+
+// CommitDraftStatus ответ на коммит черновика, UnresolvedLinks - wiki-ссылки без заметок
+type CommitDraftStatus struct {
+	DraftStatus
+	UnresolvedLinks []string `json:"unresolvedLinks,omitempty"`
+}
+
+// NewSocketStatusMessage собирает ответ со статусом. При ошибке в ответ попадает код и сообщение apperror
+func NewSocketStatusMessage(event string, err error) *SocketMessage {
+	payload, _ := json.Marshal(newSocketStatus(err))
+	return &SocketMessage{
+		Event:   event,
+		Payload: payload,
+	}
+}
+
+func NewDraftStatusMessage(version int64, err error) *SocketMessage {
+	payload, _ := json.Marshal(DraftStatus{
+		SocketStatus: newSocketStatus(err),
+		Version:      version,
+	})
+	return &SocketMessage{
+		Event:   UpdateDraftResponseEvent,
+		Payload: payload,
+	}
+}
+
+func NewCommitDraftStatusMessage(version int64, unresolvedLinks []string, err error) *SocketMessage {
+	payload, _ := json.Marshal(CommitDraftStatus{
+		DraftStatus:     DraftStatus{SocketStatus: newSocketStatus(err), Version: version},
+		UnresolvedLinks: unresolvedLinks,
+	})
+	return &SocketMessage{
+		Event:   CommitDraftResponseEvent,
+		Payload: payload,
+	}
+}
+
+func newSocketStatus(err error) SocketStatus {
+	status := SocketStatus{Status: "true"}
+	if err != nil {
+		status.Status = "false"
+		appErr, ok := errors.Cause(err).(*apperror.AppError)
+		switch {
+		case ok:
+			status.Code = appErr.Code
+			status.Message = appErr.Message
+			status.Data = appErr.Data
+		case errors.Is(err, context.DeadlineExceeded):
+			status.Code = socketTimeoutCode
+			status.Message = "request timeout"
+		default:
+			status.Message = apperror.NewInternalError(err).Message
+		}
+	}
+	return status
 }
@@ -0,0 +1,120 @@
+package dto
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"wn/internal/domain/enum"
+
+	"github.com/google/uuid"
+)
+
+type SyncLink struct {
+	FirstNoteId  uuid.UUID `json:"firstNoteId" binding:"required"`
+	SecondNoteId uuid.UUID `json:"secondNoteId" binding:"required"`
+}
+
+// SyncChange изменение в ленте. Для UPSERT заполнено текущее состояние сущности,
+// DELETE приходит без данных (tombstone)
+type SyncChange struct {
+	Seq       int64               `json:"seq"`
+	Kind      enum.SyncEntityKind `json:"kind"`
+	Operation enum.SyncOperation  `json:"operation"`
+	EntityId  uuid.UUID           `json:"entityId"`
+	LayoutId  uuid.UUID           `json:"layoutId"`
+	Note      *Note               `json:"note,omitempty"`
+	Position  *Position           `json:"position,omitempty"`
+	Link      *SyncLink           `json:"link,omitempty"`
+	Layout    *Layout             `json:"layout,omitempty"`
+}
+
+// SyncCursor позиция в ленте: транзакция и seq последнего отданного изменения
+type SyncCursor struct {
+	Txid int64 `json:"x"`
+	Seq  int64 `json:"s"`
+}
+
+func (c SyncCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// After курсор c позже other
+func (c SyncCursor) After(other SyncCursor) bool {
+	return c.Txid > other.Txid || c.Txid == other.Txid && c.Seq > other.Seq
+}
+
+// DecodeSyncCursor нулевой курсор для пустой строки
+func DecodeSyncCursor(s string) (SyncCursor, error) {
+	var c SyncCursor
+	if s == "" {
+		return c, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// SyncChangesResponse Resync - курсор старше хранимой ленты: клиент заново скачивает
+// лейауты целиком и продолжает с Cursor
+type SyncChangesResponse struct {
+	Cursor  string       `json:"cursor"`
+	HasMore bool         `json:"hasMore"`
+	Resync  bool         `json:"resync"`
+	Changes []SyncChange `json:"changes"`
+}
+
+// SyncPushRequest
+// @Schema
+type SyncPushRequest struct {
+	Items []SyncPushItem `json:"items" binding:"required,max=500,dive"`
+}
+
+// SyncPushItem офлайн изменение. BaseSeq - seq сущности, который клиент видел последним, 0 для новых
+type SyncPushItem struct {
+	Kind      enum.SyncEntityKind `json:"kind" binding:"required"`
+	Operation enum.SyncOperation  `json:"operation" binding:"required"`
+	BaseSeq   int64               `json:"baseSeq"`
+	Note      *SyncPushNote       `json:"note,omitempty"`
+	Position  *SyncPushPosition   `json:"position,omitempty"`
+	Link      *SyncLink           `json:"link,omitempty"`
+	Layout    *SyncPushLayout     `json:"layout,omitempty"`
+}
+
+// SyncPushNote nil Title и Payload не меняются, пустой LayoutId не переносит заметку
+type SyncPushNote struct {
+	Id       uuid.UUID `json:"id" binding:"required"`
+	Title    *string   `json:"title"`
+	Payload  *string   `json:"payload"`
+	LayoutId uuid.UUID `json:"layoutId"`
+}
+
+type SyncPushPosition struct {
+	NoteId uuid.UUID `json:"noteId" binding:"required"`
+	XPos   *float64  `json:"xPos"`
+	YPos   *float64  `json:"yPos"`
+}
+
+// SyncPushLayout nil поля не меняются
+type SyncPushLayout struct {
+	Id    uuid.UUID `json:"id" binding:"required"`
+	Title *string   `json:"title"`
+	Color *string   `json:"color"`
+}
+
+type SyncPushResult struct {
+	Index   int                 `json:"index"`
+	Status  enum.SyncPushStatus `json:"status"`
+	Code    string              `json:"code,omitempty"`
+	Message string              `json:"message,omitempty"`
+	Seq     int64               `json:"seq,omitempty"`
+	Current *SyncChange         `json:"current,omitempty"`
+}
+
+type SyncPushResponse struct {
+	Results []SyncPushResult `json:"results"`
+}
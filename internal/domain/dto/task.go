@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+// Task задача из чек-листа заметки. Line - номер строки в тексте заметки с нуля,
+// NoteVersion - версия заметки, для которой верен Line
+type Task struct {
+	NoteId      uuid.UUID `json:"noteId"`
+	NoteTitle   string    `json:"noteTitle"`
+	LayoutId    uuid.UUID `json:"layoutId"`
+	NoteVersion int64     `json:"noteVersion"`
+	Line        int       `json:"line"`
+	Text        string    `json:"text"`
+	Done        bool      `json:"done"`
+	Due         *string   `json:"due,omitempty"`
+	Assignees   []string  `json:"assignees"`
+}
+
+func TaskFromEntity(item *entity.FoundTask) Task {
+	t := Task{
+		NoteId:      item.NoteId,
+		NoteTitle:   item.Title,
+		LayoutId:    item.LayoutId,
+		NoteVersion: item.NoteVersion,
+		Line:        item.Line,
+		Text:        item.Text,
+		Done:        item.Done,
+		Assignees:   item.Assignees,
+	}
+	if t.Assignees == nil {
+		t.Assignees = []string{}
+	}
+	if item.Due != nil {
+		due := item.Due.Format(DayFormat)
+		t.Due = &due
+	}
+	return t
+}
+
+type TasksResponse struct {
+	Tasks []Task `json:"tasks"`
+	Total int    `json:"total"`
+}
+
+// TaskToggled новая версия заметки и отметка задачи после переключения
+type TaskToggled struct {
+	Version int64 `json:"version"`
+	Done    bool  `json:"done"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"time"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+)
+
+// Template шаблон в библиотеке. Prompts - вопросы из {{prompt:...}}, ответы на них передаются при создании заметки
+type Template struct {
+	Id        uuid.UUID  `json:"id"`
+	OwnerId   uuid.UUID  `json:"ownerId"`
+	LayoutId  *uuid.UUID `json:"layoutId,omitempty"`
+	Name      string     `json:"name"`
+	Title     string     `json:"title"`
+	Payload   string     `json:"payload"`
+	Prompts   []string   `json:"prompts"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+func TemplateFromEntity(item *entity.Template, prompts []string) Template {
+	return Template{
+		Id:        item.Id,
+		OwnerId:   item.OwnerId,
+		LayoutId:  item.LayoutId,
+		Name:      item.Name,
+		Title:     item.Title,
+		Payload:   item.Payload,
+		Prompts:   prompts,
+		CreatedAt: item.CreatedAt,
+	}
+}
+
+// TemplateValuesMissing тело ответа 422 template_values_missing
+type TemplateValuesMissing struct {
+	Prompts []string `json:"prompts"`
+}
@@ -1,8 +1,8 @@
 package user
 
 import (
-	"wn/internal/infrastructure/repository/user"
 	"time"
+	"wn/internal/infrastructure/repository/user"
 
 	"github.com/google/uuid"
 )
@@ -14,6 +14,11 @@ type User struct {
 	ImgUrl    string    `json:"imgUrl"`
 	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"createdAt"`
+
+	// Avatars адреса аватарки по размеру большей стороны
+	Avatars map[string]string `json:"avatars,omitempty"`
+	// DefaultAvatar аватарка сгенерирована, пользователь свою не загружал
+	DefaultAvatar bool `json:"defaultAvatar"`
 }
 
 func UserDtoFromEntity(entity *user.User) *User {
@@ -0,0 +1,28 @@
+package enum
+
+// FilePurpose для чего загружается файл, от этого зависят лимиты и допустимые типы
+type FilePurpose string
+
+const (
+	FilePurposeUnspecified FilePurpose = "UNSPECIFIED"
+	FilePurposeAvatar      FilePurpose = "AVATAR"
+	FilePurposeAttachment  FilePurpose = "ATTACHMENT"
+	FilePurposeExport      FilePurpose = "EXPORT"
+)
+
+func (p FilePurpose) String() string {
+	return string(p)
+}
+
+func FilePurposeFromString(s string) FilePurpose {
+	switch s {
+	case FilePurposeAvatar.String():
+		return FilePurposeAvatar
+	case FilePurposeAttachment.String():
+		return FilePurposeAttachment
+	case FilePurposeExport.String():
+		return FilePurposeExport
+	default:
+		return FilePurposeUnspecified
+	}
+}
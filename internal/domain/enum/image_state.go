@@ -0,0 +1,31 @@
+package enum
+
+// ImageState этап фоновой обработки изображения (перекодирование и миниатюры)
+type ImageState string
+
+const (
+	ImageStateNone       ImageState = "NONE"
+	ImageStatePending    ImageState = "PENDING"
+	ImageStateProcessing ImageState = "PROCESSING"
+	ImageStateReady      ImageState = "READY"
+	ImageStateFailed     ImageState = "FAILED"
+)
+
+func (s ImageState) String() string {
+	return string(s)
+}
+
+func ImageStateFromString(s string) ImageState {
+	switch s {
+	case ImageStatePending.String():
+		return ImageStatePending
+	case ImageStateProcessing.String():
+		return ImageStateProcessing
+	case ImageStateReady.String():
+		return ImageStateReady
+	case ImageStateFailed.String():
+		return ImageStateFailed
+	default:
+		return ImageStateNone
+	}
+}
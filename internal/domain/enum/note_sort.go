@@ -0,0 +1,29 @@
+package enum
+
+// NoteSort порядок заметок в поиске, всегда от больших значений к меньшим
+type NoteSort string
+
+const (
+	NoteSortUnspecified NoteSort = "UNSPECIFIED"
+	NoteSortRelevance   NoteSort = "relevance"
+	NoteSortCreated     NoteSort = "created"
+	NoteSortUpdated     NoteSort = "updated"
+)
+
+func (s NoteSort) String() string {
+	return string(s)
+}
+
+// NoteSortFromString пустая строка - сортировка по релевантности
+func NoteSortFromString(s string) NoteSort {
+	switch s {
+	case "", NoteSortRelevance.String():
+		return NoteSortRelevance
+	case NoteSortCreated.String():
+		return NoteSortCreated
+	case NoteSortUpdated.String():
+		return NoteSortUpdated
+	default:
+		return NoteSortUnspecified
+	}
+}
@@ -0,0 +1,29 @@
+package enum
+
+// OverflowPolicy что делать с сообщением, если очередь отправки соединения заполнена
+type OverflowPolicy string
+
+const (
+	OverflowPolicyUnspecified OverflowPolicy = "UNSPECIFIED"
+	OverflowPolicyDropNewest  OverflowPolicy = "DROP_NEWEST"
+	OverflowPolicyDropOldest  OverflowPolicy = "DROP_OLDEST"
+	OverflowPolicyDisconnect  OverflowPolicy = "DISCONNECT"
+)
+
+func (v OverflowPolicy) String() string {
+	return string(v)
+}
+
+// OverflowPolicyFromString неизвестное значение - UNSPECIFIED, опечатка в конфиге не должна молча менять поведение
+func OverflowPolicyFromString(s string) OverflowPolicy {
+	switch s {
+	case OverflowPolicyDropNewest.String():
+		return OverflowPolicyDropNewest
+	case OverflowPolicyDropOldest.String():
+		return OverflowPolicyDropOldest
+	case OverflowPolicyDisconnect.String():
+		return OverflowPolicyDisconnect
+	default:
+		return OverflowPolicyUnspecified
+	}
+}
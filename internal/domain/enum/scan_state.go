@@ -0,0 +1,33 @@
+package enum
+
+// ScanState этап антивирусной проверки загруженного файла
+type ScanState string
+
+const (
+	ScanStatePending  ScanState = "PENDING"
+	ScanStateScanning ScanState = "SCANNING"
+	ScanStateClean    ScanState = "CLEAN"
+	ScanStateInfected ScanState = "INFECTED"
+	// ScanStateFailed проверить нечего: содержимого нет в хранилище
+	ScanStateFailed ScanState = "FAILED"
+)
+
+func (s ScanState) String() string {
+	return string(s)
+}
+
+// ScanStateFromString неизвестное значение считается непроверенным
+func ScanStateFromString(s string) ScanState {
+	switch s {
+	case ScanStateScanning.String():
+		return ScanStateScanning
+	case ScanStateClean.String():
+		return ScanStateClean
+	case ScanStateInfected.String():
+		return ScanStateInfected
+	case ScanStateFailed.String():
+		return ScanStateFailed
+	default:
+		return ScanStatePending
+	}
+}
@@ -0,0 +1,65 @@
+package enum
+
+type SyncEntityKind string
+
+const (
+	SyncEntityKindUnspecified SyncEntityKind = "UNSPECIFIED"
+	SyncEntityKindNote        SyncEntityKind = "NOTE"
+	SyncEntityKindPosition    SyncEntityKind = "POSITION"
+	SyncEntityKindLink        SyncEntityKind = "LINK"
+	SyncEntityKindLayout      SyncEntityKind = "LAYOUT"
+)
+
+func (k SyncEntityKind) String() string {
+	return string(k)
+}
+
+func SyncEntityKindFromString(s string) SyncEntityKind {
+	switch s {
+	case SyncEntityKindNote.String():
+		return SyncEntityKindNote
+	case SyncEntityKindPosition.String():
+		return SyncEntityKindPosition
+	case SyncEntityKindLink.String():
+		return SyncEntityKindLink
+	case SyncEntityKindLayout.String():
+		return SyncEntityKindLayout
+	default:
+		return SyncEntityKindUnspecified
+	}
+}
+
+type SyncOperation string
+
+const (
+	SyncOperationUnspecified SyncOperation = "UNSPECIFIED"
+	SyncOperationUpsert      SyncOperation = "UPSERT"
+	SyncOperationDelete      SyncOperation = "DELETE"
+)
+
+func (o SyncOperation) String() string {
+	return string(o)
+}
+
+func SyncOperationFromString(s string) SyncOperation {
+	switch s {
+	case SyncOperationUpsert.String():
+		return SyncOperationUpsert
+	case SyncOperationDelete.String():
+		return SyncOperationDelete
+	default:
+		return SyncOperationUnspecified
+	}
+}
+
+type SyncPushStatus string
+
+const (
+	SyncPushStatusApplied  SyncPushStatus = "APPLIED"
+	SyncPushStatusConflict SyncPushStatus = "CONFLICT"
+	SyncPushStatusRejected SyncPushStatus = "REJECTED"
+)
+
+func (s SyncPushStatus) String() string {
+	return string(s)
+}
@@ -0,0 +1,271 @@
+package changes
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type changesRepo interface {
+	GetChanges(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID, since dto.SyncCursor, limit int) ([]entity.Change, error)
+	GetLatestChange(ctx context.Context, item *entity.Change) (*entity.Change, error)
+	GetLatestForeignSeq(ctx context.Context, item *entity.Change) (int64, error)
+	GetPruned(ctx context.Context) (dto.SyncCursor, error)
+	GetHead(ctx context.Context) (dto.SyncCursor, error)
+	PruneChanges(ctx context.Context, before time.Time) (int64, error)
+}
+
+type layoutRepo interface {
+	GetAvailableLayouts(ctx context.Context, userId uuid.UUID) ([]entity.Layout, error)
+}
+
+type noteService interface {
+	GetFullNotesByIds(ctx context.Context, noteIds []uuid.UUID) ([]dto.Note, error)
+}
+
+type Config struct {
+	// Retention сколько хранятся изменения в ленте, 0 - бессрочно
+	Retention time.Duration
+}
+
+func NewConfig(retention time.Duration) *Config {
+	return &Config{Retention: retention}
+}
+
+type Service struct {
+	logger applogger.Logger
+	cfg    *Config
+
+	changesRepo changesRepo
+	layoutRepo  layoutRepo
+	noteService noteService
+}
+
+func NewService(
+	logger applogger.Logger,
+	cfg *Config,
+	changesRepo changesRepo,
+	layoutRepo layoutRepo,
+	noteService noteService,
+) *Service {
+	return &Service{
+		logger:      logger,
+		cfg:         cfg,
+		changesRepo: changesRepo,
+		layoutRepo:  layoutRepo,
+		noteService: noteService,
+	}
+}
+
+// GetChanges страница ленты после курсора since. Изменения одной сущности внутри
+// страницы схлопываются до последнего, для UPSERT подгружается текущее состояние.
+// Если часть ленты после since уже удалена, вместо страницы отдается Resync
+func (srv *Service) GetChanges(ctx context.Context, userId uuid.UUID, since dto.SyncCursor, limit int) (*dto.SyncChangesResponse, error) {
+	pruned, err := srv.changesRepo.GetPruned(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.changesRepo.GetPruned")
+	}
+	if pruned.After(since) {
+		head, err := srv.changesRepo.GetHead(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "srv.changesRepo.GetHead")
+		}
+		return &dto.SyncChangesResponse{
+			Cursor:  head.Encode(),
+			Resync:  true,
+			Changes: []dto.SyncChange{},
+		}, nil
+	}
+
+	available, err := srv.getAvailableLayouts(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	layoutIds := make([]uuid.UUID, 0, len(available))
+	for id := range available {
+		layoutIds = append(layoutIds, id)
+	}
+
+	changes, err := srv.changesRepo.GetChanges(ctx, userId, layoutIds, since, limit+1)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.changesRepo.GetChanges")
+	}
+
+	output := dto.SyncChangesResponse{
+		Cursor:  since.Encode(),
+		HasMore: len(changes) > limit,
+	}
+	if output.HasMore {
+		changes = changes[:limit]
+	}
+	if len(changes) > 0 {
+		last := changes[len(changes)-1]
+		output.Cursor = dto.SyncCursor{Txid: last.Txid, Seq: last.Seq}.Encode()
+	}
+
+	output.Changes, err = srv.buildChanges(ctx, available, compact(changes))
+	if err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
+
+// PruneChanges удаляет изменения старше срока хранения
+func (srv *Service) PruneChanges(ctx context.Context, now time.Time) (int64, error) {
+	if srv.cfg.Retention <= 0 {
+		return 0, nil
+	}
+	return srv.changesRepo.PruneChanges(ctx, now.Add(-srv.cfg.Retention))
+}
+
+// GetLatestForeignSeq seq последнего изменения сущности из других транзакций, вызывается после записи
+func (srv *Service) GetLatestForeignSeq(ctx context.Context, key *entity.Change) (int64, error) {
+	return srv.changesRepo.GetLatestForeignSeq(ctx, key)
+}
+
+// GetLatestSeq seq последнего изменения сущности, 0 если изменений не было
+func (srv *Service) GetLatestSeq(ctx context.Context, key *entity.Change) (int64, error) {
+	latest, err := srv.changesRepo.GetLatestChange(ctx, key)
+	if err != nil {
+		if errors.Is(err, apperrors.RecordNotFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return latest.Seq, nil
+}
+
+// GetCurrent текущее состояние сущности в формате ленты, nil если пользователь его не видит
+func (srv *Service) GetCurrent(ctx context.Context, userId uuid.UUID, key *entity.Change) (*dto.SyncChange, error) {
+	latest, err := srv.changesRepo.GetLatestChange(ctx, key)
+	if err != nil {
+		if errors.Is(err, apperrors.RecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	available, err := srv.getAvailableLayouts(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	built, err := srv.buildChanges(ctx, available, []entity.Change{*latest})
+	if err != nil || len(built) == 0 {
+		return nil, err
+	}
+	return &built[0], nil
+}
+
+func (srv *Service) getAvailableLayouts(ctx context.Context, userId uuid.UUID) (map[uuid.UUID]entity.Layout, error) {
+	layouts, err := srv.layoutRepo.GetAvailableLayouts(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.layoutRepo.GetAvailableLayouts")
+	}
+	available := make(map[uuid.UUID]entity.Layout, len(layouts))
+	for _, l := range layouts {
+		available[l.Id] = l
+	}
+	return available, nil
+}
+
+func (srv *Service) buildChanges(ctx context.Context, available map[uuid.UUID]entity.Layout, changes []entity.Change) ([]dto.SyncChange, error) {
+	noteIds := make([]uuid.UUID, 0)
+	for _, c := range changes {
+		if c.Operation == enum.SyncOperationUpsert && (c.Kind == enum.SyncEntityKindNote || c.Kind == enum.SyncEntityKindPosition) {
+			noteIds = append(noteIds, c.EntityId)
+		}
+	}
+
+	notes := make(map[uuid.UUID]dto.Note, len(noteIds))
+	if len(noteIds) > 0 {
+		items, err := srv.noteService.GetFullNotesByIds(ctx, noteIds)
+		if err != nil {
+			return nil, errors.Wrap(err, "srv.noteService.GetFullNotesByIds")
+		}
+		for _, n := range items {
+			notes[n.Id] = n
+		}
+	}
+
+	output := make([]dto.SyncChange, 0, len(changes))
+	for _, c := range changes {
+		item := dto.SyncChange{
+			Seq:       c.Seq,
+			Kind:      c.Kind,
+			Operation: c.Operation,
+			EntityId:  c.EntityId,
+			LayoutId:  c.LayoutId,
+		}
+		if c.Kind == enum.SyncEntityKindLink {
+			item.Link = &dto.SyncLink{FirstNoteId: c.EntityId, SecondNoteId: c.SecondId}
+		}
+
+		if c.Operation == enum.SyncOperationUpsert {
+			switch c.Kind {
+			case enum.SyncEntityKindNote, enum.SyncEntityKindPosition:
+				n, ok := notes[c.EntityId]
+				if !ok {
+					// заметку уже удалили, tombstone придет отдельным изменением
+					continue
+				}
+				item.LayoutId = n.LayoutId
+				if c.Kind == enum.SyncEntityKindNote {
+					item.Note = &n
+				} else {
+					item.Position = n.Position
+				}
+			case enum.SyncEntityKindLayout:
+				l, ok := available[c.EntityId]
+				if ok {
+					item.Layout = &dto.Layout{
+						Id:      l.Id,
+						Title:   l.Title,
+						OwnerId: l.OwnerId,
+						IsMain:  l.IsMain,
+						Color:   l.Color,
+						Version: l.Version,
+					}
+				}
+			}
+
+			// текущее состояние недоступно пользователю (доступ отозван или заметку унесли),
+			// отдаем вместо него tombstone, чтобы не раскрыть данные
+			if _, ok := available[item.LayoutId]; !ok {
+				item.Operation = enum.SyncOperationDelete
+				item.Note, item.Position, item.Layout = nil, nil, nil
+			}
+		}
+		output = append(output, item)
+	}
+	return output, nil
+}
+
+// compact оставляет только последнее изменение каждой сущности, порядок ленты сохраняется
+func compact(changes []entity.Change) []entity.Change {
+	type key struct {
+		kind     enum.SyncEntityKind
+		entityId uuid.UUID
+		secondId uuid.UUID
+	}
+	last := make(map[key]int64, len(changes))
+	for _, c := range changes {
+		last[key{c.Kind, c.EntityId, c.SecondId}] = c.Seq
+	}
+
+	output := make([]entity.Change, 0, len(last))
+	for _, c := range changes {
+		if last[key{c.Kind, c.EntityId, c.SecondId}] == c.Seq {
+			output = append(output, c)
+		}
+	}
+	return output
+}
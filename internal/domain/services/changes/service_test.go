@@ -0,0 +1,203 @@
+package changes_test
+
+import (
+	"context"
+	"testing"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	"wn/internal/domain/services/changes"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore лента, лейауты и заметки в памяти
+type memoryStore struct {
+	changes []entity.Change
+	layouts []entity.Layout
+	notes   map[uuid.UUID]dto.Note
+	pruned  dto.SyncCursor
+	head    dto.SyncCursor
+	before  *time.Time
+}
+
+func (s *memoryStore) GetChanges(_ context.Context, _ uuid.UUID, _ []uuid.UUID, since dto.SyncCursor, limit int) ([]entity.Change, error) {
+	var out []entity.Change
+	for _, c := range s.changes {
+		if (dto.SyncCursor{Txid: c.Txid, Seq: c.Seq}).After(since) && len(out) < limit {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetLatestChange(_ context.Context, item *entity.Change) (*entity.Change, error) {
+	for i := len(s.changes) - 1; i >= 0; i-- {
+		c := s.changes[i]
+		if c.Kind == item.Kind && c.EntityId == item.EntityId && c.SecondId == item.SecondId {
+			return &c, nil
+		}
+	}
+	return nil, apperrors.RecordNotFound
+}
+
+func (s *memoryStore) GetLatestForeignSeq(context.Context, *entity.Change) (int64, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) GetPruned(context.Context) (dto.SyncCursor, error) {
+	return s.pruned, nil
+}
+
+func (s *memoryStore) GetHead(context.Context) (dto.SyncCursor, error) {
+	return s.head, nil
+}
+
+func (s *memoryStore) PruneChanges(_ context.Context, before time.Time) (int64, error) {
+	s.before = &before
+	return 0, nil
+}
+
+func (s *memoryStore) GetAvailableLayouts(context.Context, uuid.UUID) ([]entity.Layout, error) {
+	return s.layouts, nil
+}
+
+func (s *memoryStore) GetFullNotesByIds(_ context.Context, noteIds []uuid.UUID) ([]dto.Note, error) {
+	var out []dto.Note
+	for _, id := range noteIds {
+		if n, ok := s.notes[id]; ok {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+func change(txid, seq int64, kind enum.SyncEntityKind, operation enum.SyncOperation, entityId, layoutId uuid.UUID) entity.Change {
+	c := entity.NewChange(kind, operation, entityId, layoutId)
+	c.Txid, c.Seq = txid, seq
+	return *c
+}
+
+func newService(t *testing.T, store *memoryStore, retention time.Duration) *changes.Service {
+	lgr := testutil.Logger(t)
+	return changes.NewService(lgr, changes.NewConfig(retention), store, store, store)
+}
+
+func TestGetChanges(t *testing.T) {
+	ctx := context.Background()
+	userId := uuid.New()
+	own, revoked := uuid.New(), uuid.New()
+	kept, deleted, moved := uuid.New(), uuid.New(), uuid.New()
+	store := &memoryStore{
+		layouts: []entity.Layout{{Id: own, Title: "own", OwnerId: userId}},
+		notes: map[uuid.UUID]dto.Note{
+			kept:  {Id: kept, Title: "kept", LayoutId: own, Position: &dto.Position{XPos: 1, YPos: 2}},
+			moved: {Id: moved, Title: "moved", LayoutId: revoked},
+		},
+	}
+	// транзакция 7 закоммичена раньше 9, хотя seq у нее больше
+	store.changes = []entity.Change{
+		change(7, 12, enum.SyncEntityKindNote, enum.SyncOperationUpsert, kept, own),
+		change(7, 13, enum.SyncEntityKindPosition, enum.SyncOperationUpsert, kept, uuid.Nil),
+		change(9, 10, enum.SyncEntityKindNote, enum.SyncOperationUpsert, kept, own),
+		change(9, 11, enum.SyncEntityKindNote, enum.SyncOperationUpsert, deleted, own),
+		change(10, 14, enum.SyncEntityKindNote, enum.SyncOperationUpsert, moved, own),
+		change(10, 15, enum.SyncEntityKindLayout, enum.SyncOperationUpsert, revoked, revoked),
+		change(11, 16, enum.SyncEntityKindNote, enum.SyncOperationDelete, deleted, own),
+	}
+	srv := newService(t, store, 0)
+
+	page, err := srv.GetChanges(ctx, userId, dto.SyncCursor{}, 3)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if !page.HasMore || page.Resync || page.Cursor != (dto.SyncCursor{Txid: 9, Seq: 10}).Encode() {
+		t.Fatalf("page = %+v", page)
+	}
+	// заметка схлопнута до последнего изменения, позиция осталась отдельным изменением
+	if len(page.Changes) != 2 {
+		t.Fatalf("changes = %+v", page.Changes)
+	}
+	if c := page.Changes[0]; c.Kind != enum.SyncEntityKindPosition || c.Position == nil || c.Position.XPos != 1 || c.LayoutId != own {
+		t.Fatalf("position = %+v", c)
+	}
+	if c := page.Changes[1]; c.Kind != enum.SyncEntityKindNote || c.Seq != 10 || c.Note == nil || c.Note.Title != "kept" {
+		t.Fatalf("note = %+v", c)
+	}
+
+	cursor, err := dto.DecodeSyncCursor(page.Cursor)
+	if err != nil {
+		t.Fatalf("DecodeSyncCursor() error = %v", err)
+	}
+	page, err = srv.GetChanges(ctx, userId, cursor, 10)
+	if err != nil {
+		t.Fatalf("GetChanges() next error = %v", err)
+	}
+	if page.HasMore || page.Cursor != (dto.SyncCursor{Txid: 11, Seq: 16}).Encode() {
+		t.Fatalf("next page = %+v", page)
+	}
+	// удаленная заметка приходит только tombstone, унесенная в чужой лейаут и недоступный лейаут - tombstone без данных
+	want := []struct {
+		kind      enum.SyncEntityKind
+		entityId  uuid.UUID
+		operation enum.SyncOperation
+	}{
+		{enum.SyncEntityKindNote, moved, enum.SyncOperationDelete},
+		{enum.SyncEntityKindLayout, revoked, enum.SyncOperationDelete},
+		{enum.SyncEntityKindNote, deleted, enum.SyncOperationDelete},
+	}
+	if len(page.Changes) != len(want) {
+		t.Fatalf("changes = %+v", page.Changes)
+	}
+	for i, w := range want {
+		c := page.Changes[i]
+		if c.Kind != w.kind || c.EntityId != w.entityId || c.Operation != w.operation || c.Note != nil || c.Layout != nil {
+			t.Fatalf("change %d = %+v, want %+v", i, c, w)
+		}
+	}
+}
+
+func TestGetChangesResync(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryStore{
+		changes: []entity.Change{change(20, 30, enum.SyncEntityKindNote, enum.SyncOperationDelete, uuid.New(), uuid.New())},
+		pruned:  dto.SyncCursor{Txid: 15, Seq: 25},
+		head:    dto.SyncCursor{Txid: 21},
+	}
+	srv := newService(t, store, 0)
+
+	for _, since := range []dto.SyncCursor{{}, {Txid: 15, Seq: 24}} {
+		page, err := srv.GetChanges(ctx, uuid.New(), since, 10)
+		if err != nil {
+			t.Fatalf("GetChanges(%+v) error = %v", since, err)
+		}
+		if !page.Resync || len(page.Changes) != 0 || page.Cursor != store.head.Encode() {
+			t.Fatalf("GetChanges(%+v) = %+v", since, page)
+		}
+	}
+
+	page, err := srv.GetChanges(ctx, uuid.New(), store.pruned, 10)
+	if err != nil {
+		t.Fatalf("GetChanges() error = %v", err)
+	}
+	if page.Resync || len(page.Changes) != 1 {
+		t.Fatalf("GetChanges() at pruned = %+v", page)
+	}
+}
+
+func TestPruneChanges(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	store := &memoryStore{}
+	if _, err := newService(t, store, 0).PruneChanges(ctx, now); err != nil || store.before != nil {
+		t.Fatalf("PruneChanges() without retention = %v, %v", store.before, err)
+	}
+	if _, err := newService(t, store, 720*time.Hour).PruneChanges(ctx, now); err != nil || store.before == nil ||
+		!store.before.Equal(now.Add(-720*time.Hour)) {
+		t.Fatalf("PruneChanges() before = %v, %v", store.before, err)
+	}
+}
@@ -0,0 +1,124 @@
+package file
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// gcBatch сколько файлов и blob'ов удаляется за один проход
+	gcBatch = 100
+	// gcReportLimit сколько кандидатов показывает отчет
+	gcReportLimit = 1000
+)
+
+// CollectGarbage удаляет файлы, на которые никто не ссылается дольше GCGracePeriod, и освободившееся содержимое.
+// Содержимое удаленных файлов помечается в этом же проходе и удаляется еще через GCGracePeriod
+func (srv *Service) CollectGarbage(ctx context.Context) (int, int, error) {
+	if err := srv.fileRepo.MarkOrphanFiles(ctx); err != nil {
+		return 0, 0, errors.Wrap(err, "srv.fileRepo.MarkOrphanFiles")
+	}
+	before := time.Now().Add(-srv.cfg.GCGracePeriod)
+
+	files := 0
+	for {
+		deleted, err := srv.fileRepo.DeleteOrphanFiles(ctx, before, gcBatch)
+		if err != nil {
+			return files, 0, errors.Wrap(err, "srv.fileRepo.DeleteOrphanFiles")
+		}
+		files += len(deleted)
+		if len(deleted) < gcBatch || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if err := srv.fileRepo.MarkOrphanBlobs(ctx); err != nil {
+		return files, 0, errors.Wrap(err, "srv.fileRepo.MarkOrphanBlobs")
+	}
+	blobs := 0
+	for {
+		var deleted []*entity.Blob
+		// строки удаляются только если удалось удалить содержимое
+		err := srv.tx.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			if deleted, err = srv.fileRepo.DeleteOrphanBlobs(ctx, before, gcBatch); err != nil {
+				return errors.Wrap(err, "srv.fileRepo.DeleteOrphanBlobs")
+			}
+			for _, blob := range deleted {
+				if err := srv.store.Delete(ctx, blob.StorageKey); err != nil {
+					return errors.Wrapf(err, "srv.store.Delete %s", blob.StorageKey)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return files, blobs, err
+		}
+		blobs += len(deleted)
+		if len(deleted) < gcBatch || ctx.Err() != nil {
+			return files, blobs, nil
+		}
+	}
+}
+
+// GarbageReport отчет без удаления: какие файлы и blob'ы не используются и когда будут удалены
+func (srv *Service) GarbageReport(ctx context.Context) (*dto.GarbageReport, error) {
+	files, err := srv.fileRepo.GetOrphanFiles(ctx, gcReportLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetOrphanFiles")
+	}
+	blobs, err := srv.fileRepo.GetOrphanBlobs(ctx, gcReportLimit)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetOrphanBlobs")
+	}
+
+	now := time.Now()
+	report := &dto.GarbageReport{
+		GracePeriod: srv.cfg.GCGracePeriod.String(),
+		Files:       make([]dto.GarbageFile, 0, len(files)),
+		Blobs:       make([]dto.GarbageBlob, 0, len(blobs)),
+	}
+	for _, item := range files {
+		deleteAfter := srv.deleteAfter(item.OrphanedAt, now)
+		due := !deleteAfter.After(now)
+		report.Files = append(report.Files, dto.GarbageFile{
+			Name:        item.Name,
+			OwnerId:     item.OwnerId,
+			Purpose:     item.Purpose.String(),
+			Size:        item.Size,
+			CreatedAt:   item.CreatedAt,
+			DeleteAfter: deleteAfter,
+			Due:         due,
+		})
+		if due {
+			report.DueFiles++
+		}
+	}
+	for _, blob := range blobs {
+		deleteAfter := srv.deleteAfter(blob.OrphanedAt, now)
+		due := !deleteAfter.After(now)
+		report.Blobs = append(report.Blobs, dto.GarbageBlob{
+			StorageKey:  blob.StorageKey,
+			Size:        blob.Size,
+			DeleteAfter: deleteAfter,
+			Due:         due,
+		})
+		if due {
+			report.DueBlobs++
+			report.DueBytes += blob.Size
+		}
+	}
+	return report, nil
+}
+
+// deleteAfter еще не помеченные кандидаты будут помечены при следующем запуске
+func (srv *Service) deleteAfter(orphanedAt, now time.Time) time.Time {
+	if orphanedAt.IsZero() {
+		orphanedAt = now
+	}
+	return orphanedAt.Add(srv.cfg.GCGracePeriod)
+}
@@ -0,0 +1,176 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"time"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	"wn/pkg/blobstore"
+	"wn/pkg/imaging"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// imageBatch сколько изображений забирается за один проход
+	imageBatch = 10
+	// imageStaleAfter через сколько зависшая обработка забирается повторно
+	imageStaleAfter = 5 * time.Minute
+	jpegQuality     = 85
+)
+
+var errUndecodable = errors.New("image can not be processed")
+
+// ProcessImages перекодирует оригиналы без метаданных и строит миниатюры. Битые изображения и изображения
+// без содержимого больше не обрабатываются. При временной ошибке (хранилище недоступно) файлы возвращаются
+// в очередь и проход прерывается, иначе он снова и снова забирал бы те же файлы
+func (srv *Service) ProcessImages(ctx context.Context) (int, error) {
+	processed := 0
+	for {
+		items, err := srv.fileRepo.ClaimImages(ctx, imageBatch, time.Now().Add(-imageStaleAfter))
+		if err != nil {
+			return processed, errors.Wrap(err, "srv.fileRepo.ClaimImages")
+		}
+		if len(items) == 0 {
+			return processed, nil
+		}
+		for i, item := range items {
+			state := enum.ImageStateReady
+			if err := srv.processImage(ctx, item); err != nil {
+				if !errors.Is(err, errUndecodable) {
+					for _, rest := range items[i:] {
+						if err := srv.fileRepo.SetImageState(ctx, rest.Name, enum.ImageStatePending); err != nil {
+							srv.logger.WithCtx(ctx).Warnf("ProcessImages requeue %s: %s", rest.Name, err.Error())
+						}
+					}
+					return processed, errors.Wrapf(err, "process %s", item.Name)
+				}
+				srv.logger.WithCtx(ctx).Warnf("processImage %s: %s", item.Name, err.Error())
+				state = enum.ImageStateFailed
+			}
+			if err := srv.fileRepo.SetImageState(ctx, item.Name, state); err != nil {
+				return processed, errors.Wrap(err, "srv.fileRepo.SetImageState")
+			}
+			if state == enum.ImageStateReady {
+				processed++
+			}
+		}
+		if ctx.Err() != nil {
+			return processed, ctx.Err()
+		}
+	}
+}
+
+func (srv *Service) processImage(ctx context.Context, item *entity.File) error {
+	img, format, err := srv.decodeImage(ctx, item)
+	if err != nil {
+		return err
+	}
+	img = imaging.ApplyOrientation(img, item.Orientation)
+
+	// gif не перекодируем, чтобы не потерять анимацию
+	if format != "gif" {
+		if err := srv.storeEncoded(ctx, item, img, format); err != nil {
+			return err
+		}
+	}
+
+	variants, err := srv.fileRepo.GetVariants(ctx, item.Name)
+	if err != nil {
+		return errors.Wrap(err, "srv.fileRepo.GetVariants")
+	}
+	for _, variant := range variants {
+		thumbFormat := "png"
+		if format == "jpeg" {
+			thumbFormat = "jpeg"
+		}
+		thumb := imaging.Fit(img, variant.Variant)
+		if err := srv.storeEncoded(ctx, variant, thumb, thumbFormat); err != nil {
+			return err
+		}
+		if err := srv.fileRepo.SetImageState(ctx, variant.Name, enum.ImageStateReady); err != nil {
+			return errors.Wrap(err, "srv.fileRepo.SetImageState")
+		}
+	}
+	return nil
+}
+
+func (srv *Service) decodeImage(ctx context.Context, item *entity.File) (image.Image, string, error) {
+	content, err := srv.store.Open(ctx, item.StorageKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, "", errors.Wrap(errUndecodable, err.Error())
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err, "srv.store.Open")
+	}
+	defer content.Close()
+
+	cfg, _, err := image.DecodeConfig(content)
+	if err != nil {
+		return nil, "", errors.Wrap(errUndecodable, err.Error())
+	}
+	if cfg.Width*cfg.Height > srv.cfg.MaxImagePixels {
+		return nil, "", errors.Wrapf(errUndecodable, "%dx%d is too large", cfg.Width, cfg.Height)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, "", errors.Wrap(err, "content.Seek")
+	}
+	img, format, err := image.Decode(content)
+	if err != nil {
+		return nil, "", errors.Wrap(errUndecodable, err.Error())
+	}
+	return img, format, nil
+}
+
+// storeEncoded кодирует изображение, пишет в хранилище и переключает на него запись.
+// Ключ вычисляется по содержимому, blob'ы не перезаписываются: старое содержимое мог использовать другой файл
+func (srv *Service) storeEncoded(ctx context.Context, item *entity.File, img image.Image, format string) error {
+	var buf bytes.Buffer
+	contentType := "image/png"
+	switch format {
+	case "jpeg":
+		contentType = "image/jpeg"
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return errors.Wrap(err, "jpeg.Encode")
+		}
+	default:
+		if err := png.Encode(&buf, img); err != nil {
+			return errors.Wrap(err, "png.Encode")
+		}
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	hash := hex.EncodeToString(sum[:])
+	blob := &entity.Blob{
+		StorageKey:  keyPrefix + hash,
+		Hash:        hash,
+		Size:        int64(buf.Len()),
+		ContentType: contentType,
+	}
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		storageKey, err := srv.fileRepo.RegisterBlob(ctx, blob)
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRepo.RegisterBlob")
+		}
+		// строка blob видна другим только после коммита, поэтому содержимое пишется внутри транзакции.
+		// Повторная запись того же содержимого под тем же ключом безопасна
+		if storageKey == blob.StorageKey {
+			if err := srv.store.Put(ctx, blob.StorageKey, bytes.NewReader(buf.Bytes()), blob.Size, contentType); err != nil {
+				return errors.Wrap(err, "srv.store.Put")
+			}
+		}
+		item.StorageKey = storageKey
+		item.ContentType = contentType
+		item.Size = blob.Size
+		item.Hash = hash
+		return errors.Wrap(srv.fileRepo.UpdateFileBlob(ctx, item), "srv.fileRepo.UpdateFileBlob")
+	})
+}
@@ -0,0 +1,109 @@
+package file
+
+import (
+	"context"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// GetOwnerFiles страница файлов пользователя с миниатюрами и ссылками, второе значение - всего файлов
+func (srv *Service) GetOwnerFiles(ctx context.Context, ownerId uuid.UUID, page int) ([]*entity.File, int, error) {
+	count, err := srv.fileRepo.CountOwnerFiles(ctx, ownerId)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "srv.fileRepo.CountOwnerFiles")
+	}
+	items, err := srv.fileRepo.GetOwnerFiles(ctx, ownerId, util.CalculateOffset(page), util.CalculateLimit())
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "srv.fileRepo.GetOwnerFiles")
+	}
+	if err := srv.fillReferences(ctx, items); err != nil {
+		return nil, 0, err
+	}
+	for _, item := range items {
+		if item.Variants, err = srv.fileRepo.GetVariants(ctx, item.Name); err != nil {
+			return nil, 0, errors.Wrap(err, "srv.fileRepo.GetVariants")
+		}
+	}
+	return items, count, nil
+}
+
+func (srv *Service) fillReferences(ctx context.Context, items []*entity.File) error {
+	if len(items) == 0 {
+		return nil
+	}
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+	refs, err := srv.fileRepo.GetFileReferences(ctx, names)
+	if err != nil {
+		return errors.Wrap(err, "srv.fileRepo.GetFileReferences")
+	}
+	for _, item := range items {
+		item.References = refs[item.Name]
+		if item.References == nil {
+			item.References = &entity.FileReferences{}
+		}
+		item.References.AttachedTo = item.NoteId
+	}
+	return nil
+}
+
+// DeleteFile удаляет файл пользователя вместе с миниатюрами и сразу удаляет содержимое,
+// которое больше не использует ни один файл. Если хранилище недоступно, содержимое удалит сборка мусора.
+// Аватарку удалить нельзя, файл, на который ссылаются заметки, удаляется только с force.
+// Возвращает удаленный файл со ссылками, которые остались без файла
+func (srv *Service) DeleteFile(ctx context.Context, name string, ownerId uuid.UUID, force bool) (*entity.File, error) {
+	item, err := srv.fileRepo.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if item.ParentName != "" {
+		return nil, apperrors.FileNotFound
+	}
+	if item.OwnerId != ownerId {
+		return nil, apperrors.FileAccessDenied
+	}
+	if err := srv.fillReferences(ctx, []*entity.File{item}); err != nil {
+		return nil, err
+	}
+	if item.References.Avatar || (item.References.InNotes() && !force) {
+		return nil, apperrors.FileInUse
+	}
+	variants, err := srv.fileRepo.GetVariants(ctx, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetVariants")
+	}
+	if err := srv.fileRepo.DeleteFile(ctx, name); err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.DeleteFile")
+	}
+	keys := []string{item.StorageKey}
+	for _, variant := range variants {
+		keys = append(keys, variant.StorageKey)
+	}
+	if err := srv.deleteUnusedBlobs(ctx, keys); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("DeleteFile %s: %s", name, err.Error())
+	}
+	return item, nil
+}
+
+// deleteUnusedBlobs удаляет содержимое из storageKeys, которое не использует ни один файл.
+// Строки удаляются только если удалось удалить содержимое, как в сборке мусора
+func (srv *Service) deleteUnusedBlobs(ctx context.Context, storageKeys []string) error {
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		deleted, err := srv.fileRepo.DeleteUnusedBlobs(ctx, storageKeys)
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRepo.DeleteUnusedBlobs")
+		}
+		for _, blob := range deleted {
+			if err := srv.store.Delete(ctx, blob.StorageKey); err != nil {
+				return errors.Wrapf(err, "srv.store.Delete %s", blob.StorageKey)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,97 @@
+package file
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	apperrors "wn/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// unlimitedQuota квота не ограничена
+const unlimitedQuota int64 = -1
+
+// quota лимит пользователя в байтах и признак индивидуального лимита
+func (srv *Service) quota(ctx context.Context, userId uuid.UUID) (int64, bool, error) {
+	quota, custom, err := srv.fileRepo.GetStorageQuota(ctx, userId)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "srv.fileRepo.GetStorageQuota")
+	}
+	if custom {
+		return quota, true, nil
+	}
+	if srv.cfg.DefaultQuota > 0 {
+		return srv.cfg.DefaultQuota, false, nil
+	}
+	return unlimitedQuota, false, nil
+}
+
+func (srv *Service) usedBytes(ctx context.Context, ownerId uuid.UUID) (int64, error) {
+	usage, err := srv.fileRepo.GetStorageUsage(ctx, ownerId)
+	if err != nil {
+		return 0, errors.Wrap(err, "srv.fileRepo.GetStorageUsage")
+	}
+	var used int64
+	for _, part := range usage {
+		used += part.Bytes
+	}
+	return used, nil
+}
+
+// GetStorageUsage занятое пользователем место по назначениям файлов
+func (srv *Service) GetStorageUsage(ctx context.Context, userId uuid.UUID) (*dto.StorageUsage, error) {
+	quota, custom, err := srv.quota(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := srv.fileRepo.GetStorageUsage(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetStorageUsage")
+	}
+
+	result := &dto.StorageUsage{CustomQuota: custom}
+	for _, part := range usage {
+		result.Used += part.Bytes
+		item := dto.StorageUsagePart{Files: part.Files, Bytes: part.Bytes}
+		switch part.Purpose {
+		case enum.FilePurposeAvatar:
+			result.Avatars = item
+		case enum.FilePurposeExport:
+			result.Exports = item
+		default:
+			result.Attachments.Files += item.Files
+			result.Attachments.Bytes += item.Bytes
+		}
+	}
+	if quota != unlimitedQuota {
+		available := max(quota-result.Used, 0)
+		result.Quota, result.Available = &quota, &available
+	}
+	return result, nil
+}
+
+// SetStorageQuota задает пользователю индивидуальный лимит, nil возвращает лимит по умолчанию.
+// Уже загруженные файлы не удаляются, даже если лимит стал меньше занятого места
+func (srv *Service) SetStorageQuota(ctx context.Context, userId uuid.UUID, quota *int64) error {
+	if quota == nil {
+		return errors.Wrap(srv.fileRepo.DeleteStorageQuota(ctx, userId), "srv.fileRepo.DeleteStorageQuota")
+	}
+	if *quota < 0 {
+		return apperrors.BadQuota
+	}
+	return errors.Wrap(srv.fileRepo.SetStorageQuota(ctx, userId, *quota), "srv.fileRepo.SetStorageQuota")
+}
+
+// uploadError переводит ошибки ограничителей потока в ошибки приложения, nil для остальных ошибок
+func uploadError(err error) error {
+	switch {
+	case errors.Is(err, errFileTooLarge):
+		return apperrors.FileTooLarge
+	case errors.Is(err, errQuotaExceeded):
+		return apperrors.StorageQuotaExceeded
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,77 @@
+package file
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	"wn/pkg/blobstore"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// scanBatch сколько файлов забирается на проверку за один проход
+	scanBatch = 10
+	// scanStaleAfter через сколько зависшая проверка забирается повторно
+	scanStaleAfter = 5 * time.Minute
+)
+
+// ScanFiles проверяет антивирусом ожидающие вложения. Зараженные остаются в карантине:
+// содержимое не отдается и не обрабатывается, пока владелец не удалит файл. Так же остаются файлы без содержимого.
+// Если антивирус недоступен, файлы возвращаются в очередь и проход прерывается
+func (srv *Service) ScanFiles(ctx context.Context) (int, error) {
+	scanned := 0
+	for {
+		items, err := srv.fileRepo.ClaimScans(ctx, scanBatch, time.Now().Add(-scanStaleAfter))
+		if err != nil {
+			return scanned, errors.Wrap(err, "srv.fileRepo.ClaimScans")
+		}
+		if len(items) == 0 {
+			return scanned, nil
+		}
+		for i, item := range items {
+			state, signature, err := srv.scanFile(ctx, item)
+			if err != nil {
+				for _, rest := range items[i:] {
+					if err := srv.fileRepo.SetScanState(ctx, rest.Name, enum.ScanStatePending, ""); err != nil {
+						srv.logger.WithCtx(ctx).Warnf("ScanFiles requeue %s: %s", rest.Name, err.Error())
+					}
+				}
+				return scanned, errors.Wrapf(err, "scan %s", item.Name)
+			}
+			if state == enum.ScanStateInfected {
+				srv.logger.WithCtx(ctx).Warnf("ScanFiles: %s quarantined, owner %s, signature %s", item.Name, item.OwnerId, signature)
+			}
+			if err := srv.fileRepo.SetScanState(ctx, item.Name, state, signature); err != nil {
+				return scanned, errors.Wrap(err, "srv.fileRepo.SetScanState")
+			}
+			scanned++
+		}
+		if ctx.Err() != nil {
+			return scanned, ctx.Err()
+		}
+	}
+}
+
+func (srv *Service) scanFile(ctx context.Context, item *entity.File) (enum.ScanState, string, error) {
+	content, err := srv.store.Open(ctx, item.StorageKey)
+	// непроверенное содержимое нельзя считать чистым, даже если оно потом появится
+	if errors.Is(err, blobstore.ErrNotFound) {
+		srv.logger.WithCtx(ctx).Errorf("ScanFiles: %s quarantined, owner %s, blob %s missing", item.Name, item.OwnerId, item.StorageKey)
+		return enum.ScanStateFailed, "", nil
+	}
+	if err != nil {
+		return "", "", errors.Wrap(err, "srv.store.Open")
+	}
+	defer content.Close()
+
+	result, err := srv.scanner.Scan(ctx, content)
+	if err != nil {
+		return "", "", errors.Wrap(err, "srv.scanner.Scan")
+	}
+	if result.Infected {
+		return enum.ScanStateInfected, result.Signature, nil
+	}
+	return enum.ScanStateClean, "", nil
+}
@@ -1,116 +1,491 @@
 package file
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"io"
-	"mime/multipart"
-	"os"
+	"mime"
+	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
 	"wn/internal/infrastructure/repository/file"
 	"wn/pkg/applogger"
+	"wn/pkg/blobstore"
+	"wn/pkg/imaging"
+	"wn/pkg/scanner"
+	"wn/pkg/trx"
 	"wn/pkg/util"
 
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 )
 
-const filePath = "./statics/images/"
+const (
+	keyPrefix = "files/"
+	// sniffLen столько байт смотрит http.DetectContentType
+	sniffLen = 512
+	// legacyBatch сколько строк из базы переносится за один проход
+	legacyBatch = 50
+)
 
 type fileRepo interface {
-	GetAllFiles(ctx context.Context) ([]file.StaticFile, error)
-	CreateFile(ctx context.Context, filename string, encodedFile string) error
+	CreateFile(ctx context.Context, item *entity.File) error
+	GetFile(ctx context.Context, name string) (*entity.File, error)
+	GetLegacyFiles(ctx context.Context, limit uint64) ([]file.StaticFile, error)
+	MarkFileMoved(ctx context.Context, item *entity.File) error
+	GetVariants(ctx context.Context, parentName string) ([]*entity.File, error)
+	UpdateFileBlob(ctx context.Context, item *entity.File) error
+	ClaimImages(ctx context.Context, limit uint64, staleBefore time.Time) ([]*entity.File, error)
+	SetImageState(ctx context.Context, name string, state enum.ImageState) error
+	ClaimScans(ctx context.Context, limit uint64, staleBefore time.Time) ([]*entity.File, error)
+	SetScanState(ctx context.Context, name string, state enum.ScanState, signature string) error
+	GetNoteAttachments(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error)
+	SetNoteId(ctx context.Context, name string, noteId uuid.UUID) error
+	RegisterBlob(ctx context.Context, blob *entity.Blob) (string, error)
+	MarkOrphanFiles(ctx context.Context) error
+	GetOrphanFiles(ctx context.Context, limit uint64) ([]*entity.File, error)
+	DeleteOrphanFiles(ctx context.Context, before time.Time, limit uint64) ([]*entity.File, error)
+	MarkOrphanBlobs(ctx context.Context) error
+	GetOrphanBlobs(ctx context.Context, limit uint64) ([]*entity.Blob, error)
+	DeleteOrphanBlobs(ctx context.Context, before time.Time, limit uint64) ([]*entity.Blob, error)
+	GetStorageUsage(ctx context.Context, ownerId uuid.UUID) ([]entity.StorageUsage, error)
+	GetStorageQuota(ctx context.Context, userId uuid.UUID) (int64, bool, error)
+	SetStorageQuota(ctx context.Context, userId uuid.UUID, quota int64) error
+	DeleteStorageQuota(ctx context.Context, userId uuid.UUID) error
+	LockOwner(ctx context.Context, ownerId uuid.UUID) error
+	GetOwnerFiles(ctx context.Context, ownerId uuid.UUID, offset, limit int) ([]*entity.File, error)
+	CountOwnerFiles(ctx context.Context, ownerId uuid.UUID) (int, error)
+	GetFileReferences(ctx context.Context, names []string) (map[string]*entity.FileReferences, error)
+	DeleteFile(ctx context.Context, name string) error
+	DeleteUnusedBlobs(ctx context.Context, storageKeys []string) ([]*entity.Blob, error)
+}
+
+type Config struct {
+	MaxAvatarSize     int64
+	MaxAttachmentSize int64
+	// ThumbnailSizes размеры большей стороны миниатюр
+	ThumbnailSizes []int
+	// MaxImagePixels изображения больше не декодируются, защита от decompression bomb
+	MaxImagePixels int
+	// GCGracePeriod сколько неиспользуемый файл живет до удаления
+	GCGracePeriod time.Duration
+	// DefaultQuota лимит места на пользователя в байтах, 0 без ограничения
+	DefaultQuota int64
+	// ScanUploads вложения отдаются только после проверки антивирусом
+	ScanUploads bool
+}
+
+func NewConfig(maxAvatarSize, maxAttachmentSize int64, thumbnailSizes []int, maxImagePixels int, gcGracePeriod time.Duration, defaultQuota int64, scanUploads bool) *Config {
+	return &Config{
+		MaxAvatarSize:     maxAvatarSize,
+		MaxAttachmentSize: maxAttachmentSize,
+		ThumbnailSizes:    thumbnailSizes,
+		MaxImagePixels:    maxImagePixels,
+		GCGracePeriod:     gcGracePeriod,
+		DefaultQuota:      defaultQuota,
+		ScanUploads:       scanUploads,
+	}
+}
+
+func (c *Config) maxSize(purpose enum.FilePurpose) int64 {
+	if purpose == enum.FilePurposeAvatar {
+		return c.MaxAvatarSize
+	}
+	return c.MaxAttachmentSize
 }
 
 type Service struct {
+	tx     trx.TransactionManager
 	logger applogger.Logger
+	cfg    *Config
 
 	fileRepo fileRepo
+	store    blobstore.BlobStore
+	scanner  scanner.Scanner
 }
 
-func NewService(lgr applogger.Logger, fileRepo fileRepo) *Service {
+func NewService(tx trx.TransactionManager, lgr applogger.Logger, cfg *Config, fileRepo fileRepo, store blobstore.BlobStore, scanner scanner.Scanner) *Service {
 	return &Service{
+		tx:       tx,
 		logger:   lgr,
+		cfg:      cfg,
 		fileRepo: fileRepo,
+		store:    store,
+		scanner:  scanner,
 	}
 }
 
-func (srv *Service) GenerateStatics(ctx context.Context) error {
-	files, err := srv.fileRepo.GetAllFiles(ctx)
+// NewFile проверяет и пишет поток в хранилище, не загружая его целиком в память.
+// Тип определяется по расширению и сверяется с первыми байтами содержимого.
+// У изображений сразу вырезаются метаданные, миниатюры строит фоновая обработка.
+// Если такое содержимое уже загружалось, файл ссылается на существующий blob, а новая копия удаляется.
+// Файлы с владельцем учитываются в его квоте, загрузка обрывается, как только квота превышена.
+// Вложения при включенной проверке недоступны, пока их не проверит антивирус
+func (srv *Service) NewFile(ctx context.Context, purpose enum.FilePurpose, ownerId, noteId uuid.UUID, originalName string, r io.Reader) (*entity.File, error) {
+	ext := strings.ToLower(filepath.Ext(originalName))
+	kind, err := lookupKind(purpose, ext)
 	if err != nil {
-		return errors.Wrap(err, "srv.fileRepo.GetAllFiles")
+		return nil, err
 	}
 
-	for _, item := range files {
-		createFile(&item)
+	quota := unlimitedQuota
+	if ownerId != uuid.Nil {
+		if quota, _, err = srv.quota(ctx, ownerId); err != nil {
+			return nil, err
+		}
+	}
+	var src io.Reader = &limitedReader{r: r, left: srv.cfg.maxSize(purpose)}
+	if quota != unlimitedQuota {
+		used, err := srv.usedBytes(ctx, ownerId)
+		if err != nil {
+			return nil, err
+		}
+		if used >= quota {
+			return nil, apperrors.StorageQuotaExceeded
+		}
+		src = &limitedReader{r: src, left: quota - used, err: errQuotaExceeded}
+	}
+
+	br := bufio.NewReaderSize(src, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if appErr := uploadError(err); appErr != nil {
+		return nil, appErr
+	}
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, errors.Wrap(err, "br.Peek")
+	}
+	if len(head) == 0 {
+		return nil, apperrors.FileEmpty
+	}
+	if err := kind.checkHead(head); err != nil {
+		return nil, err
+	}
+
+	var content io.Reader = br
+	if kind.svg {
+		// svg проверяется целиком, он небольшой и ограничен лимитом размера
+		data, err := io.ReadAll(br)
+		if appErr := uploadError(err); appErr != nil {
+			return nil, appErr
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "io.ReadAll")
+		}
+		if err := checkSVG(data); err != nil {
+			return nil, err
+		}
+		content = bytes.NewReader(data)
 	}
-	return nil
-}
 
-func (srv *Service) NewFile(ctx context.Context, fileObj *multipart.FileHeader) (string, error) {
-	ext := strings.TrimPrefix(fileObj.Filename, ".")
-	filename := util.NewUUID().String() + "." + ext
-	encodedFile, err := encodeFile(fileObj)
+	var stripper *imaging.StripReader
+	if kind.image != "" {
+		stripper = imaging.NewStripReader(content, kind.image)
+		defer stripper.Close()
+		content = stripper
+	}
+
+	filename := util.NewUUID().String() + ext
+	item := &entity.File{
+		Name:        filename,
+		StorageKey:  keyPrefix + filename,
+		ContentType: kind.contentType,
+		OwnerId:     ownerId,
+		NoteId:      noteId,
+		Purpose:     purpose,
+		Orientation: 1,
+		ImageState:  enum.ImageStateNone,
+		ScanState:   enum.ScanStateClean,
+	}
+	if purpose == enum.FilePurposeAttachment && srv.cfg.ScanUploads {
+		item.ScanState = enum.ScanStatePending
+	}
+
+	hash := sha256.New()
+	counter := &countingWriter{}
+	body := io.TeeReader(content, io.MultiWriter(hash, counter))
+	if err := srv.store.Put(ctx, item.StorageKey, body, blobstore.UnknownSize, item.ContentType); err != nil {
+		if appErr := uploadError(err); appErr != nil {
+			return nil, appErr
+		}
+		if errors.Is(err, imaging.ErrBadImage) {
+			return nil, apperrors.FileTypeMismatch
+		}
+		return nil, errors.Wrap(err, "srv.store.Put")
+	}
+	item.Size = counter.n
+	item.Hash = hex.EncodeToString(hash.Sum(nil))
+	if stripper != nil {
+		item.Orientation = stripper.Orientation()
+	}
+
+	// пока миниатюры не готовы, они указывают на оригинал
+	if kind.thumbExt != "" && len(srv.cfg.ThumbnailSizes) > 0 {
+		item.ImageState = enum.ImageStatePending
+		base := strings.TrimSuffix(filename, ext)
+		for _, size := range srv.cfg.ThumbnailSizes {
+			item.Variants = append(item.Variants, &entity.File{
+				Name:        base + "_" + strconv.Itoa(size) + kind.thumbExt,
+				StorageKey:  item.StorageKey,
+				ContentType: item.ContentType,
+				Size:        item.Size,
+				Hash:        item.Hash,
+				OwnerId:     item.OwnerId,
+				NoteId:      item.NoteId,
+				Purpose:     item.Purpose,
+				ParentName:  item.Name,
+				Variant:     size,
+				Orientation: 1,
+				ImageState:  enum.ImageStatePending,
+				ScanState:   item.ScanState,
+			})
+		}
+	}
+
+	uploadedKey := item.StorageKey
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		storageKey, err := srv.fileRepo.RegisterBlob(ctx, &entity.Blob{
+			StorageKey:  uploadedKey,
+			Hash:        item.Hash,
+			Size:        item.Size,
+			ContentType: item.ContentType,
+		})
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRepo.RegisterBlob")
+		}
+		item.StorageKey = storageKey
+		// параллельные загрузки одного пользователя не должны вместе превысить квоту
+		if quota != unlimitedQuota {
+			if err := srv.fileRepo.LockOwner(ctx, ownerId); err != nil {
+				return errors.Wrap(err, "srv.fileRepo.LockOwner")
+			}
+			used, err := srv.usedBytes(ctx, ownerId)
+			if err != nil {
+				return err
+			}
+			if used+item.Size > quota {
+				return apperrors.StorageQuotaExceeded
+			}
+		}
+		if err := srv.fileRepo.CreateFile(ctx, item); err != nil {
+			return errors.Wrap(err, "srv.fileRepo.CreateFile")
+		}
+		for _, variant := range item.Variants {
+			variant.StorageKey = storageKey
+			if err := srv.fileRepo.CreateFile(ctx, variant); err != nil {
+				return errors.Wrap(err, "srv.fileRepo.CreateFile variant")
+			}
+		}
+		return nil
+	})
+	if err != nil || item.StorageKey != uploadedKey {
+		if delErr := srv.store.Delete(ctx, uploadedKey); delErr != nil {
+			srv.logger.WithCtx(ctx).Warnf("NewFile delete unused blob: %s", delErr.Error())
+		}
+	}
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	return item, nil
+}
+
+// CheckUpload проверяет то, что известно до начала загрузки: расширение, заявленный размер и квоту.
+// Содержимое проверит NewFile, когда загрузка завершится
+func (srv *Service) CheckUpload(ctx context.Context, purpose enum.FilePurpose, ownerId uuid.UUID, originalName string, size int64) error {
+	if _, err := lookupKind(purpose, strings.ToLower(filepath.Ext(originalName))); err != nil {
+		return err
+	}
+	if size <= 0 {
+		return apperrors.FileEmpty
 	}
-	err = srv.fileRepo.CreateFile(ctx, filename, encodedFile)
+	if size > srv.cfg.maxSize(purpose) {
+		return apperrors.FileTooLarge
+	}
+	quota, _, err := srv.quota(ctx, ownerId)
+	if err != nil || quota == unlimitedQuota {
+		return err
+	}
+	used, err := srv.usedBytes(ctx, ownerId)
 	if err != nil {
-		return filename, err
+		return err
 	}
-	f := &file.StaticFile{
-		Filename:     filename,
-		FileAsString: encodedFile,
-		File:         []byte{},
+	if used+size > quota {
+		return apperrors.StorageQuotaExceeded
 	}
-	return filename, createFile(f)
+	return nil
 }
 
-func createFile(item *file.StaticFile) error {
-	var err error
-	if item.FileAsString == "" {
-		return errors.Wrap(err, "item.FileAsString")
+// MaxSize наибольший допустимый размер файла
+func (srv *Service) MaxSize(purpose enum.FilePurpose) int64 {
+	return srv.cfg.maxSize(purpose)
+}
+
+func (srv *Service) GetFile(ctx context.Context, name string) (*entity.File, error) {
+	return srv.fileRepo.GetFile(ctx, name)
+}
+
+// GetFileWithVariants файл вместе с миниатюрами
+func (srv *Service) GetFileWithVariants(ctx context.Context, name string) (*entity.File, error) {
+	item, err := srv.fileRepo.GetFile(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if item.Variants, err = srv.fileRepo.GetVariants(ctx, name); err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetVariants")
 	}
+	return item, nil
+}
 
-	item.File, err = base64.RawStdEncoding.DecodeString(item.FileAsString)
+// OpenContent открывает содержимое файла. Вызывающий закрывает reader
+func (srv *Service) OpenContent(ctx context.Context, item *entity.File) (io.ReadSeekCloser, error) {
+	content, err := srv.store.Open(ctx, item.StorageKey)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		srv.logger.WithCtx(ctx).Warnf("OpenContent: blob %s missing", item.StorageKey)
+		return nil, apperrors.FileNotFound
+	}
 	if err != nil {
-		return errors.Wrap(err, "base64.RawStdEncoding.DecodeString")
+		return nil, errors.Wrap(err, "srv.store.Open")
 	}
+	return content, nil
+}
 
-	fullPath := filepath.Join(filePath, item.Filename)
+// GetNoteAttachments файлы заметки вместе с миниатюрами
+func (srv *Service) GetNoteAttachments(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error) {
+	items, err := srv.fileRepo.GetNoteAttachments(ctx, noteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.fileRepo.GetNoteAttachments")
+	}
+	for _, item := range items {
+		if item.Variants, err = srv.fileRepo.GetVariants(ctx, item.Name); err != nil {
+			return nil, errors.Wrap(err, "srv.fileRepo.GetVariants")
+		}
+	}
+	return items, nil
+}
 
-	// Проверяем существование файла
-	if _, err := os.Stat(fullPath); err == nil {
+// AttachFile прикрепляет к заметке загруженное пользователем вложение
+func (srv *Service) AttachFile(ctx context.Context, name string, noteId, userId uuid.UUID) error {
+	item, err := srv.fileRepo.GetFile(ctx, name)
+	if err != nil {
+		return err
+	}
+	if item.OwnerId != userId {
+		return apperrors.FileAccessDenied
+	}
+	if item.Purpose != enum.FilePurposeAttachment || item.ParentName != "" {
+		return apperrors.FileNotAttachable
+	}
+	if item.NoteId == noteId {
+		return nil
+	}
+	if item.NoteId != uuid.Nil {
+		return apperrors.FileAlreadyAttached
+	}
+	return srv.fileRepo.SetNoteId(ctx, name, noteId)
+}
 
-	} else if !os.IsNotExist(err) {
-		return errors.Wrap(err, "os.IsNotExist")
+// DetachFile открепляет файл от заметки, сам файл остается у владельца
+func (srv *Service) DetachFile(ctx context.Context, name string, noteId uuid.UUID) error {
+	item, err := srv.fileRepo.GetFile(ctx, name)
+	if err != nil {
+		return err
+	}
+	if item.NoteId != noteId {
+		return apperrors.FileNotFound
 	}
+	return srv.fileRepo.SetNoteId(ctx, name, uuid.Nil)
+}
 
-	// Атомарная запись файла
-	tempPath := fullPath + ".tmp"
-	if err := os.WriteFile(tempPath, item.File, 0644); err != nil {
-		return errors.Wrap(err, "os.WriteFile")
+// MoveLegacyFiles переносит файлы, которые раньше хранились в базе в base64, в хранилище
+func (srv *Service) MoveLegacyFiles(ctx context.Context) (int, error) {
+	moved := 0
+	for {
+		files, err := srv.fileRepo.GetLegacyFiles(ctx, legacyBatch)
+		if err != nil {
+			return moved, errors.Wrap(err, "srv.fileRepo.GetLegacyFiles")
+		}
+		if len(files) == 0 {
+			return moved, nil
+		}
+		for _, legacy := range files {
+			if err := srv.moveLegacyFile(ctx, &legacy); err != nil {
+				return moved, errors.Wrapf(err, "moveLegacyFile %s", legacy.Filename)
+			}
+			moved++
+		}
 	}
+}
 
-	// Переименовываем временный файл в целевой
-	if err := os.Rename(tempPath, fullPath); err != nil {
-		return errors.Wrap(err, "os.Rename(tempPath, fullPath)")
+func (srv *Service) moveLegacyFile(ctx context.Context, legacy *file.StaticFile) error {
+	data, err := base64.RawStdEncoding.DecodeString(legacy.FileAsString)
+	if err != nil {
+		return errors.Wrap(err, "base64.RawStdEncoding.DecodeString")
+	}
+	sum := sha256.Sum256(data)
+	item := &entity.File{
+		Name:        legacy.Filename,
+		StorageKey:  keyPrefix + legacy.Filename,
+		ContentType: detectContentType(legacy.Filename, data),
+		Size:        int64(len(data)),
+		Hash:        hex.EncodeToString(sum[:]),
+	}
+	if err := srv.store.Put(ctx, item.StorageKey, bytes.NewReader(data), item.Size, item.ContentType); err != nil {
+		return errors.Wrap(err, "srv.store.Put")
 	}
 
+	uploadedKey := item.StorageKey
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		storageKey, err := srv.fileRepo.RegisterBlob(ctx, &entity.Blob{
+			StorageKey:  uploadedKey,
+			Hash:        item.Hash,
+			Size:        item.Size,
+			ContentType: item.ContentType,
+		})
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRepo.RegisterBlob")
+		}
+		item.StorageKey = storageKey
+		return srv.fileRepo.MarkFileMoved(ctx, item)
+	})
+	if err != nil {
+		return err
+	}
+	if item.StorageKey != uploadedKey {
+		if err := srv.store.Delete(ctx, uploadedKey); err != nil {
+			srv.logger.WithCtx(ctx).Warnf("moveLegacyFile delete duplicate blob: %s", err.Error())
+		}
+	}
 	return nil
 }
 
-func encodeFile(fileHeader *multipart.FileHeader) (string, error) {
-	file, err := fileHeader.Open()
-	if err != nil {
-		return "", err
+// detectContentType определяет тип по содержимому, расширение используется, если сниффинг ничего не дал
+func detectContentType(filename string, head []byte) string {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
 	}
-	defer file.Close()
-	fileData, err := io.ReadAll(file)
-	if err != nil {
-		return "", err
+	contentType := http.DetectContentType(head)
+	if contentType == "application/octet-stream" || strings.HasPrefix(contentType, "text/plain") {
+		if byExt := mime.TypeByExtension(filepath.Ext(filename)); byExt != "" {
+			return byExt
+		}
 	}
-	encodedFile := base64.RawStdEncoding.EncodeToString(fileData)
-	return encodedFile, nil
+	return contentType
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
 }
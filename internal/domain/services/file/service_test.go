@@ -0,0 +1,896 @@
+package file_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"wn/internal/domain/enum"
+	filesrv "wn/internal/domain/services/file"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/infrastructure/repository/file"
+	"wn/internal/testutil"
+	"wn/pkg/blobstore"
+	"wn/pkg/scanner"
+
+	"github.com/google/uuid"
+)
+
+type memoryRepo struct {
+	files map[string]*entity.File
+	blobs map[string]*entity.Blob
+	// used имена файлов, на которые ссылаются пользователи или заметки
+	used   map[string]bool
+	quotas map[uuid.UUID]int64
+	// avatars и mentions ссылки, которые видит удаление файла
+	avatars  map[string]bool
+	mentions map[string][]uuid.UUID
+}
+
+func (r *memoryRepo) CreateFile(_ context.Context, item *entity.File) error {
+	r.files[item.Name] = item
+	return nil
+}
+
+func (r *memoryRepo) GetFile(_ context.Context, name string) (*entity.File, error) {
+	item, ok := r.files[name]
+	if !ok {
+		return nil, apperrors.FileNotFound
+	}
+	return item, nil
+}
+
+func (r *memoryRepo) GetLegacyFiles(context.Context, uint64) ([]file.StaticFile, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) MarkFileMoved(context.Context, *entity.File) error {
+	return nil
+}
+
+func (r *memoryRepo) GetVariants(_ context.Context, parentName string) ([]*entity.File, error) {
+	var variants []*entity.File
+	for _, item := range r.files {
+		if item.ParentName == parentName {
+			variants = append(variants, item)
+		}
+	}
+	return variants, nil
+}
+
+func (r *memoryRepo) UpdateFileBlob(_ context.Context, item *entity.File) error {
+	r.files[item.Name] = item
+	return nil
+}
+
+func (r *memoryRepo) ClaimImages(context.Context, uint64, time.Time) ([]*entity.File, error) {
+	var items []*entity.File
+	for _, item := range r.files {
+		if item.ParentName == "" && item.ScanState == enum.ScanStateClean && item.ImageState == enum.ImageStatePending {
+			item.ImageState = enum.ImageStateProcessing
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryRepo) ClaimScans(context.Context, uint64, time.Time) ([]*entity.File, error) {
+	var items []*entity.File
+	for _, item := range r.files {
+		if item.ParentName == "" && item.ScanState == enum.ScanStatePending {
+			item.ScanState = enum.ScanStateScanning
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryRepo) SetScanState(_ context.Context, name string, state enum.ScanState, signature string) error {
+	for _, item := range r.files {
+		if item.Name == name || item.ParentName == name {
+			item.ScanState, item.ScanSignature = state, signature
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) GetNoteAttachments(_ context.Context, noteId uuid.UUID) ([]*entity.File, error) {
+	var items []*entity.File
+	for _, item := range r.files {
+		if item.NoteId == noteId && item.ParentName == "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryRepo) SetNoteId(_ context.Context, name string, noteId uuid.UUID) error {
+	for _, item := range r.files {
+		if item.Name == name || item.ParentName == name {
+			item.NoteId = noteId
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) RegisterBlob(_ context.Context, blob *entity.Blob) (string, error) {
+	for _, existing := range r.blobs {
+		if existing.Hash == blob.Hash {
+			existing.OrphanedAt = time.Time{}
+			return existing.StorageKey, nil
+		}
+	}
+	r.blobs[blob.StorageKey] = blob
+	return blob.StorageKey, nil
+}
+
+func (r *memoryRepo) orphanFile(item *entity.File) bool {
+	return item.ParentName == "" && item.OwnerId != uuid.Nil && item.NoteId == uuid.Nil && !r.used[item.Name]
+}
+
+func (r *memoryRepo) MarkOrphanFiles(context.Context) error {
+	for _, item := range r.files {
+		switch {
+		case !r.orphanFile(item):
+			item.OrphanedAt = time.Time{}
+		case item.OrphanedAt.IsZero():
+			item.OrphanedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) GetOrphanFiles(context.Context, uint64) ([]*entity.File, error) {
+	var items []*entity.File
+	for _, item := range r.files {
+		if r.orphanFile(item) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryRepo) DeleteOrphanFiles(_ context.Context, before time.Time, _ uint64) ([]*entity.File, error) {
+	var deleted []*entity.File
+	for name, item := range r.files {
+		if r.orphanFile(item) && !item.OrphanedAt.IsZero() && !item.OrphanedAt.After(before) {
+			deleted = append(deleted, item)
+			delete(r.files, name)
+		}
+	}
+	for name, item := range r.files {
+		if _, ok := r.files[item.ParentName]; item.ParentName != "" && !ok {
+			delete(r.files, name)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryRepo) blobUsed(key string) bool {
+	for _, item := range r.files {
+		if item.StorageKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *memoryRepo) MarkOrphanBlobs(context.Context) error {
+	for _, blob := range r.blobs {
+		switch {
+		case r.blobUsed(blob.StorageKey):
+			blob.OrphanedAt = time.Time{}
+		case blob.OrphanedAt.IsZero():
+			blob.OrphanedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *memoryRepo) GetOrphanBlobs(context.Context, uint64) ([]*entity.Blob, error) {
+	var blobs []*entity.Blob
+	for _, blob := range r.blobs {
+		if !r.blobUsed(blob.StorageKey) {
+			blobs = append(blobs, blob)
+		}
+	}
+	return blobs, nil
+}
+
+func (r *memoryRepo) DeleteOrphanBlobs(_ context.Context, before time.Time, _ uint64) ([]*entity.Blob, error) {
+	var deleted []*entity.Blob
+	for key, blob := range r.blobs {
+		if !r.blobUsed(key) && !blob.OrphanedAt.IsZero() && !blob.OrphanedAt.After(before) {
+			deleted = append(deleted, blob)
+			delete(r.blobs, key)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryRepo) GetStorageUsage(_ context.Context, ownerId uuid.UUID) ([]entity.StorageUsage, error) {
+	parts := map[enum.FilePurpose]*entity.StorageUsage{}
+	for _, item := range r.files {
+		if item.OwnerId != ownerId || item.ParentName != "" {
+			continue
+		}
+		if parts[item.Purpose] == nil {
+			parts[item.Purpose] = &entity.StorageUsage{Purpose: item.Purpose}
+		}
+		parts[item.Purpose].Files++
+		parts[item.Purpose].Bytes += item.Size
+	}
+	var usage []entity.StorageUsage
+	for _, part := range parts {
+		usage = append(usage, *part)
+	}
+	return usage, nil
+}
+
+func (r *memoryRepo) GetStorageQuota(_ context.Context, userId uuid.UUID) (int64, bool, error) {
+	quota, ok := r.quotas[userId]
+	return quota, ok, nil
+}
+
+func (r *memoryRepo) SetStorageQuota(_ context.Context, userId uuid.UUID, quota int64) error {
+	r.quotas[userId] = quota
+	return nil
+}
+
+func (r *memoryRepo) DeleteStorageQuota(_ context.Context, userId uuid.UUID) error {
+	delete(r.quotas, userId)
+	return nil
+}
+
+func (r *memoryRepo) LockOwner(context.Context, uuid.UUID) error {
+	return nil
+}
+
+func (r *memoryRepo) SetImageState(_ context.Context, name string, state enum.ImageState) error {
+	r.files[name].ImageState = state
+	return nil
+}
+
+func (r *memoryRepo) GetOwnerFiles(_ context.Context, ownerId uuid.UUID, _, _ int) ([]*entity.File, error) {
+	var items []*entity.File
+	for _, item := range r.files {
+		if item.OwnerId == ownerId && item.ParentName == "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *memoryRepo) CountOwnerFiles(ctx context.Context, ownerId uuid.UUID) (int, error) {
+	items, err := r.GetOwnerFiles(ctx, ownerId, 0, 0)
+	return len(items), err
+}
+
+func (r *memoryRepo) GetFileReferences(_ context.Context, names []string) (map[string]*entity.FileReferences, error) {
+	refs := map[string]*entity.FileReferences{}
+	for _, name := range names {
+		refs[name] = &entity.FileReferences{Avatar: r.avatars[name], Notes: r.mentions[name]}
+	}
+	return refs, nil
+}
+
+func (r *memoryRepo) DeleteUnusedBlobs(_ context.Context, storageKeys []string) ([]*entity.Blob, error) {
+	var deleted []*entity.Blob
+	for _, key := range storageKeys {
+		if blob, ok := r.blobs[key]; ok && !r.blobUsed(key) {
+			deleted = append(deleted, blob)
+			delete(r.blobs, key)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *memoryRepo) DeleteFile(_ context.Context, name string) error {
+	for key, item := range r.files {
+		if item.Name == name || item.ParentName == name {
+			delete(r.files, key)
+		}
+	}
+	return nil
+}
+
+// flakyStore локальное хранилище, Open которого возвращает openErr, пока она задана
+type flakyStore struct {
+	blobstore.BlobStore
+	openErr error
+}
+
+func (s *flakyStore) Open(ctx context.Context, key string) (io.ReadSeekCloser, error) {
+	if s.openErr != nil {
+		return nil, s.openErr
+	}
+	return s.BlobStore.Open(ctx, key)
+}
+
+func newService(t *testing.T) (*filesrv.Service, *memoryRepo, *flakyStore) {
+	return newServiceWith(t, func(*filesrv.Config) {})
+}
+
+func newServiceWith(t *testing.T, configure func(cfg *filesrv.Config)) (*filesrv.Service, *memoryRepo, *flakyStore) {
+	return newScanningService(t, configure, scanner.Noop{})
+}
+
+func newScanningService(t *testing.T, configure func(cfg *filesrv.Config), sc scanner.Scanner) (*filesrv.Service, *memoryRepo, *flakyStore) {
+	lgr := testutil.Logger(t)
+	local, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	store := &flakyStore{BlobStore: local}
+	repo := &memoryRepo{
+		files:  map[string]*entity.File{},
+		blobs:  map[string]*entity.Blob{},
+		used:   map[string]bool{},
+		quotas: map[uuid.UUID]int64{},
+
+		avatars:  map[string]bool{},
+		mentions: map[string][]uuid.UUID{},
+	}
+	cfg := filesrv.NewConfig(1024, 4096, []int{8, 64}, 1<<20, time.Hour, 0, false)
+	configure(cfg)
+	return filesrv.NewService(testutil.NoTx{}, lgr, cfg, repo, store, sc), repo, store
+}
+
+func pngBytes(t *testing.T, w, h int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestNewFile(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("stores allowed file with sniffed type", func(t *testing.T) {
+		srv, repo, _ := newService(t)
+		created, err := srv.NewFile(ctx, enum.FilePurposeAvatar, uuid.New(), uuid.Nil, "../../Me.PNG", bytes.NewReader(pngBytes(t, 4, 4)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if !strings.HasSuffix(created.Name, ".png") || strings.Contains(created.Name, "/") {
+			t.Errorf("NewFile() name = %q", created.Name)
+		}
+		item := repo.files[created.Name]
+		if item.ContentType != "image/png" || item.Size == 0 || len(item.Hash) != 64 {
+			t.Errorf("stored file = %+v", item)
+		}
+		if item.ImageState != enum.ImageStatePending || len(created.Variants) != 2 {
+			t.Errorf("image state = %s, variants = %d", item.ImageState, len(created.Variants))
+		}
+	})
+
+	rejections := []struct {
+		name    string
+		purpose enum.FilePurpose
+		file    string
+		content string
+		want    error
+	}{
+		{"empty file", enum.FilePurposeAttachment, "a.txt", "", apperrors.FileEmpty},
+		{"extension outside allowlist", enum.FilePurposeAttachment, "a.exe", "MZ", apperrors.FileExtensionNotAllowed},
+		{"pdf is not an avatar", enum.FilePurposeAvatar, "a.pdf", "%PDF-1.4", apperrors.FileExtensionNotAllowed},
+		{"content does not match extension", enum.FilePurposeAttachment, "a.png", "%PDF-1.4", apperrors.FileTypeMismatch},
+		{"html disguised as text", enum.FilePurposeAttachment, "a.txt", "<html><script>alert(1)</script>", apperrors.FileUnsafe},
+		{"svg with script", enum.FilePurposeAttachment, "a.svg", `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script></svg>`, apperrors.FileUnsafe},
+		{"svg with handler", enum.FilePurposeAttachment, "a.svg", `<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`, apperrors.FileUnsafe},
+		{"svg with external href", enum.FilePurposeAttachment, "a.svg", `<svg xmlns="http://www.w3.org/2000/svg"><image href="https://evil/x.png"/></svg>`, apperrors.FileUnsafe},
+		{"svg with doctype", enum.FilePurposeAttachment, "a.svg", `<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY x "y">]><svg/>`, apperrors.FileUnsafe},
+		{"svg as avatar", enum.FilePurposeAvatar, "a.svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`, apperrors.FileExtensionNotAllowed},
+		{"avatar over its own limit", enum.FilePurposeAvatar, "a.png", "\x89PNG\r\n\x1a\n" + strings.Repeat("a", 2048), apperrors.FileTooLarge},
+		{"too large attachment", enum.FilePurposeAttachment, "a.txt", strings.Repeat("a", 5000), apperrors.FileTooLarge},
+	}
+	for _, tc := range rejections {
+		t.Run(tc.name, func(t *testing.T) {
+			srv, repo, _ := newService(t)
+			_, err := srv.NewFile(ctx, tc.purpose, uuid.New(), uuid.Nil, tc.file, strings.NewReader(tc.content))
+			if !errors.Is(err, tc.want) {
+				t.Errorf("NewFile() error = %v, want %v", err, tc.want)
+			}
+			if len(repo.files) != 0 {
+				t.Errorf("rejected file was stored")
+			}
+		})
+	}
+
+	t.Run("accepts static svg", func(t *testing.T) {
+		srv, repo, _ := newService(t)
+		svg := `<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><defs><linearGradient id="g"/></defs>` +
+			`<rect fill="url(#g)" width="4" height="4"/><use href="#g"/></svg>`
+		created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "pic.svg", strings.NewReader(svg))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if created.ContentType != "image/svg+xml" || len(created.Variants) != 0 {
+			t.Errorf("created = %+v", created)
+		}
+		if len(repo.files) != 1 {
+			t.Errorf("stored %d files, want 1", len(repo.files))
+		}
+	})
+}
+
+func TestProcessImages(t *testing.T) {
+	ctx := context.Background()
+	srv, repo, store := newService(t)
+
+	created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "wide.png", bytes.NewReader(pngBytes(t, 200, 50)))
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+	processed, err := srv.ProcessImages(ctx)
+	if err != nil || processed != 1 {
+		t.Fatalf("ProcessImages() = %d, %v", processed, err)
+	}
+	if !repo.files[created.Name].Settled() {
+		t.Errorf("image state = %s, want READY", repo.files[created.Name].ImageState)
+	}
+
+	for _, variant := range created.Variants {
+		stored := repo.files[variant.Name]
+		if !stored.Settled() {
+			t.Errorf("variant %s state = %s", variant.Name, stored.ImageState)
+		}
+		if stored.StorageKey == repo.files[created.Name].StorageKey {
+			t.Fatalf("variant %s still points to original", variant.Name)
+		}
+		content, err := store.Open(ctx, stored.StorageKey)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", stored.StorageKey, err)
+		}
+		img, err := png.Decode(content)
+		content.Close()
+		if err != nil {
+			t.Fatalf("variant %s does not decode: %v", variant.Name, err)
+		}
+		want := min(variant.Variant, 200)
+		if img.Bounds().Dx() != want || img.Bounds().Dy() != want/4 {
+			t.Errorf("variant %d size = %v", variant.Variant, img.Bounds())
+		}
+	}
+}
+
+func TestProcessImagesFailures(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("storage unavailable", func(t *testing.T) {
+		srv, repo, store := newService(t)
+		created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "a.png", bytes.NewReader(pngBytes(t, 20, 20)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		// проход прерывается, а не забирает тот же файл снова и снова
+		store.openErr = errors.New("connection refused")
+		done := make(chan error, 1)
+		go func() {
+			_, err := srv.ProcessImages(ctx)
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Fatal("ProcessImages() without storage succeeded")
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("ProcessImages() does not return")
+		}
+		if got := repo.files[created.Name].ImageState; got != enum.ImageStatePending {
+			t.Fatalf("image state = %s, want PENDING", got)
+		}
+
+		store.openErr = nil
+		if processed, err := srv.ProcessImages(ctx); err != nil || processed != 1 {
+			t.Fatalf("ProcessImages() after recovery = %d, %v, want 1", processed, err)
+		}
+	})
+
+	t.Run("missing blob", func(t *testing.T) {
+		srv, repo, store := newService(t)
+		created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "a.png", bytes.NewReader(pngBytes(t, 20, 20)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if err := store.Delete(ctx, created.StorageKey); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if processed, err := srv.ProcessImages(ctx); err != nil || processed != 0 {
+			t.Fatalf("ProcessImages() = %d, %v, want 0", processed, err)
+		}
+		if got := repo.files[created.Name].ImageState; got != enum.ImageStateFailed {
+			t.Fatalf("image state = %s, want FAILED", got)
+		}
+	})
+}
+
+func TestAttachFile(t *testing.T) {
+	ctx := context.Background()
+	srv, repo, _ := newService(t)
+	owner, noteId := uuid.New(), uuid.New()
+
+	created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "wide.png", bytes.NewReader(pngBytes(t, 20, 20)))
+	if err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+
+	t.Run("not owner", func(t *testing.T) {
+		err := srv.AttachFile(ctx, created.Name, noteId, uuid.New())
+		if !errors.Is(err, apperrors.FileAccessDenied) {
+			t.Errorf("AttachFile() error = %v, want %v", err, apperrors.FileAccessDenied)
+		}
+	})
+
+	t.Run("variant", func(t *testing.T) {
+		err := srv.AttachFile(ctx, created.Variants[0].Name, noteId, owner)
+		if !errors.Is(err, apperrors.FileNotAttachable) {
+			t.Errorf("AttachFile() error = %v, want %v", err, apperrors.FileNotAttachable)
+		}
+	})
+
+	t.Run("attach and detach", func(t *testing.T) {
+		if err := srv.AttachFile(ctx, created.Name, noteId, owner); err != nil {
+			t.Fatalf("AttachFile() error = %v", err)
+		}
+		attachments, err := srv.GetNoteAttachments(ctx, noteId)
+		if err != nil || len(attachments) != 1 || len(attachments[0].Variants) != len(created.Variants) {
+			t.Fatalf("GetNoteAttachments() = %v, %v", attachments, err)
+		}
+		if repo.files[created.Variants[0].Name].NoteId != noteId {
+			t.Errorf("variant is not attached")
+		}
+		if err := srv.AttachFile(ctx, created.Name, uuid.New(), owner); !errors.Is(err, apperrors.FileAlreadyAttached) {
+			t.Errorf("AttachFile() other note error = %v", err)
+		}
+		if err := srv.DetachFile(ctx, created.Name, noteId); err != nil {
+			t.Fatalf("DetachFile() error = %v", err)
+		}
+		if repo.files[created.Name].NoteId != uuid.Nil {
+			t.Errorf("file is still attached")
+		}
+	})
+}
+
+func TestCollectGarbage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("dedup", func(t *testing.T) {
+		srv, repo, store := newService(t)
+		first, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "a.txt", strings.NewReader("same content"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		second, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "b.txt", strings.NewReader("same content"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if first.StorageKey != second.StorageKey || len(repo.blobs) != 1 {
+			t.Fatalf("storage keys %q and %q, blobs %d", first.StorageKey, second.StorageKey, len(repo.blobs))
+		}
+		if _, err := store.Open(ctx, "files/"+second.Name); !errors.Is(err, blobstore.ErrNotFound) {
+			t.Errorf("duplicate upload is kept in store: %v", err)
+		}
+	})
+
+	t.Run("grace period", func(t *testing.T) {
+		srv, repo, _ := newService(t)
+		if _, err := srv.NewFile(ctx, enum.FilePurposeAttachment, uuid.New(), uuid.Nil, "a.txt", strings.NewReader("data")); err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		files, blobs, err := srv.CollectGarbage(ctx)
+		if err != nil || files != 0 || blobs != 0 {
+			t.Fatalf("CollectGarbage() = %d, %d, %v", files, blobs, err)
+		}
+		if len(repo.files) != 1 {
+			t.Errorf("file deleted before grace period")
+		}
+	})
+
+	t.Run("delete unused", func(t *testing.T) {
+		srv, repo, store := newServiceWith(t, func(cfg *filesrv.Config) { cfg.GCGracePeriod = 0 })
+		owner := uuid.New()
+		unused, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "pic.png", bytes.NewReader(pngBytes(t, 20, 20)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		avatar, err := srv.NewFile(ctx, enum.FilePurposeAvatar, owner, uuid.Nil, "me.png", bytes.NewReader(pngBytes(t, 30, 30)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		attached, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.New(), "doc.txt", strings.NewReader("doc"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		repo.used[avatar.Name] = true
+
+		report, err := srv.GarbageReport(ctx)
+		if err != nil || len(report.Files) != 1 || report.Files[0].Name != unused.Name || !report.Files[0].Due {
+			t.Fatalf("GarbageReport() = %+v, %v", report, err)
+		}
+		if len(repo.files) != 2*3+1 {
+			t.Fatalf("GarbageReport() changed files")
+		}
+
+		// первый проход удаляет файл, второй освободившееся содержимое
+		if files, _, err := srv.CollectGarbage(ctx); err != nil || files != 1 {
+			t.Fatalf("CollectGarbage() files = %d, %v", files, err)
+		}
+		if _, blobs, err := srv.CollectGarbage(ctx); err != nil || blobs != 1 {
+			t.Fatalf("CollectGarbage() blobs = %d, %v", blobs, err)
+		}
+		for name := range repo.files {
+			if strings.HasPrefix(name, strings.TrimSuffix(unused.Name, ".png")) {
+				t.Errorf("file %s is not deleted", name)
+			}
+		}
+		if _, err := store.Open(ctx, unused.StorageKey); !errors.Is(err, blobstore.ErrNotFound) {
+			t.Errorf("blob of deleted file is kept: %v", err)
+		}
+		for _, kept := range []*entity.File{avatar, attached} {
+			if _, ok := repo.files[kept.Name]; !ok {
+				t.Errorf("used file %s is deleted", kept.Name)
+			}
+		}
+	})
+}
+
+func TestStorageQuota(t *testing.T) {
+	ctx := context.Background()
+	srv, repo, _ := newServiceWith(t, func(cfg *filesrv.Config) { cfg.DefaultQuota = 3000 })
+	owner := uuid.New()
+
+	if _, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.txt", strings.NewReader(strings.Repeat("a", 2000))); err != nil {
+		t.Fatalf("NewFile() error = %v", err)
+	}
+
+	t.Run("exceeded while streaming", func(t *testing.T) {
+		_, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "b.txt", strings.NewReader(strings.Repeat("b", 1500)))
+		if !errors.Is(err, apperrors.StorageQuotaExceeded) {
+			t.Fatalf("NewFile() error = %v, want %v", err, apperrors.StorageQuotaExceeded)
+		}
+		if len(repo.files) != 1 || len(repo.blobs) != 1 {
+			t.Errorf("rejected upload is stored: %d files, %d blobs", len(repo.files), len(repo.blobs))
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		usage, err := srv.GetStorageUsage(ctx, owner)
+		if err != nil {
+			t.Fatalf("GetStorageUsage() error = %v", err)
+		}
+		if usage.Used != 2000 || usage.Attachments.Files != 1 || usage.Quota == nil || *usage.Available != 1000 {
+			t.Errorf("GetStorageUsage() = %+v", usage)
+		}
+	})
+
+	t.Run("override", func(t *testing.T) {
+		blocked := int64(0)
+		if err := srv.SetStorageQuota(ctx, owner, &blocked); err != nil {
+			t.Fatalf("SetStorageQuota() error = %v", err)
+		}
+		_, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "c.txt", strings.NewReader("c"))
+		if !errors.Is(err, apperrors.StorageQuotaExceeded) {
+			t.Errorf("NewFile() error = %v, want %v", err, apperrors.StorageQuotaExceeded)
+		}
+
+		if err := srv.SetStorageQuota(ctx, owner, nil); err != nil {
+			t.Fatalf("SetStorageQuota() error = %v", err)
+		}
+		if _, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "c.txt", strings.NewReader("c")); err != nil {
+			t.Errorf("NewFile() after reset error = %v", err)
+		}
+	})
+}
+
+func TestDeleteFile(t *testing.T) {
+	ctx := context.Background()
+	srv, repo, store := newServiceWith(t, func(cfg *filesrv.Config) { cfg.GCGracePeriod = 0 })
+	owner := uuid.New()
+	upload := func(content string) *entity.File {
+		created, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.txt", strings.NewReader(content))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		return created
+	}
+
+	t.Run("not owner", func(t *testing.T) {
+		created := upload("foreign")
+		if _, err := srv.DeleteFile(ctx, created.Name, uuid.New(), true); !errors.Is(err, apperrors.FileAccessDenied) {
+			t.Errorf("DeleteFile() error = %v, want %v", err, apperrors.FileAccessDenied)
+		}
+	})
+
+	t.Run("avatar", func(t *testing.T) {
+		created := upload("avatar")
+		repo.avatars[created.Name] = true
+		if _, err := srv.DeleteFile(ctx, created.Name, owner, true); !errors.Is(err, apperrors.FileInUse) {
+			t.Errorf("DeleteFile() error = %v, want %v", err, apperrors.FileInUse)
+		}
+	})
+
+	t.Run("referenced by note", func(t *testing.T) {
+		created := upload("mentioned")
+		noteId := uuid.New()
+		repo.mentions[created.Name] = []uuid.UUID{noteId}
+		if _, err := srv.DeleteFile(ctx, created.Name, owner, false); !errors.Is(err, apperrors.FileInUse) {
+			t.Fatalf("DeleteFile() error = %v, want %v", err, apperrors.FileInUse)
+		}
+		deleted, err := srv.DeleteFile(ctx, created.Name, owner, true)
+		if err != nil {
+			t.Fatalf("DeleteFile() force error = %v", err)
+		}
+		if len(deleted.References.Notes) != 1 || deleted.References.Notes[0] != noteId {
+			t.Errorf("References.Notes = %v, want [%s]", deleted.References.Notes, noteId)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		items, count, err := srv.GetOwnerFiles(ctx, owner, 1)
+		if err != nil {
+			t.Fatalf("GetOwnerFiles() error = %v", err)
+		}
+		if count != len(items) || count != 2 {
+			t.Fatalf("GetOwnerFiles() = %d files, count %d, want 2", len(items), count)
+		}
+		avatars := 0
+		for _, item := range items {
+			if item.References.Avatar {
+				avatars++
+			}
+		}
+		if avatars != 1 {
+			t.Errorf("avatars = %d, want 1", avatars)
+		}
+	})
+
+	t.Run("content deleted with last file", func(t *testing.T) {
+		first, second := upload("same"), upload("same")
+		if _, err := srv.DeleteFile(ctx, first.Name, owner, false); err != nil {
+			t.Fatalf("DeleteFile() error = %v", err)
+		}
+		if _, ok := repo.files[first.Name]; ok {
+			t.Errorf("file is not deleted")
+		}
+		if _, err := store.Open(ctx, second.StorageKey); err != nil {
+			t.Errorf("shared blob is deleted: %v", err)
+		}
+
+		// последний файл уносит содержимое сразу, без сборки мусора
+		if _, err := srv.DeleteFile(ctx, second.Name, owner, false); err != nil {
+			t.Fatalf("DeleteFile() error = %v", err)
+		}
+		if _, ok := repo.blobs[second.StorageKey]; ok {
+			t.Errorf("blob row is not deleted")
+		}
+		if _, err := store.Open(ctx, second.StorageKey); !errors.Is(err, blobstore.ErrNotFound) {
+			t.Errorf("Open() deleted blob error = %v, want %v", err, blobstore.ErrNotFound)
+		}
+	})
+}
+
+// fakeScanner находит сигнатуру EICAR, с err антивирус недоступен
+type fakeScanner struct {
+	err error
+}
+
+func (s *fakeScanner) Scan(_ context.Context, r io.Reader) (*scanner.Result, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Contains(data, []byte("EICAR")) {
+		return &scanner.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &scanner.Result{}, nil
+}
+
+func TestScanFiles(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	scanUploads := func(cfg *filesrv.Config) { cfg.ScanUploads = true }
+
+	t.Run("attachments wait for scan", func(t *testing.T) {
+		srv, repo, _ := newScanningService(t, scanUploads, &fakeScanner{})
+		clean, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.png", bytes.NewReader(pngBytes(t, 100, 100)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		infected, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "b.txt", strings.NewReader("X5O!P EICAR test"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		avatar, err := srv.NewFile(ctx, enum.FilePurposeAvatar, owner, uuid.Nil, "c.png", bytes.NewReader(pngBytes(t, 4, 4)))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if clean.ScanState != enum.ScanStatePending || infected.ScanState != enum.ScanStatePending {
+			t.Fatalf("ScanState = %s, %s, want PENDING", clean.ScanState, infected.ScanState)
+		}
+		if avatar.ScanState != enum.ScanStateClean {
+			t.Fatalf("avatar ScanState = %s, want CLEAN", avatar.ScanState)
+		}
+
+		// до проверки изображение не обрабатывается
+		if err := repo.SetImageState(ctx, avatar.Name, enum.ImageStateReady); err != nil {
+			t.Fatalf("SetImageState() error = %v", err)
+		}
+		if n, err := srv.ProcessImages(ctx); err != nil || n != 0 {
+			t.Fatalf("ProcessImages() = %d, %v, want 0", n, err)
+		}
+
+		scanned, err := srv.ScanFiles(ctx)
+		if err != nil || scanned != 2 {
+			t.Fatalf("ScanFiles() = %d, %v, want 2", scanned, err)
+		}
+		if got := repo.files[clean.Name]; got.ScanState != enum.ScanStateClean {
+			t.Fatalf("clean ScanState = %s", got.ScanState)
+		}
+		for _, variant := range clean.Variants {
+			if got := repo.files[variant.Name]; got.ScanState != enum.ScanStateClean {
+				t.Fatalf("variant %s ScanState = %s", variant.Name, got.ScanState)
+			}
+		}
+		got := repo.files[infected.Name]
+		if got.ScanState != enum.ScanStateInfected || got.ScanSignature != "Eicar-Test-Signature" {
+			t.Fatalf("infected = %s %q", got.ScanState, got.ScanSignature)
+		}
+		if n, err := srv.ProcessImages(ctx); err != nil || n != 1 {
+			t.Fatalf("ProcessImages() after scan = %d, %v, want 1", n, err)
+		}
+	})
+
+	t.Run("scanner unavailable", func(t *testing.T) {
+		srv, repo, _ := newScanningService(t, scanUploads, &fakeScanner{err: errors.New("connection refused")})
+		item, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.txt", strings.NewReader("text"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if _, err := srv.ScanFiles(ctx); err == nil {
+			t.Fatalf("ScanFiles() without scanner succeeded")
+		}
+		if got := repo.files[item.Name]; got.ScanState != enum.ScanStatePending {
+			t.Fatalf("ScanState = %s, want PENDING", got.ScanState)
+		}
+	})
+
+	t.Run("missing blob", func(t *testing.T) {
+		srv, repo, store := newScanningService(t, scanUploads, &fakeScanner{})
+		item, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.txt", strings.NewReader("text"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if err := store.Delete(ctx, item.StorageKey); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if scanned, err := srv.ScanFiles(ctx); err != nil || scanned != 1 {
+			t.Fatalf("ScanFiles() = %d, %v, want 1", scanned, err)
+		}
+		if got := repo.files[item.Name]; got.ScanState != enum.ScanStateFailed {
+			t.Fatalf("ScanState = %s, want FAILED", got.ScanState)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		srv, _, _ := newService(t)
+		item, err := srv.NewFile(ctx, enum.FilePurposeAttachment, owner, uuid.Nil, "a.txt", strings.NewReader("text"))
+		if err != nil {
+			t.Fatalf("NewFile() error = %v", err)
+		}
+		if item.ScanState != enum.ScanStateClean {
+			t.Fatalf("ScanState = %s, want CLEAN", item.ScanState)
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package file
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"wn/internal/domain/enum"
+	apperrors "wn/internal/errors"
+	"wn/pkg/imaging"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	errFileTooLarge  = errors.New("file too large")
+	errQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// fileKind допустимый тип файла
+type fileKind struct {
+	// contentType с каким типом файл сохраняется и отдается
+	contentType string
+	// sniffed что может вернуть http.DetectContentType для такого файла
+	sniffed []string
+	// svg файл проверяется целиком на активное содержимое
+	svg bool
+	// image формат изображения, из которого вырезаются метаданные
+	image imaging.Format
+	// thumbExt расширение миниатюр, пустое если миниатюры не строятся
+	thumbExt string
+}
+
+var (
+	kindPng = fileKind{
+		contentType: "image/png", sniffed: []string{"image/png"},
+		image: imaging.FormatPNG, thumbExt: ".png",
+	}
+	kindJpeg = fileKind{
+		contentType: "image/jpeg", sniffed: []string{"image/jpeg"},
+		image: imaging.FormatJPEG, thumbExt: ".jpg",
+	}
+	// анимация сохраняется как есть, миниатюры строятся по первому кадру
+	kindGif = fileKind{
+		contentType: "image/gif", sniffed: []string{"image/gif"},
+		image: imaging.FormatGIF, thumbExt: ".png",
+	}
+	// декодера webp нет, поэтому только вырезаем метаданные
+	kindWebp = fileKind{
+		contentType: "image/webp", sniffed: []string{"image/webp"},
+		image: imaging.FormatWebP,
+	}
+	kindSvg = fileKind{contentType: "image/svg+xml", sniffed: []string{"text/xml", "text/plain"}, svg: true}
+	// офисные форматы это zip архивы, сниффинг их не различает
+	kindOffice = func(contentType string) fileKind {
+		return fileKind{contentType: contentType, sniffed: []string{"application/zip"}}
+	}
+	kindText = func(contentType string) fileKind {
+		return fileKind{contentType: contentType + "; charset=utf-8", sniffed: []string{"text/plain"}}
+	}
+)
+
+// allowlists допустимые расширения для каждого назначения загрузки
+var allowlists = map[enum.FilePurpose]map[string]fileKind{
+	enum.FilePurposeAvatar: {
+		".png":  kindPng,
+		".jpg":  kindJpeg,
+		".jpeg": kindJpeg,
+		".gif":  kindGif,
+		".webp": kindWebp,
+	},
+	enum.FilePurposeAttachment: {
+		".png":  kindPng,
+		".jpg":  kindJpeg,
+		".jpeg": kindJpeg,
+		".gif":  kindGif,
+		".webp": kindWebp,
+		".svg":  kindSvg,
+		".pdf":  {contentType: "application/pdf", sniffed: []string{"application/pdf"}},
+		".zip":  {contentType: "application/zip", sniffed: []string{"application/zip"}},
+		".docx": kindOffice("application/vnd.openxmlformats-officedocument.wordprocessingml.document"),
+		".xlsx": kindOffice("application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"),
+		".pptx": kindOffice("application/vnd.openxmlformats-officedocument.presentationml.presentation"),
+		".txt":  kindText("text/plain"),
+		".md":   kindText("text/markdown"),
+		".csv":  kindText("text/csv"),
+		".json": kindText("application/json"),
+		".mp3":  {contentType: "audio/mpeg", sniffed: []string{"audio/mpeg"}},
+		".wav":  {contentType: "audio/wav", sniffed: []string{"audio/wave"}},
+		".ogg":  {contentType: "audio/ogg", sniffed: []string{"application/ogg"}},
+		".mp4":  {contentType: "video/mp4", sniffed: []string{"video/mp4"}},
+		".webm": {contentType: "video/webm", sniffed: []string{"video/webm"}},
+	},
+}
+
+func lookupKind(purpose enum.FilePurpose, ext string) (fileKind, error) {
+	kind, ok := allowlists[purpose][ext]
+	if !ok {
+		return fileKind{}, apperrors.FileExtensionNotAllowed
+	}
+	return kind, nil
+}
+
+// checkHead сверяет первые байты файла с типом, который следует из расширения
+func (k fileKind) checkHead(head []byte) error {
+	sniffed, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return apperrors.FileTypeMismatch
+	}
+	// html под любым расширением не принимаем, он исполнится при открытии по ссылке
+	if sniffed == "text/html" {
+		return apperrors.FileUnsafe
+	}
+	for _, allowed := range k.sniffed {
+		if sniffed == allowed {
+			return nil
+		}
+	}
+	return apperrors.FileTypeMismatch
+}
+
+// svgForbiddenElements элементы, через которые svg может исполнить код или подгрузить чужое содержимое
+var svgForbiddenElements = map[string]bool{
+	"script":        true,
+	"foreignobject": true,
+	"iframe":        true,
+	"embed":         true,
+	"object":        true,
+	"handler":       true,
+	"listener":      true,
+}
+
+// checkSVG принимает только статичный svg: без скриптов, обработчиков событий,
+// внешних ссылок и DTD. Такой файл безопасно открывать в браузере напрямую
+func checkSVG(data []byte) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = true
+	sawSvg := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return apperrors.FileTypeMismatch
+		}
+		switch t := token.(type) {
+		case xml.Directive:
+			// DTD может объявлять сущности
+			return apperrors.FileUnsafe
+		case xml.ProcInst:
+			if t.Target != "xml" {
+				return apperrors.FileUnsafe
+			}
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			if !sawSvg {
+				if name != "svg" {
+					return apperrors.FileTypeMismatch
+				}
+				sawSvg = true
+			}
+			if svgForbiddenElements[name] {
+				return apperrors.FileUnsafe
+			}
+			for _, attr := range t.Attr {
+				if !safeSVGAttr(attr) {
+					return apperrors.FileUnsafe
+				}
+			}
+		}
+	}
+	if !sawSvg {
+		return apperrors.FileTypeMismatch
+	}
+	return nil
+}
+
+func safeSVGAttr(attr xml.Attr) bool {
+	name := strings.ToLower(attr.Name.Local)
+	value := strings.ToLower(strings.TrimSpace(attr.Value))
+	if strings.HasPrefix(name, "on") {
+		return false
+	}
+	if name == "href" {
+		return strings.HasPrefix(value, "#") || strings.HasPrefix(value, "data:image/png") ||
+			strings.HasPrefix(value, "data:image/jpeg") || strings.HasPrefix(value, "data:image/gif")
+	}
+	// url(javascript:...), внешние url() и @import в style и презентационных атрибутах
+	if strings.Contains(value, "javascript:") || strings.Contains(value, "@import") {
+		return false
+	}
+	return strings.Count(value, "url(") == strings.Count(value, "url(#")
+}
+
+// limitedReader возвращает err (по умолчанию errFileTooLarge), если в потоке больше limit байт
+type limitedReader struct {
+	r    io.Reader
+	left int64
+	err  error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, l.exceeded()
+	}
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n, l.exceeded()
+	}
+	return n, err
+}
+
+func (l *limitedReader) exceeded() error {
+	if l.err != nil {
+		return l.err
+	}
+	return errFileTooLarge
+}
@@ -0,0 +1,241 @@
+package journal
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/template"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultTitleFormat заголовок записи, подстановки как в шаблонах заметок
+	DefaultTitleFormat = "{{date}}"
+	// maxTitleFormatLength длина формата заголовка в символах
+	maxTitleFormatLength = 128
+	// dayStep расстояние между записями соседних дней на графе
+	dayStep = 300
+)
+
+// errEntryExists запись за день успела создать другая транзакция
+var errEntryExists = errors.New("journal entry exists")
+
+type journalRepo interface {
+	GetJournal(ctx context.Context, userId uuid.UUID) (*entity.Journal, error)
+	SetJournal(ctx context.Context, item *entity.Journal) error
+	CreateEntry(ctx context.Context, item *entity.JournalEntry) (bool, error)
+	GetEntry(ctx context.Context, layoutId uuid.UUID, day time.Time) (*entity.JournalEntry, error)
+	GetPreviousEntry(ctx context.Context, layoutId uuid.UUID, day time.Time) (*entity.JournalEntry, error)
+	GetEntries(ctx context.Context, layoutId uuid.UUID, from, to time.Time) ([]entity.JournalEntry, error)
+}
+
+type noteService interface {
+	CreateNote(ctx context.Context, title, payload string, ownerId, layoutId, mainLayoutId uuid.UUID) (uuid.UUID, []string, error)
+	UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error
+	CreateLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error
+	GetFullNotesByIds(ctx context.Context, noteIds []uuid.UUID) ([]dto.Note, error)
+}
+
+type templateService interface {
+	GetTemplate(ctx context.Context, templateId, userId uuid.UUID, write bool) (*entity.Template, error)
+	RenderTemplate(ctx context.Context, templateId, userId, layoutId uuid.UUID, values map[string]string, now time.Time) (string, string, error)
+}
+
+type permissionsService interface {
+	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type Service struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	journalRepo        journalRepo
+	noteService        noteService
+	templateService    templateService
+	permissionsService permissionsService
+}
+
+func NewService(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	journalRepo journalRepo,
+	noteService noteService,
+	templateService templateService,
+	permissionsService permissionsService,
+) *Service {
+	return &Service{
+		tx:                 tx,
+		logger:             logger,
+		journalRepo:        journalRepo,
+		noteService:        noteService,
+		templateService:    templateService,
+		permissionsService: permissionsService,
+	}
+}
+
+// Day полночь UTC того же календарного дня, ключ записи журнала
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func (srv *Service) GetJournal(ctx context.Context, userId uuid.UUID) (*dto.Journal, error) {
+	item, err := srv.journalRepo.GetJournal(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	out := dto.JournalFromEntity(item)
+	return &out, nil
+}
+
+// SetJournal назначает лейаут журналом. Писать в лейаут пользователь должен уметь, шаблон - видеть.
+// Пустой формат заголовка - DefaultTitleFormat, now - текущее время пользователя
+func (srv *Service) SetJournal(ctx context.Context, userId, layoutId uuid.UUID, titleFormat string, templateId *uuid.UUID, now time.Time) error {
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, layoutId, userId, true, true, false); err != nil {
+		return err
+	}
+	titleFormat = strings.TrimSpace(titleFormat)
+	if titleFormat == "" {
+		titleFormat = DefaultTitleFormat
+	}
+	// вопросы в заголовке задать некому
+	title, missing := template.Render(titleFormat, template.Vars{Now: now})
+	if utf8.RuneCountInString(titleFormat) > maxTitleFormatLength || strings.TrimSpace(title) == "" || len(missing) > 0 {
+		return apperrors.BadJournalTitle
+	}
+	if templateId != nil {
+		if _, err := srv.templateService.GetTemplate(ctx, *templateId, userId, false); err != nil {
+			return err
+		}
+	}
+	return srv.journalRepo.SetJournal(ctx, &entity.Journal{
+		UserId:      userId,
+		LayoutId:    layoutId,
+		TitleFormat: titleFormat,
+		TemplateId:  templateId,
+		StartedOn:   Day(now),
+	})
+}
+
+// GetDay запись журнала за день now, при необходимости создает ее: заголовок по формату журнала,
+// текст из шаблона журнала, место на графе по дню и связь с ближайшей более ранней записью.
+// now - нужный день со временем пользователя, values - ответы на вопросы шаблона
+func (srv *Service) GetDay(ctx context.Context, userId uuid.UUID, now time.Time, values map[string]string) (*dto.JournalDay, error) {
+	journal, err := srv.journalRepo.GetJournal(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, journal.LayoutId, userId, true, false, false); err != nil {
+		return nil, err
+	}
+	day := Day(now)
+	out := dto.JournalDay{Date: day.Format(dto.DayFormat)}
+
+	var noteId uuid.UUID
+	entry, err := srv.journalRepo.GetEntry(ctx, journal.LayoutId, day)
+	switch {
+	case err == nil:
+		noteId = entry.NoteId
+	case errors.Is(err, apperrors.RecordNotFound):
+		noteId, err = srv.createEntry(ctx, userId, journal, now, values)
+		if errors.Is(err, errEntryExists) {
+			// запись успел создать параллельный запрос
+			entry, err = srv.journalRepo.GetEntry(ctx, journal.LayoutId, day)
+			if err != nil {
+				return nil, errors.Wrap(err, "srv.journalRepo.GetEntry")
+			}
+			noteId = entry.NoteId
+		} else if err != nil {
+			return nil, err
+		} else {
+			out.Created = true
+		}
+	default:
+		return nil, errors.Wrap(err, "srv.journalRepo.GetEntry")
+	}
+
+	notes, err := srv.noteService.GetFullNotesByIds(ctx, []uuid.UUID{noteId})
+	if err != nil {
+		return nil, err
+	}
+	if len(notes) == 0 {
+		return nil, apperrors.NoteNotFound
+	}
+	out.Note = notes[0]
+	return &out, nil
+}
+
+func (srv *Service) createEntry(ctx context.Context, userId uuid.UUID, journal *entity.Journal, now time.Time, values map[string]string) (uuid.UUID, error) {
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, journal.LayoutId, userId, true, true, false); err != nil {
+		return uuid.Nil, err
+	}
+	title, _ := template.Render(journal.TitleFormat, template.Vars{Now: now})
+	payload := ""
+	if journal.TemplateId != nil {
+		var err error
+		_, payload, err = srv.templateService.RenderTemplate(ctx, *journal.TemplateId, userId, journal.LayoutId, values, now)
+		if err != nil {
+			return uuid.Nil, err
+		}
+	}
+
+	day := Day(now)
+	xPos, yPos := float64(day.Sub(Day(journal.StartedOn))/(24*time.Hour))*dayStep, 0.0
+	var noteId uuid.UUID
+	err := srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		noteId, _, err = srv.noteService.CreateNote(ctx, title, payload, userId, journal.LayoutId, uuid.Nil)
+		if err != nil {
+			return err
+		}
+		created, err := srv.journalRepo.CreateEntry(ctx, &entity.JournalEntry{LayoutId: journal.LayoutId, Day: day, NoteId: noteId})
+		if err != nil {
+			return errors.Wrap(err, "srv.journalRepo.CreateEntry")
+		}
+		if !created {
+			// откатывает созданную заметку
+			return errEntryExists
+		}
+		err = srv.noteService.UpdateNotePosition(ctx, noteId, &xPos, &yPos)
+		if err != nil {
+			return err
+		}
+		prev, err := srv.journalRepo.GetPreviousEntry(ctx, journal.LayoutId, day)
+		if errors.Is(err, apperrors.RecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "srv.journalRepo.GetPreviousEntry")
+		}
+		return srv.noteService.CreateLink(ctx, prev.NoteId, noteId)
+	})
+	return noteId, err
+}
+
+// GetCalendar дни месяца month, за которые в журнале есть записи
+func (srv *Service) GetCalendar(ctx context.Context, userId uuid.UUID, month time.Time) (*dto.JournalCalendar, error) {
+	journal, err := srv.journalRepo.GetJournal(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, journal.LayoutId, userId, true, false, false); err != nil {
+		return nil, err
+	}
+	from := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	entries, err := srv.journalRepo.GetEntries(ctx, journal.LayoutId, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.journalRepo.GetEntries")
+	}
+	out := dto.JournalCalendar{Days: make([]dto.JournalDate, 0, len(entries))}
+	for _, e := range entries {
+		out.Days = append(out.Days, dto.JournalDate{Date: e.Day.Format(dto.DayFormat), NoteId: e.NoteId})
+	}
+	return &out, nil
+}
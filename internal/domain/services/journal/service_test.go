@@ -0,0 +1,177 @@
+package journal_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/journal"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore журналы, записи и заметки в памяти, все права есть
+type memoryStore struct {
+	journals map[uuid.UUID]entity.Journal
+	entries  map[time.Time]entity.JournalEntry
+	notes    map[uuid.UUID]*dto.Note
+	links    [][2]uuid.UUID
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		journals: map[uuid.UUID]entity.Journal{},
+		entries:  map[time.Time]entity.JournalEntry{},
+		notes:    map[uuid.UUID]*dto.Note{},
+	}
+}
+
+func (s *memoryStore) GetJournal(_ context.Context, userId uuid.UUID) (*entity.Journal, error) {
+	item, ok := s.journals[userId]
+	if !ok {
+		return nil, apperrors.JournalNotConfigured
+	}
+	return &item, nil
+}
+
+func (s *memoryStore) SetJournal(_ context.Context, item *entity.Journal) error {
+	s.journals[item.UserId] = *item
+	return nil
+}
+
+func (s *memoryStore) CreateEntry(_ context.Context, item *entity.JournalEntry) (bool, error) {
+	if _, ok := s.entries[item.Day]; ok {
+		return false, nil
+	}
+	s.entries[item.Day] = *item
+	return true, nil
+}
+
+func (s *memoryStore) GetEntry(_ context.Context, _ uuid.UUID, day time.Time) (*entity.JournalEntry, error) {
+	item, ok := s.entries[day]
+	if !ok {
+		return nil, apperrors.RecordNotFound
+	}
+	return &item, nil
+}
+
+func (s *memoryStore) GetPreviousEntry(_ context.Context, _ uuid.UUID, day time.Time) (*entity.JournalEntry, error) {
+	var prev *entity.JournalEntry
+	for d, item := range s.entries {
+		if d.Before(day) && (prev == nil || d.After(prev.Day)) {
+			item := item
+			prev = &item
+		}
+	}
+	if prev == nil {
+		return nil, apperrors.RecordNotFound
+	}
+	return prev, nil
+}
+
+func (s *memoryStore) GetEntries(_ context.Context, _ uuid.UUID, from, to time.Time) ([]entity.JournalEntry, error) {
+	var out []entity.JournalEntry
+	for d, item := range s.entries {
+		if !d.Before(from) && d.Before(to) {
+			out = append(out, item)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Day.Before(out[j].Day) })
+	return out, nil
+}
+
+func (s *memoryStore) CreateNote(_ context.Context, title, payload string, ownerId, layoutId, _ uuid.UUID) (uuid.UUID, []string, error) {
+	id := uuid.New()
+	s.notes[id] = &dto.Note{Id: id, Title: title, Payload: payload, OwnerId: ownerId, LayoutId: layoutId}
+	return id, nil, nil
+}
+
+func (s *memoryStore) UpdateNotePosition(_ context.Context, noteId uuid.UUID, xPos, yPos *float64) error {
+	s.notes[noteId].Position = &dto.Position{XPos: *xPos, YPos: *yPos}
+	return nil
+}
+
+func (s *memoryStore) CreateLink(_ context.Context, noteId1, noteId2 uuid.UUID) error {
+	s.links = append(s.links, [2]uuid.UUID{noteId1, noteId2})
+	return nil
+}
+
+func (s *memoryStore) GetFullNotesByIds(_ context.Context, noteIds []uuid.UUID) ([]dto.Note, error) {
+	var out []dto.Note
+	for _, id := range noteIds {
+		out = append(out, *s.notes[id])
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetTemplate(context.Context, uuid.UUID, uuid.UUID, bool) (*entity.Template, error) {
+	return &entity.Template{}, nil
+}
+
+func (s *memoryStore) RenderTemplate(_ context.Context, _, _, _ uuid.UUID, values map[string]string, _ time.Time) (string, string, error) {
+	return "ignored", "mood: " + values["mood"], nil
+}
+
+func (s *memoryStore) CheckPermissionByLayoutId(context.Context, uuid.UUID, uuid.UUID, bool, bool, bool) error {
+	return nil
+}
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	userId, layoutId, templateId := uuid.New(), uuid.New(), uuid.New()
+	lgr := testutil.Logger(t)
+	store := newMemoryStore()
+	srv := journal.NewService(testutil.NoTx{}, lgr, store, store, store, store)
+	day := func(d int) time.Time { return time.Date(2026, 10, d, 9, 30, 0, 0, time.UTC) }
+
+	if _, err := srv.GetDay(ctx, userId, day(12), nil); err != apperrors.JournalNotConfigured {
+		t.Fatalf("GetDay() error = %v, want %v", err, apperrors.JournalNotConfigured)
+	}
+	if err := srv.SetJournal(ctx, userId, layoutId, "{{prompt:Title}}", nil, day(10)); err != apperrors.BadJournalTitle {
+		t.Fatalf("SetJournal() error = %v, want %v", err, apperrors.BadJournalTitle)
+	}
+	if err := srv.SetJournal(ctx, userId, layoutId, "{{date:02.01.2006}}", &templateId, day(10)); err != nil {
+		t.Fatalf("SetJournal() error = %v", err)
+	}
+
+	first, err := srv.GetDay(ctx, userId, day(12), map[string]string{"mood": "ok"})
+	if err != nil {
+		t.Fatalf("GetDay() error = %v", err)
+	}
+	if !first.Created || first.Date != "2026-10-12" || first.Note.Title != "12.10.2026" || first.Note.Payload != "mood: ok" {
+		t.Fatalf("GetDay() = %+v", first)
+	}
+	if want := (&dto.Position{XPos: 600}); !reflect.DeepEqual(first.Note.Position, want) {
+		t.Fatalf("position = %v, want %v", first.Note.Position, want)
+	}
+
+	again, err := srv.GetDay(ctx, userId, day(12), nil)
+	if err != nil || again.Created || again.Note.Id != first.Note.Id {
+		t.Fatalf("GetDay() = %+v, %v, want existing note", again, err)
+	}
+
+	later, err := srv.GetDay(ctx, userId, day(15), nil)
+	if err != nil {
+		t.Fatalf("GetDay() error = %v", err)
+	}
+	if want := [][2]uuid.UUID{{first.Note.Id, later.Note.Id}}; !reflect.DeepEqual(store.links, want) {
+		t.Fatalf("links = %v, want %v", store.links, want)
+	}
+
+	calendar, err := srv.GetCalendar(ctx, userId, day(1))
+	if err != nil {
+		t.Fatalf("GetCalendar() error = %v", err)
+	}
+	want := []dto.JournalDate{{Date: "2026-10-12", NoteId: first.Note.Id}, {Date: "2026-10-15", NoteId: later.Note.Id}}
+	if !reflect.DeepEqual(calendar.Days, want) {
+		t.Fatalf("GetCalendar() = %v, want %v", calendar.Days, want)
+	}
+	if calendar, _ := srv.GetCalendar(ctx, userId, day(1).AddDate(0, 1, 0)); len(calendar.Days) != 0 {
+		t.Fatalf("GetCalendar() next month = %v", calendar.Days)
+	}
+}
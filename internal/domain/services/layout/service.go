@@ -3,10 +3,12 @@ package layout
 import (
 	"context"
 	"fmt"
+	"strings"
 	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
+	"wn/internal/domain/enum"
+	"wn/internal/domain/services/tag"
 	"wn/internal/entity"
-	apperrors "wn/internal/errors"
 	"wn/pkg/applogger"
 	"wn/pkg/trx"
 	"wn/pkg/util"
@@ -15,11 +17,15 @@ import (
 	"github.com/pkg/errors"
 )
 
+// copySuffix дописывается к названию копии лейаута, если новое не задано
+const copySuffix = " (копия)"
+
 type layoutRepo interface {
 	CreateLayout(ctx context.Context, item *entity.Layout) (uuid.UUID, error)
+	GetById(ctx context.Context, layoutId uuid.UUID) (*entity.Layout, error)
 	DeleteLayoutById(ctx context.Context, layoutId uuid.UUID) error
 	GetAvailableLayouts(ctx context.Context, userId uuid.UUID) ([]entity.Layout, error)
-	UpdateLayout(ctx context.Context, userId, layoutId uuid.UUID, color, title string) (int, error)
+	UpdateLayout(ctx context.Context, layoutId uuid.UUID, params *dto.UpdateLayoutParams) (int64, error)
 }
 
 type linksRepo interface {
@@ -36,6 +42,7 @@ type noteRepo interface {
 
 type noteService interface {
 	RessurectNotes(ctx context.Context, item *dto.Note) error
+	CopyNotes(ctx context.Context, notes []dto.Note, ownerId, layoutId uuid.UUID, offset dto.Position) (map[uuid.UUID]uuid.UUID, error)
 }
 
 type positionsRepo interface {
@@ -50,6 +57,17 @@ type permissionsRepository interface {
 	CreatePermissions(ctx context.Context, item *entity.Permission) error
 }
 
+type changesRepo interface {
+	RecordChange(ctx context.Context, item *entity.Change) error
+}
+
+type tagsRepo interface {
+	GetTags(ctx context.Context, ownerId uuid.UUID) ([]entity.TagCount, error)
+	GetNotesTags(ctx context.Context, ownerId uuid.UUID, noteIds []uuid.UUID) (map[uuid.UUID][]string, error)
+	EnsureTag(ctx context.Context, item *entity.Tag) (uuid.UUID, error)
+	AttachTag(ctx context.Context, noteId, tagId uuid.UUID) error
+}
+
 type Service struct {
 	tx     trx.TransactionManager
 	logger applogger.Logger
@@ -60,6 +78,8 @@ type Service struct {
 	positionsRepo         positionsRepo
 	noteService           noteService
 	permissionsRepository permissionsRepository
+	changesRepo           changesRepo
+	tagsRepo              tagsRepo
 }
 
 func NewService(
@@ -71,6 +91,8 @@ func NewService(
 	positionsRepo positionsRepo,
 	noteService noteService,
 	permissionsRepository permissionsRepository,
+	changesRepo changesRepo,
+	tagsRepo tagsRepo,
 ) *Service {
 	return &Service{
 		tx:                    tx,
@@ -81,19 +103,32 @@ func NewService(
 		noteService:           noteService,
 		positionsRepo:         positionsRepo,
 		permissionsRepository: permissionsRepository,
+		changesRepo:           changesRepo,
+		tagsRepo:              tagsRepo,
 	}
 }
 
 func (srv *Service) CreateLayout(ctx context.Context, title, color string, ownerId uuid.UUID, isMain bool) (uuid.UUID, error) {
+	return srv.CreateLayoutWithId(ctx, util.NewUUID(), title, color, ownerId, isMain)
+}
+
+// CreateLayoutWithId создает лейаут с id, выданным клиентом (офлайн синхронизация)
+func (srv *Service) CreateLayoutWithId(ctx context.Context, layoutId uuid.UUID, title, color string, ownerId uuid.UUID, isMain bool) (uuid.UUID, error) {
 	item := entity.Layout{
-		Id:         util.NewUUID(),
+		Id:         layoutId,
 		Title:      title,
 		OwnerId:    ownerId,
 		HaveAccess: []uuid.UUID{ownerId},
 		IsMain:     isMain,
 		Color:      color,
 	}
-	return srv.layoutRepo.CreateLayout(ctx, &item)
+	return item.Id, srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		_, err := srv.layoutRepo.CreateLayout(ctx, &item)
+		if err != nil {
+			return err
+		}
+		return srv.recordLayoutChange(ctx, enum.SyncOperationUpsert, item.Id)
+	})
 }
 
 func (srv *Service) DeleteLayoutById(ctx context.Context, layoutId, ownerId uuid.UUID) error {
@@ -110,8 +145,13 @@ func (srv *Service) DeleteLayoutById(ctx context.Context, layoutId, ownerId uuid
 	}
 
 	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		// пишем до удаления пермишенов, иначе соавторы не попадут в audience
+		err := srv.recordLayoutChange(ctx, enum.SyncOperationDelete, layoutId)
+		if err != nil {
+			return errors.Wrap(err, "recordLayoutChange")
+		}
 
-		err := srv.permissionsRepository.DeletePermissions(ctx, permIds...)
+		err = srv.permissionsRepository.DeletePermissions(ctx, permIds...)
 		if err != nil {
 			return errors.Wrap(err, "srv.permissionsRepository.DeletePermissions")
 		}
@@ -166,6 +206,7 @@ func (srv *Service) GetAvailableLayouts(ctx context.Context, userId uuid.UUID) (
 			Title:   item.Title,
 			IsMain:  item.IsMain,
 			Color:   item.Color,
+			Version: item.Version,
 		}
 		p, ok := perms[item.Id]
 		if ok {
@@ -177,15 +218,24 @@ func (srv *Service) GetAvailableLayouts(ctx context.Context, userId uuid.UUID) (
 	return output, nil
 }
 
-func (srv *Service) UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) error {
-	updatedRows, err := srv.layoutRepo.UpdateLayout(ctx, userId, req.LayoutId, req.Color, req.Title)
-	if err != nil {
-		return errors.Wrap(err, "srv.layoutRepo.UpdateLayout")
-	}
-	if updatedRows < 1 {
-		return apperrors.LayoutNotFound
+// UpdateLayout nil title и color не меняются, пустой color убирает цвет. Возвращает новую версию
+func (srv *Service) UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) (int64, error) {
+	params := dto.UpdateLayoutParams{
+		Title:   req.Title,
+		Color:   req.Color,
+		Version: req.Version,
 	}
-	return nil
+
+	var version int64
+	err := srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		var err error
+		version, err = srv.layoutRepo.UpdateLayout(ctx, req.LayoutId, &params)
+		if err != nil {
+			return errors.Wrap(err, "srv.layoutRepo.UpdateLayout")
+		}
+		return srv.recordLayoutChange(ctx, enum.SyncOperationUpsert, req.LayoutId)
+	})
+	return version, err
 }
 
 func (srv *Service) ExportLayouts(ctx context.Context, userId uuid.UUID) (*dto.ExportInfo, error) {
@@ -207,6 +257,9 @@ func (srv *Service) ExportLayouts(ctx context.Context, userId uuid.UUID) (*dto.E
 		if err != nil {
 			return nil, errors.Wrap(err, "GetFullNotesByLayoutId")
 		}
+		if err := srv.fillTags(ctx, userId, notes); err != nil {
+			return nil, err
+		}
 		output.Layouts = append(output.Layouts, dto.Layout{
 			Id:      l.Id,
 			Title:   l.Title,
@@ -216,9 +269,75 @@ func (srv *Service) ExportLayouts(ctx context.Context, userId uuid.UUID) (*dto.E
 		})
 		output.Notes[l.Id] = notes
 	}
+
+	tags, err := srv.tagsRepo.GetTags(ctx, userId)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetTags")
+	}
+	for _, t := range tags {
+		output.Tags = append(output.Tags, dto.Tag{Id: t.Id, Name: t.Name, Color: t.Color})
+	}
 	return &output, nil
 }
 
+func (srv *Service) fillTags(ctx context.Context, userId uuid.UUID, notes []dto.Note) error {
+	ids := make([]uuid.UUID, 0, len(notes))
+	for _, n := range notes {
+		ids = append(ids, n.Id)
+	}
+	tags, err := srv.tagsRepo.GetNotesTags(ctx, userId, ids)
+	if err != nil {
+		return errors.Wrap(err, "GetNotesTags")
+	}
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].Id]
+	}
+	return nil
+}
+
+// importTags метки сопоставляются с существующими по имени, недостающие создаются, некорректные имена пропускаются.
+// Возвращает id по имени в нижнем регистре
+func (srv *Service) importTags(ctx context.Context, userId uuid.UUID, info *dto.ExportInfo) (map[string]uuid.UUID, error) {
+	ids := map[string]uuid.UUID{}
+	ensure := func(name, color string) error {
+		name, err := tag.NormalizeName(name)
+		if err != nil {
+			return nil
+		}
+		key := strings.ToLower(name)
+		if _, ok := ids[key]; ok {
+			return nil
+		}
+		id, err := srv.tagsRepo.EnsureTag(ctx, &entity.Tag{
+			Id:        util.NewUUID(),
+			OwnerId:   userId,
+			Name:      name,
+			Color:     color,
+			CreatedAt: util.GetCurrentUTCTime(),
+		})
+		if err != nil {
+			return errors.Wrap(err, "EnsureTag")
+		}
+		ids[key] = id
+		return nil
+	}
+	for _, t := range info.Tags {
+		if err := ensure(t.Name, t.Color); err != nil {
+			return nil, err
+		}
+	}
+	for _, notes := range info.Notes {
+		for _, n := range notes {
+			for _, name := range n.Tags {
+				if err := ensure(name, ""); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return ids, nil
+}
+
 func (srv *Service) ImportLayouts(ctx context.Context, userId uuid.UUID, info *dto.ExportInfo) error {
 	layouts, err := srv.layoutRepo.GetAvailableLayouts(ctx, userId)
 	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
@@ -233,6 +352,11 @@ func (srv *Service) ImportLayouts(ctx context.Context, userId uuid.UUID, info *d
 			}
 		}
 
+		tagIds, err := srv.importTags(ctx, userId, info)
+		if err != nil {
+			return err
+		}
+
 		for _, l := range info.Layouts {
 			if l.IsMain || l.OwnerId != userId {
 				continue
@@ -248,11 +372,25 @@ func (srv *Service) ImportLayouts(ctx context.Context, userId uuid.UUID, info *d
 			if err != nil {
 				return errors.Wrap(err, "CreateLayout")
 			}
+			err = srv.recordLayoutChange(ctx, enum.SyncOperationUpsert, l.Id)
+			if err != nil {
+				return errors.Wrap(err, "recordLayoutChange")
+			}
 			for _, note := range info.Notes[l.Id] {
 				err = srv.noteService.RessurectNotes(ctx, &note)
 				if err != nil {
 					return errors.Wrap(err, "RessurectNotes")
 				}
+				for _, name := range note.Tags {
+					tagId, ok := tagIds[strings.ToLower(strings.TrimSpace(name))]
+					if !ok {
+						continue
+					}
+					err = srv.tagsRepo.AttachTag(ctx, note.Id, tagId)
+					if err != nil {
+						return errors.Wrap(err, "AttachTag")
+					}
+				}
 			}
 		}
 		for _, l := range info.Layouts {
@@ -265,9 +403,52 @@ func (srv *Service) ImportLayouts(ctx context.Context, userId uuid.UUID, info *d
 					if err != nil {
 						return errors.Wrap(err, "LinkNotes")
 					}
+					err = srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationUpsert, item.Id, out, l.Id))
+					if err != nil {
+						return errors.Wrap(err, "RecordChange")
+					}
 				}
 			}
 		}
 		return nil
 	})
 }
+
+// DuplicateLayout копия лейаута со всеми заметками, позициями и связями между заметками.
+// Владелец копии и ее заметок - userId, копия никогда не главный лейаут
+func (srv *Service) DuplicateLayout(ctx context.Context, layoutId, userId uuid.UUID, title string) (uuid.UUID, error) {
+	source, err := srv.layoutRepo.GetById(ctx, layoutId)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	notes, err := srv.noteRepo.GetFullNotesByLayoutId(ctx, layoutId, userId)
+	if err != nil {
+		return uuid.Nil, errors.Wrap(err, "GetFullNotesByLayoutId")
+	}
+	if title == "" {
+		title = source.Title + copySuffix
+	}
+	item := entity.Layout{
+		Id:         util.NewUUID(),
+		Title:      title,
+		OwnerId:    userId,
+		HaveAccess: []uuid.UUID{userId},
+		Color:      source.Color,
+	}
+	return item.Id, srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		_, err := srv.layoutRepo.CreateLayout(ctx, &item)
+		if err != nil {
+			return errors.Wrap(err, "CreateLayout")
+		}
+		err = srv.recordLayoutChange(ctx, enum.SyncOperationUpsert, item.Id)
+		if err != nil {
+			return errors.Wrap(err, "recordLayoutChange")
+		}
+		_, err = srv.noteService.CopyNotes(ctx, notes, userId, item.Id, dto.Position{})
+		return err
+	})
+}
+
+func (srv *Service) recordLayoutChange(ctx context.Context, operation enum.SyncOperation, layoutId uuid.UUID) error {
+	return srv.changesRepo.RecordChange(ctx, entity.NewChange(enum.SyncEntityKindLayout, operation, layoutId, layoutId))
+}
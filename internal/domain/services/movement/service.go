@@ -0,0 +1,193 @@
+package movement
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/socket"
+	"wn/pkg/applogger"
+
+	"github.com/google/uuid"
+)
+
+const persistTimeout = 5 * time.Second
+
+type publisher interface {
+	Publish(topic string, msg *dto.SocketMessage)
+}
+
+type positionsSaver interface {
+	UpdateMovedNotePosition(ctx context.Context, noteId, layoutId uuid.UUID, xPos, yPos *float64, movedAt time.Time) error
+}
+
+type Config struct {
+	// Tick период рассылки накопленных позиций подписчикам лейаута
+	Tick time.Duration
+	// PersistDelay сколько заметка должна простоять, чтобы позиция записалась в базу
+	PersistDelay time.Duration
+	// MaxPersistDelay при непрерывном перетаскивании позиция пишется не реже этого периода
+	MaxPersistDelay time.Duration
+}
+
+func NewConfig(tick, persistDelay, maxPersistDelay time.Duration) *Config {
+	return &Config{
+		Tick:            tick,
+		PersistDelay:    persistDelay,
+		MaxPersistDelay: maxPersistDelay,
+	}
+}
+
+type unsavedMove struct {
+	layoutId uuid.UUID
+	move     dto.NoteMove
+	firstAt  time.Time
+	lastAt   time.Time
+}
+
+// Service схлопывает поток позиций при перетаскивании: подписчикам за тик уходит
+// только последняя позиция каждой заметки, а в базу она пишется с задержкой
+type Service struct {
+	lgr       applogger.Logger
+	cfg       *Config
+	publisher publisher
+	saver     positionsSaver
+
+	mu      sync.Mutex
+	pending map[uuid.UUID]map[uuid.UUID]dto.NoteMove // layoutId -> noteId -> позиция
+	unsaved map[uuid.UUID]*unsavedMove               // noteId -> позиция
+
+	stop     chan struct{}
+	wg       sync.WaitGroup
+	stopOnce sync.Once
+}
+
+func NewService(lgr applogger.Logger, cfg *Config, publisher publisher, saver positionsSaver) *Service {
+	s := &Service{
+		lgr:       lgr,
+		cfg:       cfg,
+		publisher: publisher,
+		saver:     saver,
+		pending:   map[uuid.UUID]map[uuid.UUID]dto.NoteMove{},
+		unsaved:   map[uuid.UUID]*unsavedMove{},
+		stop:      make(chan struct{}),
+	}
+
+	s.wg.Add(2)
+	go s.relayLoop()
+	go s.persistLoop()
+	return s
+}
+
+// Move запоминает позицию, предыдущая необработанная позиция той же заметки затирается
+func (s *Service) Move(layoutId uuid.UUID, move dto.NoteMove) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending[layoutId] == nil {
+		s.pending[layoutId] = map[uuid.UUID]dto.NoteMove{}
+	}
+	s.pending[layoutId][move.NoteId] = move
+
+	u, ok := s.unsaved[move.NoteId]
+	if !ok {
+		u = &unsavedMove{firstAt: now}
+		s.unsaved[move.NoteId] = u
+	}
+	u.layoutId = layoutId
+	u.move = move
+	u.lastAt = now
+}
+
+// Stop останавливает рассылку и дописывает в базу все несохраненные позиции
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+	})
+}
+
+func (s *Service) relayLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.Tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.relay()
+		}
+	}
+}
+
+func (s *Service) relay() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = map[uuid.UUID]map[uuid.UUID]dto.NoteMove{}
+	s.mu.Unlock()
+
+	for layoutId, notes := range pending {
+		moved := dto.NotesMoved{
+			LayoutId: layoutId,
+			Moves:    make([]dto.NoteMove, 0, len(notes)),
+		}
+		for _, move := range notes {
+			moved.Moves = append(moved.Moves, move)
+		}
+		payload, err := json.Marshal(moved)
+		if err != nil {
+			s.lgr.Errorf("movement relay marshal: %s", err.Error())
+			continue
+		}
+		s.publisher.Publish(socket.LayoutTopic(layoutId), &dto.SocketMessage{
+			Event:   dto.NotesMovedEvent,
+			Payload: payload,
+		})
+	}
+}
+
+func (s *Service) persistLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.cfg.Tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			s.persist(true)
+			return
+		case <-ticker.C:
+			s.persist(false)
+		}
+	}
+}
+
+// persist пишет позиции, которые успокоились или слишком долго не сохранялись. force пишет все.
+// Позиция пишется с временем последнего перемещения, более новую запись она не затрет
+func (s *Service) persist(force bool) {
+	now := time.Now()
+	due := make([]*unsavedMove, 0)
+
+	s.mu.Lock()
+	for noteId, u := range s.unsaved {
+		if force || now.Sub(u.lastAt) >= s.cfg.PersistDelay || now.Sub(u.firstAt) >= s.cfg.MaxPersistDelay {
+			due = append(due, u)
+			delete(s.unsaved, noteId)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, u := range due {
+		ctx, cancel := context.WithTimeout(context.Background(), persistTimeout)
+		x, y := u.move.XPos, u.move.YPos
+		if err := s.saver.UpdateMovedNotePosition(ctx, u.move.NoteId, u.layoutId, &x, &y, u.lastAt); err != nil {
+			s.lgr.Warnf("movement persist note %s: %s", u.move.NoteId, err.Error())
+		}
+		cancel()
+	}
+}
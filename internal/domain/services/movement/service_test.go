@@ -0,0 +1,161 @@
+package movement_test
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/movement"
+	"wn/internal/domain/services/socket"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+type saved struct {
+	noteId   uuid.UUID
+	layoutId uuid.UUID
+	x, y     float64
+	movedAt  time.Time
+}
+
+// memoryStore разосланные сообщения и записанные позиции, методы вызываются из горутин сервиса
+type memoryStore struct {
+	mu        sync.Mutex
+	published map[string][]dto.NotesMoved
+	saved     []saved
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{published: map[string][]dto.NotesMoved{}}
+}
+
+func (s *memoryStore) Publish(topic string, msg *dto.SocketMessage) {
+	var moved dto.NotesMoved
+	_ = json.Unmarshal(msg.Payload, &moved)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published[topic] = append(s.published[topic], moved)
+}
+
+func (s *memoryStore) UpdateMovedNotePosition(_ context.Context, noteId, layoutId uuid.UUID, xPos, yPos *float64, movedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = append(s.saved, saved{noteId: noteId, layoutId: layoutId, x: *xPos, y: *yPos, movedAt: movedAt})
+	return nil
+}
+
+func (s *memoryStore) savedCopy() []saved {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]saved(nil), s.saved...)
+}
+
+func (s *memoryStore) publishedCopy(topic string) []dto.NotesMoved {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]dto.NotesMoved(nil), s.published[topic]...)
+}
+
+func newService(t *testing.T, store *memoryStore, tick, persistDelay, maxPersistDelay time.Duration) *movement.Service {
+	lgr := testutil.Logger(t)
+	srv := movement.NewService(lgr, movement.NewConfig(tick, persistDelay, maxPersistDelay), store, store)
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+func TestRelayCoalesces(t *testing.T) {
+	store := newMemoryStore()
+	srv := newService(t, store, 100*time.Millisecond, time.Hour, time.Hour)
+	layoutId, first, second := uuid.New(), uuid.New(), uuid.New()
+
+	for i := range 5 {
+		srv.Move(layoutId, dto.NoteMove{NoteId: first, XPos: float64(i), YPos: 1})
+	}
+	srv.Move(layoutId, dto.NoteMove{NoteId: second, XPos: 7, YPos: 8})
+	time.Sleep(250 * time.Millisecond)
+
+	published := store.publishedCopy(socket.LayoutTopic(layoutId))
+	if len(published) != 1 || published[0].LayoutId != layoutId || len(published[0].Moves) != 2 {
+		t.Fatalf("published = %+v", published)
+	}
+	for _, move := range published[0].Moves {
+		if move.NoteId == first && move.XPos != 4 {
+			t.Fatalf("not the last position: %+v", move)
+		}
+	}
+}
+
+func TestPersistDebounce(t *testing.T) {
+	store := newMemoryStore()
+	srv := newService(t, store, 10*time.Millisecond, 100*time.Millisecond, time.Hour)
+	layoutId, noteId := uuid.New(), uuid.New()
+
+	// пока заметку тащат, в базу ничего не пишется
+	for i := range 7 {
+		srv.Move(layoutId, dto.NoteMove{NoteId: noteId, XPos: float64(i), YPos: 2})
+		time.Sleep(30 * time.Millisecond)
+	}
+	if saved := store.savedCopy(); len(saved) != 0 {
+		t.Fatalf("saved while moving: %+v", saved)
+	}
+	movedAt := time.Now()
+
+	time.Sleep(300 * time.Millisecond)
+	saved := store.savedCopy()
+	if len(saved) != 1 || saved[0].noteId != noteId || saved[0].layoutId != layoutId || saved[0].x != 6 || saved[0].y != 2 {
+		t.Fatalf("saved = %+v", saved)
+	}
+	// позиция пишется со временем последнего перемещения, а не записи
+	if saved[0].movedAt.After(movedAt) {
+		t.Fatalf("movedAt = %v, after %v", saved[0].movedAt, movedAt)
+	}
+}
+
+func TestPersistMaxDelay(t *testing.T) {
+	store := newMemoryStore()
+	srv := newService(t, store, 10*time.Millisecond, time.Hour, 100*time.Millisecond)
+	layoutId, noteId := uuid.New(), uuid.New()
+
+	for i := range 20 {
+		srv.Move(layoutId, dto.NoteMove{NoteId: noteId, XPos: float64(i), YPos: 3})
+		time.Sleep(20 * time.Millisecond)
+	}
+	if saved := store.savedCopy(); len(saved) < 2 {
+		t.Fatalf("continuous drag saved %d times", len(saved))
+	}
+
+	srv.Stop()
+	saved := store.savedCopy()
+	if last := saved[len(saved)-1]; last.x != 19 {
+		t.Fatalf("last saved = %+v", last)
+	}
+}
+
+func TestStopFlushes(t *testing.T) {
+	store := newMemoryStore()
+	srv := newService(t, store, 10*time.Millisecond, time.Hour, time.Hour)
+	layoutId, first, second := uuid.New(), uuid.New(), uuid.New()
+
+	srv.Move(layoutId, dto.NoteMove{NoteId: first, XPos: 1, YPos: 1})
+	srv.Move(layoutId, dto.NoteMove{NoteId: second, XPos: 2, YPos: 2})
+	srv.Move(layoutId, dto.NoteMove{NoteId: first, XPos: 3, YPos: 3})
+	srv.Stop()
+
+	saved := store.savedCopy()
+	if len(saved) != 2 {
+		t.Fatalf("saved = %+v", saved)
+	}
+	for _, s := range saved {
+		if s.noteId == first && s.x != 3 || s.noteId == second && s.x != 2 {
+			t.Fatalf("saved = %+v", saved)
+		}
+	}
+	// повторный Stop ничего не ждет и не пишет
+	srv.Stop()
+	if again := store.savedCopy(); len(again) != 2 {
+		t.Fatalf("saved after second Stop = %+v", again)
+	}
+}
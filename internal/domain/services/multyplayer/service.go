@@ -7,23 +7,24 @@ import (
 // Service handles WebSocket connections and message proxying
 type Service struct {
 	// rooms maps noteId to a map of userId to connection
-	rooms map[string]map[string]*Connection
+	rooms map[string]map[string]Connection
 	mu    sync.RWMutex
 }
 
 // Connection represents a WebSocket connection
-type Connection struct {
-	// Send is a channel for sending messages to the client
-	Send chan []byte
+type Connection interface {
+	// SendRaw queues a message for the client without blocking.
+	// It returns an error once the connection is closed or was dropped as a slow consumer
+	SendRaw(data []byte) error
 
-	// Close is a function to close the connection
-	Close func() error
+	// Close closes the connection
+	Close() error
 }
 
 // NewService creates a new instance of Service
 func NewService() *Service {
 	return &Service{
-		rooms: make(map[string]map[string]*Connection),
+		rooms: make(map[string]map[string]Connection),
 	}
 }
 
@@ -31,13 +32,13 @@ func NewService() *Service {
 // userId - identifier of the user
 // noteId - room identifier
 // conn - WebSocket connection with send channel and close function
-func (s *Service) Connect(userId, noteId string, conn *Connection) {
+func (s *Service) Connect(userId, noteId string, conn Connection) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	// Create room if not exists
 	if _, exists := s.rooms[noteId]; !exists {
-		s.rooms[noteId] = make(map[string]*Connection)
+		s.rooms[noteId] = make(map[string]Connection)
 	}
 
 	// Store connection
@@ -67,38 +68,45 @@ func (s *Service) Disconnect(userId, noteId string) {
 func (s *Service) HandleMessage(userId, noteId string, message []byte) []byte {
 	s.mu.RLock()
 	room, exists := s.rooms[noteId]
-	s.mu.RUnlock()
-
 	if !exists {
+		s.mu.RUnlock()
 		return message
 	}
-
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	// Broadcast to all participants in the room
+	participants := make(map[string]Connection, len(room))
 	for id, conn := range room {
+		participants[id] = conn
+	}
+	s.mu.RUnlock()
+
+	// Broadcast to all participants in the room.
+	// SendRaw does not block, so a slow participant can't stall the sender
+	for id, conn := range participants {
 		// Skip sender if needed (uncomment line below)
 		// if id == userId { continue }
 
-		select {
-		case conn.Send <- message:
-			// Message sent successfully
-		default:
-			// Connection is dead, remove it
-			if conn.Close != nil {
-				conn.Close()
-			}
-			delete(room, id)
+		if err := conn.SendRaw(message); err != nil {
+			// Connection is dead or was dropped as a slow consumer, remove it
+			s.disconnect(id, noteId, conn)
 		}
 	}
 
-	// Clean up empty rooms
+	return message
+}
+
+// disconnect removes user from room only if it is still the same connection
+func (s *Service) disconnect(userId, noteId string, conn Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	room, exists := s.rooms[noteId]
+	if !exists || room[userId] != conn {
+		return
+	}
+	_ = conn.Close()
+	delete(room, userId)
 	if len(room) == 0 {
 		delete(s.rooms, noteId)
 	}
-
-	return message
 }
 
 // GetRoomParticipants returns list of user IDs in a room
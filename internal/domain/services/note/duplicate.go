@@ -0,0 +1,110 @@
+package note
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// CopyNotes копирует заметки с новыми id, вызывается внутри транзакции. notes как из GetFullNotesByIds:
+// payload и draft зашифрованы. Копии принадлежат ownerId и попадают в layoutId (uuid.Nil - в лейаут оригинала),
+// позиции сдвигаются на offset. Ручные связи копируются только между самими копиями, wiki-ссылки копий
+// разрешаются заново от имени ownerId. Метки ownerId переносятся на копии. Возвращает id копии по id оригинала
+func (srv *Service) CopyNotes(ctx context.Context, notes []dto.Note, ownerId, layoutId uuid.UUID, offset dto.Position) (map[uuid.UUID]uuid.UUID, error) {
+	copies := make(map[uuid.UUID]uuid.UUID, len(notes))
+	layouts := make(map[uuid.UUID]uuid.UUID, len(notes))
+	payloads := make(map[uuid.UUID]string, len(notes))
+	ids := make([]uuid.UUID, 0, len(notes))
+	for i := range notes {
+		item := notes[i]
+		item.Id = util.NewUUID()
+		item.OwnerId = ownerId
+		item.HaveAccess = []uuid.UUID{ownerId}
+		if layoutId != uuid.Nil {
+			item.LayoutId = layoutId
+		}
+		if item.Position != nil {
+			item.Position = &dto.Position{
+				XPos: item.Position.XPos + offset.XPos,
+				YPos: item.Position.YPos + offset.YPos,
+			}
+		}
+		err := srv.RessurectNotes(ctx, &item)
+		if err != nil {
+			return nil, errors.Wrap(err, "RessurectNotes")
+		}
+		err = srv.tagsRepo.CopyNoteTags(ctx, ownerId, notes[i].Id, item.Id)
+		if err != nil {
+			return nil, errors.Wrap(err, "srv.tagsRepo.CopyNoteTags")
+		}
+		plain, err := srv.decryptedNote(&entity.Note{Payload: item.Payload})
+		if err != nil {
+			// без текста wiki-ссылки копии не разрешить, копия останется без автоматических связей
+			srv.logger.WithCtx(ctx).Warnf("CopyNotes %s: %s", item.Id, err.Error())
+		} else {
+			payloads[item.Id] = plain.Payload
+		}
+		copies[notes[i].Id] = item.Id
+		layouts[item.Id] = item.LayoutId
+		ids = append(ids, notes[i].Id)
+	}
+
+	links, err := srv.linksRepo.GetAllLinks(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.linksRepo.GetAllLinks")
+	}
+	for _, l := range links {
+		first, ok := copies[l.FirstNoteId]
+		second, ok2 := copies[l.SecondNoteId]
+		if !ok || !ok2 || l.Auto {
+			continue
+		}
+		err = srv.linksRepo.LinkNotes(ctx, first, second)
+		if err != nil {
+			return nil, errors.Wrap(err, "srv.linksRepo.LinkNotes")
+		}
+		err = srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationUpsert, first, second, layouts[first]))
+		if err != nil {
+			return nil, err
+		}
+	}
+	// все копии уже созданы: ссылка по названию в новом лейауте найдет копию, а не оригинал
+	for _, id := range ids {
+		copyId := copies[id]
+		payload, ok := payloads[copyId]
+		if !ok {
+			continue
+		}
+		targets, _, err := srv.resolveWikiLinks(ctx, ownerId, copyId, layouts[copyId], payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := srv.syncWikiLinks(ctx, copyId, layouts[copyId], targets); err != nil {
+			return nil, err
+		}
+	}
+	return copies, nil
+}
+
+// DuplicateNotes копирует заметки одной транзакцией, см. CopyNotes
+func (srv *Service) DuplicateNotes(ctx context.Context, noteIds []uuid.UUID, userId, layoutId uuid.UUID, offset dto.Position) (map[uuid.UUID]uuid.UUID, error) {
+	notes, err := srv.noteRepo.GetFullNotesByIds(ctx, noteIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.noteRepo.GetFullNotesByIds")
+	}
+	if len(notes) != len(noteIds) {
+		return nil, apperrors.NoteNotFound
+	}
+	var copies map[uuid.UUID]uuid.UUID
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		copies, err = srv.CopyNotes(ctx, notes, userId, layoutId, offset)
+		return err
+	})
+	return copies, err
+}
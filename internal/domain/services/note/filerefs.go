@@ -0,0 +1,83 @@
+package note
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// fileRefsBatch сколько заметок индексируется за один проход
+const fileRefsBatch = 100
+
+// fileRefPattern ссылка на файл в тексте заметки. Миниатюры (<id>_256.jpg) и подписанные ссылки
+// ведут на тот же id, что и оригинал
+var fileRefPattern = regexp.MustCompile(`/statics/images/([0-9a-fA-F-]{36})`)
+
+type fileRefsRepo interface {
+	SetNoteFileRefs(ctx context.Context, noteId uuid.UUID, keys []string) error
+	GetPendingNotes(ctx context.Context, limit uint64) ([]entity.Note, error)
+	LockPendingNote(ctx context.Context, noteId uuid.UUID) (bool, error)
+}
+
+// fileRefs имена файлов без расширения, на которые ссылаются тексты
+func fileRefs(texts ...string) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, text := range texts {
+		for _, match := range fileRefPattern.FindAllStringSubmatch(text, -1) {
+			key := strings.ToLower(match[1])
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// decryptedNote копия заметки с расшифрованными payload и draft
+func (srv *Service) decryptedNote(n *entity.Note) (*entity.Note, error) {
+	plain := *n
+	if err := plain.DecryptNote(srv.encryptor); err != nil {
+		return nil, errors.Wrap(err, "DecryptNote")
+	}
+	return &plain, nil
+}
+
+// IndexFileRefs собирает ссылки на файлы из заметок, созданных до появления индекса.
+// Пока очередь не пуста, сборщик мусора не удаляет файлы
+func (srv *Service) IndexFileRefs(ctx context.Context) (int, error) {
+	indexed := 0
+	for {
+		notes, err := srv.fileRefsRepo.GetPendingNotes(ctx, fileRefsBatch)
+		if err != nil {
+			return indexed, errors.Wrap(err, "srv.fileRefsRepo.GetPendingNotes")
+		}
+		for i := range notes {
+			plain, err := srv.decryptedNote(&notes[i])
+			if err != nil {
+				// без текста ссылок не собрать, файлы заметки останутся без защиты от сборщика
+				srv.logger.WithCtx(ctx).Warnf("IndexFileRefs %s: %s", notes[i].Id, err.Error())
+				plain = &entity.Note{Id: notes[i].Id}
+			}
+			err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+				pending, err := srv.fileRefsRepo.LockPendingNote(ctx, plain.Id)
+				if err != nil || !pending {
+					return err
+				}
+				return srv.fileRefsRepo.SetNoteFileRefs(ctx, plain.Id, fileRefs(plain.Payload, plain.Draft))
+			})
+			if err != nil {
+				return indexed, errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+			}
+			indexed++
+		}
+		if len(notes) < fileRefsBatch || ctx.Err() != nil {
+			return indexed, nil
+		}
+	}
+}
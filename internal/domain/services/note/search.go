@@ -0,0 +1,73 @@
+package note
+
+import (
+	"context"
+	"wn/internal/entity"
+	"wn/pkg/textindex"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// searchIndexBatch сколько заметок индексируется за один проход
+	searchIndexBatch = 100
+
+	// titleWeight слово в заголовке важнее слова в тексте
+	titleWeight   = 3
+	payloadWeight = 1
+)
+
+type searchRepo interface {
+	SetNoteTokens(ctx context.Context, noteId uuid.UUID, tokens []textindex.Token) error
+	GetPendingNotes(ctx context.Context, after uuid.UUID, limit uint64) ([]entity.Note, error)
+	LockPendingNote(ctx context.Context, noteId uuid.UUID) (bool, error)
+}
+
+// indexNote заменяет поисковые токены и задачи заметки, title и payload в открытом виде
+func (srv *Service) indexNote(ctx context.Context, noteId uuid.UUID, title, payload string) error {
+	tokens := srv.indexer.Index(
+		textindex.Field{Text: title, Weight: titleWeight},
+		textindex.Field{Text: payload, Weight: payloadWeight},
+	)
+	if err := srv.searchRepo.SetNoteTokens(ctx, noteId, tokens); err != nil {
+		return errors.Wrap(err, "srv.searchRepo.SetNoteTokens")
+	}
+	return srv.setTasks(ctx, noteId, payload)
+}
+
+// IndexSearch строит поисковый индекс и список задач для заметок из очереди: созданных до появления индекса
+// или поставленных в очередь после смены ключа. Заметки, которые не удалось расшифровать, остаются в очереди
+func (srv *Service) IndexSearch(ctx context.Context) (int, error) {
+	indexed := 0
+	after := uuid.Nil
+	for {
+		notes, err := srv.searchRepo.GetPendingNotes(ctx, after, searchIndexBatch)
+		if err != nil {
+			return indexed, errors.Wrap(err, "srv.searchRepo.GetPendingNotes")
+		}
+		for i := range notes {
+			after = notes[i].Id
+			plain, err := srv.decryptedNote(&notes[i])
+			if err != nil {
+				// пустой payload стер бы токены и задачи заметки, поэтому ждем следующего запуска
+				srv.logger.WithCtx(ctx).Errorf("IndexSearch %s: %s", notes[i].Id, err.Error())
+				continue
+			}
+			err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+				pending, err := srv.searchRepo.LockPendingNote(ctx, plain.Id)
+				if err != nil || !pending {
+					return err
+				}
+				return srv.indexNote(ctx, plain.Id, plain.Title, plain.Payload)
+			})
+			if err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+		if len(notes) < searchIndexBatch || ctx.Err() != nil {
+			return indexed, nil
+		}
+	}
+}
@@ -2,13 +2,15 @@ package note
 
 import (
 	"context"
-	"encoding/json"
 	"math"
 	"sort"
+	"time"
 	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
 	"wn/internal/domain/services/crypto"
 	"wn/internal/entity"
 	"wn/pkg/applogger"
+	"wn/pkg/textindex"
 	"wn/pkg/trx"
 	"wn/pkg/util"
 
@@ -19,20 +21,24 @@ import (
 type noteRepo interface {
 	DeleteNoteById(ctx context.Context, noteId uuid.UUID) error
 	CreateNote(ctx context.Context, item *entity.Note) (uuid.UUID, error)
-	UpdateNote(ctx context.Context, newItem *entity.Note) error
-	GetNoteCountInLayout(ctx context.Context, layoutId uuid.UUID) (int, error)
-	GetNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID, offset, limit int) ([]entity.Note, error)
+	UpdateNote(ctx context.Context, noteId uuid.UUID, params *dto.UpdateNoteParams) (int64, error)
+	GetNoteCountInLayout(ctx context.Context, layoutId uuid.UUID, filter *dto.TagFilter) (int, error)
+	GetNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID, offset, limit int, filter *dto.TagFilter) ([]entity.Note, error)
 	GetNotesWithPosition(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]entity.NoteWithPosition, error)
 	GetNotesWithoutPosition(ctx context.Context, layoutId, userId uuid.UUID) ([]entity.Note, error)
-	SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]entity.Note, error)
-	UpdateDraftById(ctx context.Context, noteId uuid.UUID, newDraft string) error
-	CommitDraft(ctx context.Context, noteId uuid.UUID) error
+	SearchNotes(ctx context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) ([]entity.FoundNote, error)
+	CountSearchNotes(ctx context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) (int, error)
+	UpdateDraftById(ctx context.Context, noteId uuid.UUID, newDraft string, version *int64) (int64, error)
+	CommitDraft(ctx context.Context, noteId uuid.UUID, version *int64) (int64, error)
 	GetById(ctx context.Context, noteId uuid.UUID) (*entity.Note, error)
+	GetFullNotesByIds(ctx context.Context, noteIds []uuid.UUID) ([]dto.Note, error)
+	GetReadableNotes(ctx context.Context, userId uuid.UUID, ids []uuid.UUID, titles []string) ([]entity.Note, error)
 }
 
 type positionsRepo interface {
 	CreateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error
 	UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error
+	UpdateMovedNotePosition(ctx context.Context, noteId, layoutId uuid.UUID, xPos, yPos *float64, movedAt time.Time) (bool, error)
 	DeleteNotesPositionByNoteId(ctx context.Context, noteId uuid.UUID) error
 }
 
@@ -41,47 +47,90 @@ type linksRepo interface {
 	DeleteLink(ctx context.Context, firstNoteId, secondNoteId uuid.UUID) error
 	LinkNotes(ctx context.Context, firstNoteId, secondNoteId uuid.UUID) error
 	GetAllLinks(ctx context.Context, noteIds []uuid.UUID) ([]entity.Link, error)
+	SetAutoLinks(ctx context.Context, noteId uuid.UUID, targets []uuid.UUID) ([]uuid.UUID, []uuid.UUID, error)
+	GetBacklinks(ctx context.Context, noteId uuid.UUID) ([]entity.Link, error)
 }
 
 type layoutRepo interface {
 }
 
+type changesRepo interface {
+	RecordChange(ctx context.Context, item *entity.Change) error
+}
+
+type tagsRepo interface {
+	GetNotesTags(ctx context.Context, ownerId uuid.UUID, noteIds []uuid.UUID) (map[uuid.UUID][]string, error)
+	CopyNoteTags(ctx context.Context, ownerId, fromNoteId, toNoteId uuid.UUID) error
+}
+
 type Service struct {
 	tx        trx.TransactionManager
 	logger    applogger.Logger
 	encryptor *crypto.Encryptor
+	indexer   *textindex.Indexer
 
 	noteRepo      noteRepo
 	layoutRepo    layoutRepo
 	linksRepo     linksRepo
 	positionsRepo positionsRepo
+	changesRepo   changesRepo
+	fileRefsRepo  fileRefsRepo
+	tagsRepo      tagsRepo
+	searchRepo    searchRepo
+	tasksRepo     tasksRepo
 }
 
 func NewService(
 	tx trx.TransactionManager,
 	logger applogger.Logger,
 	encryptor *crypto.Encryptor,
+	indexer *textindex.Indexer,
 	noteRepo noteRepo,
 	layoutRepo layoutRepo,
 	linksRepo linksRepo,
 	positionsRepo positionsRepo,
+	changesRepo changesRepo,
+	fileRefsRepo fileRefsRepo,
+	tagsRepo tagsRepo,
+	searchRepo searchRepo,
+	tasksRepo tasksRepo,
 ) *Service {
 	return &Service{
 		tx:            tx,
 		logger:        logger,
 		encryptor:     encryptor,
+		indexer:       indexer,
 		noteRepo:      noteRepo,
 		layoutRepo:    layoutRepo,
 		linksRepo:     linksRepo,
 		positionsRepo: positionsRepo,
+		changesRepo:   changesRepo,
+		fileRefsRepo:  fileRefsRepo,
+		tagsRepo:      tagsRepo,
+		searchRepo:    searchRepo,
+		tasksRepo:     tasksRepo,
 	}
 }
 
 func (srv *Service) DeleteLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error {
-	return srv.linksRepo.DeleteLink(ctx, noteId1, noteId2)
+	note, err := srv.noteRepo.GetById(ctx, noteId1)
+	if err != nil {
+		return err
+	}
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		err := srv.linksRepo.DeleteLink(ctx, noteId1, noteId2)
+		if err != nil {
+			return err
+		}
+		return srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationDelete, noteId1, noteId2, note.LayoutId))
+	})
 }
 
 func (srv *Service) DeleteNoteById(ctx context.Context, noteId uuid.UUID) error {
+	note, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return err
+	}
 	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
 		err := srv.positionsRepo.DeleteNotesPositionByNoteId(ctx, noteId)
 		if err != nil {
@@ -95,13 +144,19 @@ func (srv *Service) DeleteNoteById(ctx context.Context, noteId uuid.UUID) error
 		if err != nil {
 			return err
 		}
-		return nil
+		return srv.recordNoteChange(ctx, enum.SyncOperationDelete, noteId, note.LayoutId)
 	})
 }
 
-func (srv *Service) CreateNote(ctx context.Context, title, payload string, ownerId, layoutId, mainLayoutId uuid.UUID) (uuid.UUID, error) {
+// CreateNote возвращает id заметки и wiki-ссылки, для которых не нашлось заметок
+func (srv *Service) CreateNote(ctx context.Context, title, payload string, ownerId, layoutId, mainLayoutId uuid.UUID) (uuid.UUID, []string, error) {
+	return srv.CreateNoteWithId(ctx, util.NewUUID(), title, payload, ownerId, layoutId)
+}
+
+// CreateNoteWithId создает заметку с id, выданным клиентом (офлайн синхронизация)
+func (srv *Service) CreateNoteWithId(ctx context.Context, noteId uuid.UUID, title, payload string, ownerId, layoutId uuid.UUID) (uuid.UUID, []string, error) {
 	n := entity.Note{
-		Id:         util.NewUUID(),
+		Id:         noteId,
 		Title:      title,
 		Payload:    payload,
 		CreatedAt:  util.GetCurrentUTCTime(),
@@ -109,13 +164,18 @@ func (srv *Service) CreateNote(ctx context.Context, title, payload string, owner
 		HaveAccess: []uuid.UUID{ownerId},
 		LayoutId:   layoutId,
 	}
+	refs := fileRefs(payload)
+	targets, unresolved, err := srv.resolveWikiLinks(ctx, ownerId, n.Id, layoutId, payload)
+	if err != nil {
+		return uuid.Nil, nil, err
+	}
 
-	err := n.EncryptNote(srv.encryptor)
+	err = n.EncryptNote(srv.encryptor)
 	if err != nil {
-		return uuid.Nil, err
+		return uuid.Nil, nil, err
 	}
 
-	return n.Id, srv.tx.Transaction(ctx, func(ctx context.Context) error {
+	return n.Id, unresolved, srv.tx.Transaction(ctx, func(ctx context.Context) error {
 		_, err := srv.noteRepo.CreateNote(ctx, &n)
 		if err != nil {
 			return errors.Wrap(err, "srv.noteRepo.CreateNote")
@@ -124,36 +184,103 @@ func (srv *Service) CreateNote(ctx context.Context, title, payload string, owner
 		if err != nil {
 			return errors.Wrap(err, "srv.noteRepo.CreateNote")
 		}
-		return nil
+		err = srv.fileRefsRepo.SetNoteFileRefs(ctx, n.Id, refs)
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+		}
+		err = srv.indexNote(ctx, n.Id, title, payload)
+		if err != nil {
+			return err
+		}
+		err = srv.recordNoteChange(ctx, enum.SyncOperationUpsert, n.Id, n.LayoutId)
+		if err != nil {
+			return err
+		}
+		return srv.syncWikiLinks(ctx, n.Id, n.LayoutId, targets)
 	})
 }
 
-func (srv *Service) UpdateNote(ctx context.Context, title, payload string, noteId uuid.UUID) error {
+// UpdateNote обновляет переданные поля, nil поля не трогаются. Возвращает новую версию
+// и wiki-ссылки из нового текста, для которых не нашлось заметок
+func (srv *Service) UpdateNote(ctx context.Context, noteId, userId uuid.UUID, title, payload *string, version *int64) (int64, []string, error) {
 	n, err := srv.noteRepo.GetById(ctx, noteId)
 	if err != nil {
-		return err
+		return 0, nil, err
 	}
 
-	n.Title = title
-	n.Payload = payload
+	params := dto.UpdateNoteParams{
+		Title:   title,
+		Version: version,
+	}
+	var refs []string
+	var targets []uuid.UUID
+	var unresolved []string
+	var plain *entity.Note
+	if title != nil || payload != nil {
+		// для поискового индекса нужен весь текст, даже если меняется только заголовок
+		plain, err = srv.decryptedNote(n)
+		if err != nil {
+			return 0, nil, err
+		}
+		if title != nil {
+			plain.Title = *title
+		}
+	}
+	if payload != nil {
+		plain.Payload = *payload
+		refs = fileRefs(*payload, plain.Draft)
+		targets, unresolved, err = srv.resolveWikiLinks(ctx, userId, noteId, n.LayoutId, *payload)
+		if err != nil {
+			return 0, nil, err
+		}
 
-	err = n.EncryptNote(srv.encryptor)
-	if err != nil {
-		return err
+		encrypted := *payload
+		if encrypted != "" {
+			encrypted, err = srv.encryptor.Encrypt(encrypted)
+			if err != nil {
+				return 0, nil, errors.Wrap(err, "srv.encryptor.Encrypt")
+			}
+		}
+		params.Payload = &encrypted
 	}
 
-	return srv.noteRepo.UpdateNote(ctx, n)
+	var newVersion int64
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		newVersion, err = srv.noteRepo.UpdateNote(ctx, noteId, &params)
+		if err != nil {
+			return err
+		}
+		if payload != nil {
+			err = srv.fileRefsRepo.SetNoteFileRefs(ctx, noteId, refs)
+			if err != nil {
+				return errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+			}
+			err = srv.syncWikiLinks(ctx, noteId, n.LayoutId, targets)
+			if err != nil {
+				return err
+			}
+		}
+		if plain != nil {
+			err = srv.indexNote(ctx, noteId, plain.Title, plain.Payload)
+			if err != nil {
+				return err
+			}
+		}
+		return srv.recordNoteChange(ctx, enum.SyncOperationUpsert, noteId, n.LayoutId)
+	})
+	return newVersion, unresolved, err
 }
 
-func (srv *Service) GetNotesWithPagination(ctx context.Context, page int, layoutId, userId uuid.UUID) ([]dto.Note, int, error) {
+// GetNotesWithPagination filter может быть nil
+func (srv *Service) GetNotesWithPagination(ctx context.Context, page int, layoutId, userId uuid.UUID, filter *dto.TagFilter) ([]dto.Note, int, error) {
 
-	count, err := srv.noteRepo.GetNoteCountInLayout(ctx, layoutId)
+	count, err := srv.noteRepo.GetNoteCountInLayout(ctx, layoutId, filter)
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "srv.noteRepo.GetNoteCountInLayout")
 	}
 	offset := util.CalculateOffset(page)
 	limit := util.CalculateLimit()
-	notes, err := srv.noteRepo.GetNotesByLayoutId(ctx, layoutId, userId, offset, limit)
+	notes, err := srv.noteRepo.GetNotesByLayoutId(ctx, layoutId, userId, offset, limit, filter)
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "srv.noteRepo.GetNotesByLayoutId")
 	}
@@ -164,8 +291,11 @@ func (srv *Service) GetNotesWithPagination(ctx context.Context, page int, layout
 	}
 
 	links, err := srv.linksRepo.GetAllLinks(ctx, getIds(notes))
+	if err != nil {
+		return nil, 0, err
+	}
 	notesDto := dto.NotesFromEntities(notes, links)
-	return notesDto, count, err
+	return notesDto, count, srv.fillTags(ctx, userId, notesDto)
 }
 
 func (srv *Service) GetNotesWithoutPosition(ctx context.Context, layoutId, userId uuid.UUID) ([]dto.Note, error) {
@@ -180,8 +310,11 @@ func (srv *Service) GetNotesWithoutPosition(ctx context.Context, layoutId, userI
 	}
 
 	links, err := srv.linksRepo.GetAllLinks(ctx, getIds(notes))
+	if err != nil {
+		return nil, err
+	}
 	notesDto := dto.NotesFromEntities(notes, links)
-	return notesDto, err
+	return notesDto, srv.fillTags(ctx, userId, notesDto)
 }
 
 func (srv *Service) GetNotesWithPosition(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]dto.Note, error) {
@@ -197,101 +330,239 @@ func (srv *Service) GetNotesWithPosition(ctx context.Context, userId uuid.UUID,
 	}
 
 	links, err := srv.linksRepo.GetAllLinks(ctx, getIds(notes))
+	if err != nil {
+		return nil, err
+	}
 	notesDto := dto.NotesFromEntitiesWithPosition(notes, links)
-	return notesDto, err
+	return notesDto, srv.fillTags(ctx, userId, notesDto)
 }
 
 func (srv *Service) UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error {
-	return srv.positionsRepo.UpdateNotePosition(ctx, noteId, xPos, yPos)
+	note, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return err
+	}
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		err := srv.positionsRepo.UpdateNotePosition(ctx, noteId, xPos, yPos)
+		if err != nil {
+			return err
+		}
+		return srv.changesRepo.RecordChange(ctx, entity.NewChange(enum.SyncEntityKindPosition, enum.SyncOperationUpsert, noteId, note.LayoutId))
+	})
 }
 
-func (srv *Service) CreateLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error {
-	return srv.linksRepo.LinkNotes(ctx, noteId1, noteId2)
+// UpdateMovedNotePosition отложенное сохранение перетаскивания. Если после movedAt позицию записали
+// другим путем или заметку перенесли, более новая запись остается
+func (srv *Service) UpdateMovedNotePosition(ctx context.Context, noteId, layoutId uuid.UUID, xPos, yPos *float64, movedAt time.Time) error {
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		updated, err := srv.positionsRepo.UpdateMovedNotePosition(ctx, noteId, layoutId, xPos, yPos, movedAt)
+		if err != nil {
+			return err
+		}
+		if !updated {
+			return nil
+		}
+		return srv.changesRepo.RecordChange(ctx, entity.NewChange(enum.SyncEntityKindPosition, enum.SyncOperationUpsert, noteId, layoutId))
+	})
 }
 
-func (srv *Service) DragNote(ctx context.Context, noteId, toLayout uuid.UUID) error {
-	note, err := srv.noteRepo.GetById(ctx, noteId)
+func (srv *Service) CreateLink(ctx context.Context, noteId1, noteId2 uuid.UUID) error {
+	note, err := srv.noteRepo.GetById(ctx, noteId1)
 	if err != nil {
 		return err
 	}
-	note.LayoutId = toLayout
-	return srv.noteRepo.UpdateNote(ctx, note)
+	return srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		err := srv.linksRepo.LinkNotes(ctx, noteId1, noteId2)
+		if err != nil {
+			return err
+		}
+		return srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationUpsert, noteId1, noteId2, note.LayoutId))
+	})
 }
 
-// todo добавлять беклинки?
-func (srv *Service) SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]dto.Note, error) {
-	notes, err := srv.noteRepo.SearchNotes(ctx, userId, search)
+func (srv *Service) DragNote(ctx context.Context, noteId, toLayout uuid.UUID, version *int64) (int64, error) {
+	note, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return 0, err
+	}
+	fromLayout := note.LayoutId
+	var newVersion int64
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		newVersion, err = srv.noteRepo.UpdateNote(ctx, noteId, &dto.UpdateNoteParams{
+			LayoutId: &toLayout,
+			Version:  version,
+		})
+		if err != nil {
+			return err
+		}
+		// для подписчиков старого лейаута заметка исчезла
+		err = srv.recordNoteChange(ctx, enum.SyncOperationDelete, noteId, fromLayout)
+		if err != nil {
+			return err
+		}
+		return srv.recordNoteChange(ctx, enum.SyncOperationUpsert, noteId, toLayout)
+	})
+	return newVersion, err
+}
+
+// SearchNotes страница поиска и сколько всего заметок подходит под запрос
+func (srv *Service) SearchNotes(ctx context.Context, userId uuid.UUID, params *dto.NoteSearch) (*dto.SearchNotesResponse, error) {
+	terms := srv.indexer.Query(params.Search)
+	limit := params.Limit
+	// лишняя заметка показывает, что есть следующая страница
+	page := *params
+	page.Limit = limit + 1
+	found, err := srv.noteRepo.SearchNotes(ctx, userId, terms, &page)
+	if err != nil {
+		return nil, err
+	}
+	total, err := srv.noteRepo.CountSearchNotes(ctx, userId, terms, params)
 	if err != nil {
 		return nil, err
 	}
 
+	resp := dto.SearchNotesResponse{Total: total}
+	if uint64(len(found)) > limit {
+		found = found[:limit]
+		last := found[len(found)-1]
+		cursor := dto.SearchCursor{Sort: params.Sort, Rank: last.Rank, Time: last.CreatedAt, Id: last.Id}
+		if params.Sort == enum.NoteSortUpdated {
+			cursor.Time = last.UpdatedAt
+		}
+		resp.NextCursor = cursor.Encode()
+	}
+
+	notes := make([]entity.Note, 0, len(found))
+	for i := range found {
+		notes = append(notes, found[i].Note)
+	}
 	notes, err = srv.decryptSliceNotes(notes)
 	if err != nil {
 		return nil, err
 	}
 
-	notesDto := dto.NotesFromEntities(notes, nil)
-	return notesDto, err
+	links, err := srv.linksRepo.GetAllLinks(ctx, getIds(notes))
+	if err != nil {
+		return nil, err
+	}
+	resp.Notes = dto.NotesFromEntities(notes, links)
+	return &resp, srv.fillTags(ctx, userId, resp.Notes)
 }
 
-func (srv *Service) HandleCreateDraft(msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
-	ctx := context.Background()
-	var item dto.DraftNote
-	err := json.Unmarshal(msg.Payload, &item)
+// fillTags проставляет заметкам метки пользователя
+func (srv *Service) fillTags(ctx context.Context, userId uuid.UUID, notes []dto.Note) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	ids := make([]uuid.UUID, 0, len(notes))
+	for _, n := range notes {
+		ids = append(ids, n.Id)
+	}
+	tags, err := srv.tagsRepo.GetNotesTags(ctx, userId, ids)
 	if err != nil {
-		return &dto.SocketMessage{
-			Event:   "COMMIT_DRAFT_RESPONSE",
-			Payload: []byte("{\"status\": \"false\"}"),
-		}, err
+		return errors.Wrap(err, "srv.tagsRepo.GetNotesTags")
+	}
+	for i := range notes {
+		notes[i].Tags = tags[notes[i].Id]
 	}
+	return nil
+}
 
-	n, err := srv.noteRepo.GetById(ctx, item.NoteId)
+// UpdateDraft как и UpdateNote проверяет version, если она передана. Возвращает новую версию
+func (srv *Service) UpdateDraft(ctx context.Context, noteId uuid.UUID, draft string, version *int64) (int64, error) {
+	plainDraft := draft
+	if draft != "" {
+		encrypted, err := srv.encryptor.Encrypt(draft)
+		if err != nil {
+			return 0, errors.Wrap(err, "srv.encryptor.Encrypt")
+		}
+		draft = encrypted
+	}
+	note, err := srv.noteRepo.GetById(ctx, noteId)
 	if err != nil {
-		return &dto.SocketMessage{
-			Event:   "COMMIT_DRAFT_RESPONSE",
-			Payload: []byte("{\"status\": \"false\"}"),
-		}, err
+		return 0, err
 	}
-
-	if err := n.DecryptNote(srv.encryptor); err != nil {
-		return &dto.SocketMessage{
-			Event:   "COMMIT_DRAFT_RESPONSE",
-			Payload: []byte("{\"status\": \"false\"}"),
-		}, err
+	plain, err := srv.decryptedNote(note)
+	if err != nil {
+		return 0, err
 	}
-
-	n.Draft = item.NewDraft
-
-	if err := n.EncryptNote(srv.encryptor); err != nil {
-		return &dto.SocketMessage{
-			Event:   "COMMIT_DRAFT_RESPONSE",
-			Payload: []byte("{\"status\": \"false\"}"),
-		}, err
+	var newVersion int64
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		newVersion, err = srv.noteRepo.UpdateDraftById(ctx, noteId, draft, version)
+		if err != nil {
+			return err
+		}
+		err = srv.fileRefsRepo.SetNoteFileRefs(ctx, noteId, fileRefs(plain.Payload, plainDraft))
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+		}
+		// черновик в ленту не пишется: автосохранение приходит на каждое нажатие,
+		// изменение появится при CommitDraft
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
-
-	err = srv.noteRepo.UpdateNote(ctx, n)
-	return &dto.SocketMessage{
-		Event:   "UPDATE_DRAFT_RESPONSE",
-		Payload: []byte("{\"status\": \"true\"}"),
-	}, nil
+	return newVersion, nil
 }
 
-func (srv *Service) HandleCommitDraft(msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error) {
-	ctx := context.Background()
-	var item dto.CommitDraftNote
-	err := json.Unmarshal(msg.Payload, &item)
+// CommitDraft как и UpdateNote проверяет version, если она передана. Возвращает новую версию
+// и wiki-ссылки из черновика, для которых не нашлось заметок
+func (srv *Service) CommitDraft(ctx context.Context, noteId, userId uuid.UUID, version *int64) (int64, []string, error) {
+	note, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return 0, nil, err
+	}
+	plain, err := srv.decryptedNote(note)
+	if err != nil {
+		return 0, nil, err
+	}
+	targets, unresolved, err := srv.resolveWikiLinks(ctx, userId, noteId, note.LayoutId, plain.Draft)
+	if err != nil {
+		return 0, nil, err
+	}
+	var newVersion int64
+	err = srv.tx.Transaction(ctx, func(ctx context.Context) error {
+		newVersion, err = srv.noteRepo.CommitDraft(ctx, noteId, version)
+		if err != nil {
+			return err
+		}
+		// текст заменяется черновиком
+		err = srv.fileRefsRepo.SetNoteFileRefs(ctx, noteId, fileRefs(plain.Draft))
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+		}
+		err = srv.syncWikiLinks(ctx, noteId, note.LayoutId, targets)
+		if err != nil {
+			return err
+		}
+		err = srv.indexNote(ctx, noteId, note.Title, plain.Draft)
+		if err != nil {
+			return err
+		}
+		return srv.recordNoteChange(ctx, enum.SyncOperationUpsert, noteId, note.LayoutId)
+	})
 	if err != nil {
-		return &dto.SocketMessage{
-			Event:   "COMMIT_DRAFT_RESPONSE",
-			Payload: []byte("{\"status\": \"false\"}"),
-		}, err
+		return 0, nil, err
 	}
+	return newVersion, unresolved, nil
+}
 
-	err = srv.noteRepo.CommitDraft(ctx, item.NoteId)
-	return &dto.SocketMessage{
-		Event:   "COMMIT_DRAFT_RESPONSE",
-		Payload: []byte("{\"status\": \"true\"}"),
-	}, nil
+// GetFullNotesByIds заметки с позициями и связями для ленты изменений
+func (srv *Service) GetFullNotesByIds(ctx context.Context, noteIds []uuid.UUID) ([]dto.Note, error) {
+	notes, err := srv.noteRepo.GetFullNotesByIds(ctx, noteIds)
+	if err != nil {
+		return nil, err
+	}
+	for i := range notes {
+		n := entity.Note{Payload: notes[i].Payload, Draft: notes[i].Draft}
+		err := n.DecryptNote(srv.encryptor)
+		if err != nil {
+			return nil, err
+		}
+		notes[i].Payload, notes[i].Draft = n.Payload, n.Draft
+	}
+	return notes, nil
 }
 
 func (srv *Service) GenerateCluster(notes []dto.Note) []dto.Note {
@@ -516,7 +787,7 @@ func calculateClusterBounds(notes []dto.Note) (minX, minY, maxX, maxY float64) {
 }
 
 func (srv *Service) RessurectNotes(ctx context.Context, item *dto.Note) error {
-	_, err := srv.noteRepo.CreateNote(ctx, &entity.Note{
+	n := entity.Note{
 		Id:         item.Id,
 		Title:      item.Title,
 		Payload:    item.Payload,
@@ -525,7 +796,25 @@ func (srv *Service) RessurectNotes(ctx context.Context, item *dto.Note) error {
 		HaveAccess: item.HaveAccess,
 		Draft:      item.Draft,
 		LayoutId:   item.LayoutId,
-	})
+	}
+	plain, decryptErr := srv.decryptedNote(&n)
+	if decryptErr != nil {
+		// заметка найдется хотя бы по заголовку
+		srv.logger.WithCtx(ctx).Warnf("RessurectNotes %s: %s", item.Id, decryptErr.Error())
+		plain = &entity.Note{Title: item.Title}
+	}
+	_, err := srv.noteRepo.CreateNote(ctx, &n)
+	if err != nil {
+		return err
+	}
+	if decryptErr == nil {
+		// без ссылок сборщик мусора сочтет файлы заметки ненужными
+		err = srv.fileRefsRepo.SetNoteFileRefs(ctx, item.Id, fileRefs(plain.Payload, plain.Draft))
+		if err != nil {
+			return errors.Wrap(err, "srv.fileRefsRepo.SetNoteFileRefs")
+		}
+	}
+	err = srv.indexNote(ctx, item.Id, plain.Title, plain.Payload)
 	if err != nil {
 		return err
 	}
@@ -533,7 +822,15 @@ func (srv *Service) RessurectNotes(ctx context.Context, item *dto.Note) error {
 	if item.Position != nil {
 		xPos, yPos = &item.Position.XPos, &item.Position.YPos
 	}
-	return srv.positionsRepo.CreateNotePosition(ctx, item.Id, xPos, yPos)
+	err = srv.positionsRepo.CreateNotePosition(ctx, item.Id, xPos, yPos)
+	if err != nil {
+		return err
+	}
+	return srv.recordNoteChange(ctx, enum.SyncOperationUpsert, item.Id, item.LayoutId)
+}
+
+func (srv *Service) recordNoteChange(ctx context.Context, operation enum.SyncOperation, noteId, layoutId uuid.UUID) error {
+	return srv.changesRepo.RecordChange(ctx, entity.NewChange(enum.SyncEntityKindNote, operation, noteId, layoutId))
 }
 
 func (srv *Service) decryptSliceNotes(e []entity.Note) ([]entity.Note, error) {
@@ -0,0 +1,791 @@
+package note_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	"wn/internal/domain/services/crypto"
+	notesrv "wn/internal/domain/services/note"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+	"wn/pkg/textindex"
+
+	"github.com/google/uuid"
+)
+
+type link struct {
+	first, second uuid.UUID
+}
+
+// memoryStore заметки и связи в памяти. Пользователь может читать свои заметки и заметки из shared
+type memoryStore struct {
+	notes  map[uuid.UUID]*entity.Note
+	shared map[uuid.UUID]bool
+	links  map[link]bool // значение - связь из wiki-ссылки
+	tokens map[uuid.UUID]map[string]float64
+	pos    map[uuid.UUID]dto.Position
+	tasks  map[uuid.UUID][]entity.Task
+	// pending очередь поисковой индексации
+	pending map[uuid.UUID]bool
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		notes:   map[uuid.UUID]*entity.Note{},
+		shared:  map[uuid.UUID]bool{},
+		links:   map[link]bool{},
+		tokens:  map[uuid.UUID]map[string]float64{},
+		pos:     map[uuid.UUID]dto.Position{},
+		tasks:   map[uuid.UUID][]entity.Task{},
+		pending: map[uuid.UUID]bool{},
+	}
+}
+
+func (s *memoryStore) DeleteNoteById(_ context.Context, noteId uuid.UUID) error {
+	delete(s.notes, noteId)
+	return nil
+}
+
+func (s *memoryStore) CreateNote(_ context.Context, item *entity.Note) (uuid.UUID, error) {
+	stored := *item
+	s.notes[item.Id] = &stored
+	return item.Id, nil
+}
+
+func (s *memoryStore) UpdateNote(_ context.Context, noteId uuid.UUID, params *dto.UpdateNoteParams) (int64, error) {
+	n, ok := s.notes[noteId]
+	if !ok {
+		return 0, apperrors.NoteNotFound
+	}
+	if params.Version != nil && *params.Version != n.Version {
+		return 0, apperrors.VersionConflict
+	}
+	if params.Title != nil {
+		n.Title = *params.Title
+	}
+	if params.Payload != nil {
+		n.Payload = *params.Payload
+	}
+	n.Version++
+	return n.Version, nil
+}
+
+func (s *memoryStore) GetNoteCountInLayout(context.Context, uuid.UUID, *dto.TagFilter) (int, error) {
+	return 0, nil
+}
+
+func (s *memoryStore) GetNotesByLayoutId(context.Context, uuid.UUID, uuid.UUID, int, int, *dto.TagFilter) ([]entity.Note, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GetNotesWithPosition(context.Context, uuid.UUID, []uuid.UUID) ([]entity.NoteWithPosition, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) GetNotesWithoutPosition(context.Context, uuid.UUID, uuid.UUID) ([]entity.Note, error) {
+	return nil, nil
+}
+
+// SearchNotes заметки пользователя, в которых есть все слова запроса, новые первыми
+func (s *memoryStore) SearchNotes(_ context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) ([]entity.FoundNote, error) {
+	var out []entity.FoundNote
+	for _, n := range s.notes {
+		if n.OwnerId != userId {
+			continue
+		}
+		matched := true
+		for _, term := range terms {
+			_, exact := s.tokens[n.Id][string(term.Exact)]
+			_, prefix := s.tokens[n.Id][string(term.Prefix)]
+			matched = matched && (exact || prefix)
+		}
+		if matched {
+			out = append(out, entity.FoundNote{Note: *n, Rank: "0"})
+		}
+	}
+	after := func(a, b entity.Note) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return bytes.Compare(a.Id[:], b.Id[:]) > 0
+	}
+	sort.Slice(out, func(i, j int) bool { return after(out[i].Note, out[j].Note) })
+	if params.After != nil {
+		cursor := entity.Note{Id: params.After.Id, CreatedAt: params.After.Time}
+		for len(out) > 0 && !after(cursor, out[0].Note) {
+			out = out[1:]
+		}
+	}
+	if params.Limit > 0 && uint64(len(out)) > params.Limit {
+		out = out[:params.Limit]
+	}
+	return out, nil
+}
+
+func (s *memoryStore) CountSearchNotes(ctx context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) (int, error) {
+	all := *params
+	all.After, all.Limit = nil, 0
+	notes, err := s.SearchNotes(ctx, userId, terms, &all)
+	return len(notes), err
+}
+
+func (s *memoryStore) UpdateDraftById(_ context.Context, noteId uuid.UUID, newDraft string, version *int64) (int64, error) {
+	n := s.notes[noteId]
+	if version != nil && *version != n.Version {
+		return 0, apperrors.VersionConflict
+	}
+	n.Draft = newDraft
+	n.Version++
+	return n.Version, nil
+}
+
+func (s *memoryStore) CommitDraft(_ context.Context, noteId uuid.UUID, version *int64) (int64, error) {
+	n := s.notes[noteId]
+	if version != nil && *version != n.Version {
+		return 0, apperrors.VersionConflict
+	}
+	n.Payload, n.Draft = n.Draft, ""
+	n.Version++
+	return n.Version, nil
+}
+
+func (s *memoryStore) GetById(_ context.Context, noteId uuid.UUID) (*entity.Note, error) {
+	n, ok := s.notes[noteId]
+	if !ok {
+		return nil, apperrors.RecordNotFound
+	}
+	copied := *n
+	return &copied, nil
+}
+
+func (s *memoryStore) GetFullNotesByIds(_ context.Context, noteIds []uuid.UUID) ([]dto.Note, error) {
+	var out []dto.Note
+	for _, id := range noteIds {
+		n, ok := s.notes[id]
+		if !ok {
+			continue
+		}
+		item := dto.Note{
+			Id:         n.Id,
+			Title:      n.Title,
+			Payload:    n.Payload,
+			OwnerId:    n.OwnerId,
+			HaveAccess: n.HaveAccess,
+			Draft:      n.Draft,
+			LayoutId:   n.LayoutId,
+		}
+		if p, ok := s.pos[id]; ok {
+			item.Position = &p
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetReadableNotes(_ context.Context, userId uuid.UUID, ids []uuid.UUID, titles []string) ([]entity.Note, error) {
+	var out []entity.Note
+	for _, n := range s.notes {
+		if n.OwnerId != userId && !s.shared[n.Id] {
+			continue
+		}
+		for _, id := range ids {
+			if n.Id == id {
+				out = append(out, *n)
+			}
+		}
+		for _, title := range titles {
+			if strings.ToLower(n.Title) == title {
+				out = append(out, *n)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+func (s *memoryStore) DeleteLinksWithNote(context.Context, uuid.UUID) error { return nil }
+
+func (s *memoryStore) DeleteLink(_ context.Context, first, second uuid.UUID) error {
+	delete(s.links, link{first, second})
+	return nil
+}
+
+func (s *memoryStore) LinkNotes(_ context.Context, first, second uuid.UUID) error {
+	s.links[link{first, second}] = false
+	return nil
+}
+
+func (s *memoryStore) GetAllLinks(_ context.Context, noteIds []uuid.UUID) ([]entity.Link, error) {
+	ids := map[uuid.UUID]bool{}
+	for _, id := range noteIds {
+		ids[id] = true
+	}
+	var out []entity.Link
+	for l, auto := range s.links {
+		if ids[l.first] || ids[l.second] {
+			out = append(out, entity.Link{FirstNoteId: l.first, SecondNoteId: l.second, Auto: auto})
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) SetAutoLinks(_ context.Context, noteId uuid.UUID, targets []uuid.UUID) ([]uuid.UUID, []uuid.UUID, error) {
+	want := map[uuid.UUID]bool{}
+	for _, id := range targets {
+		want[id] = true
+	}
+	var added, removed []uuid.UUID
+	for l, auto := range s.links {
+		if l.first == noteId && auto && !want[l.second] {
+			delete(s.links, l)
+			removed = append(removed, l.second)
+		}
+	}
+	for _, id := range targets {
+		if _, ok := s.links[link{noteId, id}]; !ok {
+			s.links[link{noteId, id}] = true
+			added = append(added, id)
+		}
+	}
+	return added, removed, nil
+}
+
+func (s *memoryStore) GetBacklinks(_ context.Context, noteId uuid.UUID) ([]entity.Link, error) {
+	var out []entity.Link
+	for l, auto := range s.links {
+		if l.second == noteId {
+			out = append(out, entity.Link{FirstNoteId: l.first, SecondNoteId: l.second, Auto: auto})
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) CreateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error {
+	return s.UpdateNotePosition(ctx, noteId, xPos, yPos)
+}
+
+func (s *memoryStore) UpdateNotePosition(_ context.Context, noteId uuid.UUID, xPos, yPos *float64) error {
+	if xPos != nil && yPos != nil {
+		s.pos[noteId] = dto.Position{XPos: *xPos, YPos: *yPos}
+	}
+	return nil
+}
+
+func (s *memoryStore) UpdateMovedNotePosition(ctx context.Context, noteId, _ uuid.UUID, xPos, yPos *float64, _ time.Time) (bool, error) {
+	return true, s.UpdateNotePosition(ctx, noteId, xPos, yPos)
+}
+
+func (s *memoryStore) DeleteNotesPositionByNoteId(context.Context, uuid.UUID) error { return nil }
+
+func (s *memoryStore) RecordChange(context.Context, *entity.Change) error { return nil }
+
+func (s *memoryStore) SetNoteFileRefs(context.Context, uuid.UUID, []string) error { return nil }
+
+func (s *memoryStore) GetPendingNotes(context.Context, uint64) ([]entity.Note, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) LockPendingNote(context.Context, uuid.UUID) (bool, error) { return false, nil }
+
+// searchQueue очередь поисковой индексации, у файловых ссылок своя очередь с другой сигнатурой
+type searchQueue struct {
+	*memoryStore
+}
+
+func (q searchQueue) GetPendingNotes(_ context.Context, after uuid.UUID, limit uint64) ([]entity.Note, error) {
+	var notes []entity.Note
+	for id := range q.pending {
+		if bytes.Compare(id[:], after[:]) > 0 {
+			notes = append(notes, *q.notes[id])
+		}
+	}
+	sort.Slice(notes, func(i, j int) bool { return bytes.Compare(notes[i].Id[:], notes[j].Id[:]) < 0 })
+	if uint64(len(notes)) > limit {
+		notes = notes[:limit]
+	}
+	return notes, nil
+}
+
+func (q searchQueue) LockPendingNote(_ context.Context, noteId uuid.UUID) (bool, error) {
+	return q.pending[noteId], nil
+}
+
+func (s *memoryStore) SetNoteTokens(_ context.Context, noteId uuid.UUID, tokens []textindex.Token) error {
+	delete(s.pending, noteId)
+	s.tokens[noteId] = map[string]float64{}
+	for _, token := range tokens {
+		s.tokens[noteId][string(token.Hash)] = token.Weight
+	}
+	return nil
+}
+
+func (s *memoryStore) GetNotesTags(context.Context, uuid.UUID, []uuid.UUID) (map[uuid.UUID][]string, error) {
+	return nil, nil
+}
+
+func (s *memoryStore) CopyNoteTags(context.Context, uuid.UUID, uuid.UUID, uuid.UUID) error {
+	return nil
+}
+
+func (s *memoryStore) SetNoteTasks(_ context.Context, noteId uuid.UUID, tasks []entity.Task) error {
+	s.tasks[noteId] = tasks
+	return nil
+}
+
+// GetTasks фильтрует только по заметке, отметке и исполнителю
+func (s *memoryStore) GetTasks(_ context.Context, userId uuid.UUID, params *dto.TaskFilter) ([]entity.FoundTask, error) {
+	var out []entity.FoundTask
+	for noteId, tasks := range s.tasks {
+		n := s.notes[noteId]
+		if n == nil || n.OwnerId != userId && !s.shared[noteId] || params.NoteId != nil && *params.NoteId != noteId {
+			continue
+		}
+		for _, task := range tasks {
+			if params.Done != nil && *params.Done != task.Done {
+				continue
+			}
+			if params.Assignee != "" && !slices.Contains(task.Assignees, params.Assignee) {
+				continue
+			}
+			task.NoteId = noteId
+			out = append(out, entity.FoundTask{Task: task, Title: n.Title, LayoutId: n.LayoutId, NoteVersion: n.Version})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Line < out[j].Line })
+	return out, nil
+}
+
+func (s *memoryStore) CountTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) (int, error) {
+	found, err := s.GetTasks(ctx, userId, params)
+	return len(found), err
+}
+
+// outLinks связи из заметки: id -> связь из wiki-ссылки
+func (s *memoryStore) outLinks(noteId uuid.UUID) map[uuid.UUID]bool {
+	out := map[uuid.UUID]bool{}
+	for l, auto := range s.links {
+		if l.first == noteId {
+			out[l.second] = auto
+		}
+	}
+	return out
+}
+
+func newService(t *testing.T) (*notesrv.Service, *memoryStore) {
+	lgr := testutil.Logger(t)
+	s := newMemoryStore()
+	return notesrv.NewService(testutil.NoTx{}, lgr, crypto.NewEncryptor("test"), textindex.NewIndexer("test"), s, nil, s, s, s, s, s, searchQueue{s}, s), s
+}
+
+func TestWikiLinks(t *testing.T) {
+	ctx := context.Background()
+	owner, stranger := uuid.New(), uuid.New()
+	layoutId := uuid.New()
+
+	srv, store := newService(t)
+	mustCreate := func(userId uuid.UUID, title, payload string) (uuid.UUID, []string) {
+		t.Helper()
+		id, unresolved, err := srv.CreateNote(ctx, title, payload, userId, layoutId, uuid.Nil)
+		if err != nil {
+			t.Fatalf("CreateNote() error = %v", err)
+		}
+		return id, unresolved
+	}
+
+	target, _ := mustCreate(owner, "Project Plan", "")
+	other, _ := mustCreate(owner, "Other", "")
+	hidden, _ := mustCreate(stranger, "Secret", "")
+
+	t.Run("create", func(t *testing.T) {
+		payload := "see [[project plan]], [[" + other.String() + "|alias]], [[Missing]] and [[Secret]]"
+		noteId, unresolved := mustCreate(owner, "Source", payload)
+		if want := []string{"Missing", "Secret"}; !reflect.DeepEqual(unresolved, want) {
+			t.Fatalf("unresolved = %v, want %v", unresolved, want)
+		}
+		got := store.outLinks(noteId)
+		if want := map[uuid.UUID]bool{target: true, other: true}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("links = %v, want %v", got, want)
+		}
+		if _, ok := got[hidden]; ok {
+			t.Fatalf("linked a note the user cannot read")
+		}
+	})
+
+	t.Run("update keeps manual links", func(t *testing.T) {
+		noteId, _ := mustCreate(owner, "Source 2", "[[Project Plan]] [[Other]]")
+		if err := srv.CreateLink(ctx, noteId, other); err != nil {
+			t.Fatalf("CreateLink() error = %v", err)
+		}
+
+		payload := "only [[Secret]] now"
+		_, unresolved, err := srv.UpdateNote(ctx, noteId, owner, nil, &payload, nil)
+		if err != nil {
+			t.Fatalf("UpdateNote() error = %v", err)
+		}
+		if want := []string{"Secret"}; !reflect.DeepEqual(unresolved, want) {
+			t.Fatalf("unresolved = %v, want %v", unresolved, want)
+		}
+		if got, want := store.outLinks(noteId), map[uuid.UUID]bool{other: false}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("links = %v, want %v", got, want)
+		}
+
+		store.shared[hidden] = true
+		draft := "[[Secret]] and [[" + noteId.String() + "]]"
+		if _, err := srv.UpdateDraft(ctx, noteId, draft, nil); err != nil {
+			t.Fatalf("UpdateDraft() error = %v", err)
+		}
+		_, unresolved, err = srv.CommitDraft(ctx, noteId, owner, nil)
+		if err != nil || len(unresolved) != 0 {
+			t.Fatalf("CommitDraft() = %v, %v, want no unresolved", unresolved, err)
+		}
+		if got, want := store.outLinks(noteId), map[uuid.UUID]bool{other: false, hidden: true}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("links = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestBacklinks(t *testing.T) {
+	ctx := context.Background()
+	owner, stranger := uuid.New(), uuid.New()
+	layoutId := uuid.New()
+	srv, store := newService(t)
+
+	target, _, err := srv.CreateNote(ctx, "Target", "", owner, layoutId, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	long := strings.Repeat("word ", 40)
+	linking, _, err := srv.CreateNote(ctx, "Linking", long+"about\n[[target|the target]] here", owner, layoutId, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	manual, _, err := srv.CreateNote(ctx, "Manual", "no reference", owner, layoutId, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	if err := srv.CreateLink(ctx, manual, target); err != nil {
+		t.Fatalf("CreateLink() error = %v", err)
+	}
+	foreign, _, err := srv.CreateNote(ctx, "Foreign", "", stranger, layoutId, uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	store.links[link{foreign, target}] = false
+
+	backlinks, err := srv.GetBacklinks(ctx, target, owner)
+	if err != nil {
+		t.Fatalf("GetBacklinks() error = %v", err)
+	}
+	got := map[uuid.UUID]string{}
+	for _, b := range backlinks {
+		got[b.NoteId] = b.Snippet
+		if b.Auto != (b.NoteId == linking) {
+			t.Fatalf("backlink %s auto = %v", b.Title, b.Auto)
+		}
+	}
+	if len(got) != 2 || got[manual] != "" {
+		t.Fatalf("backlinks = %v, want linking and manual notes", got)
+	}
+	if want := "…" + strings.TrimSpace(strings.Repeat("word ", 14)) + " about [[target|the target]] here"; got[linking] != want {
+		t.Fatalf("snippet = %q, want %q", got[linking], want)
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	srv, store := newService(t)
+
+	id, _, err := srv.CreateNote(ctx, "Отпуск", "купить билеты на поезд", owner, uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	search := func(query string) int {
+		t.Helper()
+		found, err := srv.SearchNotes(ctx, owner, &dto.NoteSearch{Search: query, Limit: 10})
+		if err != nil {
+			t.Fatalf("SearchNotes() error = %v", err)
+		}
+		return len(found.Notes)
+	}
+
+	t.Run("payload is encrypted but searchable", func(t *testing.T) {
+		if strings.Contains(store.notes[id].Payload, "билеты") {
+			t.Fatal("payload stored in plain text")
+		}
+		if got := search("билет поезда"); got != 1 {
+			t.Fatalf("SearchNotes() = %d notes, want 1", got)
+		}
+		if got := search("билет самолет"); got != 0 {
+			t.Fatalf("SearchNotes() = %d notes, want 0", got)
+		}
+	})
+
+	t.Run("title update keeps payload tokens", func(t *testing.T) {
+		title := "Командировка"
+		if _, _, err := srv.UpdateNote(ctx, id, owner, &title, nil, nil); err != nil {
+			t.Fatalf("UpdateNote() error = %v", err)
+		}
+		if got := search("командир поезд"); got != 1 {
+			t.Fatalf("SearchNotes() = %d notes, want 1", got)
+		}
+		if got := search("отпуск"); got != 0 {
+			t.Fatalf("SearchNotes() = %d notes, want 0", got)
+		}
+	})
+
+	t.Run("committed draft replaces payload tokens", func(t *testing.T) {
+		if _, err := srv.UpdateDraft(ctx, id, "забронировать гостиницу", nil); err != nil {
+			t.Fatalf("UpdateDraft() error = %v", err)
+		}
+		if got := search("гостиница"); got != 0 {
+			t.Fatal("draft is searchable before commit")
+		}
+		if _, _, err := srv.CommitDraft(ctx, id, owner, nil); err != nil {
+			t.Fatalf("CommitDraft() error = %v", err)
+		}
+		if got := search("гостиница"); got != 1 {
+			t.Fatalf("SearchNotes() = %d notes, want 1", got)
+		}
+		if got := search("поезд"); got != 0 {
+			t.Fatalf("SearchNotes() = %d notes, want 0", got)
+		}
+	})
+
+	t.Run("undecryptable note stays pending", func(t *testing.T) {
+		broken, _, err := srv.CreateNote(ctx, "Сломанная", "старый ключ", owner, uuid.New(), uuid.Nil)
+		if err != nil {
+			t.Fatalf("CreateNote() error = %v", err)
+		}
+		store.notes[broken].Payload = "not encrypted"
+		store.pending[id], store.pending[broken] = true, true
+
+		indexed, err := srv.IndexSearch(ctx)
+		if err != nil || indexed != 1 {
+			t.Fatalf("IndexSearch() = %d, %v, want 1", indexed, err)
+		}
+		if store.pending[id] || !store.pending[broken] {
+			t.Fatalf("pending = %v", store.pending)
+		}
+		// токены сломанной заметки не стерты пустым payload
+		if len(store.tokens[broken]) == 0 {
+			t.Fatal("tokens of undecryptable note are erased")
+		}
+	})
+}
+
+func TestDraftVersion(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	srv, store := newService(t)
+	id, _, err := srv.CreateNote(ctx, "note", "text", owner, uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+	stale := store.notes[id].Version
+
+	// автосохранение черновика тоже меняет версию
+	version, err := srv.UpdateDraft(ctx, id, "draft", &stale)
+	if err != nil || version != stale+1 {
+		t.Fatalf("UpdateDraft() = %d, %v, want %d", version, err, stale+1)
+	}
+	if _, err := srv.UpdateDraft(ctx, id, "lost", &stale); err != apperrors.VersionConflict {
+		t.Fatalf("UpdateDraft() with stale version error = %v", err)
+	}
+	if _, _, err := srv.CommitDraft(ctx, id, owner, &stale); err != apperrors.VersionConflict {
+		t.Fatalf("CommitDraft() with stale version error = %v", err)
+	}
+	if n := store.notes[id]; n.Version != version {
+		t.Fatalf("version after conflicts = %d, want %d", n.Version, version)
+	}
+
+	committed, _, err := srv.CommitDraft(ctx, id, owner, &version)
+	if err != nil || committed != version+1 {
+		t.Fatalf("CommitDraft() = %d, %v, want %d", committed, err, version+1)
+	}
+	if n := store.notes[id]; n.Draft != "" || n.Payload == "" {
+		t.Fatalf("committed note = %+v", n)
+	}
+}
+
+func TestSearchPagination(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	srv, _ := newService(t)
+	for _, title := range []string{"план на понедельник", "план на вторник", "план на среду", "список покупок"} {
+		if _, _, err := srv.CreateNote(ctx, title, "", owner, uuid.New(), uuid.Nil); err != nil {
+			t.Fatalf("CreateNote() error = %v", err)
+		}
+	}
+
+	params := &dto.NoteSearch{Search: "план", Sort: enum.NoteSortCreated, Limit: 2}
+	seen := map[uuid.UUID]bool{}
+	for page := 1; ; page++ {
+		found, err := srv.SearchNotes(ctx, owner, params)
+		if err != nil {
+			t.Fatalf("SearchNotes() error = %v", err)
+		}
+		if found.Total != 3 {
+			t.Fatalf("page %d: Total = %d, want 3", page, found.Total)
+		}
+		for _, n := range found.Notes {
+			if seen[n.Id] {
+				t.Fatalf("page %d: note %s repeated", page, n.Title)
+			}
+			seen[n.Id] = true
+		}
+		if found.NextCursor == "" {
+			break
+		}
+		if page > 2 {
+			t.Fatal("too many pages")
+		}
+		params.After, err = dto.DecodeSearchCursor(found.NextCursor)
+		if err != nil {
+			t.Fatalf("DecodeSearchCursor() error = %v", err)
+		}
+		if params.After.Sort != enum.NoteSortCreated {
+			t.Fatalf("cursor sort = %s", params.After.Sort)
+		}
+	}
+	if len(seen) != 3 {
+		t.Fatalf("got %d notes, want 3", len(seen))
+	}
+}
+
+func TestDuplicateNotes(t *testing.T) {
+	ctx := context.Background()
+	owner, copier := uuid.New(), uuid.New()
+	layoutId, toLayout := uuid.New(), uuid.New()
+
+	srv, store := newService(t)
+	mustCreate := func(title, payload string) uuid.UUID {
+		t.Helper()
+		id, _, err := srv.CreateNote(ctx, title, payload, owner, layoutId, uuid.Nil)
+		if err != nil {
+			t.Fatalf("CreateNote() error = %v", err)
+		}
+		return id
+	}
+	plan := mustCreate("Plan", "steps")
+	outside := mustCreate("Outside", "")
+	source := mustCreate("Source", "see [[Plan]] and [[Outside]]")
+	// копирующий может читать Outside, но не оригинал Plan
+	store.shared[outside] = true
+	if err := srv.CreateLink(ctx, plan, outside); err != nil {
+		t.Fatalf("CreateLink() error = %v", err)
+	}
+	if err := srv.CreateLink(ctx, plan, source); err != nil {
+		t.Fatalf("CreateLink() error = %v", err)
+	}
+	x, y := 10.0, 20.0
+	if err := srv.UpdateNotePosition(ctx, plan, &x, &y); err != nil {
+		t.Fatalf("UpdateNotePosition() error = %v", err)
+	}
+
+	copies, err := srv.DuplicateNotes(ctx, []uuid.UUID{plan, source}, copier, toLayout, dto.Position{XPos: 5, YPos: -5})
+	if err != nil {
+		t.Fatalf("DuplicateNotes() error = %v", err)
+	}
+	planCopy, sourceCopy := copies[plan], copies[source]
+	if len(copies) != 2 || planCopy == plan || sourceCopy == source {
+		t.Fatalf("copies = %v", copies)
+	}
+
+	got, err := srv.GetFullNotesByIds(ctx, []uuid.UUID{planCopy, sourceCopy})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("GetFullNotesByIds() = %v, %v", got, err)
+	}
+	if got[0].Title != "Plan" || got[0].Payload != "steps" || got[0].OwnerId != copier || got[0].LayoutId != toLayout {
+		t.Fatalf("copy = %+v", got[0])
+	}
+	if want := (&dto.Position{XPos: 15, YPos: 15}); !reflect.DeepEqual(got[0].Position, want) {
+		t.Fatalf("position = %v, want %v", got[0].Position, want)
+	}
+	if got[1].Position != nil {
+		t.Fatalf("unpositioned note got position %v", got[1].Position)
+	}
+
+	// ручная связь с заметкой вне копии не копируется, wiki-ссылки копии разрешаются заново:
+	// [[Plan]] ведет на копию, [[Outside]] на заметку вне копии
+	if got, want := store.outLinks(planCopy), map[uuid.UUID]bool{sourceCopy: false}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("links = %v, want %v", got, want)
+	}
+	if got, want := store.outLinks(sourceCopy), map[uuid.UUID]bool{planCopy: true, outside: true}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("links = %v, want %v", got, want)
+	}
+	if got, want := store.outLinks(plan), map[uuid.UUID]bool{outside: false, source: false}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("original links = %v, want %v", got, want)
+	}
+
+	if _, err := srv.DuplicateNotes(ctx, []uuid.UUID{uuid.New()}, copier, uuid.Nil, dto.Position{}); err != apperrors.NoteNotFound {
+		t.Fatalf("DuplicateNotes() error = %v, want %v", err, apperrors.NoteNotFound)
+	}
+}
+
+func TestTasks(t *testing.T) {
+	ctx := context.Background()
+	owner, stranger := uuid.New(), uuid.New()
+
+	srv, _ := newService(t)
+	payload := strings.Join([]string{
+		"# Plan",
+		"- [ ] buy milk @due(2026-11-01) @Ann",
+		"  * [x] call @bob",
+		"```",
+		"- [ ] not a task",
+		"```",
+		"- [] not a task either",
+	}, "\n")
+	noteId, _, err := srv.CreateNote(ctx, "Plan", payload, owner, uuid.New(), uuid.Nil)
+	if err != nil {
+		t.Fatalf("CreateNote() error = %v", err)
+	}
+
+	all, err := srv.GetTasks(ctx, owner, &dto.TaskFilter{})
+	if err != nil {
+		t.Fatalf("GetTasks() error = %v", err)
+	}
+	if all.Total != 2 || len(all.Tasks) != 2 {
+		t.Fatalf("tasks = %+v", all)
+	}
+	first := all.Tasks[0]
+	if first.Line != 1 || first.Text != "buy milk @Ann" || first.Done || first.Due == nil || *first.Due != "2026-11-01" ||
+		!reflect.DeepEqual(first.Assignees, []string{"ann"}) || first.NoteTitle != "Plan" {
+		t.Fatalf("first task = %+v", first)
+	}
+	if second := all.Tasks[1]; second.Line != 2 || !second.Done || second.Due != nil {
+		t.Fatalf("second task = %+v", second)
+	}
+	if got, _ := srv.GetTasks(ctx, stranger, &dto.TaskFilter{}); got.Total != 0 {
+		t.Fatalf("stranger sees %d tasks", got.Total)
+	}
+
+	version, done, err := srv.ToggleTask(ctx, noteId, owner, 1, nil)
+	if err != nil || !done {
+		t.Fatalf("ToggleTask() = %v, %v", done, err)
+	}
+	if _, _, err := srv.ToggleTask(ctx, noteId, owner, 2, &first.NoteVersion); err != apperrors.VersionConflict {
+		t.Fatalf("ToggleTask() with stale version error = %v", err)
+	}
+	if _, _, err := srv.ToggleTask(ctx, noteId, owner, 4, &version); err != apperrors.TaskNotFound {
+		t.Fatalf("ToggleTask() in code block error = %v", err)
+	}
+	doneOnly := true
+	got, err := srv.GetTasks(ctx, owner, &dto.TaskFilter{Done: &doneOnly})
+	if err != nil || got.Total != 2 {
+		t.Fatalf("done tasks = %+v, %v", got, err)
+	}
+	notes, err := srv.GetFullNotesByIds(ctx, []uuid.UUID{noteId})
+	if err != nil || !strings.Contains(notes[0].Payload, "- [x] buy milk") {
+		t.Fatalf("payload = %q, %v", notes[0].Payload, err)
+	}
+}
@@ -0,0 +1,152 @@
+package note
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+var (
+	// taskPattern пункт чек-листа: отступ, маркер списка, [ ] или [x] и текст
+	taskPattern = regexp.MustCompile(`^(\s*[-*+]\s+\[)([ xX])(\](?:\s+|$))(.*)$`)
+	// dueMarker срок задачи @due(2026-11-01)
+	dueMarker = regexp.MustCompile(`\s*@due\((\d{4}-\d{2}-\d{2})\)`)
+	// mentionMarker исполнитель @user
+	mentionMarker = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.-]+)`)
+)
+
+// codeFence строка, которая открывает или закрывает блок кода. Чек-листы внутри блока кода не задачи
+const codeFence = "```"
+
+type tasksRepo interface {
+	SetNoteTasks(ctx context.Context, noteId uuid.UUID, tasks []entity.Task) error
+	GetTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) ([]entity.FoundTask, error)
+	CountTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) (int, error)
+}
+
+// parseTasks задачи из текста заметки, текст задачи без срока
+func parseTasks(payload string) []entity.Task {
+	var tasks []entity.Task
+	inCode := false
+	for i, line := range strings.Split(payload, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			inCode = !inCode
+			continue
+		}
+		if inCode {
+			continue
+		}
+		m := taskPattern.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		task := entity.Task{Line: i, Done: m[2] != " "}
+		text := m[4]
+		if due := dueMarker.FindStringSubmatch(text); due != nil {
+			if t, err := time.Parse(dto.DayFormat, due[1]); err == nil {
+				task.Due = &t
+				text = strings.Replace(text, due[0], "", 1)
+			}
+		}
+		seen := map[string]bool{}
+		for _, mention := range mentionMarker.FindAllStringSubmatch(text, -1) {
+			name := strings.ToLower(strings.TrimRight(mention[1], ".-"))
+			if name == "" || name == "due" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			task.Assignees = append(task.Assignees, name)
+		}
+		task.Text = strings.TrimSpace(text)
+		tasks = append(tasks, task)
+	}
+	return tasks
+}
+
+// toggleTaskLine переключает отметку задачи в строке line. false, если в строке нет задачи
+func toggleTaskLine(payload string, line int) (string, bool, bool) {
+	found := false
+	for _, task := range parseTasks(payload) {
+		if task.Line == line {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return payload, false, false
+	}
+	lines := strings.Split(payload, "\n")
+	m := taskPattern.FindStringSubmatchIndex(lines[line])
+	// m[4]:m[5] - отметка в квадратных скобках
+	done := lines[line][m[4]:m[5]] == " "
+	mark := " "
+	if done {
+		mark = "x"
+	}
+	lines[line] = lines[line][:m[4]] + mark + lines[line][m[5]:]
+	return strings.Join(lines, "\n"), done, true
+}
+
+// setTasks заменяет задачи заметки задачами из payload в открытом виде, вызывается внутри транзакции записи текста
+func (srv *Service) setTasks(ctx context.Context, noteId uuid.UUID, payload string) error {
+	tasks := parseTasks(payload)
+	for i := range tasks {
+		encrypted, err := srv.encryptor.Encrypt(tasks[i].Text)
+		if err != nil {
+			return errors.Wrap(err, "srv.encryptor.Encrypt")
+		}
+		tasks[i].Text = encrypted
+	}
+	return errors.Wrap(srv.tasksRepo.SetNoteTasks(ctx, noteId, tasks), "srv.tasksRepo.SetNoteTasks")
+}
+
+// GetTasks страница задач и сколько всего задач подходит под фильтр
+func (srv *Service) GetTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) (*dto.TasksResponse, error) {
+	found, err := srv.tasksRepo.GetTasks(ctx, userId, params)
+	if err != nil {
+		return nil, err
+	}
+	total, err := srv.tasksRepo.CountTasks(ctx, userId, params)
+	if err != nil {
+		return nil, err
+	}
+	resp := dto.TasksResponse{Tasks: make([]dto.Task, 0, len(found)), Total: total}
+	for i := range found {
+		found[i].Text, err = srv.encryptor.Decrypt(found[i].Text)
+		if err != nil {
+			return nil, errors.Wrap(err, "srv.encryptor.Decrypt")
+		}
+		resp.Tasks = append(resp.Tasks, dto.TaskFromEntity(&found[i]))
+	}
+	return &resp, nil
+}
+
+// ToggleTask переключает отметку задачи в строке line текста заметки. version - версия, которую видел клиент,
+// без нее берется прочитанная сейчас: текст не должен измениться между чтением и записью.
+// Возвращает новую версию заметки и новую отметку
+func (srv *Service) ToggleTask(ctx context.Context, noteId, userId uuid.UUID, line int, version *int64) (int64, bool, error) {
+	n, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return 0, false, err
+	}
+	plain, err := srv.decryptedNote(n)
+	if err != nil {
+		return 0, false, err
+	}
+	if version == nil {
+		version = &n.Version
+	}
+	payload, done, ok := toggleTaskLine(plain.Payload, line)
+	if !ok {
+		return 0, false, apperrors.TaskNotFound
+	}
+	newVersion, _, err := srv.UpdateNote(ctx, noteId, userId, nil, &payload, version)
+	return newVersion, done, err
+}
@@ -0,0 +1,200 @@
+package note
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// wikiLinkPattern [[Название заметки]] или [[id|подпись]], подпись на связь не влияет
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]|]+)(?:\|[^\[\]]*)?\]\]`)
+
+// wikiRef ссылка из текста: на заметку по id или по названию
+type wikiRef struct {
+	raw   string
+	id    uuid.UUID
+	title string
+}
+
+// wikiRefs разбирает ссылки из текста без повторов
+func wikiRefs(text string) []wikiRef {
+	seen := map[string]bool{}
+	var refs []wikiRef
+	for _, match := range wikiLinkPattern.FindAllStringSubmatch(text, -1) {
+		raw := strings.TrimSpace(match[1])
+		if raw == "" {
+			continue
+		}
+		ref := wikiRef{raw: raw}
+		if id, err := uuid.Parse(raw); err == nil {
+			ref.id = id
+		} else {
+			ref.title = strings.ToLower(raw)
+		}
+		key := ref.id.String() + ref.title
+		if !seen[key] {
+			seen[key] = true
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// resolveWikiLinks ищет заметки, на которые ссылается текст, среди доступных пользователю на чтение.
+// Если название есть у нескольких заметок, берется заметка из того же лейаута, затем самая старая.
+// Возвращает id найденных заметок и ссылки, для которых заметки нет
+func (srv *Service) resolveWikiLinks(ctx context.Context, userId, noteId, layoutId uuid.UUID, text string) ([]uuid.UUID, []string, error) {
+	refs := wikiRefs(text)
+	if len(refs) == 0 {
+		return nil, nil, nil
+	}
+	ids := []uuid.UUID{}
+	titles := []string{}
+	for _, ref := range refs {
+		if ref.id != uuid.Nil {
+			ids = append(ids, ref.id)
+		} else {
+			titles = append(titles, ref.title)
+		}
+	}
+	notes, err := srv.noteRepo.GetReadableNotes(ctx, userId, ids, titles)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "srv.noteRepo.GetReadableNotes")
+	}
+
+	byId := make(map[uuid.UUID]bool, len(notes))
+	byTitle := make(map[string]entity.Note, len(notes))
+	for _, n := range notes {
+		byId[n.Id] = true
+		title := strings.ToLower(n.Title)
+		if found, ok := byTitle[title]; !ok || (found.LayoutId != layoutId && n.LayoutId == layoutId) {
+			byTitle[title] = n
+		}
+	}
+
+	seen := map[uuid.UUID]bool{}
+	var targets []uuid.UUID
+	var unresolved []string
+	for _, ref := range refs {
+		target := ref.id
+		if target == uuid.Nil {
+			target = byTitle[ref.title].Id
+		}
+		if target == uuid.Nil || !byId[target] {
+			unresolved = append(unresolved, ref.raw)
+			continue
+		}
+		if target != noteId && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	}
+	return targets, unresolved, nil
+}
+
+// syncWikiLinks приводит связи заметки из wiki-ссылок к тексту, вызывается внутри транзакции записи текста
+func (srv *Service) syncWikiLinks(ctx context.Context, noteId, layoutId uuid.UUID, targets []uuid.UUID) error {
+	added, removed, err := srv.linksRepo.SetAutoLinks(ctx, noteId, targets)
+	if err != nil {
+		return errors.Wrap(err, "srv.linksRepo.SetAutoLinks")
+	}
+	for _, id := range added {
+		err := srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationUpsert, noteId, id, layoutId))
+		if err != nil {
+			return err
+		}
+	}
+	for _, id := range removed {
+		err := srv.changesRepo.RecordChange(ctx, entity.NewLinkChange(enum.SyncOperationDelete, noteId, id, layoutId))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snippetRadius сколько символов текста показывается с каждой стороны от ссылки
+const snippetRadius = 80
+
+// GetBacklinks заметки, которые ссылаются на noteId и доступны пользователю на чтение
+func (srv *Service) GetBacklinks(ctx context.Context, noteId, userId uuid.UUID) ([]dto.Backlink, error) {
+	target, err := srv.noteRepo.GetById(ctx, noteId)
+	if err != nil {
+		return nil, err
+	}
+	links, err := srv.linksRepo.GetBacklinks(ctx, noteId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.linksRepo.GetBacklinks")
+	}
+	output := []dto.Backlink{}
+	if len(links) == 0 {
+		return output, nil
+	}
+	auto := make(map[uuid.UUID]bool, len(links))
+	ids := make([]uuid.UUID, 0, len(links))
+	for _, l := range links {
+		auto[l.FirstNoteId] = l.Auto
+		ids = append(ids, l.FirstNoteId)
+	}
+	notes, err := srv.noteRepo.GetReadableNotes(ctx, userId, ids, []string{})
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.noteRepo.GetReadableNotes")
+	}
+	for i := range notes {
+		plain, err := srv.decryptedNote(&notes[i])
+		if err != nil {
+			return nil, err
+		}
+		output = append(output, dto.Backlink{
+			NoteId:   plain.Id,
+			Title:    plain.Title,
+			LayoutId: plain.LayoutId,
+			Auto:     auto[plain.Id],
+			Snippet:  wikiSnippet(plain.Payload, target),
+		})
+	}
+	return output, nil
+}
+
+// wikiSnippet текст вокруг первой ссылки на target, пробелы схлопываются
+func wikiSnippet(text string, target *entity.Note) string {
+	for _, loc := range wikiLinkPattern.FindAllStringSubmatchIndex(text, -1) {
+		raw := strings.TrimSpace(text[loc[2]:loc[3]])
+		if !strings.EqualFold(raw, target.Id.String()) && !strings.EqualFold(raw, target.Title) {
+			continue
+		}
+		start, end := loc[0], loc[1]
+		for i := 0; i < snippetRadius && start > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:start])
+			start -= size
+		}
+		for i := 0; i < snippetRadius && end < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[end:])
+			end += size
+		}
+		// обрезанные слова по краям не показываются
+		if i := strings.IndexFunc(text[start:loc[0]], unicode.IsSpace); start > 0 && i >= 0 {
+			start += i
+		}
+		if i := strings.LastIndexFunc(text[loc[1]:end], unicode.IsSpace); end < len(text) && i >= 0 {
+			end = loc[1] + i
+		}
+		snippet := strings.Join(strings.Fields(text[start:end]), " ")
+		if start > 0 {
+			snippet = "…" + snippet
+		}
+		if end < len(text) {
+			snippet += "…"
+		}
+		return snippet
+	}
+	return ""
+}
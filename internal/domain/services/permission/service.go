@@ -24,6 +24,7 @@ type layoutRepo interface {
 
 type noteRepo interface {
 	GetById(ctx context.Context, noteId uuid.UUID) (*entity.Note, error)
+	GetReadableNotes(ctx context.Context, userId uuid.UUID, ids []uuid.UUID, titles []string) ([]entity.Note, error)
 }
 
 type Service struct {
@@ -114,3 +115,19 @@ func (srv *Service) CheckPermissionByNoteId(ctx context.Context, targetId, userI
 
 	return nil
 }
+
+// GetReadableNoteIds какие из заметок noteIds пользователь может читать, одним запросом
+func (srv *Service) GetReadableNoteIds(ctx context.Context, userId uuid.UUID, noteIds []uuid.UUID) (map[uuid.UUID]bool, error) {
+	readable := make(map[uuid.UUID]bool, len(noteIds))
+	if len(noteIds) == 0 {
+		return readable, nil
+	}
+	notes, err := srv.noteRepo.GetReadableNotes(ctx, userId, noteIds, []string{})
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.noteRepo.GetReadableNotes")
+	}
+	for _, n := range notes {
+		readable[n.Id] = true
+	}
+	return readable, nil
+}
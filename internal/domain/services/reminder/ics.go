@@ -0,0 +1,139 @@
+package reminder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"wn/internal/entity"
+)
+
+const (
+	icsUTCFormat   = "20060102T150405Z"
+	icsLocalFormat = "20060102T150405"
+	// icsLineLength длина строки в октетах, длиннее переносится
+	icsLineLength = 75
+	// icsTimezoneYears на сколько лет вперед описываются переходы часовых поясов
+	icsTimezoneYears = 10
+)
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// calendar напоминания в формате iCalendar (RFC 5545): событие со звонком в момент начала.
+// Время начала записывается в часовом поясе напоминания, чтобы повторения не съезжали после перевода часов,
+// для каждого такого пояса в начале календаря описывается VTIMEZONE
+func calendar(items []entity.ReminderWithNote, now time.Time) []byte {
+	var b strings.Builder
+	line := func(s string) {
+		for len(s) > icsLineLength {
+			cut := icsLineLength
+			for !utf8.RuneStart(s[cut]) {
+				cut--
+			}
+			b.WriteString(s[:cut] + "\r\n")
+			s = " " + s[cut:]
+		}
+		b.WriteString(s + "\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Walrus Notes//Reminders//RU")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:Walrus Notes")
+
+	// пояса в порядке первого появления, описываются с самого раннего напоминания в поясе
+	locations := map[string]*time.Location{}
+	earliest := map[string]time.Time{}
+	var zones []string
+	for _, item := range items {
+		loc, err := time.LoadLocation(item.Timezone)
+		if err != nil || loc == time.UTC {
+			continue
+		}
+		if _, ok := locations[item.Timezone]; !ok {
+			locations[item.Timezone] = loc
+			zones = append(zones, item.Timezone)
+		}
+		if first, ok := earliest[item.Timezone]; !ok || item.RemindAt.Before(first) {
+			earliest[item.Timezone] = item.RemindAt
+		}
+	}
+	for _, zone := range zones {
+		vtimezone(line, zone, locations[zone], earliest[zone], now.AddDate(icsTimezoneYears, 0, 0))
+	}
+
+	for _, item := range items {
+		summary := icsEscaper.Replace(item.NoteTitle)
+		line("BEGIN:VEVENT")
+		line("UID:" + item.Id.String() + "@walrus-notes")
+		line("DTSTAMP:" + now.UTC().Format(icsUTCFormat))
+		if loc, ok := locations[item.Timezone]; ok {
+			line("DTSTART;TZID=" + item.Timezone + ":" + item.RemindAt.In(loc).Format(icsLocalFormat))
+		} else {
+			line("DTSTART:" + item.RemindAt.UTC().Format(icsUTCFormat))
+		}
+		if item.RRule != "" {
+			line("RRULE:" + item.RRule)
+		}
+		line("SUMMARY:" + summary)
+		line("BEGIN:VALARM")
+		line("ACTION:DISPLAY")
+		line("DESCRIPTION:" + summary)
+		line("TRIGGER:PT0S")
+		line("END:VALARM")
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return []byte(b.String())
+}
+
+// vtimezone описание пояса для TZID (RFC 5545, 3.6.5) с момента from до until. Правил перевода часов
+// из tzdata не достать, поэтому каждый переход записывается отдельным STANDARD или DAYLIGHT.
+// После until клиент продолжает последнее смещение
+func vtimezone(line func(string), zone string, loc *time.Location, from, until time.Time) {
+	line("BEGIN:VTIMEZONE")
+	line("TZID:" + zone)
+	t := from.In(loc)
+	name, offset := t.Zone()
+	observance(line, t.IsDST(), t.Format(icsLocalFormat), offset, offset, name)
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || end.After(until) {
+			break
+		}
+		t = end.In(loc)
+		nextName, next := t.Zone()
+		// начало перехода записывается по местному времени до него
+		start := end.UTC().Add(time.Duration(offset) * time.Second).Format(icsLocalFormat)
+		observance(line, t.IsDST(), start, offset, next, nextName)
+		offset = next
+	}
+	line("END:VTIMEZONE")
+}
+
+func observance(line func(string), dst bool, start string, offsetFrom, offsetTo int, name string) {
+	kind := "STANDARD"
+	if dst {
+		kind = "DAYLIGHT"
+	}
+	line("BEGIN:" + kind)
+	line("DTSTART:" + start)
+	line("TZOFFSETFROM:" + icsOffset(offsetFrom))
+	line("TZOFFSETTO:" + icsOffset(offsetTo))
+	line("TZNAME:" + icsEscaper.Replace(name))
+	line("END:" + kind)
+}
+
+// icsOffset смещение в формате ±hhmm, секунды только если они есть
+func icsOffset(seconds int) string {
+	sign := "+"
+	if seconds < 0 {
+		sign, seconds = "-", -seconds
+	}
+	offset := fmt.Sprintf("%s%02d%02d", sign, seconds/3600, seconds/60%60)
+	if seconds%60 != 0 {
+		offset += fmt.Sprintf("%02d", seconds%60)
+	}
+	return offset
+}
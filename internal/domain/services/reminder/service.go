@@ -0,0 +1,240 @@
+package reminder
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/rrule"
+	"wn/pkg/trx"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// fireBatch сколько напоминаний берется за одну транзакцию
+	fireBatch = 100
+	// feedTokenSize байт в секрете ссылки на календарь
+	feedTokenSize = 32
+	// emailTimeFormat время срабатывания в письме
+	emailTimeFormat = "02.01.2006 15:04 MST"
+)
+
+type reminderRepo interface {
+	CreateReminder(ctx context.Context, item *entity.Reminder) error
+	DeleteReminder(ctx context.Context, reminderId, userId uuid.UUID) error
+	GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]entity.ReminderWithNote, error)
+	ClaimDueReminders(ctx context.Context, now time.Time, limit uint64) ([]entity.ReminderWithNote, error)
+	SetFired(ctx context.Context, reminderId uuid.UUID, firedAt time.Time, next *time.Time) error
+	GetFeedToken(ctx context.Context, userId uuid.UUID) (string, error)
+	SetFeedToken(ctx context.Context, userId uuid.UUID, token string) error
+	GetFeedOwner(ctx context.Context, token string) (uuid.UUID, error)
+}
+
+type mailer interface {
+	SendReminderMessage(email, title, at string) error
+}
+
+type notifier interface {
+	SendToUser(userId uuid.UUID, msg *dto.SocketMessage)
+}
+
+type Service struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	reminderRepo reminderRepo
+	mailer       mailer
+	notifier     notifier
+}
+
+func NewService(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	reminderRepo reminderRepo,
+	mailer mailer,
+	notifier notifier,
+) *Service {
+	return &Service{
+		tx:           tx,
+		logger:       logger,
+		reminderRepo: reminderRepo,
+		mailer:       mailer,
+		notifier:     notifier,
+	}
+}
+
+// CreateReminder напоминание о заметке. Часовой пояс напоминания берется из now, в нем считаются повторения.
+// Для повторяющегося напоминания с прошедшим началом первым сработает ближайшее будущее повторение
+func (srv *Service) CreateReminder(ctx context.Context, userId, noteId uuid.UUID, remindAt time.Time, rule string, now time.Time) (*dto.Reminder, error) {
+	item := entity.Reminder{
+		Id:        util.NewUUID(),
+		UserId:    userId,
+		NoteId:    noteId,
+		RemindAt:  remindAt.In(now.Location()),
+		Timezone:  now.Location().String(),
+		CreatedAt: now,
+	}
+	var r *rrule.Rule
+	if rule != "" {
+		var err error
+		r, err = rrule.Parse(rule)
+		if err != nil {
+			return nil, apperrors.BadRecurrence
+		}
+		item.RRule = r.String()
+	}
+	next := item.RemindAt
+	if !next.After(now) {
+		if r == nil {
+			return nil, apperrors.ReminderInPast
+		}
+		var ok bool
+		if next, ok = r.Next(item.RemindAt, now); !ok {
+			return nil, apperrors.ReminderInPast
+		}
+	}
+	item.NextFireAt = &next
+	if err := srv.reminderRepo.CreateReminder(ctx, &item); err != nil {
+		return nil, errors.Wrap(err, "srv.reminderRepo.CreateReminder")
+	}
+	reminder := dto.ReminderFromEntity(&entity.ReminderWithNote{Reminder: item})
+	return &reminder, nil
+}
+
+func (srv *Service) DeleteReminder(ctx context.Context, reminderId, userId uuid.UUID) error {
+	return srv.reminderRepo.DeleteReminder(ctx, reminderId, userId)
+}
+
+// GetReminders напоминания пользователя, noteId nil - по всем заметкам
+func (srv *Service) GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]dto.Reminder, error) {
+	items, err := srv.reminderRepo.GetReminders(ctx, userId, noteId)
+	if err != nil {
+		return nil, err
+	}
+	reminders := make([]dto.Reminder, 0, len(items))
+	for i := range items {
+		reminders = append(reminders, dto.ReminderFromEntity(&items[i]))
+	}
+	return reminders, nil
+}
+
+// FireReminders отправляет напоминания, время которых подошло к now. Напоминание переводится на следующее
+// повторение в той же транзакции, в которой взято, а отправляется после коммита: другая реплика
+// его уже не возьмет, а при падении между коммитом и отправкой напоминание теряется, но не дублируется.
+// Повторения, пропущенные пока сервис не работал, не отправляются - только одно, и дальше по расписанию
+func (srv *Service) FireReminders(ctx context.Context, now time.Time) (int, error) {
+	fired := 0
+	for {
+		var due []entity.ReminderWithNote
+		err := srv.tx.Transaction(ctx, func(ctx context.Context) error {
+			var err error
+			due, err = srv.reminderRepo.ClaimDueReminders(ctx, now, fireBatch)
+			if err != nil {
+				return errors.Wrap(err, "srv.reminderRepo.ClaimDueReminders")
+			}
+			for i := range due {
+				if err := srv.reminderRepo.SetFired(ctx, due[i].Id, now, nextFire(&due[i].Reminder, now)); err != nil {
+					return errors.Wrap(err, "srv.reminderRepo.SetFired")
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return fired, err
+		}
+		for i := range due {
+			// доступ к заметке отозван: повторение сдвигается, но ничего не отправляется
+			if due[i].Readable {
+				srv.notify(ctx, &due[i], now)
+			}
+		}
+		fired += len(due)
+		if len(due) < fireBatch || ctx.Err() != nil {
+			return fired, nil
+		}
+	}
+}
+
+// nextFire следующее повторение позже now, nil для разового или закончившегося напоминания
+func nextFire(item *entity.Reminder, now time.Time) *time.Time {
+	if item.RRule == "" {
+		return nil
+	}
+	r, err := rrule.Parse(item.RRule)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(item.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	next, ok := r.Next(item.RemindAt.In(loc), now)
+	if !ok {
+		return nil
+	}
+	return &next
+}
+
+// notify письмо и событие в сокет. Ошибки только логируются: напоминание уже отмечено отправленным
+func (srv *Service) notify(ctx context.Context, item *entity.ReminderWithNote, now time.Time) {
+	payload, err := json.Marshal(dto.ReminderFired{
+		ReminderId: item.Id,
+		NoteId:     item.NoteId,
+		NoteTitle:  item.NoteTitle,
+		LayoutId:   item.LayoutId,
+		FiredAt:    now,
+	})
+	if err != nil {
+		srv.logger.WithCtx(ctx).Errorf("reminder %s marshal: %s", item.Id, err.Error())
+	} else {
+		srv.notifier.SendToUser(item.UserId, &dto.SocketMessage{Event: dto.ReminderEvent, Payload: payload})
+	}
+
+	loc, err := time.LoadLocation(item.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if err := srv.mailer.SendReminderMessage(item.Email, item.NoteTitle, now.In(loc).Format(emailTimeFormat)); err != nil {
+		srv.logger.WithCtx(ctx).Warnf("reminder %s email: %s", item.Id, err.Error())
+	}
+}
+
+// GetFeedToken секрет ссылки на календарь, создается при первом запросе. reset выдает новый, старая ссылка перестает работать
+func (srv *Service) GetFeedToken(ctx context.Context, userId uuid.UUID, reset bool) (string, error) {
+	if !reset {
+		token, err := srv.reminderRepo.GetFeedToken(ctx, userId)
+		if !errors.Is(err, apperrors.ReminderFeedNotFound) {
+			return token, err
+		}
+	}
+	raw := make([]byte, feedTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "rand.Read")
+	}
+	token := hex.EncodeToString(raw)
+	if err := srv.reminderRepo.SetFeedToken(ctx, userId, token); err != nil {
+		return "", errors.Wrap(err, "srv.reminderRepo.SetFeedToken")
+	}
+	return token, nil
+}
+
+// GetFeed календарь напоминаний владельца ссылки в формате iCalendar
+func (srv *Service) GetFeed(ctx context.Context, token string, now time.Time) ([]byte, error) {
+	userId, err := srv.reminderRepo.GetFeedOwner(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	items, err := srv.reminderRepo.GetReminders(ctx, userId, nil)
+	if err != nil {
+		return nil, err
+	}
+	return calendar(items, now), nil
+}
@@ -0,0 +1,283 @@
+package reminder_test
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/services/reminder"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// memoryStore напоминания в памяти, письма и события в сокет складываются в списки.
+// revoked - заметки, к которым у пользователя больше нет доступа
+type memoryStore struct {
+	reminders map[uuid.UUID]*entity.Reminder
+	revoked   map[uuid.UUID]bool
+	feeds     map[uuid.UUID]string
+	emails    []string
+	events    []uuid.UUID
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		reminders: map[uuid.UUID]*entity.Reminder{},
+		revoked:   map[uuid.UUID]bool{},
+		feeds:     map[uuid.UUID]string{},
+	}
+}
+
+func (s *memoryStore) CreateReminder(_ context.Context, item *entity.Reminder) error {
+	copied := *item
+	s.reminders[item.Id] = &copied
+	return nil
+}
+
+func (s *memoryStore) DeleteReminder(_ context.Context, reminderId, userId uuid.UUID) error {
+	item, ok := s.reminders[reminderId]
+	if !ok || item.UserId != userId {
+		return apperrors.ReminderNotFound
+	}
+	delete(s.reminders, reminderId)
+	return nil
+}
+
+func (s *memoryStore) GetReminders(_ context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]entity.ReminderWithNote, error) {
+	var out []entity.ReminderWithNote
+	for _, item := range s.reminders {
+		if item.UserId == userId && (noteId == nil || *noteId == item.NoteId) && !s.revoked[item.NoteId] {
+			out = append(out, entity.ReminderWithNote{Reminder: *item, NoteTitle: "Plan, v2", Email: "ann@example.com"})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].RemindAt.Before(out[j].RemindAt) })
+	return out, nil
+}
+
+func (s *memoryStore) ClaimDueReminders(_ context.Context, now time.Time, limit uint64) ([]entity.ReminderWithNote, error) {
+	var out []entity.ReminderWithNote
+	for _, item := range s.reminders {
+		if item.NextFireAt != nil && !item.NextFireAt.After(now) && uint64(len(out)) < limit {
+			out = append(out, entity.ReminderWithNote{
+				Reminder:  *item,
+				NoteTitle: "Plan",
+				Email:     "ann@example.com",
+				Readable:  !s.revoked[item.NoteId],
+			})
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) SetFired(_ context.Context, reminderId uuid.UUID, firedAt time.Time, next *time.Time) error {
+	s.reminders[reminderId].LastFiredAt = &firedAt
+	s.reminders[reminderId].NextFireAt = next
+	return nil
+}
+
+func (s *memoryStore) GetFeedToken(_ context.Context, userId uuid.UUID) (string, error) {
+	token, ok := s.feeds[userId]
+	if !ok {
+		return "", apperrors.ReminderFeedNotFound
+	}
+	return token, nil
+}
+
+func (s *memoryStore) SetFeedToken(_ context.Context, userId uuid.UUID, token string) error {
+	s.feeds[userId] = token
+	return nil
+}
+
+func (s *memoryStore) GetFeedOwner(_ context.Context, token string) (uuid.UUID, error) {
+	for userId, t := range s.feeds {
+		if t == token {
+			return userId, nil
+		}
+	}
+	return uuid.Nil, apperrors.ReminderFeedNotFound
+}
+
+func (s *memoryStore) SendReminderMessage(email, title, at string) error {
+	s.emails = append(s.emails, email+" "+title+" "+at)
+	return nil
+}
+
+func (s *memoryStore) SendToUser(userId uuid.UUID, msg *dto.SocketMessage) {
+	if msg.Event == dto.ReminderEvent {
+		s.events = append(s.events, userId)
+	}
+}
+
+func TestReminders(t *testing.T) {
+	ctx := context.Background()
+	userId, noteId := uuid.New(), uuid.New()
+	lgr := testutil.Logger(t)
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	store := newMemoryStore()
+	srv := reminder.NewService(testutil.NoTx{}, lgr, store, store, store)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, moscow)
+
+	if _, err := srv.CreateReminder(ctx, userId, noteId, now.Add(-time.Hour), "", now); err != apperrors.ReminderInPast {
+		t.Fatalf("CreateReminder() in past error = %v", err)
+	}
+	if _, err := srv.CreateReminder(ctx, userId, noteId, now.Add(time.Hour), "FREQ=HOURLY", now); err != apperrors.BadRecurrence {
+		t.Fatalf("CreateReminder() bad rule error = %v", err)
+	}
+	once, err := srv.CreateReminder(ctx, userId, noteId, now.Add(time.Hour), "", now)
+	if err != nil {
+		t.Fatalf("CreateReminder() error = %v", err)
+	}
+	// начало в прошлом: первым сработает ближайшее повторение, 20 октября в 9:00 по Москве
+	daily, err := srv.CreateReminder(ctx, userId, noteId, now.Add(-3*time.Hour), "freq=daily;count=4", now)
+	if err != nil {
+		t.Fatalf("CreateReminder() daily error = %v", err)
+	}
+	if want := time.Date(2026, 10, 20, 9, 0, 0, 0, moscow); daily.NextFireAt == nil || !daily.NextFireAt.Equal(want) ||
+		daily.RRule != "FREQ=DAILY;COUNT=4" || daily.Timezone != "Europe/Moscow" {
+		t.Fatalf("daily = %+v", daily)
+	}
+
+	if fired, err := srv.FireReminders(ctx, now); err != nil || fired != 0 {
+		t.Fatalf("FireReminders() before time = %d, %v", fired, err)
+	}
+	// сервис не работал двое суток: каждое напоминание отправляется один раз
+	later := time.Date(2026, 10, 21, 10, 0, 0, 0, moscow)
+	if fired, err := srv.FireReminders(ctx, later); err != nil || fired != 2 {
+		t.Fatalf("FireReminders() = %d, %v", fired, err)
+	}
+	if len(store.emails) != 2 || len(store.events) != 2 || store.events[0] != userId {
+		t.Fatalf("emails = %v, events = %v", store.emails, store.events)
+	}
+	if fired, err := srv.FireReminders(ctx, later); err != nil || fired != 0 {
+		t.Fatalf("FireReminders() again = %d, %v", fired, err)
+	}
+	reminders, err := srv.GetReminders(ctx, userId, &noteId)
+	if err != nil || len(reminders) != 2 {
+		t.Fatalf("GetReminders() = %v, %v", reminders, err)
+	}
+	for _, r := range reminders {
+		switch r.Id {
+		case once.Id:
+			if r.NextFireAt != nil {
+				t.Fatalf("one-shot reminder not finished: %+v", r)
+			}
+		case daily.Id:
+			// четвертое и последнее повторение, из пропущенных 20 и 21 октября отправлено одно
+			if want := time.Date(2026, 10, 22, 9, 0, 0, 0, moscow); r.NextFireAt == nil || !r.NextFireAt.Equal(want) {
+				t.Fatalf("daily next = %v, want %v", r.NextFireAt, want)
+			}
+		}
+	}
+
+	token, err := srv.GetFeedToken(ctx, userId, false)
+	if err != nil || len(token) != 64 {
+		t.Fatalf("GetFeedToken() = %q, %v", token, err)
+	}
+	if again, _ := srv.GetFeedToken(ctx, userId, false); again != token {
+		t.Fatalf("GetFeedToken() changed without reset")
+	}
+	feed, err := srv.GetFeed(ctx, token, later)
+	if err != nil {
+		t.Fatalf("GetFeed() error = %v", err)
+	}
+	ics := string(feed)
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Moscow\r\nBEGIN:STANDARD\r\n",
+		"TZOFFSETFROM:+0300\r\nTZOFFSETTO:+0300\r\n",
+		"DTSTART;TZID=Europe/Moscow:20261019T090000\r\n",
+		"RRULE:FREQ=DAILY;COUNT=4\r\n",
+		"SUMMARY:Plan\\, v2\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("feed has no %q:\n%s", want, ics)
+		}
+	}
+	reset, err := srv.GetFeedToken(ctx, userId, true)
+	if err != nil || reset == token {
+		t.Fatalf("GetFeedToken(reset) = %q, %v", reset, err)
+	}
+	if _, err := srv.GetFeed(ctx, token, later); err != apperrors.ReminderFeedNotFound {
+		t.Fatalf("GetFeed() with old token error = %v", err)
+	}
+
+	if err := srv.DeleteReminder(ctx, once.Id, uuid.New()); err != apperrors.ReminderNotFound {
+		t.Fatalf("DeleteReminder() by stranger error = %v", err)
+	}
+
+	// доступ к заметке отозван: напоминание не видно и не отправляется, но повторение сдвигается
+	store.revoked[noteId] = true
+	if reminders, err := srv.GetReminders(ctx, userId, nil); err != nil || len(reminders) != 0 {
+		t.Fatalf("GetReminders() after revoke = %v, %v", reminders, err)
+	}
+	emails := len(store.emails)
+	last := time.Date(2026, 10, 22, 10, 0, 0, 0, moscow)
+	if fired, err := srv.FireReminders(ctx, last); err != nil || fired != 1 {
+		t.Fatalf("FireReminders() after revoke = %d, %v", fired, err)
+	}
+	if len(store.emails) != emails || len(store.events) != emails {
+		t.Fatalf("notified after revoke: emails = %v", store.emails)
+	}
+	if next := store.reminders[daily.Id].NextFireAt; next != nil {
+		t.Fatalf("daily next after revoke = %v", next)
+	}
+}
+
+func TestFeedTimezone(t *testing.T) {
+	ctx := context.Background()
+	userId := uuid.New()
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	store := newMemoryStore()
+	srv := reminder.NewService(testutil.NoTx{}, testutil.Logger(t), store, store, store)
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, berlin)
+	if _, err := srv.CreateReminder(ctx, userId, uuid.New(), now.Add(time.Hour), "FREQ=WEEKLY", now); err != nil {
+		t.Fatalf("CreateReminder() error = %v", err)
+	}
+	if _, err := srv.CreateReminder(ctx, userId, uuid.New(), now.Add(2*time.Hour), "", now); err != nil {
+		t.Fatalf("CreateReminder() error = %v", err)
+	}
+	token, err := srv.GetFeedToken(ctx, userId, false)
+	if err != nil {
+		t.Fatalf("GetFeedToken() error = %v", err)
+	}
+	feed, err := srv.GetFeed(ctx, token, now)
+	if err != nil {
+		t.Fatalf("GetFeed() error = %v", err)
+	}
+	ics := string(feed)
+
+	// пояс описан один раз и до событий, которые на него ссылаются
+	if n := strings.Count(ics, "BEGIN:VTIMEZONE"); n != 1 {
+		t.Fatalf("feed has %d VTIMEZONE:\n%s", n, ics)
+	}
+	if strings.Index(ics, "END:VTIMEZONE") > strings.Index(ics, "BEGIN:VEVENT") {
+		t.Fatalf("VTIMEZONE after events:\n%s", ics)
+	}
+	for _, want := range []string{
+		"TZID:Europe/Berlin\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20261019T130000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20261025T030000\r\nTZOFFSETFROM:+0200\r\nTZOFFSETTO:+0100\r\nTZNAME:CET\r\n",
+		"BEGIN:DAYLIGHT\r\nDTSTART:20270328T020000\r\nTZOFFSETFROM:+0100\r\nTZOFFSETTO:+0200\r\nTZNAME:CEST\r\n",
+		"DTSTART;TZID=Europe/Berlin:20261019T130000\r\n",
+	} {
+		if !strings.Contains(ics, want) {
+			t.Fatalf("feed has no %q:\n%s", want, ics)
+		}
+	}
+	// летнее время с первого напоминания и переходы на него на 10 лет вперед
+	if n := strings.Count(ics, "BEGIN:DAYLIGHT"); n != 11 {
+		t.Fatalf("feed has %d DAYLIGHT, want 11", n)
+	}
+}
@@ -3,6 +3,7 @@ package smtp
 import (
 	"context"
 	"fmt"
+	"html"
 	"math"
 	"math/rand"
 	"time"
@@ -122,6 +123,56 @@ func (srv *Service) SendConfirmEmailMessage(email, code, action string) error {
 	return srv.SendMessage(email, htmlContent, subject)
 }
 
+// SendReminderMessage письмо о сработавшем напоминании. title - заголовок заметки, at - время срабатывания
+func (srv *Service) SendReminderMessage(email, title, at string) error {
+	htmlTemplate := `
+<!DOCTYPE html>
+<html>
+
+<body>
+    <div class="email">
+        <div class="header">
+            <h1>Напоминание</h1>
+        </div>
+
+        <div class="content">
+            <div class="message">
+                <p>Пора вернуться к заметке <span class="note">%s</span>.</p>
+                <p>Напоминание на %s</p>
+            </div>
+        </div>
+
+        <div class="footer">
+            <p><strong>Walrus Notes Team</strong></p>
+            <p style="margin-top: 8px; font-size: 12px; opacity: 0.8;">
+                Автоматическое сообщение • Не отвечать<br>
+                support@walrus-notes.ru
+            </p>
+        </div>
+    </div>
+</body>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { margin: 0; padding: 20px; background: #f8fafc; font-family: -apple-system, sans-serif; }
+        .email { max-width: 500px; margin: 0 auto; background: white; border-radius: 12px; overflow: hidden; box-shadow: 0 4px 12px rgba(0,0,0,0.08); }
+        .header { background: linear-gradient(135deg, #667eea 0%%, #764ba2 100%%); padding: 25px 20px; text-align: center; }
+        .header h1 { color: white; margin: 0; font-size: 20px; font-weight: 600; }
+        .content { padding: 30px; }
+        .message { background: #f1f5f9; padding: 15px; border-radius: 8px; margin: 20px 0; font-size: 15px; line-height: 1.5; }
+        .footer { background: #f8fafc; padding: 20px; text-align: center; color: #64748b; font-size: 13px; border-top: 1px solid #e2e8f0; }
+        .note { color: #4f46e5; font-weight: 600; }
+    </style>
+</head>
+</html>
+    `
+
+	htmlContent := fmt.Sprintf(htmlTemplate, html.EscapeString(title), html.EscapeString(at))
+	subject := fmt.Sprintf("Напоминание: %s", title)
+
+	return srv.SendMessage(email, htmlContent, subject)
+}
+
 func (srv *Service) SendMessage(email, messageText, title string) error {
 	toEmail := email
 	fromEmail := srv.cfg.OwnerEmail
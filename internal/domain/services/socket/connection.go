@@ -0,0 +1,219 @@
+package socket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	ErrConnectionClosed = errors.New("connection closed")
+	ErrSlowConsumer     = errors.New("outbound queue is full, connection closed")
+)
+
+type ConnectionConfig struct {
+	QueueSize       int
+	WriteTimeout    time.Duration
+	PingPeriod      time.Duration
+	PongTimeout     time.Duration
+	MaxMessageBytes int64
+	OverflowPolicy  enum.OverflowPolicy
+}
+
+// NewConnectionConfig ошибка, если очередь, период ping или таймаут записи нулевые, pong ждется не дольше
+// периода ping или политика переполнения неизвестна: такие соединения закрывались бы сразу
+func NewConnectionConfig(queueSize int, writeTimeout, pingPeriod, pongTimeout time.Duration, maxMessageBytes int64, overflowPolicy string) (*ConnectionConfig, error) {
+	cfg := &ConnectionConfig{
+		QueueSize:       queueSize,
+		WriteTimeout:    writeTimeout,
+		PingPeriod:      pingPeriod,
+		PongTimeout:     pongTimeout,
+		MaxMessageBytes: maxMessageBytes,
+		OverflowPolicy:  enum.OverflowPolicyFromString(overflowPolicy),
+	}
+	if cfg.QueueSize <= 0 {
+		return nil, fmt.Errorf("send queue size must be positive, got %d", queueSize)
+	}
+	if cfg.PingPeriod <= 0 {
+		return nil, fmt.Errorf("ping period must be positive, got %s", pingPeriod)
+	}
+	if cfg.PongTimeout <= cfg.PingPeriod {
+		return nil, fmt.Errorf("pong timeout must be longer than ping period %s, got %s", pingPeriod, pongTimeout)
+	}
+	if cfg.WriteTimeout <= 0 {
+		return nil, fmt.Errorf("write timeout must be positive, got %s", writeTimeout)
+	}
+	if cfg.OverflowPolicy == enum.OverflowPolicyUnspecified {
+		return nil, fmt.Errorf("unknown overflow policy %q", overflowPolicy)
+	}
+	return cfg, nil
+}
+
+type outbound struct {
+	messageType int
+	data        []byte
+}
+
+// WSConnection пишет в сокет только из своей горутины writePump.
+// Send и SendRaw не блокируются: сообщение кладется в ограниченную очередь,
+// а при ее переполнении применяется cfg.OverflowPolicy
+type WSConnection struct {
+	conn    *websocket.Conn
+	id      ConnectionID
+	userID  uuid.UUID
+	kind    string
+	cfg     *ConnectionConfig
+	metrics *Metrics
+
+	queue     chan outbound
+	done      chan struct{}
+	mu        sync.Mutex
+	closeOnce sync.Once
+}
+
+func newWSConnection(conn *websocket.Conn, userID uuid.UUID, kind string, cfg *ConnectionConfig, metrics *Metrics) *WSConnection {
+	w := &WSConnection{
+		conn:    conn,
+		id:      ConnectionID(uuid.New().String()),
+		userID:  userID,
+		kind:    kind,
+		cfg:     cfg,
+		metrics: metrics,
+		queue:   make(chan outbound, cfg.QueueSize),
+		done:    make(chan struct{}),
+	}
+
+	// Клиент обязан отвечать на ping, иначе чтение упадет по дедлайну
+	conn.SetReadLimit(cfg.MaxMessageBytes)
+	_ = conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(cfg.PongTimeout))
+	})
+
+	metrics.clientConnected(kind)
+	go w.writePump()
+	return w
+}
+
+func (w *WSConnection) ID() ConnectionID {
+	return w.id
+}
+
+func (w *WSConnection) UserID() uuid.UUID {
+	return w.userID
+}
+
+func (w *WSConnection) Send(msg *dto.SocketMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return w.enqueue(outbound{messageType: websocket.TextMessage, data: data})
+}
+
+// SendRaw отправляет бинарное сообщение как есть, используется для комнат
+func (w *WSConnection) SendRaw(data []byte) error {
+	return w.enqueue(outbound{messageType: websocket.BinaryMessage, data: data})
+}
+
+func (w *WSConnection) ReadMessage() (*dto.SocketMessage, error) {
+	var msg dto.SocketMessage
+	err := w.conn.ReadJSON(&msg)
+	return &msg, err
+}
+
+func (w *WSConnection) ReadRaw() (int, []byte, error) {
+	return w.conn.ReadMessage()
+}
+
+// Close останавливает writePump, тот отправит close frame и закроет сокет
+func (w *WSConnection) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.metrics.clientDisconnected(w.kind)
+	})
+	return nil
+}
+
+func (w *WSConnection) enqueue(item outbound) error {
+	// mu нужен, чтобы вытеснение старого сообщения и вставка нового шли атомарно
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-w.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case w.queue <- item:
+		w.metrics.queueChanged(w.kind, 1)
+		return nil
+	default:
+	}
+
+	w.metrics.messageDropped(w.kind, w.cfg.OverflowPolicy)
+	switch w.cfg.OverflowPolicy {
+	case enum.OverflowPolicyDropOldest:
+		select {
+		case <-w.queue:
+		default:
+			w.metrics.queueChanged(w.kind, 1)
+		}
+		select {
+		case w.queue <- item:
+		default:
+			w.metrics.queueChanged(w.kind, -1)
+		}
+		return nil
+	case enum.OverflowPolicyDisconnect:
+		_ = w.Close()
+		return ErrSlowConsumer
+	default:
+		return nil
+	}
+}
+
+func (w *WSConnection) writePump() {
+	ticker := time.NewTicker(w.cfg.PingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = w.Close()
+		w.mu.Lock()
+		w.metrics.queueChanged(w.kind, -len(w.queue))
+		w.mu.Unlock()
+		_ = w.conn.Close()
+	}()
+
+	for {
+		select {
+		case <-w.done:
+			_ = w.conn.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+				time.Now().Add(w.cfg.WriteTimeout),
+			)
+			return
+
+		case item := <-w.queue:
+			w.metrics.queueChanged(w.kind, -1)
+			_ = w.conn.SetWriteDeadline(time.Now().Add(w.cfg.WriteTimeout))
+			if err := w.conn.WriteMessage(item.messageType, item.data); err != nil {
+				return
+			}
+
+		case <-ticker.C:
+			if err := w.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(w.cfg.WriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package socket_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"wn/internal/domain/enum"
+	"wn/internal/domain/services/socket"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// payloadSize достаточно большой, чтобы клиент, который не читает, быстро забил буферы сокета
+const payloadSize = 256 << 10
+
+// connect соединение сервиса с клиентом, который сам ничего не читает
+func connect(t *testing.T, queueSize int, writeTimeout time.Duration, policy enum.OverflowPolicy) (*socket.WSConnection, *websocket.Conn, *prometheus.Registry) {
+	t.Helper()
+	lgr := testutil.Logger(t)
+	cfg, err := socket.NewConnectionConfig(queueSize, writeTimeout, time.Hour, 2*time.Hour, 1<<20, policy.String())
+	if err != nil {
+		t.Fatalf("NewConnectionConfig() error = %v", err)
+	}
+	registry := prometheus.NewRegistry()
+	srv := socket.NewService(lgr, time.Second, cfg, socket.NewMetrics(registry))
+
+	accepted := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("Upgrade() error = %v", err)
+			return
+		}
+		accepted <- conn
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	conn := srv.NewConnection(<-accepted, uuid.New(), socket.KindHub)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn, client, registry
+}
+
+func message(i int) []byte {
+	data := make([]byte, payloadSize)
+	binary.BigEndian.PutUint32(data, uint32(i))
+	return data
+}
+
+// metric значение метрики wn_socket_<name> с указанными label, 0 если ее нет
+func metric(t *testing.T, registry *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != "wn_socket_"+name {
+			continue
+		}
+	next:
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if labels[label.GetName()] != label.GetValue() {
+					continue next
+				}
+			}
+			if m.GetGauge() != nil {
+				return m.GetGauge().GetValue()
+			}
+			return m.GetCounter().GetValue()
+		}
+	}
+	return 0
+}
+
+// fill отправляет сообщения, пока очередь не переполнится, и возвращает число отправленных
+func fill(t *testing.T, conn *socket.WSConnection, registry *prometheus.Registry, policy enum.OverflowPolicy) int {
+	t.Helper()
+	labels := map[string]string{"kind": socket.KindHub, "policy": policy.String()}
+	for i := 0; i < 1000; i++ {
+		if err := conn.SendRaw(message(i)); err != nil {
+			t.Fatalf("SendRaw(%d) error = %v", i, err)
+		}
+		if metric(t, registry, "dropped_messages_total", labels) > 0 {
+			return i + 1
+		}
+	}
+	t.Fatalf("queue never overflowed")
+	return 0
+}
+
+// receive номера всех сообщений, которые клиент успел получить
+func receive(t *testing.T, client *websocket.Conn) []int {
+	t.Helper()
+	var got []int
+	for {
+		_ = client.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			return got
+		}
+		got = append(got, int(binary.BigEndian.Uint32(data)))
+	}
+}
+
+func TestOverflowDropNewest(t *testing.T) {
+	conn, client, registry := connect(t, 2, 10*time.Second, enum.OverflowPolicyDropNewest)
+
+	sent := fill(t, conn, registry, enum.OverflowPolicyDropNewest)
+	// выброшено только последнее сообщение, остальные пришли по порядку
+	got := receive(t, client)
+	if len(got) != sent-1 {
+		t.Fatalf("received %d of %d: %v", len(got), sent, got)
+	}
+	for i, n := range got {
+		if n != i {
+			t.Fatalf("received %v", got)
+		}
+	}
+	if err := conn.SendRaw(message(sent)); err != nil {
+		t.Fatalf("SendRaw() after overflow error = %v", err)
+	}
+}
+
+func TestOverflowDropOldest(t *testing.T) {
+	conn, client, registry := connect(t, 2, 10*time.Second, enum.OverflowPolicyDropOldest)
+
+	sent := fill(t, conn, registry, enum.OverflowPolicyDropOldest)
+	// последнее сообщение дошло вместо самого старого в очереди
+	got := receive(t, client)
+	if len(got) != sent-1 || got[len(got)-1] != sent-1 {
+		t.Fatalf("received %d of %d: %v", len(got), sent, got)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i] <= got[i-1] {
+			t.Fatalf("received out of order: %v", got)
+		}
+	}
+	if depth := metric(t, registry, "outbound_queue_depth", map[string]string{"kind": socket.KindHub}); depth != 0 {
+		t.Fatalf("queue depth = %v", depth)
+	}
+}
+
+func TestOverflowDisconnect(t *testing.T) {
+	conn, client, registry := connect(t, 2, 10*time.Second, enum.OverflowPolicyDisconnect)
+
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = conn.SendRaw(message(i))
+	}
+	if !errors.Is(err, socket.ErrSlowConsumer) {
+		t.Fatalf("SendRaw() error = %v, want %v", err, socket.ErrSlowConsumer)
+	}
+	if err := conn.SendRaw(message(0)); !errors.Is(err, socket.ErrConnectionClosed) {
+		t.Fatalf("SendRaw() after disconnect error = %v, want %v", err, socket.ErrConnectionClosed)
+	}
+	if connected := metric(t, registry, "connected_clients", map[string]string{"kind": socket.KindHub}); connected != 0 {
+		t.Fatalf("connected clients = %v", connected)
+	}
+	// клиент дочитывает то, что успело уйти, и видит закрытие соединения
+	_ = client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		if _, _, err = client.ReadMessage(); err != nil {
+			break
+		}
+	}
+	if isTimeout(err) {
+		t.Fatalf("connection not closed: %v", err)
+	}
+}
+
+func TestWriteDeadline(t *testing.T) {
+	conn, _, registry := connect(t, 1000, 100*time.Millisecond, enum.OverflowPolicyDropNewest)
+
+	// клиент не читает: запись упирается в дедлайн, и writePump закрывает соединение
+	deadline := time.Now().Add(5 * time.Second)
+	var err error
+	for i := 0; err == nil && time.Now().Before(deadline); i++ {
+		err = conn.SendRaw(message(i))
+		time.Sleep(time.Millisecond)
+	}
+	if !errors.Is(err, socket.ErrConnectionClosed) {
+		t.Fatalf("SendRaw() error = %v, want %v", err, socket.ErrConnectionClosed)
+	}
+	// writePump снимает соединение из метрик сразу после закрытия очереди
+	for metric(t, registry, "connected_clients", map[string]string{"kind": socket.KindHub}) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection still counted as connected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr interface{ Timeout() bool }
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func TestNewConnectionConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		queueSize    int
+		writeTimeout time.Duration
+		pingPeriod   time.Duration
+		pongTimeout  time.Duration
+		policy       string
+		ok           bool
+	}{
+		{"valid", 16, time.Second, time.Second, 3 * time.Second, "DROP_OLDEST", true},
+		{"zero queue", 0, time.Second, time.Second, 3 * time.Second, "DROP_NEWEST", false},
+		{"zero ping", 16, time.Second, 0, 3 * time.Second, "DISCONNECT", false},
+		{"zero pong", 16, time.Second, time.Second, 0, "DISCONNECT", false},
+		{"pong within ping", 16, time.Second, time.Second, time.Second, "DISCONNECT", false},
+		{"zero write", 16, 0, time.Second, 3 * time.Second, "DISCONNECT", false},
+		{"negative write", 16, -time.Second, time.Second, 3 * time.Second, "DISCONNECT", false},
+		{"typo", 16, time.Second, time.Second, 3 * time.Second, "DROP_NEWST", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := socket.NewConnectionConfig(tt.queueSize, tt.writeTimeout, tt.pingPeriod, tt.pongTimeout, 1024, tt.policy)
+			if (err == nil) != tt.ok {
+				t.Fatalf("NewConnectionConfig() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package socket
+
+import (
+	"wn/internal/domain/enum"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Виды соединений, используются как label в метриках
+const (
+	KindHub  = "hub"
+	KindRoom = "room"
+)
+
+type Metrics struct {
+	connected *prometheus.GaugeVec
+	queued    *prometheus.GaugeVec
+	dropped   *prometheus.CounterVec
+}
+
+func NewMetrics(registerer prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		connected: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "wn",
+			Subsystem: "socket",
+			Name:      "connected_clients",
+			Help:      "Number of open websocket connections.",
+		}, []string{"kind"}),
+		queued: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "wn",
+			Subsystem: "socket",
+			Name:      "outbound_queue_depth",
+			Help:      "Number of messages waiting in outbound queues of all connections.",
+		}, []string{"kind"}),
+		dropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "wn",
+			Subsystem: "socket",
+			Name:      "dropped_messages_total",
+			Help:      "Number of outbound messages dropped because a queue was full.",
+		}, []string{"kind", "policy"}),
+	}
+	registerer.MustRegister(m.connected, m.queued, m.dropped)
+	return m
+}
+
+func (m *Metrics) clientConnected(kind string) {
+	m.connected.WithLabelValues(kind).Inc()
+}
+
+func (m *Metrics) clientDisconnected(kind string) {
+	m.connected.WithLabelValues(kind).Dec()
+}
+
+func (m *Metrics) queueChanged(kind string, delta int) {
+	m.queued.WithLabelValues(kind).Add(float64(delta))
+}
+
+func (m *Metrics) messageDropped(kind string, policy enum.OverflowPolicy) {
+	m.dropped.WithLabelValues(kind, policy.String()).Inc()
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 	"wn/internal/domain/dto"
 	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/util"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -18,30 +20,43 @@ type Connection interface {
 	ID() ConnectionID
 	UserID() uuid.UUID
 	Send(msg *dto.SocketMessage) error
-	SendPing() error
 	Close() error
 	ReadMessage() (*dto.SocketMessage, error)
 }
 
-type MessageHandler func(msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error)
+// MessageHandler получает контекст соединения с userId и requestId, ограниченный по времени handlerTimeout
+type MessageHandler func(ctx context.Context, msg *dto.SocketMessage, userId uuid.UUID) (*dto.SocketMessage, error)
 
 type Service struct {
-	lgr         applogger.Logger
-	connections sync.Map // map[ConnectionID]Connection
-	handlers    map[string]MessageHandler
-	broadcast   chan *dto.SocketMessage
-	register    chan Connection
-	unregister  chan Connection
-	mu          sync.RWMutex
+	lgr            applogger.Logger
+	handlerTimeout time.Duration
+	connCfg        *ConnectionConfig
+	metrics        *Metrics
+	connections    sync.Map // map[ConnectionID]Connection
+	handlers       map[string]MessageHandler
+	broadcast      chan *dto.SocketMessage
+	register       chan Connection
+	unregister     chan Connection
+	mu             sync.RWMutex
+
+	// подписки соединений на топики, например на лейаут
+	subMu       sync.RWMutex
+	subscribers map[string]map[ConnectionID]Connection
+	connTopics  map[ConnectionID]map[string]struct{}
 }
 
-func NewService(lgr applogger.Logger) *Service {
+func NewService(lgr applogger.Logger, handlerTimeout time.Duration, connCfg *ConnectionConfig, metrics *Metrics) *Service {
 	s := &Service{
-		lgr:        lgr,
-		handlers:   map[string]MessageHandler{},
-		broadcast:  make(chan *dto.SocketMessage, 100),
-		register:   make(chan Connection, 10),
-		unregister: make(chan Connection, 10),
+		lgr:            lgr,
+		handlerTimeout: handlerTimeout,
+		connCfg:        connCfg,
+		metrics:        metrics,
+		handlers:       map[string]MessageHandler{},
+		broadcast:      make(chan *dto.SocketMessage, 100),
+		register:       make(chan Connection, 10),
+		unregister:     make(chan Connection, 10),
+		subscribers:    map[string]map[ConnectionID]Connection{},
+		connTopics:     map[ConnectionID]map[string]struct{}{},
 	}
 
 	go s.run()
@@ -58,6 +73,7 @@ func (s *Service) run() {
 
 		case conn := <-s.unregister:
 			s.connections.Delete(conn.ID())
+			s.unsubscribeAll(conn.ID())
 			conn.Close()
 			s.lgr.Infof("connection unregistered: %s", conn.ID())
 
@@ -67,69 +83,47 @@ func (s *Service) run() {
 	}
 }
 
+// NewConnection оборачивает сокет в соединение с очередью отправки. kind - KindHub или KindRoom
+func (s *Service) NewConnection(conn *websocket.Conn, userId uuid.UUID, kind string) *WSConnection {
+	return newWSConnection(conn, userId, kind, s.connCfg, s.metrics)
+}
+
 // HTTP хендлер для апгрейда соединения
+// ctx не должен отменяться вместе с http запросом, из него берутся только значения для логов
 func (s *Service) HandleConnection(ctx context.Context, conn Connection) {
-	// Регистрируем соединение
+	ctx = connectionContext(ctx, conn)
+	// Регистрируем соединение. Store сразу, чтобы первая же подписка нашла соединение
+	s.connections.Store(conn.ID(), conn)
 	s.register <- conn
 	defer func() {
 		s.unregister <- conn
 	}()
 
-	// Создаем тикер для пинга каждые 10 секунд
-	pingTicker := time.NewTicker(10 * time.Second)
-	defer pingTicker.Stop()
-
-	// Канал для обработки сообщений
-	messageChan := make(chan *dto.SocketMessage)
-	errorChan := make(chan error)
-
-	// Запускаем горутину для чтения сообщений
-	go func() {
-		for {
-			msg, err := conn.ReadMessage()
-			if err != nil {
-				errorChan <- err
-				return
+	// Пинги шлет writePump соединения control фреймами, здесь только читаем
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				s.lgr.WithCtx(ctx).Errorf("read message error: %s", err.Error())
 			}
-			messageChan <- msg
+			return
 		}
-	}()
-
-	// Основной цикл обработки
-	for {
-		select {
 
-		case <-pingTicker.C:
-			// Отправляем пинг каждые 10 секунд
-			if err := conn.SendPing(); err != nil {
-				s.lgr.Errorf("send ping error: %s", err.Error())
+		processedMsg, err := s.handleMessage(ctx, conn, msg)
+		if err != nil {
+			s.lgr.WithCtx(ctx).Errorf("handle message %s error: %s", msg.Event, err)
+		}
+		if processedMsg != nil {
+			if err := conn.Send(processedMsg); err != nil {
+				s.lgr.WithCtx(ctx).Errorf("send message error: %s", err.Error())
 				return
 			}
-			s.lgr.Debugf("ping sent to connection %s", conn.ID())
-
-		case msg := <-messageChan:
-			// Обрабатываем входящее сообщение
-			processedMsg, err := s.handleMessage(conn.ID(), msg)
-			if err != nil {
-				s.lgr.Errorf("handle message error: %s", err)
-			}
-			if processedMsg != nil {
-				if err := conn.Send(processedMsg); err != nil {
-					s.lgr.Errorf("send message error: %s", err.Error())
-					return
-				}
-			}
-
-		case err := <-errorChan:
-			// Ошибка чтения сообщения
-			s.lgr.Errorf("read message error: %s", err.Error())
-			return
 		}
 	}
 }
 
 // Обработка входящих сообщений
-func (s *Service) handleMessage(connID ConnectionID, msg *dto.SocketMessage) (*dto.SocketMessage, error) {
+func (s *Service) handleMessage(ctx context.Context, conn Connection, msg *dto.SocketMessage) (*dto.SocketMessage, error) {
 	s.mu.RLock()
 	handler, exists := s.handlers[msg.Event]
 	s.mu.RUnlock()
@@ -137,9 +131,32 @@ func (s *Service) handleMessage(connID ConnectionID, msg *dto.SocketMessage) (*d
 	if !exists {
 		return nil, fmt.Errorf("no handler for event: %s", msg.Event)
 	}
-	connAny, _ := s.connections.Load(connID)
-	conn := connAny.(Connection)
-	return handler(msg, conn.UserID())
+
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, msg.Event)
+	ctx, cancel := context.WithTimeout(ctx, s.handlerTimeout)
+	defer cancel()
+
+	return handler(ctx, msg, conn.UserID())
+}
+
+// connectionContext отвязывает контекст от http запроса и дописывает в него данные соединения
+func connectionContext(parent context.Context, conn Connection) context.Context {
+	ctx := util.CopyContextValues(parent, constants.RequestIdCtx)
+	if requestId, _ := util.GetRequestId(ctx); requestId == "" {
+		ctx = context.WithValue(ctx, constants.RequestIdCtx, string(conn.ID()))
+	}
+	ctx = context.WithValue(ctx, constants.ConnIdCtx, conn.ID())
+	return context.WithValue(ctx, constants.UserIdCtx, conn.UserID().String())
+}
+
+// ConnectionIDFromContext id соединения, из которого пришло сообщение
+func ConnectionIDFromContext(ctx context.Context) (ConnectionID, bool) {
+	id, ok := ctx.Value(constants.ConnIdCtx).(ConnectionID)
+	return id, ok
+}
+
+func LayoutTopic(layoutId uuid.UUID) string {
+	return "layout:" + layoutId.String()
 }
 
 // Регистрация обработчиков сообщений
@@ -162,60 +179,90 @@ func (s *Service) SendTo(connID ConnectionID, msg *dto.SocketMessage) error {
 	return fmt.Errorf("connection not found: %s", connID)
 }
 
-// Бродкаст сообщения
-func (s *Service) broadcastMessage(msg *dto.SocketMessage) {
+// SendToUser отправка во все соединения пользователя на этой реплике
+func (s *Service) SendToUser(userId uuid.UUID, msg *dto.SocketMessage) {
 	s.connections.Range(func(key, value interface{}) bool {
-		if conn, ok := value.(Connection); ok {
+		if conn, ok := value.(Connection); ok && conn.UserID() == userId {
 			if err := conn.Send(msg); err != nil {
-				s.lgr.Errorf("broadcast error: connId: %s error: %s", key.(string), err.Error())
+				s.lgr.Warnf("send to user error: connId: %s error: %s", key.(ConnectionID), err.Error())
 			}
 		}
 		return true
 	})
 }
 
-type WSConnection struct {
-	conn   *websocket.Conn
-	id     ConnectionID
-	userID uuid.UUID
-	mu     sync.Mutex
+// Бродкаст сообщения. Send не блокируется, поэтому медленный клиент не тормозит цикл run
+func (s *Service) broadcastMessage(msg *dto.SocketMessage) {
+	s.connections.Range(func(key, value interface{}) bool {
+		if conn, ok := value.(Connection); ok {
+			if err := conn.Send(msg); err != nil {
+				s.lgr.Warnf("broadcast error: connId: %s error: %s", key.(ConnectionID), err.Error())
+			}
+		}
+		return true
+	})
 }
 
-func NewWSConnection(conn *websocket.Conn, userID uuid.UUID) *WSConnection {
-	return &WSConnection{
-		conn:   conn,
-		id:     ConnectionID(uuid.New().String()),
-		userID: userID,
+func (s *Service) Subscribe(connID ConnectionID, topic string) error {
+	value, ok := s.connections.Load(connID)
+	if !ok {
+		return fmt.Errorf("connection not found: %s", connID)
 	}
-}
 
-func (w *WSConnection) ID() ConnectionID {
-	return w.id
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	if s.subscribers[topic] == nil {
+		s.subscribers[topic] = map[ConnectionID]Connection{}
+	}
+	s.subscribers[topic][connID] = value.(Connection)
+	if s.connTopics[connID] == nil {
+		s.connTopics[connID] = map[string]struct{}{}
+	}
+	s.connTopics[connID][topic] = struct{}{}
+	return nil
 }
 
-func (w *WSConnection) UserID() uuid.UUID {
-	return w.userID
+func (s *Service) Unsubscribe(connID ConnectionID, topic string) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	s.unsubscribe(connID, topic)
 }
 
-func (w *WSConnection) Send(msg *dto.SocketMessage) error {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	return w.conn.WriteJSON(msg)
-}
+// Publish отправляет сообщение всем подписчикам топика, не блокируясь на медленных
+func (s *Service) Publish(topic string, msg *dto.SocketMessage) {
+	s.subMu.RLock()
+	conns := make([]Connection, 0, len(s.subscribers[topic]))
+	for _, conn := range s.subscribers[topic] {
+		conns = append(conns, conn)
+	}
+	s.subMu.RUnlock()
 
-func (w *WSConnection) SendPing() error {
-	return w.Send(&dto.SocketMessage{
-		Event:   "PING",
-		Payload: []byte("{}"),
-	})
+	for _, conn := range conns {
+		if err := conn.Send(msg); err != nil {
+			s.lgr.Warnf("publish error: topic: %s connId: %s error: %s", topic, conn.ID(), err.Error())
+		}
+	}
 }
 
-func (w *WSConnection) ReadMessage() (*dto.SocketMessage, error) {
-	var msg dto.SocketMessage
-	err := w.conn.ReadJSON(&msg)
-	return &msg, err
+func (s *Service) unsubscribeAll(connID ConnectionID) {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	for topic := range s.connTopics[connID] {
+		s.unsubscribe(connID, topic)
+	}
 }
 
-func (w *WSConnection) Close() error {
-	return w.conn.Close()
+func (s *Service) unsubscribe(connID ConnectionID, topic string) {
+	if subs, ok := s.subscribers[topic]; ok {
+		delete(subs, connID)
+		if len(subs) == 0 {
+			delete(s.subscribers, topic)
+		}
+	}
+	if topics, ok := s.connTopics[connID]; ok {
+		delete(topics, topic)
+		if len(topics) == 0 {
+			delete(s.connTopics, connID)
+		}
+	}
 }
@@ -0,0 +1,135 @@
+package tag
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// maxNameLength длина имени метки в символах
+const maxNameLength = 64
+
+type tagsRepo interface {
+	CreateTag(ctx context.Context, item *entity.Tag) error
+	GetTag(ctx context.Context, tagId uuid.UUID) (*entity.Tag, error)
+	UpdateTag(ctx context.Context, tagId uuid.UUID, name, color *string) error
+	DeleteTag(ctx context.Context, tagId uuid.UUID) error
+	GetTags(ctx context.Context, ownerId uuid.UUID) ([]entity.TagCount, error)
+	GetLayoutTagCounts(ctx context.Context, ownerId, layoutId uuid.UUID) ([]entity.TagCount, error)
+	AttachTag(ctx context.Context, noteId, tagId uuid.UUID) error
+	DetachTag(ctx context.Context, noteId, tagId uuid.UUID) error
+}
+
+type Service struct {
+	tx     trx.TransactionManager
+	logger applogger.Logger
+
+	tagsRepo tagsRepo
+}
+
+func NewService(tx trx.TransactionManager, logger applogger.Logger, tagsRepo tagsRepo) *Service {
+	return &Service{
+		tx:       tx,
+		logger:   logger,
+		tagsRepo: tagsRepo,
+	}
+}
+
+// NormalizeName обрезает пробелы и проверяет имя. Запятая запрещена, через нее метки перечисляются в фильтре
+func NormalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength || strings.Contains(name, ",") {
+		return "", apperrors.BadTagName
+	}
+	return name, nil
+}
+
+func (srv *Service) CreateTag(ctx context.Context, ownerId uuid.UUID, name, color string) (uuid.UUID, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	item := entity.Tag{
+		Id:        util.NewUUID(),
+		OwnerId:   ownerId,
+		Name:      name,
+		Color:     color,
+		CreatedAt: util.GetCurrentUTCTime(),
+	}
+	if err := srv.tagsRepo.CreateTag(ctx, &item); err != nil {
+		return uuid.Nil, err
+	}
+	return item.Id, nil
+}
+
+// GetOwnTag метка пользователя, чужие метки для него не существуют
+func (srv *Service) GetOwnTag(ctx context.Context, tagId, ownerId uuid.UUID) (*entity.Tag, error) {
+	item, err := srv.tagsRepo.GetTag(ctx, tagId)
+	if err != nil {
+		return nil, err
+	}
+	if item.OwnerId != ownerId {
+		return nil, apperrors.TagNotFound
+	}
+	return item, nil
+}
+
+func (srv *Service) UpdateTag(ctx context.Context, tagId, ownerId uuid.UUID, name, color *string) error {
+	if _, err := srv.GetOwnTag(ctx, tagId, ownerId); err != nil {
+		return err
+	}
+	if name != nil {
+		normalized, err := NormalizeName(*name)
+		if err != nil {
+			return err
+		}
+		name = &normalized
+	}
+	return srv.tagsRepo.UpdateTag(ctx, tagId, name, color)
+}
+
+func (srv *Service) DeleteTag(ctx context.Context, tagId, ownerId uuid.UUID) error {
+	if _, err := srv.GetOwnTag(ctx, tagId, ownerId); err != nil {
+		return err
+	}
+	return srv.tagsRepo.DeleteTag(ctx, tagId)
+}
+
+func (srv *Service) GetTags(ctx context.Context, ownerId uuid.UUID) ([]entity.TagCount, error) {
+	items, err := srv.tagsRepo.GetTags(ctx, ownerId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.tagsRepo.GetTags")
+	}
+	return items, nil
+}
+
+func (srv *Service) GetLayoutTagCounts(ctx context.Context, ownerId, layoutId uuid.UUID) ([]entity.TagCount, error) {
+	items, err := srv.tagsRepo.GetLayoutTagCounts(ctx, ownerId, layoutId)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.tagsRepo.GetLayoutTagCounts")
+	}
+	return items, nil
+}
+
+// AttachTag отмечает заметку меткой пользователя, повторная отметка ничего не меняет
+func (srv *Service) AttachTag(ctx context.Context, noteId, tagId, ownerId uuid.UUID) error {
+	if _, err := srv.GetOwnTag(ctx, tagId, ownerId); err != nil {
+		return err
+	}
+	return srv.tagsRepo.AttachTag(ctx, noteId, tagId)
+}
+
+func (srv *Service) DetachTag(ctx context.Context, noteId, tagId, ownerId uuid.UUID) error {
+	if _, err := srv.GetOwnTag(ctx, tagId, ownerId); err != nil {
+		return err
+	}
+	return srv.tagsRepo.DetachTag(ctx, noteId, tagId)
+}
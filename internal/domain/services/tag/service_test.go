@@ -0,0 +1,153 @@
+package tag_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	tagsrv "wn/internal/domain/services/tag"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+type memoryRepo struct {
+	tags  map[uuid.UUID]*entity.Tag
+	notes map[uuid.UUID][]uuid.UUID
+}
+
+func (r *memoryRepo) CreateTag(_ context.Context, item *entity.Tag) error {
+	for _, tag := range r.tags {
+		if tag.OwnerId == item.OwnerId && strings.EqualFold(tag.Name, item.Name) {
+			return apperrors.TagAlreadyExists
+		}
+	}
+	stored := *item
+	r.tags[item.Id] = &stored
+	return nil
+}
+
+func (r *memoryRepo) GetTag(_ context.Context, tagId uuid.UUID) (*entity.Tag, error) {
+	item, ok := r.tags[tagId]
+	if !ok {
+		return nil, apperrors.TagNotFound
+	}
+	copied := *item
+	return &copied, nil
+}
+
+func (r *memoryRepo) UpdateTag(_ context.Context, tagId uuid.UUID, name, color *string) error {
+	if name != nil {
+		r.tags[tagId].Name = *name
+	}
+	if color != nil {
+		r.tags[tagId].Color = *color
+	}
+	return nil
+}
+
+func (r *memoryRepo) DeleteTag(_ context.Context, tagId uuid.UUID) error {
+	delete(r.tags, tagId)
+	return nil
+}
+
+func (r *memoryRepo) GetTags(_ context.Context, _ uuid.UUID) ([]entity.TagCount, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) GetLayoutTagCounts(_ context.Context, _, _ uuid.UUID) ([]entity.TagCount, error) {
+	return nil, nil
+}
+
+func (r *memoryRepo) AttachTag(_ context.Context, noteId, tagId uuid.UUID) error {
+	r.notes[noteId] = append(r.notes[noteId], tagId)
+	return nil
+}
+
+func (r *memoryRepo) DetachTag(_ context.Context, noteId, _ uuid.UUID) error {
+	delete(r.notes, noteId)
+	return nil
+}
+
+func newService(t *testing.T) (*tagsrv.Service, *memoryRepo) {
+	lgr := testutil.Logger(t)
+	repo := &memoryRepo{tags: map[uuid.UUID]*entity.Tag{}, notes: map[uuid.UUID][]uuid.UUID{}}
+	return tagsrv.NewService(nil, lgr, repo), repo
+}
+
+func TestNormalizeName(t *testing.T) {
+	cases := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{name: "  work ", want: "work", ok: true},
+		{name: "Работа", want: "Работа", ok: true},
+		{name: "   ", ok: false},
+		{name: "a,b", ok: false},
+		{name: strings.Repeat("я", 64), want: strings.Repeat("я", 64), ok: true},
+		{name: strings.Repeat("я", 65), ok: false},
+	}
+	for _, tc := range cases {
+		got, err := tagsrv.NormalizeName(tc.name)
+		if tc.ok && (err != nil || got != tc.want) {
+			t.Fatalf("NormalizeName(%q) = %q, %v, want %q", tc.name, got, err, tc.want)
+		}
+		if !tc.ok && !errors.Is(err, apperrors.BadTagName) {
+			t.Fatalf("NormalizeName(%q) error = %v, want BadTagName", tc.name, err)
+		}
+	}
+}
+
+func TestOwnTags(t *testing.T) {
+	ctx := context.Background()
+	owner, stranger := uuid.New(), uuid.New()
+
+	t.Run("duplicate name", func(t *testing.T) {
+		srv, _ := newService(t)
+		if _, err := srv.CreateTag(ctx, owner, "Work", ""); err != nil {
+			t.Fatalf("CreateTag() error = %v", err)
+		}
+		if _, err := srv.CreateTag(ctx, owner, " work", ""); !errors.Is(err, apperrors.TagAlreadyExists) {
+			t.Fatalf("CreateTag() duplicate error = %v, want TagAlreadyExists", err)
+		}
+		if _, err := srv.CreateTag(ctx, stranger, "work", ""); err != nil {
+			t.Fatalf("CreateTag() other owner error = %v", err)
+		}
+	})
+
+	t.Run("foreign tag", func(t *testing.T) {
+		srv, repo := newService(t)
+		tagId, err := srv.CreateTag(ctx, owner, "work", "#ff0000")
+		if err != nil {
+			t.Fatalf("CreateTag() error = %v", err)
+		}
+		noteId := uuid.New()
+
+		if err := srv.AttachTag(ctx, noteId, tagId, stranger); !errors.Is(err, apperrors.TagNotFound) {
+			t.Fatalf("AttachTag() foreign error = %v, want TagNotFound", err)
+		}
+		name := "home"
+		if err := srv.UpdateTag(ctx, tagId, stranger, &name, nil); !errors.Is(err, apperrors.TagNotFound) {
+			t.Fatalf("UpdateTag() foreign error = %v, want TagNotFound", err)
+		}
+		if err := srv.DeleteTag(ctx, tagId, stranger); !errors.Is(err, apperrors.TagNotFound) {
+			t.Fatalf("DeleteTag() foreign error = %v, want TagNotFound", err)
+		}
+
+		if err := srv.AttachTag(ctx, noteId, tagId, owner); err != nil {
+			t.Fatalf("AttachTag() error = %v", err)
+		}
+		if len(repo.notes[noteId]) != 1 {
+			t.Fatalf("note tags = %v, want one tag", repo.notes[noteId])
+		}
+		if err := srv.UpdateTag(ctx, tagId, owner, &name, nil); err != nil {
+			t.Fatalf("UpdateTag() error = %v", err)
+		}
+		if got := repo.tags[tagId]; got.Name != "home" || got.Color != "#ff0000" {
+			t.Fatalf("tag = %+v, want renamed with color kept", got)
+		}
+	})
+}
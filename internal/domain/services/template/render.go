@@ -0,0 +1,84 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDateFormat = "2006-01-02"
+	defaultTimeFormat = "15:04"
+)
+
+// placeholderPattern {{name}}, {{name:arg}} или {{name:arg|default}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*(?::([^}|]*))?(?:\|([^}]*))?\}\}`)
+
+// Vars значения подстановок. Now уже в часовом поясе пользователя
+type Vars struct {
+	Now    time.Time
+	User   string
+	Layout string
+	// Values ответы на {{prompt:...}} по имени вопроса
+	Values map[string]string
+}
+
+// Render подставляет значения в текст шаблона:
+//   - {{date}}, {{time}} и {{datetime}}, формат можно задать как в Go: {{date:02.01.2006}};
+//   - {{user}} имя пользователя, {{layout}} название лейаута;
+//   - {{prompt:Вопрос}} или {{prompt:Вопрос|по умолчанию}} значение, которое спрашивается у пользователя.
+//
+// Незнакомые подстановки остаются как есть. Возвращает вопросы без ответа и без значения по умолчанию
+func Render(text string, vars Vars) (string, []string) {
+	var missing []string
+	out := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		m := placeholderPattern.FindStringSubmatch(match)
+		name, arg, def := strings.ToLower(m[1]), strings.TrimSpace(m[2]), m[3]
+		switch name {
+		case "date":
+			return vars.Now.Format(orDefault(arg, defaultDateFormat))
+		case "time":
+			return vars.Now.Format(orDefault(arg, defaultTimeFormat))
+		case "datetime":
+			return vars.Now.Format(orDefault(arg, defaultDateFormat+" "+defaultTimeFormat))
+		case "user":
+			return vars.User
+		case "layout":
+			return vars.Layout
+		case "prompt":
+			if v, ok := vars.Values[arg]; ok {
+				return v
+			}
+			if !strings.Contains(match, "|") {
+				missing = append(missing, arg)
+			}
+			return def
+		}
+		return match
+	})
+	return out, missing
+}
+
+// Prompts вопросы из текстов шаблона без повторов, в порядке появления
+func Prompts(texts ...string) []string {
+	seen := map[string]bool{}
+	prompts := []string{}
+	for _, text := range texts {
+		for _, m := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+			name := strings.TrimSpace(m[2])
+			if strings.ToLower(m[1]) != "prompt" || name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			prompts = append(prompts, name)
+		}
+	}
+	return prompts
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
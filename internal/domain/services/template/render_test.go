@@ -0,0 +1,51 @@
+package template_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"wn/internal/domain/services/template"
+)
+
+func TestRender(t *testing.T) {
+	vars := template.Vars{
+		Now:    time.Date(2026, 3, 9, 14, 5, 0, 0, time.UTC),
+		User:   "walrus",
+		Layout: "Работа",
+		Values: map[string]string{"Проект": "wn"},
+	}
+
+	t.Run("builtins", func(t *testing.T) {
+		got, missing := template.Render("{{date}} {{ time }} {{date:02.01.2006}} {{user}}@{{layout}}", vars)
+		if want := "2026-03-09 14:05 09.03.2026 walrus@Работа"; got != want {
+			t.Fatalf("Render() = %q, want %q", got, want)
+		}
+		if len(missing) != 0 {
+			t.Fatalf("missing = %v", missing)
+		}
+	})
+
+	t.Run("prompts", func(t *testing.T) {
+		got, missing := template.Render("{{prompt:Проект}} {{prompt:Статус|черновик}} {{prompt:Участники}}", vars)
+		if want := "wn черновик "; got != want {
+			t.Fatalf("Render() = %q, want %q", got, want)
+		}
+		if !reflect.DeepEqual(missing, []string{"Участники"}) {
+			t.Fatalf("missing = %v", missing)
+		}
+	})
+
+	t.Run("unknown placeholders are kept", func(t *testing.T) {
+		text := "{{weather}} {{ [[Ссылка]] }}"
+		if got, _ := template.Render(text, vars); got != text {
+			t.Fatalf("Render() = %q, want %q", got, text)
+		}
+	})
+
+	t.Run("prompts list", func(t *testing.T) {
+		got := template.Prompts("{{prompt:Проект}}", "{{prompt:Статус|черновик}} {{prompt:Проект}} {{date}}")
+		if want := []string{"Проект", "Статус"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("Prompts() = %v, want %v", got, want)
+		}
+	})
+}
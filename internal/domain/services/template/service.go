@@ -0,0 +1,227 @@
+package template
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/user"
+	"wn/internal/domain/services/crypto"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/applogger"
+	"wn/pkg/trx"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+// maxNameLength длина названия шаблона в символах
+const maxNameLength = 64
+
+type templatesRepo interface {
+	CreateTemplate(ctx context.Context, item *entity.Template) error
+	GetTemplate(ctx context.Context, templateId uuid.UUID) (*entity.Template, error)
+	GetTemplates(ctx context.Context, ownerId uuid.UUID, layoutIds []uuid.UUID) ([]entity.Template, error)
+	UpdateTemplate(ctx context.Context, templateId uuid.UUID, name, title, payload *string) error
+	DeleteTemplate(ctx context.Context, templateId uuid.UUID) error
+}
+
+type layoutRepo interface {
+	GetById(ctx context.Context, layoutId uuid.UUID) (*entity.Layout, error)
+}
+
+type userService interface {
+	GetUserById(ctx context.Context, userId uuid.UUID, password string) (*user.User, error)
+}
+
+type permissionsService interface {
+	CheckPermissionByLayoutId(ctx context.Context, targetId, userId uuid.UUID, read, write, edit bool) error
+}
+
+type Service struct {
+	tx        trx.TransactionManager
+	logger    applogger.Logger
+	encryptor *crypto.Encryptor
+
+	templatesRepo      templatesRepo
+	layoutRepo         layoutRepo
+	userService        userService
+	permissionsService permissionsService
+}
+
+func NewService(
+	tx trx.TransactionManager,
+	logger applogger.Logger,
+	encryptor *crypto.Encryptor,
+	templatesRepo templatesRepo,
+	layoutRepo layoutRepo,
+	userService userService,
+	permissionsService permissionsService,
+) *Service {
+	return &Service{
+		tx:                 tx,
+		logger:             logger,
+		encryptor:          encryptor,
+		templatesRepo:      templatesRepo,
+		layoutRepo:         layoutRepo,
+		userService:        userService,
+		permissionsService: permissionsService,
+	}
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", apperrors.BadTemplateName
+	}
+	return name, nil
+}
+
+// CreateTemplate без layoutId шаблон личный. Права на лейаут проверяются здесь же: писать в него должен уметь автор
+func (srv *Service) CreateTemplate(ctx context.Context, ownerId uuid.UUID, layoutId *uuid.UUID, name, title, payload string) (uuid.UUID, error) {
+	name, err := normalizeName(name)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if layoutId != nil {
+		if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, *layoutId, ownerId, true, true, false); err != nil {
+			return uuid.Nil, err
+		}
+	}
+	payload, err = srv.encrypt(payload)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	item := entity.Template{
+		Id:        util.NewUUID(),
+		OwnerId:   ownerId,
+		LayoutId:  layoutId,
+		Name:      name,
+		Title:     title,
+		Payload:   payload,
+		CreatedAt: util.GetCurrentUTCTime(),
+	}
+	if err := srv.templatesRepo.CreateTemplate(ctx, &item); err != nil {
+		return uuid.Nil, errors.Wrap(err, "srv.templatesRepo.CreateTemplate")
+	}
+	return item.Id, nil
+}
+
+// GetTemplate шаблон с расшифрованным payload. Личный шаблон виден только владельцу,
+// общий - всем, кто может читать лейаут (write - писать в него)
+func (srv *Service) GetTemplate(ctx context.Context, templateId, userId uuid.UUID, write bool) (*entity.Template, error) {
+	item, err := srv.templatesRepo.GetTemplate(ctx, templateId)
+	if err != nil {
+		return nil, err
+	}
+	if item.LayoutId == nil {
+		if item.OwnerId != userId {
+			return nil, apperrors.TemplateNotFound
+		}
+	} else if err := srv.permissionsService.CheckPermissionByLayoutId(ctx, *item.LayoutId, userId, true, write, false); err != nil {
+		return nil, err
+	}
+	if item.Payload, err = srv.decrypt(item.Payload); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// GetTemplates личные шаблоны пользователя и шаблоны лейаутов, доступ к которым уже проверен
+func (srv *Service) GetTemplates(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]dto.Template, error) {
+	items, err := srv.templatesRepo.GetTemplates(ctx, userId, layoutIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.templatesRepo.GetTemplates")
+	}
+	out := make([]dto.Template, 0, len(items))
+	for i := range items {
+		if items[i].Payload, err = srv.decrypt(items[i].Payload); err != nil {
+			return nil, err
+		}
+		out = append(out, dto.TemplateFromEntity(&items[i], Prompts(items[i].Title, items[i].Payload)))
+	}
+	return out, nil
+}
+
+func (srv *Service) UpdateTemplate(ctx context.Context, templateId, userId uuid.UUID, name, title, payload *string) error {
+	if _, err := srv.GetTemplate(ctx, templateId, userId, true); err != nil {
+		return err
+	}
+	if name != nil {
+		normalized, err := normalizeName(*name)
+		if err != nil {
+			return err
+		}
+		name = &normalized
+	}
+	if payload != nil {
+		encrypted, err := srv.encrypt(*payload)
+		if err != nil {
+			return err
+		}
+		payload = &encrypted
+	}
+	return srv.templatesRepo.UpdateTemplate(ctx, templateId, name, title, payload)
+}
+
+func (srv *Service) DeleteTemplate(ctx context.Context, templateId, userId uuid.UUID) error {
+	if _, err := srv.GetTemplate(ctx, templateId, userId, true); err != nil {
+		return err
+	}
+	return srv.templatesRepo.DeleteTemplate(ctx, templateId)
+}
+
+// RenderTemplate заголовок и текст новой заметки в лейауте layoutId из шаблона.
+// Если на вопросы шаблона не хватает ответов, возвращает template_values_missing со списком вопросов
+func (srv *Service) RenderTemplate(ctx context.Context, templateId, userId, layoutId uuid.UUID, values map[string]string, now time.Time) (string, string, error) {
+	item, err := srv.GetTemplate(ctx, templateId, userId, false)
+	if err != nil {
+		return "", "", err
+	}
+	u, err := srv.userService.GetUserById(ctx, userId, "")
+	if err != nil {
+		return "", "", err
+	}
+	l, err := srv.layoutRepo.GetById(ctx, layoutId)
+	if err != nil {
+		return "", "", err
+	}
+	vars := Vars{Now: now, User: u.Username, Layout: l.Title, Values: values}
+	title, missingTitle := Render(item.Title, vars)
+	payload, missingPayload := Render(item.Payload, vars)
+	if missing := unique(append(missingTitle, missingPayload...)); len(missing) > 0 {
+		return "", "", apperrors.TemplateValuesMissing.WithData(dto.TemplateValuesMissing{Prompts: missing})
+	}
+	return title, payload, nil
+}
+
+func unique(items []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if !seen[item] {
+			seen[item] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func (srv *Service) encrypt(payload string) (string, error) {
+	if payload == "" {
+		return "", nil
+	}
+	encrypted, err := srv.encryptor.Encrypt(payload)
+	return encrypted, errors.Wrap(err, "srv.encryptor.Encrypt")
+}
+
+func (srv *Service) decrypt(payload string) (string, error) {
+	if payload == "" {
+		return "", nil
+	}
+	plain, err := srv.encryptor.Decrypt(payload)
+	return plain, errors.Wrap(err, "srv.encryptor.Decrypt")
+}
@@ -0,0 +1,343 @@
+package upload
+
+import (
+	"context"
+	"io"
+	"time"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/blobstore"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	chunkPrefix = "uploads/"
+	// expireBatch сколько просроченных загрузок удаляется за один проход
+	expireBatch = 50
+	// assemblyStaleAfter через сколько зависшую сборку (упал инстанс) может начать другой запрос
+	assemblyStaleAfter = 15 * time.Minute
+)
+
+type uploadRepo interface {
+	CreateUpload(ctx context.Context, item *entity.Upload) error
+	GetUpload(ctx context.Context, id uuid.UUID) (*entity.Upload, error)
+	AppendChunk(ctx context.Context, id uuid.UUID, offset int64, key string, size int64, expiresAt time.Time) (bool, error)
+	ClaimAssembly(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error)
+	ReleaseAssembly(ctx context.Context, id uuid.UUID) error
+	CompleteUpload(ctx context.Context, id uuid.UUID, resultName string) (bool, error)
+	DeleteUpload(ctx context.Context, id uuid.UUID) error
+	GetExpiredUploads(ctx context.Context, before time.Time, limit uint64) ([]*entity.Upload, error)
+}
+
+type fileService interface {
+	CheckUpload(ctx context.Context, purpose enum.FilePurpose, ownerId uuid.UUID, originalName string, size int64) error
+	NewFile(ctx context.Context, purpose enum.FilePurpose, ownerId, noteId uuid.UUID, originalName string, r io.Reader) (*entity.File, error)
+	GetFileWithVariants(ctx context.Context, name string) (*entity.File, error)
+	DeleteFile(ctx context.Context, name string, ownerId uuid.UUID, force bool) (*entity.File, error)
+}
+
+type Config struct {
+	// Expiry сколько незавершенная загрузка живет после последнего куска
+	Expiry time.Duration
+}
+
+func NewConfig(expiry time.Duration) *Config {
+	return &Config{Expiry: expiry}
+}
+
+type Service struct {
+	logger applogger.Logger
+	cfg    *Config
+
+	uploadRepo  uploadRepo
+	fileService fileService
+	store       blobstore.BlobStore
+}
+
+func NewService(lgr applogger.Logger, cfg *Config, uploadRepo uploadRepo, fileService fileService, store blobstore.BlobStore) *Service {
+	return &Service{
+		logger:      lgr,
+		cfg:         cfg,
+		uploadRepo:  uploadRepo,
+		fileService: fileService,
+		store:       store,
+	}
+}
+
+// CreateUpload заводит загрузку вложения заявленного размера
+func (srv *Service) CreateUpload(ctx context.Context, ownerId, noteId uuid.UUID, fileName string, length int64) (*entity.Upload, error) {
+	if err := srv.fileService.CheckUpload(ctx, enum.FilePurposeAttachment, ownerId, fileName, length); err != nil {
+		return nil, err
+	}
+	item := &entity.Upload{
+		Id:        util.NewUUID(),
+		OwnerId:   ownerId,
+		NoteId:    noteId,
+		FileName:  fileName,
+		Length:    length,
+		ExpiresAt: time.Now().Add(srv.cfg.Expiry),
+	}
+	if err := srv.uploadRepo.CreateUpload(ctx, item); err != nil {
+		return nil, errors.Wrap(err, "srv.uploadRepo.CreateUpload")
+	}
+	return item, nil
+}
+
+// GetUpload загрузка пользователя, чужие и просроченные не отдаются
+func (srv *Service) GetUpload(ctx context.Context, id, ownerId uuid.UUID) (*entity.Upload, error) {
+	item, err := srv.uploadRepo.GetUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item.OwnerId != ownerId {
+		return nil, apperrors.FileAccessDenied
+	}
+	if !item.Completed() && item.ExpiresAt.Before(time.Now()) {
+		return nil, apperrors.UploadNotFound
+	}
+	return item, nil
+}
+
+// WriteChunk дописывает кусок с позиции offset. Когда получены все байты, загрузка собирается в файл
+// через обычную проверку и запись NewFile, файл возвращается вторым значением.
+// У оборванного куска сохраняется полученная часть, клиент продолжает с новой позиции (tus 1.0).
+// Пустой кусок на полностью полученной загрузке повторяет сборку, если прошлая не удалась
+func (srv *Service) WriteChunk(ctx context.Context, id, ownerId uuid.UUID, offset int64, r io.Reader) (*entity.Upload, *entity.File, error) {
+	item, err := srv.GetUpload(ctx, id, ownerId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if item.Completed() {
+		return nil, nil, apperrors.UploadCompleted
+	}
+	if offset != item.Offset {
+		return nil, nil, apperrors.UploadOffsetMismatch
+	}
+
+	body := &cutReader{r: io.LimitReader(r, item.Length-item.Offset+1)}
+	counter := &countingReader{r: body}
+	// при обрыве контекст запроса отменяется вместе с соединением, а полученное нужно успеть записать
+	writeCtx := context.WithoutCancel(ctx)
+	key := chunkPrefix + item.Id.String() + "/" + util.NewUUID().String()
+	if err := srv.store.Put(writeCtx, key, counter, blobstore.UnknownSize, "application/offset+octet-stream"); err != nil {
+		return nil, nil, errors.Wrap(err, "srv.store.Put")
+	}
+	if item.Offset+counter.n > item.Length {
+		srv.deleteChunks(writeCtx, []string{key})
+		return nil, nil, apperrors.UploadTooLong
+	}
+
+	if counter.n > 0 {
+		expiresAt := time.Now().Add(srv.cfg.Expiry)
+		// параллельный запрос с той же позицией мог успеть раньше
+		ok, err := srv.uploadRepo.AppendChunk(writeCtx, item.Id, item.Offset, key, counter.n, expiresAt)
+		if err != nil || !ok {
+			srv.deleteChunks(writeCtx, []string{key})
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "srv.uploadRepo.AppendChunk")
+			}
+			return nil, nil, apperrors.UploadOffsetMismatch
+		}
+		item.Offset += counter.n
+		item.ChunkKeys = append(item.ChunkKeys, key)
+		item.ExpiresAt = expiresAt
+	} else {
+		srv.deleteChunks(writeCtx, []string{key})
+	}
+
+	if item.Offset < item.Length {
+		if body.err != nil {
+			return nil, nil, errors.Wrap(body.err, "read chunk")
+		}
+		return item, nil, nil
+	}
+	created, err := srv.complete(ctx, item)
+	if err != nil {
+		return nil, nil, err
+	}
+	return item, created, nil
+}
+
+// complete собирает куски в файл. Собирает только один запрос: пока идет сборка, остальные получают nil,
+// а если загрузку уже собрали, ее файл. Если содержимое не прошло проверку или не влезло в квоту,
+// загрузка удаляется целиком. После временной ошибки она остается, сборку повторит
+// следующий PATCH или HEAD
+func (srv *Service) complete(ctx context.Context, item *entity.Upload) (*entity.File, error) {
+	claimed, err := srv.uploadRepo.ClaimAssembly(ctx, item.Id, time.Now().Add(-assemblyStaleAfter))
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.uploadRepo.ClaimAssembly")
+	}
+	if !claimed {
+		return srv.completedFile(ctx, item)
+	}
+
+	content := &chunkReader{ctx: ctx, store: srv.store, keys: item.ChunkKeys}
+	defer content.Close()
+
+	created, err := srv.fileService.NewFile(ctx, enum.FilePurposeAttachment, item.OwnerId, item.NoteId, item.FileName, content)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*apperror.AppError); !ok {
+			if relErr := srv.uploadRepo.ReleaseAssembly(ctx, item.Id); relErr != nil {
+				srv.logger.WithCtx(ctx).Warnf("complete release assembly: %s", relErr.Error())
+			}
+			return nil, errors.Wrap(err, "srv.fileService.NewFile")
+		}
+		if delErr := srv.DeleteUpload(ctx, item); delErr != nil {
+			srv.logger.WithCtx(ctx).Warnf("complete delete upload: %s", delErr.Error())
+		}
+		return nil, err
+	}
+	completed, err := srv.uploadRepo.CompleteUpload(ctx, item.Id, created.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "srv.uploadRepo.CompleteUpload")
+	}
+	if !completed {
+		// сборку перехватил другой запрос, когда эта считалась зависшей: остается его файл
+		if _, delErr := srv.fileService.DeleteFile(ctx, created.Name, item.OwnerId, true); delErr != nil {
+			srv.logger.WithCtx(ctx).Warnf("complete delete duplicate %s: %s", created.Name, delErr.Error())
+		}
+		return srv.completedFile(ctx, item)
+	}
+	item.ResultName = created.Name
+	srv.deleteChunks(ctx, item.ChunkKeys)
+	item.ChunkKeys = nil
+	return created, nil
+}
+
+// completedFile перечитывает загрузку, которую собирал другой запрос. nil, если сборка еще идет
+func (srv *Service) completedFile(ctx context.Context, item *entity.Upload) (*entity.File, error) {
+	current, err := srv.uploadRepo.GetUpload(ctx, item.Id)
+	if err != nil {
+		return nil, err
+	}
+	if !current.Completed() {
+		return nil, nil
+	}
+	item.ResultName = current.ResultName
+	item.ChunkKeys = nil
+	return srv.fileService.GetFileWithVariants(ctx, item.ResultName)
+}
+
+// GetResult файл завершенной загрузки вместе с миниатюрами, nil пока загрузка не завершена.
+// Загрузка, получившая все байты, но не собранная из-за временной ошибки, собирается здесь
+func (srv *Service) GetResult(ctx context.Context, item *entity.Upload) (*entity.File, error) {
+	if !item.Completed() {
+		if item.Offset < item.Length {
+			return nil, nil
+		}
+		return srv.complete(ctx, item)
+	}
+	return srv.fileService.GetFileWithVariants(ctx, item.ResultName)
+}
+
+// DeleteUpload удаляет загрузку и ее куски, созданный файл остается
+func (srv *Service) DeleteUpload(ctx context.Context, item *entity.Upload) error {
+	if err := srv.uploadRepo.DeleteUpload(ctx, item.Id); err != nil {
+		return errors.Wrap(err, "srv.uploadRepo.DeleteUpload")
+	}
+	srv.deleteChunks(ctx, item.ChunkKeys)
+	return nil
+}
+
+// ExpireUploads удаляет загрузки, которые не продолжались дольше Expiry
+func (srv *Service) ExpireUploads(ctx context.Context) (int, error) {
+	expired := 0
+	for {
+		items, err := srv.uploadRepo.GetExpiredUploads(ctx, time.Now(), expireBatch)
+		if err != nil {
+			return expired, errors.Wrap(err, "srv.uploadRepo.GetExpiredUploads")
+		}
+		for _, item := range items {
+			if err := srv.DeleteUpload(ctx, item); err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(items) < expireBatch || ctx.Err() != nil {
+			return expired, nil
+		}
+	}
+}
+
+func (srv *Service) deleteChunks(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := srv.store.Delete(ctx, key); err != nil {
+			srv.logger.WithCtx(ctx).Warnf("deleteChunks %s: %s", key, err.Error())
+		}
+	}
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// cutReader превращает обрыв чтения в конец потока, чтобы хранилище записало полученную часть.
+// Сама ошибка остается в err
+type cutReader struct {
+	r   io.Reader
+	err error
+}
+
+func (c *cutReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if err != nil && err != io.EOF {
+		c.err = err
+		return n, io.EOF
+	}
+	return n, err
+}
+
+// chunkReader читает куски подряд, открывая следующий только когда закончился предыдущий
+type chunkReader struct {
+	ctx   context.Context
+	store blobstore.BlobStore
+	keys  []string
+	cur   io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if len(c.keys) == 0 {
+				return 0, io.EOF
+			}
+			content, err := c.store.Open(c.ctx, c.keys[0])
+			if err != nil {
+				return 0, errors.Wrapf(err, "srv.store.Open %s", c.keys[0])
+			}
+			c.cur, c.keys = content, c.keys[1:]
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			c.cur.Close()
+			c.cur = nil
+			if n == 0 {
+				continue
+			}
+			return n, nil
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.cur == nil {
+		return nil
+	}
+	err := c.cur.Close()
+	c.cur = nil
+	return err
+}
@@ -0,0 +1,375 @@
+package upload_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"wn/internal/domain/enum"
+	uploadsrv "wn/internal/domain/services/upload"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/testutil"
+	"wn/pkg/blobstore"
+
+	"github.com/google/uuid"
+)
+
+type memoryRepo struct {
+	uploads map[uuid.UUID]*entity.Upload
+	// assembling загрузки, которые сейчас собираются
+	assembling map[uuid.UUID]bool
+}
+
+func (r *memoryRepo) CreateUpload(_ context.Context, item *entity.Upload) error {
+	stored := *item
+	r.uploads[item.Id] = &stored
+	return nil
+}
+
+func (r *memoryRepo) GetUpload(_ context.Context, id uuid.UUID) (*entity.Upload, error) {
+	item, ok := r.uploads[id]
+	if !ok {
+		return nil, apperrors.UploadNotFound
+	}
+	copied := *item
+	copied.ChunkKeys = append([]string(nil), item.ChunkKeys...)
+	return &copied, nil
+}
+
+func (r *memoryRepo) AppendChunk(_ context.Context, id uuid.UUID, offset int64, key string, size int64, expiresAt time.Time) (bool, error) {
+	item, ok := r.uploads[id]
+	if !ok || item.Offset != offset || item.ResultName != "" {
+		return false, nil
+	}
+	item.Offset += size
+	item.ChunkKeys = append(item.ChunkKeys, key)
+	item.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (r *memoryRepo) ClaimAssembly(_ context.Context, id uuid.UUID, _ time.Time) (bool, error) {
+	item, ok := r.uploads[id]
+	if !ok || item.ResultName != "" || item.Offset != item.Length || r.assembling[id] {
+		return false, nil
+	}
+	r.assembling[id] = true
+	return true, nil
+}
+
+func (r *memoryRepo) ReleaseAssembly(_ context.Context, id uuid.UUID) error {
+	delete(r.assembling, id)
+	return nil
+}
+
+func (r *memoryRepo) CompleteUpload(_ context.Context, id uuid.UUID, resultName string) (bool, error) {
+	item, ok := r.uploads[id]
+	if !ok || item.ResultName != "" {
+		return false, nil
+	}
+	item.ResultName = resultName
+	item.ChunkKeys = nil
+	return true, nil
+}
+
+func (r *memoryRepo) DeleteUpload(_ context.Context, id uuid.UUID) error {
+	delete(r.uploads, id)
+	return nil
+}
+
+func (r *memoryRepo) GetExpiredUploads(_ context.Context, before time.Time, limit uint64) ([]*entity.Upload, error) {
+	var items []*entity.Upload
+	for _, item := range r.uploads {
+		if item.ResultName == "" && item.ExpiresAt.Before(before) && uint64(len(items)) < limit {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// fakeFiles принимает файлы до maxSize байт, содержимое с префиксом "bad" не проходит проверку.
+// fail возвращается один раз вместо создания файла, during вызывается один раз посреди создания
+type fakeFiles struct {
+	maxSize int64
+	created map[string][]byte
+	fail    error
+	during  func()
+}
+
+func (f *fakeFiles) CheckUpload(_ context.Context, _ enum.FilePurpose, _ uuid.UUID, _ string, size int64) error {
+	if size > f.maxSize {
+		return apperrors.FileTooLarge
+	}
+	return nil
+}
+
+func (f *fakeFiles) NewFile(_ context.Context, _ enum.FilePurpose, ownerId, noteId uuid.UUID, _ string, r io.Reader) (*entity.File, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.fail != nil {
+		err, f.fail = f.fail, nil
+		return nil, err
+	}
+	if f.during != nil {
+		during := f.during
+		f.during = nil
+		during()
+	}
+	if bytes.HasPrefix(content, []byte("bad")) {
+		return nil, apperrors.FileTypeMismatch
+	}
+	name := uuid.NewString() + ".txt"
+	f.created[name] = content
+	return &entity.File{Name: name, OwnerId: ownerId, NoteId: noteId, Size: int64(len(content))}, nil
+}
+
+func (f *fakeFiles) GetFileWithVariants(_ context.Context, name string) (*entity.File, error) {
+	if _, ok := f.created[name]; !ok {
+		return nil, apperrors.FileNotFound
+	}
+	return &entity.File{Name: name}, nil
+}
+
+func (f *fakeFiles) DeleteFile(_ context.Context, name string, _ uuid.UUID, _ bool) (*entity.File, error) {
+	delete(f.created, name)
+	return &entity.File{Name: name}, nil
+}
+
+// brokenReader отдает data и обрывается, как тело запроса при разрыве соединения
+type brokenReader struct {
+	data []byte
+}
+
+func (r *brokenReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func newService(t *testing.T, expiry time.Duration) (*uploadsrv.Service, *memoryRepo, *fakeFiles, blobstore.BlobStore) {
+	lgr := testutil.Logger(t)
+	store, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+	repo := &memoryRepo{uploads: map[uuid.UUID]*entity.Upload{}, assembling: map[uuid.UUID]bool{}}
+	files := &fakeFiles{maxSize: 64, created: map[string][]byte{}}
+	return uploadsrv.NewService(lgr, uploadsrv.NewConfig(expiry), repo, files, store), repo, files, store
+}
+
+func TestWriteChunk(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+
+	t.Run("too large", func(t *testing.T) {
+		srv, _, _, _ := newService(t, time.Hour)
+		if _, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 65); !errors.Is(err, apperrors.FileTooLarge) {
+			t.Fatalf("CreateUpload() error = %v, want FileTooLarge", err)
+		}
+	})
+
+	t.Run("chunks", func(t *testing.T) {
+		srv, repo, files, store := newService(t, time.Hour)
+		item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 11)
+		if err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+
+		if _, _, err := srv.WriteChunk(ctx, item.Id, uuid.New(), 0, strings.NewReader("hello")); !errors.Is(err, apperrors.FileAccessDenied) {
+			t.Fatalf("WriteChunk() foreign error = %v, want FileAccessDenied", err)
+		}
+		got, created, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("hello "))
+		if err != nil || created != nil || got.Offset != 6 {
+			t.Fatalf("WriteChunk() = %+v, %v, %v, want offset 6", got, created, err)
+		}
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("hello ")); !errors.Is(err, apperrors.UploadOffsetMismatch) {
+			t.Fatalf("WriteChunk() repeated error = %v, want UploadOffsetMismatch", err)
+		}
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 6, strings.NewReader("world!")); !errors.Is(err, apperrors.UploadTooLong) {
+			t.Fatalf("WriteChunk() long error = %v, want UploadTooLong", err)
+		}
+
+		chunks := repo.uploads[item.Id].ChunkKeys
+		got, created, err = srv.WriteChunk(ctx, item.Id, owner, 6, strings.NewReader("world"))
+		if err != nil || created == nil {
+			t.Fatalf("WriteChunk() last = %v, %v, want file", created, err)
+		}
+		if string(files.created[created.Name]) != "hello world" {
+			t.Errorf("file content = %q", files.created[created.Name])
+		}
+		if got.ResultName != created.Name || repo.uploads[item.Id].ResultName != created.Name {
+			t.Errorf("upload result = %q, want %q", repo.uploads[item.Id].ResultName, created.Name)
+		}
+		if len(got.ChunkKeys) != 0 {
+			t.Errorf("chunk keys = %v, want none", got.ChunkKeys)
+		}
+		for _, key := range chunks {
+			if _, err := store.Open(ctx, key); err == nil {
+				t.Errorf("chunk %s is not deleted", key)
+			}
+		}
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 11, strings.NewReader("x")); !errors.Is(err, apperrors.UploadCompleted) {
+			t.Fatalf("WriteChunk() after complete error = %v, want UploadCompleted", err)
+		}
+	})
+
+	t.Run("rejected content", func(t *testing.T) {
+		srv, repo, _, _ := newService(t, time.Hour)
+		item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 3)
+		if err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("bad")); !errors.Is(err, apperrors.FileTypeMismatch) {
+			t.Fatalf("WriteChunk() error = %v, want FileTypeMismatch", err)
+		}
+		if _, ok := repo.uploads[item.Id]; ok {
+			t.Errorf("rejected upload is not deleted")
+		}
+	})
+}
+
+func TestWriteChunkRecovery(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+
+	t.Run("interrupted chunk", func(t *testing.T) {
+		srv, repo, files, _ := newService(t, time.Hour)
+		item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 11)
+		if err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, &brokenReader{data: []byte("hello w")}); !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("WriteChunk() broken error = %v, want ErrUnexpectedEOF", err)
+		}
+		// полученная часть сохранена, клиент продолжает с нее
+		if offset := repo.uploads[item.Id].Offset; offset != 7 {
+			t.Fatalf("offset after break = %d, want 7", offset)
+		}
+		_, created, err := srv.WriteChunk(ctx, item.Id, owner, 7, strings.NewReader("orld"))
+		if err != nil || created == nil || string(files.created[created.Name]) != "hello world" {
+			t.Fatalf("WriteChunk() resumed = %v, %v", created, err)
+		}
+	})
+
+	t.Run("transient failure", func(t *testing.T) {
+		srv, repo, files, _ := newService(t, time.Hour)
+		item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 5)
+		if err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		files.fail = errors.New("store is down")
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("hello")); err == nil {
+			t.Fatalf("WriteChunk() error = nil, want store error")
+		}
+		kept, ok := repo.uploads[item.Id]
+		if !ok || kept.Offset != 5 || len(kept.ChunkKeys) != 1 {
+			t.Fatalf("upload after transient failure = %+v", kept)
+		}
+		// пустой PATCH с конечной позиции повторяет сборку
+		got, created, err := srv.WriteChunk(ctx, item.Id, owner, 5, strings.NewReader(""))
+		if err != nil || created == nil || got.ResultName != created.Name || string(files.created[created.Name]) != "hello" {
+			t.Fatalf("WriteChunk() retry = %+v, %v, %v", got, created, err)
+		}
+	})
+
+	t.Run("complete on status", func(t *testing.T) {
+		srv, _, files, _ := newService(t, time.Hour)
+		item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 5)
+		if err != nil {
+			t.Fatalf("CreateUpload() error = %v", err)
+		}
+		files.fail = errors.New("store is down")
+		if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("hello")); err == nil {
+			t.Fatalf("WriteChunk() error = nil, want store error")
+		}
+		got, err := srv.GetUpload(ctx, item.Id, owner)
+		if err != nil {
+			t.Fatalf("GetUpload() error = %v", err)
+		}
+		result, err := srv.GetResult(ctx, got)
+		if err != nil || result == nil || string(files.created[result.Name]) != "hello" {
+			t.Fatalf("GetResult() = %+v, %v", result, err)
+		}
+	})
+}
+
+func TestConcurrentAssembly(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	srv, repo, files, _ := newService(t, time.Hour)
+	item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 5)
+	if err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+	files.fail = errors.New("store is down")
+	if _, _, err := srv.WriteChunk(ctx, item.Id, owner, 0, strings.NewReader("hello")); err == nil {
+		t.Fatalf("WriteChunk() error = nil, want store error")
+	}
+	// оба HEAD прочитали загрузку до сборки
+	first, err := srv.GetUpload(ctx, item.Id, owner)
+	if err != nil {
+		t.Fatalf("GetUpload() error = %v", err)
+	}
+	second, err := srv.GetUpload(ctx, item.Id, owner)
+	if err != nil {
+		t.Fatalf("GetUpload() error = %v", err)
+	}
+
+	// второй приходит, пока первый собирает файл
+	files.during = func() {
+		result, err := srv.GetResult(ctx, second)
+		if err != nil || result != nil {
+			t.Errorf("GetResult() during assembly = %+v, %v, want nil", result, err)
+		}
+	}
+	result, err := srv.GetResult(ctx, first)
+	if err != nil || result == nil {
+		t.Fatalf("GetResult() = %+v, %v", result, err)
+	}
+	// после сборки устаревшая копия получает тот же файл, а не собирает новый
+	again, err := srv.GetResult(ctx, second)
+	if err != nil || again == nil || again.Name != result.Name {
+		t.Fatalf("GetResult() after assembly = %+v, %v, want %s", again, err, result.Name)
+	}
+	if len(files.created) != 1 || repo.uploads[item.Id].ResultName != result.Name {
+		t.Fatalf("created %d files, upload result %q", len(files.created), repo.uploads[item.Id].ResultName)
+	}
+}
+
+func TestExpireUploads(t *testing.T) {
+	ctx := context.Background()
+	owner := uuid.New()
+	srv, repo, _, store := newService(t, -time.Minute)
+
+	item, err := srv.CreateUpload(ctx, owner, uuid.Nil, "a.txt", 10)
+	if err != nil {
+		t.Fatalf("CreateUpload() error = %v", err)
+	}
+	if _, err := srv.GetUpload(ctx, item.Id, owner); !errors.Is(err, apperrors.UploadNotFound) {
+		t.Fatalf("GetUpload() expired error = %v, want UploadNotFound", err)
+	}
+	key := "uploads/" + item.Id.String() + "/chunk"
+	if err := store.Put(ctx, key, strings.NewReader("part"), 4, "application/offset+octet-stream"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	repo.uploads[item.Id].ChunkKeys = []string{key}
+
+	expired, err := srv.ExpireUploads(ctx)
+	if err != nil || expired != 1 {
+		t.Fatalf("ExpireUploads() = %d, %v, want 1", expired, err)
+	}
+	if _, ok := repo.uploads[item.Id]; ok {
+		t.Errorf("expired upload is not deleted")
+	}
+	if _, err := store.Open(ctx, key); err == nil {
+		t.Errorf("chunk of expired upload is not deleted")
+	}
+}
@@ -51,7 +51,6 @@ func (srv *Service) CreateUserFromAuthCredentials(ctx context.Context, credintia
 		Id:        util.NewUUID(),
 		Username:  credintials.Username,
 		Email:     credintials.Email,
-		ImgUrl:    "base.png",
 		CreatedAt: util.GetCurrentUTCTime(),
 	}
 	userEntity := userRepository.User{
@@ -60,7 +59,6 @@ func (srv *Service) CreateUserFromAuthCredentials(ctx context.Context, credintia
 		Email:          user.Email,
 		ConfirmedEmail: false,
 		Password:       generatePasswordHash(credintials.Password),
-		ImgUrl:         "base.png",
 		CreatedAt:      user.CreatedAt,
 		Role:           constants.ClientRole,
 	}
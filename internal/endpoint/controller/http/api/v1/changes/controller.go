@@ -0,0 +1,106 @@
+package changes
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type changesService interface {
+	GetChanges(ctx context.Context, userId uuid.UUID, since string) (*dto.SyncChangesResponse, error)
+	Push(ctx context.Context, userId uuid.UUID, req *dto.SyncPushRequest) (*dto.SyncPushResponse, error)
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	changesService changesService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, changesService changesService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		changesService: changesService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	syncAuth := authApi.Group("/sync")
+	{
+		syncAuth.GET("/changes", h.getChanges)
+		syncAuth.POST("/push", h.push)
+	}
+}
+
+// @Summary getChanges
+// @Description Лента изменений после курсора. Пустой since - с самого начала
+// @Tags sync
+// @Produce json
+// @Param since query string false "cursor"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.SyncChangesResponse}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bad_cursor, invalid_X-Request-Id"
+// @Router /wn/api/v1/sync/changes [get]
+func (h *Controller) getChanges(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	resp, err := h.changesService.GetChanges(ctx, userId, c.Query("since"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp))
+}
+
+// @Summary push
+// @Description Применить офлайн изменения. Результат по каждому элементу: APPLIED, CONFLICT или REJECTED
+// @Tags sync
+// @Produce json
+// @Param data body dto.SyncPushRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.SyncPushResponse}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Router /wn/api/v1/sync/push [post]
+func (h *Controller) push(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req dto.SyncPushRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	resp, err := h.changesService.Push(ctx, userId, &req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp))
+}
@@ -2,11 +2,17 @@ package v1
 
 import (
 	"wn/internal/endpoint/controller/http/api/v1/auth"
+	"wn/internal/endpoint/controller/http/api/v1/changes"
 	"wn/internal/endpoint/controller/http/api/v1/file"
+	"wn/internal/endpoint/controller/http/api/v1/journal"
 	"wn/internal/endpoint/controller/http/api/v1/layout"
 	"wn/internal/endpoint/controller/http/api/v1/note"
 	"wn/internal/endpoint/controller/http/api/v1/permissions"
+	"wn/internal/endpoint/controller/http/api/v1/reminder"
 	"wn/internal/endpoint/controller/http/api/v1/socket"
+	"wn/internal/endpoint/controller/http/api/v1/tag"
+	"wn/internal/endpoint/controller/http/api/v1/task"
+	"wn/internal/endpoint/controller/http/api/v1/template"
 	"wn/internal/endpoint/controller/http/api/v1/user"
 
 	"github.com/gin-gonic/gin"
@@ -22,6 +28,12 @@ type Dispatcher struct {
 	sockets     *socket.Controller
 	file        *file.Controller
 	permissions *permissions.Controller
+	changes     *changes.Controller
+	tag         *tag.Controller
+	template    *template.Controller
+	journal     *journal.Controller
+	task        *task.Controller
+	reminder    *reminder.Controller
 }
 
 func NewDispatcher(
@@ -34,6 +46,12 @@ func NewDispatcher(
 	sockets *socket.Controller,
 	file *file.Controller,
 	permissions *permissions.Controller,
+	changes *changes.Controller,
+	tag *tag.Controller,
+	template *template.Controller,
+	journal *journal.Controller,
+	task *task.Controller,
+	reminder *reminder.Controller,
 ) *Dispatcher {
 	return &Dispatcher{
 		apiPath:     apiPath,
@@ -44,9 +62,23 @@ func NewDispatcher(
 		sockets:     sockets,
 		file:        file,
 		permissions: permissions,
+		changes:     changes,
+		tag:         tag,
+		template:    template,
+		journal:     journal,
+		task:        task,
+		reminder:    reminder,
 	}
 }
 
+// InitStatics раздача загруженных файлов и календаря напоминаний, без X-Request-Id,
+// чтобы работали обычные <img src> и календарные приложения
+func (d *Dispatcher) InitStatics(router *gin.RouterGroup) {
+	d.file.InitStatics(router)
+	d.user.InitStatics(router)
+	d.reminder.InitStatics(router)
+}
+
 func (d *Dispatcher) Init(router *gin.RouterGroup, authorization gin.HandlerFunc, ws *gin.RouterGroup) {
 	api := router.Group("/v1")
 	{
@@ -59,6 +91,12 @@ func (d *Dispatcher) Init(router *gin.RouterGroup, authorization gin.HandlerFunc
 			d.sockets.ConnectionController(ws)
 			d.file.Init(api, authorizedGroup)
 			d.permissions.Init(api, authorizedGroup)
+			d.changes.Init(api, authorizedGroup)
+			d.tag.Init(api, authorizedGroup)
+			d.template.Init(api, authorizedGroup)
+			d.journal.Init(api, authorizedGroup)
+			d.task.Init(api, authorizedGroup)
+			d.reminder.Init(api, authorizedGroup)
 		}
 	}
 }
@@ -2,8 +2,16 @@ package file
 
 import (
 	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
 	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
+	"wn/internal/entity"
 	apperrors "wn/internal/errors"
 	"wn/pkg/apperror"
 	"wn/pkg/applogger"
@@ -13,10 +21,27 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
+// bundledDir картинки, которые лежат в репозитории, а не в хранилище (base.png, logo.png)
+const bundledDir = "./statics/images"
+
 type fileService interface {
-	UploadFile(ctx context.Context, userId uuid.UUID, req request.UploadFileRequest, host string) (*dto.UploadFileResponse, error)
+	UploadFile(ctx context.Context, userId, noteId uuid.UUID, originalName string, r io.Reader, host string) (*dto.UploadFileResponse, error)
+	OpenFile(ctx context.Context, name string, userId uuid.UUID, query url.Values) (*entity.File, io.ReadSeekCloser, error)
+	GetAttachments(ctx context.Context, userId, noteId uuid.UUID, host string) ([]dto.Attachment, error)
+	Attach(ctx context.Context, userId uuid.UUID, req request.AttachmentRequest) error
+	Detach(ctx context.Context, userId uuid.UUID, req request.AttachmentRequest) error
+	GarbageReport(ctx context.Context, userId uuid.UUID) (*dto.GarbageReport, error)
+	GetMyFiles(ctx context.Context, userId uuid.UUID, page int, host string) ([]dto.OwnFile, int, error)
+	DeleteFile(ctx context.Context, userId uuid.UUID, req request.DeleteFileRequest) (*dto.DeleteFileResponse, error)
+
+	MaxUploadSize() int64
+	CreateUpload(ctx context.Context, userId uuid.UUID, req request.CreateUploadRequest) (*dto.UploadStatus, error)
+	GetUpload(ctx context.Context, userId, id uuid.UUID, host string) (*dto.UploadStatus, error)
+	WriteUpload(ctx context.Context, userId, id uuid.UUID, offset int64, r io.Reader, host string) (*dto.UploadStatus, error)
+	DeleteUpload(ctx context.Context, userId, id uuid.UUID) error
 }
 
 type Controller struct {
@@ -36,45 +61,359 @@ func NewController(logger applogger.Logger, builder *response.Builder, fileServi
 }
 
 func (h *Controller) Init(api, authApi *gin.RouterGroup) {
-	_ = api.Group("/file")
+	file := api.Group("/file")
 	fileAuth := authApi.Group("/file")
 	{
+		file.OPTIONS("/tus", h.tusOptions)
+		tus := fileAuth.Group("/tus", tusResumable)
+		tus.POST("", h.tusCreate)
+		tus.HEAD("/:id", h.tusHead)
+		tus.GET("/:id", h.tusStatus)
+		tus.PATCH("/:id", h.tusPatch)
+		tus.DELETE("/:id", h.tusDelete)
+
 		fileAuth.POST("/upload", h.uploadFile)
+		fileAuth.GET("/attachments", h.getAttachments)
+		fileAuth.POST("/attachments/attach", h.attachFile)
+		fileAuth.POST("/attachments/detach", h.detachFile)
+		fileAuth.GET("/gc/report", h.garbageReport)
+		fileAuth.GET("/my", h.getMyFiles)
+		fileAuth.POST("/delete", h.deleteFile)
 	}
 }
 
-// todo add validation file
+// InitStatics раздача файлов по старым адресам /statics/images/{name}
+func (h *Controller) InitStatics(statics *gin.RouterGroup) {
+	statics.GET("/images/:name", h.downloadFile)
+	statics.HEAD("/images/:name", h.downloadFile)
+}
 
 // @Summary upload_file
-// @Description загрузить файл
+// @Description загрузить вложение, тело читается потоком и сразу пишется в хранилище.
+// @Description Ссылки в ответе подписаны и действуют ограниченное время
 // @Tags file
+// @Accept multipart/form-data
 // @Produce json
-// @Param data body request.UploadFileRequest true "data"
+// @Param file formData file true "file"
+// @Param noteId query string false "сразу прикрепить к заметке"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
 // @Success 200 {object} response.Response{data=dto.UploadFileResponse}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
 // @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query"
+// @Failure 422 {object} response.Response{} "possible codes: file_empty, file_too_large, file_extension_not_allowed, file_type_mismatch, file_unsafe, premissions_not_enough, storage_quota_exceeded"
 // @Router /wn/api/v1/file/upload [post]
 func (h *Controller) uploadFile(c *gin.Context) {
 	ctx := c.Request.Context()
-	var req request.UploadFileRequest
-	err := c.ShouldBind(&req)
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+	noteId := uuid.Nil
+	if raw := c.Query("noteId"); raw != "" {
+		if noteId, err = uuid.Parse(raw); err != nil {
+			_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+			return
+		}
+	}
+	part, err := formFilePart(c.Request, "file")
 	if err != nil {
 		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
 		return
 	}
+	defer part.Close()
+
+	picUrl, err := h.fileService.UploadFile(ctx, userId, noteId, part.FileName(), part, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, picUrl))
+}
+
+// @Summary download_file
+// @Description скачать файл, поддерживает Range и If-None-Match.
+// @Description Аватарки публичные, вложения открываются по подписанной ссылке или с токеном пользователя с доступом к заметке
+// @Tags file
+// @Produce octet-stream
+// @Param name path string true "file name"
+// @Param exp query string false "signed url expiry"
+// @Param sig query string false "signed url signature"
+// @Param Authorization header string false "auth token"
+// @Param Range header string false "byte range"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 410 {object} response.Response{} "possible codes: file_not_found"
+// @Router /statics/images/{name} [get]
+func (h *Controller) downloadFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	name := c.Param("name")
+	// без токена пользователь пустой, тогда доступ только к публичным файлам и по подписи
+	userId, _ := util.GetUserId(ctx)
+
+	item, content, err := h.fileService.OpenFile(ctx, name, userId, c.Request.URL.Query())
+	if errors.Is(err, apperrors.FileNotFound) {
+		bundled := filepath.Join(bundledDir, filepath.Base(name))
+		if info, statErr := os.Stat(bundled); statErr == nil && !info.IsDir() {
+			c.File(bundled)
+			return
+		}
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	defer content.Close()
+
+	c.Header("Content-Type", item.ContentType)
+	c.Header(constants.ETagHeader, `"`+item.Hash+`"`)
+	// имена файлов уникальны, содержимое меняется только пока изображение обрабатывается.
+	// Закрытые файлы не должны оседать в общих кэшах
+	switch {
+	case !item.Settled():
+		c.Header("Cache-Control", "no-cache")
+	case item.Public():
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	default:
+		c.Header("Cache-Control", "private, max-age=600")
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	// svg открытый по прямой ссылке не должен ничего исполнять и грузить
+	c.Header("Content-Security-Policy", "default-src 'none'; img-src data:; style-src 'unsafe-inline'; sandbox")
+	http.ServeContent(c.Writer, c.Request, item.Name, item.CreatedAt, content)
+}
+
+// @Summary get_my_files
+// @Description файлы пользователя, новые первыми, и где они используются: аватарка, прикрепление к заметке,
+// @Description ссылки из текстов заметок. Из заметок без доступа видно только количество (otherNotes)
+// @Tags file
+// @Produce json
+// @Param page query int true "page"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.OwnFile}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Router /wn/api/v1/file/my [get]
+func (h *Controller) getMyFiles(c *gin.Context) {
+	ctx := c.Request.Context()
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		_ = c.Error(apperror.NewBadRequestError("page must be positive", constants.BindQueryError))
+		return
+	}
 	userId, err := util.GetUserId(ctx)
 	if err != nil {
 		_ = c.Error(apperrors.InvalidAuthorizationHeader)
 		return
 	}
 
-	picUrl, err := h.fileService.UploadFile(ctx, userId, req, c.Request.Host)
+	files, count, err := h.fileService.GetMyFiles(ctx, userId, page, c.Request.Host)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, picUrl))
+	c.AbortWithStatusJSON(
+		200,
+		h.builder.BuildSuccessPaginationResponse(
+			ctx,
+			page,
+			constants.PageSize,
+			(count+constants.PageSize-1)/constants.PageSize,
+			files,
+		))
+}
+
+// @Summary delete_file
+// @Description удалить свой файл вместе с миниатюрами. Аватарку удалить нельзя, файл, на который ссылаются заметки,
+// @Description удаляется только с force, ссылки без файла возвращаются в brokenReferences
+// @Tags file
+// @Produce json
+// @Param data body request.DeleteFileRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.DeleteFileResponse}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 409 {object} response.Response{} "possible codes: file_in_use"
+// @Failure 410 {object} response.Response{} "possible codes: file_not_found"
+// @Router /wn/api/v1/file/delete [post]
+func (h *Controller) deleteFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.DeleteFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	deleted, err := h.fileService.DeleteFile(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, deleted))
+}
+
+// @Summary get_attachments
+// @Description вложения заметки с подписанными ссылками
+// @Tags file
+// @Produce json
+// @Param noteId query string true "note id"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.Attachment}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: premissions_not_enough"
+// @Router /wn/api/v1/file/attachments [get]
+func (h *Controller) getAttachments(c *gin.Context) {
+	ctx := c.Request.Context()
+	noteId, err := uuid.Parse(c.Query("noteId"))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	attachments, err := h.fileService.GetAttachments(ctx, userId, noteId, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, attachments))
+}
+
+// @Summary attach_file
+// @Description прикрепить свое вложение к заметке, нужны права на запись
+// @Tags file
+// @Produce json
+// @Param data body request.AttachmentRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 422 {object} response.Response{} "possible codes: premissions_not_enough, file_not_attachable, file_already_attached"
+// @Router /wn/api/v1/file/attachments/attach [post]
+func (h *Controller) attachFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.AttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.fileService.Attach(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary detach_file
+// @Description открепить файл от заметки, нужны права на запись
+// @Tags file
+// @Produce json
+// @Param data body request.AttachmentRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 410 {object} response.Response{} "possible codes: file_not_found"
+// @Failure 422 {object} response.Response{} "possible codes: premissions_not_enough"
+// @Router /wn/api/v1/file/attachments/detach [post]
+func (h *Controller) detachFile(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.AttachmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.fileService.Detach(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary garbage_report
+// @Description отчет сборщика мусора без удаления: неиспользуемые файлы и содержимое, когда они будут удалены. Только для администраторов
+// @Tags file
+// @Produce json
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.GarbageReport}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: invalid_X-Request-Id"
+// @Failure 403 {object} response.Response{} "possible codes: admin_only"
+// @Router /wn/api/v1/file/gc/report [get]
+func (h *Controller) garbageReport(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	report, err := h.fileService.GarbageReport(ctx, userId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, report))
+}
+
+// formFilePart находит в multipart теле поле с файлом, не буферизуя запрос целиком
+func formFilePart(r *http.Request, field string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, errors.Errorf("no %q field in form", field)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == field && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
 }
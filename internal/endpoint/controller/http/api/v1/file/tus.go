@@ -0,0 +1,277 @@
+package file
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/constants"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	tusVersion     = "1.0.0"
+	tusExtensions  = "creation,expiration,termination"
+	tusContentType = "application/offset+octet-stream"
+)
+
+// tusResumable каждый ответ tus содержит Tus-Resumable, запросы другой версии протокола отклоняются
+func tusResumable(c *gin.Context) {
+	c.Header(constants.TusResumableHeader, tusVersion)
+	if c.GetHeader(constants.TusResumableHeader) != tusVersion {
+		c.Header(constants.TusVersionHeader, tusVersion)
+		c.AbortWithStatus(http.StatusPreconditionFailed)
+	}
+}
+
+// @Summary tus_options
+// @Description возможности сервера загрузок по протоколу tus 1.0
+// @Tags file
+// @Param X-Request-Id header string true "Request id identity"
+// @Success 204
+// @Router /wn/api/v1/file/tus [options]
+func (h *Controller) tusOptions(c *gin.Context) {
+	c.Header(constants.TusResumableHeader, tusVersion)
+	c.Header(constants.TusVersionHeader, tusVersion)
+	c.Header(constants.TusExtensionHeader, tusExtensions)
+	c.Header(constants.TusMaxSizeHeader, strconv.FormatInt(h.fileService.MaxUploadSize(), 10))
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary tus_create
+// @Description создать загрузку вложения по частям (tus creation). В Upload-Metadata передаются filename и необязательный noteId
+// @Tags file
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Length header int true "размер файла"
+// @Param Upload-Metadata header string true "filename <base64>,noteId <base64>"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 201 "Location - адрес загрузки"
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header, bad_upload_request, invalid_X-Request-Id"
+// @Failure 413 "больше Tus-Max-Size"
+// @Failure 422 {object} response.Response{} "possible codes: file_empty, file_extension_not_allowed, storage_quota_exceeded, premissions_not_enough"
+// @Router /wn/api/v1/file/tus [post]
+func (h *Controller) tusCreate(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+	length, err := strconv.ParseInt(c.GetHeader(constants.UploadLengthHeader), 10, 64)
+	if err != nil || length < 0 {
+		_ = c.Error(errors.Wrap(apperrors.BadUploadRequest, "Upload-Length"))
+		return
+	}
+	metadata, err := parseUploadMetadata(c.GetHeader(constants.UploadMetadataHeader))
+	if err != nil {
+		_ = c.Error(errors.Wrap(apperrors.BadUploadRequest, err.Error()))
+		return
+	}
+	req := request.CreateUploadRequest{FileName: metadata["filename"], Length: length}
+	if req.FileName == "" {
+		req.FileName = metadata["name"]
+	}
+	if raw := metadata["noteId"]; raw != "" {
+		if req.NoteId, err = uuid.Parse(raw); err != nil {
+			_ = c.Error(errors.Wrap(apperrors.BadUploadRequest, "noteId"))
+			return
+		}
+	}
+
+	status, err := h.fileService.CreateUpload(ctx, userId, req)
+	if errors.Is(err, apperrors.FileTooLarge) {
+		c.AbortWithStatus(http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header(constants.LocationHeader, strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+status.Id.String())
+	setUploadHeaders(c, status)
+	c.Status(http.StatusCreated)
+}
+
+// @Summary tus_head
+// @Description сколько байт загрузки уже получено
+// @Tags file
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 "Upload-Offset, Upload-Length, Upload-File-Url у завершенной загрузки"
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 410 {object} response.Response{} "possible codes: upload_not_found"
+// @Router /wn/api/v1/file/tus/{id} [head]
+func (h *Controller) tusHead(c *gin.Context) {
+	status, ok := h.tusGet(c)
+	if !ok {
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	setUploadHeaders(c, status)
+	c.Status(http.StatusOK)
+}
+
+// @Summary tus_status
+// @Description состояние загрузки, у завершенной - ссылки на файл
+// @Tags file
+// @Produce json
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.UploadStatus}
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 410 {object} response.Response{} "possible codes: upload_not_found"
+// @Router /wn/api/v1/file/tus/{id} [get]
+func (h *Controller) tusStatus(c *gin.Context) {
+	status, ok := h.tusGet(c)
+	if !ok {
+		return
+	}
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(c.Request.Context(), status))
+}
+
+func (h *Controller) tusGet(c *gin.Context) (*dto.UploadStatus, bool) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(apperrors.UploadNotFound)
+		return nil, false
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return nil, false
+	}
+	status, err := h.fileService.GetUpload(ctx, userId, id, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return nil, false
+	}
+	return status, true
+}
+
+// @Summary tus_patch
+// @Description дописать кусок с позиции Upload-Offset. Когда получены все байты, файл проходит обычную проверку
+// @Description и сохраняется, адрес файла возвращается в Upload-File-Url
+// @Tags file
+// @Accept application/offset+octet-stream
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param Upload-Offset header int true "позиция куска"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 204 "Upload-Offset - новая позиция"
+// @Failure 400 {object} response.Response{} "possible codes: bad_upload_request, upload_too_long"
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 409 {object} response.Response{} "possible codes: upload_offset_mismatch, upload_completed"
+// @Failure 410 {object} response.Response{} "possible codes: upload_not_found"
+// @Failure 415 "Content-Type не application/offset+octet-stream"
+// @Failure 422 {object} response.Response{} "possible codes: file_type_mismatch, file_unsafe, storage_quota_exceeded, premissions_not_enough"
+// @Router /wn/api/v1/file/tus/{id} [patch]
+func (h *Controller) tusPatch(c *gin.Context) {
+	ctx := c.Request.Context()
+	if c.ContentType() != tusContentType {
+		c.AbortWithStatus(http.StatusUnsupportedMediaType)
+		return
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(apperrors.UploadNotFound)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader(constants.UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		_ = c.Error(errors.Wrap(apperrors.BadUploadRequest, "Upload-Offset"))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	status, err := h.fileService.WriteUpload(ctx, userId, id, offset, c.Request.Body, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	setUploadHeaders(c, status)
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary tus_delete
+// @Description отменить загрузку (tus termination), уже созданный файл остается
+// @Tags file
+// @Param id path string true "upload id"
+// @Param Tus-Resumable header string true "1.0.0"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 204
+// @Failure 403 {object} response.Response{} "possible codes: file_access_denied"
+// @Failure 410 {object} response.Response{} "possible codes: upload_not_found"
+// @Router /wn/api/v1/file/tus/{id} [delete]
+func (h *Controller) tusDelete(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(apperrors.UploadNotFound)
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.fileService.DeleteUpload(ctx, userId, id); err != nil {
+		_ = c.Error(err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func setUploadHeaders(c *gin.Context, status *dto.UploadStatus) {
+	c.Header(constants.UploadOffsetHeader, strconv.FormatInt(status.Offset, 10))
+	c.Header(constants.UploadLengthHeader, strconv.FormatInt(status.Length, 10))
+	if status.File != nil {
+		c.Header(constants.UploadFileUrlHeader, status.File.ImgUrl)
+		return
+	}
+	c.Header(constants.UploadExpiresHeader, status.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseUploadMetadata разбирает Upload-Metadata: пары "ключ base64(значение)" через запятую, значение может отсутствовать
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.Errorf("metadata %s is not base64", fields[0])
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, errors.Errorf("bad metadata pair %q", pair)
+		}
+	}
+	return metadata, nil
+}
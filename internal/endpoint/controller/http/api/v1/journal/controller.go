@@ -0,0 +1,178 @@
+package journal
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type journalService interface {
+	GetJournal(ctx context.Context, userId uuid.UUID) (*dto.Journal, error)
+	SetJournal(ctx context.Context, userId uuid.UUID, req request.JournalSettingsRequest) error
+	GetDay(ctx context.Context, userId uuid.UUID, req request.JournalDayRequest) (*dto.JournalDay, error)
+	GetCalendar(ctx context.Context, userId uuid.UUID, month, timezone string) (*dto.JournalCalendar, error)
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	journalService journalService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, journalService journalService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		journalService: journalService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	journalAuth := authApi.Group("/journal")
+	{
+		journalAuth.GET("", h.getJournal)
+		journalAuth.POST("/settings", h.setJournal)
+		journalAuth.POST("/day", h.getDay)
+		journalAuth.GET("/calendar", h.getCalendar)
+	}
+}
+
+// @Summary get_journal
+// @Description Настройки журнала пользователя
+// @Tags journal
+// @Produce json
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.Journal}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 422 {object} response.Response{} "possible codes: journal_not_configured"
+// @Router /wn/api/v1/journal [get]
+func (h *Controller) getJournal(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	journal, err := h.journalService.GetJournal(ctx, userId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, journal))
+}
+
+// @Summary set_journal
+// @Description Назначить лейаут журналом, задать формат заголовка и шаблон записей
+// @Tags journal
+// @Produce json
+// @Param data body request.JournalSettingsRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_journal_title, bad_timezone"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough, template_not_found"
+// @Router /wn/api/v1/journal/settings [post]
+func (h *Controller) setJournal(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.JournalSettingsRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	err = h.journalService.SetJournal(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary journal_day
+// @Description Заметка журнала за день, создается при первом запросе
+// @Tags journal
+// @Produce json
+// @Param data body request.JournalDayRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.JournalDay}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_date, bad_timezone"
+// @Failure 422 {object} response.Response{data=dto.TemplateValuesMissing} "possible codes: journal_not_configured, permissions_not_enough, template_values_missing"
+// @Router /wn/api/v1/journal/day [post]
+func (h *Controller) getDay(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.JournalDayRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	day, err := h.journalService.GetDay(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, day))
+}
+
+// @Summary journal_calendar
+// @Description Дни месяца, за которые в журнале есть записи
+// @Tags journal
+// @Produce json
+// @Param month query string false "YYYY-MM, по умолчанию текущий"
+// @Param timezone query string false "часовой пояс IANA для текущего месяца"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.JournalCalendar}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bad_month, bad_timezone, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: journal_not_configured, permissions_not_enough"
+// @Router /wn/api/v1/journal/calendar [get]
+func (h *Controller) getCalendar(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	calendar, err := h.journalService.GetCalendar(ctx, userId, c.Query("month"), c.Query("timezone"))
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, calendar))
+}
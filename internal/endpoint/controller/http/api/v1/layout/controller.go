@@ -20,9 +20,10 @@ type layoutService interface {
 	CreateLayout(ctx context.Context, req request.NewLayoutRequest, userId uuid.UUID) (uuid.UUID, error)
 	DeleteLayout(ctx context.Context, req request.LayoutIdRequest, userId uuid.UUID) error
 	GetLayoutsByUserId(ctx context.Context, userId uuid.UUID) ([]dto.Layout, error)
-	UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) error
+	UpdateLayout(ctx context.Context, req request.UpdateLayout, userId uuid.UUID) (int64, error)
 	ExportInfo(ctx context.Context, req dto.ExportInfoRequest) (*dto.ExportInfo, error)
 	ImportLayouts(ctx context.Context, userId uuid.UUID, req *dto.ImportInfoRequest) error
+	DuplicateLayout(ctx context.Context, req request.DuplicateLayoutRequest, userId uuid.UUID) (uuid.UUID, error)
 }
 
 type Controller struct {
@@ -51,6 +52,7 @@ func (h *Controller) Init(api, authApi *gin.RouterGroup) {
 		notesAuth.POST("/update", h.updateLayout)
 		notesAuth.GET("/export", h.exportLayout)
 		notesAuth.POST("/import", h.importLayout)
+		notesAuth.POST("/duplicate", h.duplicateLayout)
 	}
 }
 
@@ -154,6 +156,44 @@ func (h *Controller) importLayout(c *gin.Context) {
 	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
 }
 
+// @Summary duplicate_layout
+// @Description Скопировать лейаут со всеми заметками, позициями и связями
+// @Tags layouts
+// @Produce json
+// @Param data body request.DuplicateLayoutRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=resp.NoteId}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough, record_not_found"
+// @Router /wn/api/v1/layout/duplicate [post]
+func (h *Controller) duplicateLayout(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.DuplicateLayoutRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	layoutId, err := h.layoutService.DuplicateLayout(ctx, req, userId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp.NoteId{
+		Id: layoutId,
+	}))
+}
+
 // @Summary get_my_layouts
 // @Description Получить все layout-ы, к которым имеет доступ пользователь
 // @Tags layouts
@@ -235,9 +275,11 @@ func (h *Controller) deleteLayout(c *gin.Context) {
 // @Param data body request.UpdateLayout true "data"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
-// @Success 200 {object} response.Response{}
+// @Param If-Match header string false "expected version"
+// @Success 200 {object} response.Response{data=dto.VersionResponse}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
 // @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, permissions_not_enough"
+// @Failure 409 {object} response.Response{data=dto.VersionConflict} "possible codes: version_conflict"
 // @Router /wn/api/v1/layout/update [post]
 func (h *Controller) updateLayout(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -249,17 +291,27 @@ func (h *Controller) updateLayout(c *gin.Context) {
 		return
 	}
 
+	version, err := util.ParseIfMatch(c.GetHeader(constants.IfMatchHeader))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError("bad If-Match", "invalid_"+constants.IfMatchHeader))
+		return
+	}
+	if version != nil {
+		req.Version = version
+	}
+
 	userId, err := util.GetUserId(ctx)
 	if err != nil {
 		_ = c.Error(apperrors.InvalidAuthorizationHeader)
 		return
 	}
 
-	err = h.layoutService.UpdateLayout(ctx, req, userId)
+	newVersion, err := h.layoutService.UpdateLayout(ctx, req, userId)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+	c.Header(constants.ETagHeader, util.ETag(newVersion))
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, dto.VersionResponse{Version: newVersion}))
 }
@@ -3,6 +3,8 @@ package note
 import (
 	"context"
 	"strconv"
+	"strings"
+	"time"
 	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
 	req "wn/internal/domain/dto/request"
@@ -16,21 +18,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 type srv interface {
-	CreateNote(ctx context.Context, req req.NoteRequest, userId uuid.UUID, mainLayoutId uuid.UUID) (uuid.UUID, error)
-	UpdateNote(ctx context.Context, req req.NoteWithIdRequest, userId uuid.UUID) error
+	CreateNote(ctx context.Context, req req.NoteRequest, userId uuid.UUID, mainLayoutId uuid.UUID) (uuid.UUID, []string, error)
+	UpdateNote(ctx context.Context, req req.NoteWithIdRequest, userId uuid.UUID) (int64, []string, error)
 	DeleteNote(ctx context.Context, req req.NoteId, userId uuid.UUID, mainLayoutId uuid.UUID) error
 	GetNotesFromLayout(ctx context.Context, req req.GetNotesFromLayoutRequest, userId uuid.UUID) ([]dto.Note, int, error)
 	GetNotesWithPosition(ctx context.Context, userId, mainLayoutId uuid.UUID, req req.GetNotesFromLayoutWithoutPagRequest) ([]dto.Note, error)
 	GetNotesWithoutPosition(ctx context.Context, userId uuid.UUID, req req.GetNotesFromLayoutWithoutPagRequest) ([]dto.Note, error)
 	UpdateNotePosition(ctx context.Context, userId uuid.UUID, req req.UpdateNotePositionRequest) error
-	SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]dto.Note, error)
+	SearchNotes(ctx context.Context, userId uuid.UUID, req req.SearchNotesRequest) (*dto.SearchNotesResponse, error)
+	GetBacklinks(ctx context.Context, userId, noteId uuid.UUID) ([]dto.Backlink, error)
 
 	CreateLink(ctx context.Context, userId uuid.UUID, req req.LinkBetweenNotesRequest) error
 	DeleteLink(ctx context.Context, userId uuid.UUID, req req.LinkBetweenNotesRequest) error
-	DragNote(ctx context.Context, userId uuid.UUID, req req.DragNoteRequest) error
+	DragNote(ctx context.Context, userId uuid.UUID, req req.DragNoteRequest) (int64, error)
+	DuplicateNotes(ctx context.Context, userId uuid.UUID, req req.DuplicateNotesRequest) (map[uuid.UUID]uuid.UUID, error)
 }
 
 type Controller struct {
@@ -58,6 +63,8 @@ func (h *Controller) Init(api, authApi *gin.RouterGroup) {
 		notesAuth.POST("/delete", h.deleteNote)
 		notesAuth.GET("/search", h.searchNotes)
 		notesAuth.POST("/drag", h.dragNote)
+		notesAuth.POST("/duplicate", h.duplicateNotes)
+		notesAuth.GET("/:id/backlinks", h.getBacklinks)
 		layout := notesAuth.Group("/layout")
 		{
 			layout.GET("", h.getNotesFromLayout)
@@ -107,14 +114,15 @@ func (h *Controller) createNote(c *gin.Context) {
 		return
 	}
 
-	noteId, err := h.noteService.CreateNote(ctx, req, userId, mainLayoutId)
+	noteId, unresolved, err := h.noteService.CreateNote(ctx, req, userId, mainLayoutId)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
 	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp.NoteId{
-		Id: noteId,
+		Id:              noteId,
+		UnresolvedLinks: unresolved,
 	}))
 }
 
@@ -167,10 +175,12 @@ func (h *Controller) deleteNote(c *gin.Context) {
 // @Param data body request.NoteWithIdRequest true "data"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
-// @Success 200 {object} response.Response{}
+// @Param If-Match header string false "expected version"
+// @Success 200 {object} response.Response{data=dto.VersionResponse}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
 // @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
 // @Failure 422 {object} response.Response{} "possible codes: note_not_found, permissions_not_enough"
+// @Failure 409 {object} response.Response{data=dto.VersionConflict} "possible codes: version_conflict"
 // @Router /wn/api/v1/notes/update [post]
 func (h *Controller) updateNote(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -181,19 +191,32 @@ func (h *Controller) updateNote(c *gin.Context) {
 		return
 	}
 
+	version, err := util.ParseIfMatch(c.GetHeader(constants.IfMatchHeader))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError("bad If-Match", "invalid_"+constants.IfMatchHeader))
+		return
+	}
+	if version != nil {
+		req.Version = version
+	}
+
 	userId, err := util.GetUserId(ctx)
 	if err != nil {
 		_ = c.Error(apperrors.InvalidAuthorizationHeader)
 		return
 	}
 
-	err = h.noteService.UpdateNote(ctx, req, userId)
+	newVersion, unresolved, err := h.noteService.UpdateNote(ctx, req, userId)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+	c.Header(constants.ETagHeader, util.ETag(newVersion))
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, dto.VersionResponse{
+		Version:         newVersion,
+		UnresolvedLinks: unresolved,
+	}))
 }
 
 // @Summary get_notes_from_layout
@@ -202,11 +225,13 @@ func (h *Controller) updateNote(c *gin.Context) {
 // @Produce json
 // @Param page query int true "page"
 // @Param layoutId query string true "layoutId"
+// @Param tags query string false "имена меток через запятую"
+// @Param tagMode query string false "and (по умолчанию) или or"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
 // @Success 200 {object} response.Response{data=[]dto.Note}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
-// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id, bad_tag_mode"
 // @Failure 422 {object} response.Response{} "possible codes: note_not_found, permissions_not_enough"
 // @Router /wn/api/v1/notes/layout [get]
 func (h *Controller) getNotesFromLayout(c *gin.Context) {
@@ -224,8 +249,9 @@ func (h *Controller) getNotesFromLayout(c *gin.Context) {
 	}
 
 	req := request.GetNotesFromLayoutRequest{
-		Page:     page,
-		LayoutId: layoutId,
+		Page:       page,
+		LayoutId:   layoutId,
+		TagsFilter: tagsFilter(c),
 	}
 
 	userId, err := util.GetUserId(ctx)
@@ -439,13 +465,26 @@ func (h *Controller) deleteLinkBetweenNotes(c *gin.Context) {
 // @Description Найти заметку
 // @Tags notes
 // @Produce json
-// @Param search query string true "search"
+// @Param search query string false "search"
+// @Param layoutIds query string false "id лейаутов через запятую"
+// @Param ownerId query string false "id владельца"
+// @Param createdFrom query string false "создана не раньше, RFC3339"
+// @Param createdTo query string false "создана раньше, RFC3339"
+// @Param updatedFrom query string false "изменена не раньше, RFC3339"
+// @Param updatedTo query string false "изменена раньше, RFC3339"
+// @Param hasDraft query bool false "есть несохраненный черновик"
+// @Param linkedTo query string false "связана с заметкой"
+// @Param sort query string false "relevance (по умолчанию), created или updated"
+// @Param cursor query string false "nextCursor из прошлой страницы"
+// @Param limit query int false "размер страницы, по умолчанию 50, не больше 200"
+// @Param tags query string false "имена меток через запятую"
+// @Param tagMode query string false "and (по умолчанию) или or"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
-// @Success 200 {object} response.Response{data=[]dto.Note}
+// @Success 200 {object} response.Response{data=dto.SearchNotesResponse}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
-// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
-// @Router /wn/api/v1/notes/search [post]
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id, bad_tag_mode, bad_sort, bad_cursor"
+// @Router /wn/api/v1/notes/search [get]
 func (h *Controller) searchNotes(c *gin.Context) {
 	ctx := c.Request.Context()
 
@@ -455,9 +494,13 @@ func (h *Controller) searchNotes(c *gin.Context) {
 		return
 	}
 
-	search := c.Query("search")
+	req, err := searchNotesRequest(c)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+		return
+	}
 
-	notes, err := h.noteService.SearchNotes(ctx, userId, search)
+	notes, err := h.noteService.SearchNotes(ctx, userId, req)
 	if err != nil {
 		_ = c.Error(err)
 		return
@@ -466,6 +509,41 @@ func (h *Controller) searchNotes(c *gin.Context) {
 	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, notes))
 }
 
+// @Summary get_backlinks
+// @Description Заметки, которые ссылаются на данную, с текстом вокруг ссылки
+// @Tags notes
+// @Produce json
+// @Param id path string true "note id"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.Backlink}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: record_not_found, permissions_not_enough"
+// @Router /wn/api/v1/notes/{id}/backlinks [get]
+func (h *Controller) getBacklinks(c *gin.Context) {
+	ctx := c.Request.Context()
+	noteId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	backlinks, err := h.noteService.GetBacklinks(ctx, userId, noteId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, backlinks))
+}
+
 // @Summary drag_note
 // @Description Переместить заметку между лейаутами
 // @Tags notes
@@ -473,10 +551,12 @@ func (h *Controller) searchNotes(c *gin.Context) {
 // @Param data body request.DragNoteRequest true "data"
 // @Param X-Request-Id header string true "Request id identity"
 // @Param Authorization header string true "auth token"
-// @Success 200 {object} response.Response{}
+// @Param If-Match header string false "expected version"
+// @Success 200 {object} response.Response{data=dto.VersionResponse}
 // @Failure 401 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
 // @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
 // @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough, record_not_found"
+// @Failure 409 {object} response.Response{data=dto.VersionConflict} "possible codes: version_conflict"
 // @Router /wn/api/v1/notes/drag [post]
 func (h *Controller) dragNote(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -488,17 +568,147 @@ func (h *Controller) dragNote(c *gin.Context) {
 		return
 	}
 
+	version, err := util.ParseIfMatch(c.GetHeader(constants.IfMatchHeader))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError("bad If-Match", "invalid_"+constants.IfMatchHeader))
+		return
+	}
+	if version != nil {
+		req.Version = version
+	}
+
 	userId, err := util.GetUserId(ctx)
 	if err != nil {
 		_ = c.Error(apperrors.InvalidAuthorizationHeader)
 		return
 	}
 
-	err = h.noteService.DragNote(ctx, userId, req)
+	newVersion, err := h.noteService.DragNote(ctx, userId, req)
 	if err != nil {
 		_ = c.Error(err)
 		return
 	}
 
-	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+	c.Header(constants.ETagHeader, util.ETag(newVersion))
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, dto.VersionResponse{Version: newVersion}))
+}
+
+// @Summary duplicate_notes
+// @Description Скопировать заметки с позициями и связями между ними, в тот же или другой лейаут
+// @Tags notes
+// @Produce json
+// @Param data body request.DuplicateNotesRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=resp.DuplicatedNotes}
+// @Failure 401 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough, note_not_found"
+// @Router /wn/api/v1/notes/duplicate [post]
+func (h *Controller) duplicateNotes(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req request.DuplicateNotesRequest
+	err := c.BindJSON(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	ids, err := h.noteService.DuplicateNotes(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp.DuplicatedNotes{Ids: ids}))
+}
+
+// tagsFilter фильтр по меткам из query: tags=a,b&tagMode=or
+func tagsFilter(c *gin.Context) request.TagsFilter {
+	var f request.TagsFilter
+	if tags := c.Query("tags"); tags != "" {
+		f.Tags = strings.Split(tags, ",")
+	}
+	f.TagMode = c.Query("tagMode")
+	return f
+}
+
+// searchNotesRequest параметры поиска из query, пустые параметры не заполняются
+func searchNotesRequest(c *gin.Context) (request.SearchNotesRequest, error) {
+	f := request.SearchNotesRequest{
+		Search:     c.Query("search"),
+		Sort:       c.Query("sort"),
+		Cursor:     c.Query("cursor"),
+		TagsFilter: tagsFilter(c),
+	}
+	var err error
+	if ids := c.Query("layoutIds"); ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			layoutId, err := uuid.Parse(strings.TrimSpace(id))
+			if err != nil {
+				return f, errors.Wrap(err, "layoutIds")
+			}
+			f.LayoutIds = append(f.LayoutIds, layoutId)
+		}
+	}
+	if f.OwnerId, err = queryUUID(c, "ownerId"); err != nil {
+		return f, err
+	}
+	if f.LinkedTo, err = queryUUID(c, "linkedTo"); err != nil {
+		return f, err
+	}
+	for name, dst := range map[string]**time.Time{
+		"createdFrom": &f.CreatedFrom,
+		"createdTo":   &f.CreatedTo,
+		"updatedFrom": &f.UpdatedFrom,
+		"updatedTo":   &f.UpdatedTo,
+	} {
+		if *dst, err = queryTime(c, name); err != nil {
+			return f, err
+		}
+	}
+	if v := c.Query("hasDraft"); v != "" {
+		hasDraft, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.Wrap(err, "hasDraft")
+		}
+		f.HasDraft = &hasDraft
+	}
+	if v := c.Query("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil {
+			return f, errors.Wrap(err, "limit")
+		}
+	}
+	return f, nil
+}
+
+func queryUUID(c *gin.Context, name string) (*uuid.UUID, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return &id, nil
+}
+
+func queryTime(c *gin.Context, name string) (*time.Time, error) {
+	v := c.Query(name)
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, errors.Wrap(err, name)
+	}
+	return &t, nil
 }
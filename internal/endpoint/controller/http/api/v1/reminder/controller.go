@@ -0,0 +1,229 @@
+package reminder
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type reminderService interface {
+	CreateReminder(ctx context.Context, userId uuid.UUID, req request.CreateReminderRequest) (*dto.Reminder, error)
+	DeleteReminder(ctx context.Context, userId uuid.UUID, req request.ReminderIdRequest) error
+	GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]dto.Reminder, error)
+	GetFeedUrl(ctx context.Context, userId uuid.UUID, req request.ReminderFeedRequest, host string) (*dto.ReminderFeed, error)
+	GetFeed(ctx context.Context, token string) ([]byte, error)
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	reminderService reminderService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, reminderService reminderService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		reminderService: reminderService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	remindersAuth := authApi.Group("/reminders")
+	{
+		remindersAuth.GET("", h.getReminders)
+		remindersAuth.POST("/create", h.createReminder)
+		remindersAuth.POST("/delete", h.deleteReminder)
+		remindersAuth.POST("/feed", h.getFeedUrl)
+	}
+}
+
+// InitStatics календарь напоминаний /statics/reminders/{token}.ics, секрет в ссылке вместо авторизации
+func (h *Controller) InitStatics(statics *gin.RouterGroup) {
+	statics.GET("/reminders/:name", h.getFeed)
+}
+
+// @Summary get_reminders
+// @Description Напоминания пользователя
+// @Tags reminders
+// @Produce json
+// @Param noteId query string false "только о заметке"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.Reminder}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Router /wn/api/v1/reminders [get]
+func (h *Controller) getReminders(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	var noteId *uuid.UUID
+	if raw := c.Query("noteId"); raw != "" {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+			return
+		}
+		noteId = &id
+	}
+
+	reminders, err := h.reminderService.GetReminders(ctx, userId, noteId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, reminders))
+}
+
+// @Summary create_reminder
+// @Description Напоминание о заметке, разовое или повторяющееся. Приходит письмом и событием REMINDER в сокет
+// @Tags reminders
+// @Produce json
+// @Param data body request.CreateReminderRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.Reminder}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_timezone, bad_recurrence"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough, reminder_in_past"
+// @Router /wn/api/v1/reminders/create [post]
+func (h *Controller) createReminder(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.CreateReminderRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	reminder, err := h.reminderService.CreateReminder(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, reminder))
+}
+
+// @Summary delete_reminder
+// @Description Удалить напоминание
+// @Tags reminders
+// @Produce json
+// @Param data body request.ReminderIdRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: reminder_not_found"
+// @Router /wn/api/v1/reminders/delete [post]
+func (h *Controller) deleteReminder(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.ReminderIdRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	err = h.reminderService.DeleteReminder(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary reminder_feed
+// @Description Ссылка на календарь напоминаний в формате iCalendar для календарных приложений
+// @Tags reminders
+// @Produce json
+// @Param data body request.ReminderFeedRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.ReminderFeed}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Router /wn/api/v1/reminders/feed [post]
+func (h *Controller) getFeedUrl(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.ReminderFeedRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	feed, err := h.reminderService.GetFeedUrl(ctx, userId, req, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, feed))
+}
+
+// @Summary reminders_ics
+// @Description Календарь напоминаний, адрес выдает /reminders/feed
+// @Tags reminders
+// @Produce text/calendar
+// @Param name path string true "{token}.ics"
+// @Success 200 {file} file
+// @Failure 404 {object} response.Response{} "possible codes: reminder_feed_not_found"
+// @Router /statics/reminders/{name} [get]
+func (h *Controller) getFeed(c *gin.Context) {
+	ctx := c.Request.Context()
+	token, ok := strings.CutSuffix(c.Param("name"), dto.ReminderFeedExt)
+	if !ok || token == "" {
+		_ = c.Error(apperrors.ReminderFeedNotFound)
+		return
+	}
+
+	content, err := h.reminderService.GetFeed(ctx, token)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", content)
+}
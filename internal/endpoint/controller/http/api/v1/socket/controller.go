@@ -6,7 +6,9 @@ import (
 	"wn/internal/domain/services/socket"
 	"wn/pkg/apperror"
 	"wn/pkg/applogger"
+	"wn/pkg/constants"
 	"wn/pkg/response"
+	"wn/pkg/util"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -86,59 +88,28 @@ func (h *Controller) createRoomConnection(c *gin.Context) {
 		return
 	}
 
-	// Создаем connection object для мультиплеер сервиса
-	wsConn := &multyplayer.Connection{
-		Send: make(chan []byte, 512),
-		Close: func() error {
-			// При закрытии соединения удаляем пользователя из комнаты
-			h.multyplayer.Disconnect(userId.String(), noteId.String())
-			return conn.Close()
-		},
-	}
+	// Создаем connection object для мультиплеер сервиса.
+	// Пишет в сокет горутина соединения, она же шлет ping
+	wsConn := h.services.NewConnection(conn, userId, socket.KindRoom)
 
 	// Подключаем пользователя к комнате
 	h.multyplayer.Connect(userId.String(), noteId.String(), wsConn)
 
-	// Запускаем горутину для отправки сообщений клиенту
-	go func() {
-		defer func() {
-			h.multyplayer.Disconnect(userId.String(), noteId.String())
-			conn.Close()
-		}()
-
-		for {
-			select {
-			case message, ok := <-wsConn.Send:
-				if !ok {
-					// Канал закрыт, закрываем соединение
-					conn.WriteMessage(websocket.CloseMessage, []byte{})
-					return
-				}
-
-				// Отправляем сообщение клиенту
-				if err := conn.WriteMessage(websocket.BinaryMessage, message); err != nil {
-					h.lgr.Errorf("failed to write message to client: %s",
-						err.Error())
-					return
-				}
-			}
-		}
-	}()
-
 	// Основной цикл чтения сообщений от клиента
 	go func() {
 		defer func() {
 			h.multyplayer.Disconnect(userId.String(), noteId.String())
-			conn.Close()
+			_ = wsConn.Close()
 		}()
 
 		for {
 			// Читаем сообщение от клиента
-			messageType, message, err := conn.ReadMessage()
+			messageType, message, err := wsConn.ReadRaw()
 			if err != nil {
 				// Клиент отключился или ошибка
-				h.lgr.Errorf("Клиент отключился или ошибка: %s",
-					err.Error())
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+					h.lgr.Errorf("Клиент отключился или ошибка: %s", err.Error())
+				}
 				break
 			}
 
@@ -175,9 +146,10 @@ func (h *Controller) createConnection(c *gin.Context) {
 		return
 	}
 	// Создаем доменное представление соединения
-	wsConn := socket.NewWSConnection(conn, userId)
-	// Передаем управление доменному сервису
-	ctx := c.Request.Context()
+	wsConn := h.services.NewConnection(conn, userId, socket.KindHub)
+	// Передаем управление доменному сервису. Контекст запроса отменится сразу после апгрейда,
+	// поэтому копируем из него только значения
+	ctx := util.CopyContextValues(c.Request.Context(), constants.RequestIdCtx)
 	go h.services.HandleConnection(ctx, wsConn)
 
 	// Не отправляем ответ через builder, т.к. соединение уже установлено
@@ -0,0 +1,293 @@
+package tag
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	resp "wn/internal/domain/dto/response"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type tagService interface {
+	GetTags(ctx context.Context, userId uuid.UUID) ([]dto.TagCount, error)
+	CreateTag(ctx context.Context, userId uuid.UUID, req request.NewTagRequest) (uuid.UUID, error)
+	UpdateTag(ctx context.Context, userId uuid.UUID, req request.UpdateTagRequest) error
+	DeleteTag(ctx context.Context, userId uuid.UUID, req request.TagIdRequest) error
+	GetLayoutTags(ctx context.Context, userId, layoutId uuid.UUID) ([]dto.TagCount, error)
+	AttachTag(ctx context.Context, userId uuid.UUID, req request.NoteTagRequest) error
+	DetachTag(ctx context.Context, userId uuid.UUID, req request.NoteTagRequest) error
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	tagService tagService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, tagService tagService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		tagService: tagService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	tagsAuth := authApi.Group("/tags")
+	{
+		tagsAuth.GET("", h.getTags)
+		tagsAuth.POST("/create", h.createTag)
+		tagsAuth.POST("/update", h.updateTag)
+		tagsAuth.POST("/delete", h.deleteTag)
+		tagsAuth.GET("/layout", h.getLayoutTags)
+		tagsAuth.POST("/attach", h.attachTag)
+		tagsAuth.POST("/detach", h.detachTag)
+	}
+}
+
+// @Summary get_tags
+// @Description Метки пользователя со счетчиками заметок
+// @Tags tags
+// @Produce json
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.TagCount}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Router /wn/api/v1/tags [get]
+func (h *Controller) getTags(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	tags, err := h.tagService.GetTags(ctx, userId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, tags))
+}
+
+// @Summary create_tag
+// @Description Создать метку
+// @Tags tags
+// @Produce json
+// @Param data body request.NewTagRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=resp.TagId}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_tag_name"
+// @Failure 422 {object} response.Response{} "possible codes: tag_already_exists"
+// @Router /wn/api/v1/tags/create [post]
+func (h *Controller) createTag(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.NewTagRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	tagId, err := h.tagService.CreateTag(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp.TagId{Id: tagId}))
+}
+
+// @Summary update_tag
+// @Description Переименовать метку или сменить цвет
+// @Tags tags
+// @Produce json
+// @Param data body request.UpdateTagRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_tag_name"
+// @Failure 422 {object} response.Response{} "possible codes: tag_not_found, tag_already_exists"
+// @Router /wn/api/v1/tags/update [post]
+func (h *Controller) updateTag(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.UpdateTagRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.tagService.UpdateTag(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary delete_tag
+// @Description Удалить метку, она снимается со всех заметок
+// @Tags tags
+// @Produce json
+// @Param data body request.TagIdRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: tag_not_found"
+// @Router /wn/api/v1/tags/delete [post]
+func (h *Controller) deleteTag(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.TagIdRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.tagService.DeleteTag(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary get_layout_tags
+// @Description Метки пользователя на заметках лейаута и сколько заметок ими отмечено
+// @Tags tags
+// @Produce json
+// @Param layoutId query string true "layoutId"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.TagCount}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough"
+// @Router /wn/api/v1/tags/layout [get]
+func (h *Controller) getLayoutTags(c *gin.Context) {
+	ctx := c.Request.Context()
+	layoutId, err := uuid.Parse(c.Query("layoutId"))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	tags, err := h.tagService.GetLayoutTags(ctx, userId, layoutId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, tags))
+}
+
+// @Summary attach_tag
+// @Description Отметить заметку меткой
+// @Tags tags
+// @Produce json
+// @Param data body request.NoteTagRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: tag_not_found, permissions_not_enough"
+// @Router /wn/api/v1/tags/attach [post]
+func (h *Controller) attachTag(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.NoteTagRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.tagService.AttachTag(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary detach_tag
+// @Description Снять метку с заметки
+// @Tags tags
+// @Produce json
+// @Param data body request.NoteTagRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: tag_not_found, permissions_not_enough"
+// @Router /wn/api/v1/tags/detach [post]
+func (h *Controller) detachTag(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.NoteTagRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.tagService.DetachTag(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
@@ -0,0 +1,177 @@
+package task
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type taskService interface {
+	GetTasks(ctx context.Context, userId uuid.UUID, req request.TasksRequest) (*dto.TasksResponse, error)
+	ToggleTask(ctx context.Context, userId uuid.UUID, req request.ToggleTaskRequest) (*dto.TaskToggled, error)
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	taskService taskService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, taskService taskService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		taskService: taskService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	tasksAuth := authApi.Group("/tasks")
+	{
+		tasksAuth.GET("", h.getTasks)
+		tasksAuth.POST("/toggle", h.toggleTask)
+	}
+}
+
+// @Summary get_tasks
+// @Description Задачи из чек-листов во всех доступных заметках
+// @Tags tasks
+// @Produce json
+// @Param layoutIds query string false "id лейаутов через запятую"
+// @Param noteId query string false "id заметки"
+// @Param status query string false "open (по умолчанию), done или all"
+// @Param assignee query string false "исполнитель, имя пользователя"
+// @Param dueFrom query string false "срок не раньше, YYYY-MM-DD"
+// @Param dueTo query string false "срок раньше, YYYY-MM-DD"
+// @Param limit query int false "размер страницы, по умолчанию 50, не больше 500"
+// @Param offset query int false "сколько задач пропустить"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.TasksResponse}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id, bad_task_status, bad_date"
+// @Router /wn/api/v1/tasks [get]
+func (h *Controller) getTasks(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	req, err := tasksRequest(c)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+		return
+	}
+
+	tasks, err := h.taskService.GetTasks(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, tasks))
+}
+
+// @Summary toggle_task
+// @Description Отметить задачу выполненной или снять отметку, меняет текст заметки
+// @Tags tasks
+// @Produce json
+// @Param data body request.ToggleTaskRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Param If-Match header string false "expected version"
+// @Success 200 {object} response.Response{data=dto.TaskToggled}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: note_not_found, permissions_not_enough, task_not_found"
+// @Failure 409 {object} response.Response{data=dto.VersionConflict} "possible codes: version_conflict"
+// @Router /wn/api/v1/tasks/toggle [post]
+func (h *Controller) toggleTask(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	var req request.ToggleTaskRequest
+	err := c.BindJSON(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	version, err := util.ParseIfMatch(c.GetHeader(constants.IfMatchHeader))
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError("bad If-Match", "invalid_"+constants.IfMatchHeader))
+		return
+	}
+	if version != nil {
+		req.Version = version
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	toggled, err := h.taskService.ToggleTask(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header(constants.ETagHeader, util.ETag(toggled.Version))
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, toggled))
+}
+
+// tasksRequest фильтр задач из query, даты проверяются в сервисе
+func tasksRequest(c *gin.Context) (request.TasksRequest, error) {
+	req := request.TasksRequest{
+		Status:   c.Query("status"),
+		Assignee: c.Query("assignee"),
+		DueFrom:  c.Query("dueFrom"),
+		DueTo:    c.Query("dueTo"),
+	}
+	if ids := c.Query("layoutIds"); ids != "" {
+		for _, id := range strings.Split(ids, ",") {
+			layoutId, err := uuid.Parse(strings.TrimSpace(id))
+			if err != nil {
+				return req, errors.Wrap(err, "layoutIds")
+			}
+			req.LayoutIds = append(req.LayoutIds, layoutId)
+		}
+	}
+	if v := c.Query("noteId"); v != "" {
+		noteId, err := uuid.Parse(v)
+		if err != nil {
+			return req, errors.Wrap(err, "noteId")
+		}
+		req.NoteId = &noteId
+	}
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return req, errors.Wrap(err, "limit")
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if req.Offset, err = strconv.Atoi(v); err != nil {
+			return req, errors.Wrap(err, "offset")
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,195 @@
+package template
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/domain/dto/request"
+	resp "wn/internal/domain/dto/response"
+	apperrors "wn/internal/errors"
+	"wn/pkg/apperror"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/response"
+	"wn/pkg/util"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type templateService interface {
+	GetTemplates(ctx context.Context, userId uuid.UUID, layoutId *uuid.UUID) ([]dto.Template, error)
+	CreateTemplate(ctx context.Context, userId uuid.UUID, req request.NewTemplateRequest) (uuid.UUID, error)
+	UpdateTemplate(ctx context.Context, userId uuid.UUID, req request.UpdateTemplateRequest) error
+	DeleteTemplate(ctx context.Context, userId uuid.UUID, req request.TemplateIdRequest) error
+}
+
+type Controller struct {
+	lgr     applogger.Logger
+	builder *response.Builder
+
+	templateService templateService
+}
+
+func NewController(logger applogger.Logger, builder *response.Builder, templateService templateService) *Controller {
+	return &Controller{
+		lgr:     logger,
+		builder: builder,
+
+		templateService: templateService,
+	}
+}
+
+func (h *Controller) Init(api, authApi *gin.RouterGroup) {
+	templatesAuth := authApi.Group("/templates")
+	{
+		templatesAuth.GET("", h.getTemplates)
+		templatesAuth.POST("/create", h.createTemplate)
+		templatesAuth.POST("/update", h.updateTemplate)
+		templatesAuth.POST("/delete", h.deleteTemplate)
+	}
+}
+
+// @Summary get_templates
+// @Description Библиотека шаблонов: личные и шаблоны лейаутов
+// @Tags templates
+// @Produce json
+// @Param layoutId query string false "только шаблоны этого лейаута и личные"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=[]dto.Template}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_query, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough"
+// @Router /wn/api/v1/templates [get]
+func (h *Controller) getTemplates(c *gin.Context) {
+	ctx := c.Request.Context()
+	var layoutId *uuid.UUID
+	if v := c.Query("layoutId"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindQueryError))
+			return
+		}
+		layoutId = &id
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	templates, err := h.templateService.GetTemplates(ctx, userId, layoutId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, templates))
+}
+
+// @Summary create_template
+// @Description Создать шаблон, общий шаблон лейаута может создать тот, кто может в него писать
+// @Tags templates
+// @Produce json
+// @Param data body request.NewTemplateRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=resp.TemplateId}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_template_name"
+// @Failure 422 {object} response.Response{} "possible codes: permissions_not_enough"
+// @Router /wn/api/v1/templates/create [post]
+func (h *Controller) createTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.NewTemplateRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	templateId, err := h.templateService.CreateTemplate(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, resp.TemplateId{Id: templateId}))
+}
+
+// @Summary update_template
+// @Description Изменить шаблон
+// @Tags templates
+// @Produce json
+// @Param data body request.UpdateTemplateRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_template_name"
+// @Failure 422 {object} response.Response{} "possible codes: template_not_found, permissions_not_enough"
+// @Router /wn/api/v1/templates/update [post]
+func (h *Controller) updateTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.UpdateTemplateRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.templateService.UpdateTemplate(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
+
+// @Summary delete_template
+// @Description Удалить шаблон
+// @Tags templates
+// @Produce json
+// @Param data body request.TemplateIdRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
+// @Failure 422 {object} response.Response{} "possible codes: template_not_found, permissions_not_enough"
+// @Router /wn/api/v1/templates/delete [post]
+func (h *Controller) deleteTemplate(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.TemplateIdRequest
+	err := c.ShouldBind(&req)
+	if err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	if err := h.templateService.DeleteTemplate(ctx, userId, req); err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, nil))
+}
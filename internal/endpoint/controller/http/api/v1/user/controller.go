@@ -1,7 +1,13 @@
 package user
 
 import (
+	"bytes"
 	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"wn/internal/domain/dto"
 	"wn/internal/domain/dto/request"
 	resp "wn/internal/domain/dto/response"
 	"wn/internal/domain/dto/user"
@@ -19,6 +25,10 @@ import (
 type userService interface {
 	ChangeProfilePicture(ctx context.Context, req request.ChangeProfilePicture, host string) (*resp.ChangePictureResponse, error)
 	GetUserById(ctx context.Context, userId uuid.UUID, host string) (*user.User, error)
+	RemoveProfilePicture(ctx context.Context, userId uuid.UUID, host string) (*resp.ChangePictureResponse, error)
+	GeneratedAvatar(userId uuid.UUID, size int) ([]byte, error)
+	GetStorageUsage(ctx context.Context, userId uuid.UUID) (*dto.StorageUsage, error)
+	SetStorageQuota(ctx context.Context, adminId uuid.UUID, req request.SetStorageQuotaRequest) (*dto.StorageUsage, error)
 }
 
 type Controller struct {
@@ -42,6 +52,9 @@ func (h *Controller) Init(api, authApi *gin.RouterGroup) {
 	userAuth := authApi.Group("/user")
 	{
 		userAuth.POST("/picture", h.changeProfilePicture)
+		userAuth.POST("/picture/remove", h.removeProfilePicture)
+		userAuth.GET("/storage", h.getStorageUsage)
+		userAuth.POST("/storage/quota", h.setStorageQuota)
 		user.GET("/profile/:id", h.getUserById)
 	}
 }
@@ -56,7 +69,7 @@ func (h *Controller) Init(api, authApi *gin.RouterGroup) {
 // @Success 200 {object} response.Response{data=resp.ChangePictureResponse}
 // @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
 // @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id"
-// @Failure 422 {object} response.Response{} "possible codes: user_not_found"
+// @Failure 422 {object} response.Response{} "possible codes: user_not_found, file_empty, file_too_large, file_extension_not_allowed, file_type_mismatch, file_unsafe, storage_quota_exceeded"
 // @Router /wn/api/v1/user/picture [post]
 func (h *Controller) changeProfilePicture(c *gin.Context) {
 	ctx := c.Request.Context()
@@ -81,8 +94,82 @@ func (h *Controller) changeProfilePicture(c *gin.Context) {
 	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, picUrl))
 }
 
+// InitStatics сгенерированные аватарки /statics/avatars/{id}.png и /statics/avatars/{id}_{size}.png
+func (h *Controller) InitStatics(statics *gin.RouterGroup) {
+	statics.GET("/avatars/:name", h.generatedAvatar)
+	statics.HEAD("/avatars/:name", h.generatedAvatar)
+}
+
+// @Summary remove_profile_picture
+// @Description убрать свою аватарку, вместо нее показывается сгенерированная
+// @Tags user
+// @Produce json
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=resp.ChangePictureResponse}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: invalid_X-Request-Id"
+// @Router /wn/api/v1/user/picture/remove [post]
+func (h *Controller) removeProfilePicture(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	picUrl, err := h.userService.RemoveProfilePicture(ctx, userId, c.Request.Host)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, picUrl))
+}
+
+// @Summary generated_avatar
+// @Description аватарка по умолчанию, рисуется по id пользователя и никогда не меняется.
+// @Description Размеры - как у миниатюр (storage.thumbnailSizes), без размера самая большая
+// @Tags user
+// @Produce png
+// @Param name path string true "{id}.png или {id}_{size}.png"
+// @Success 200 {file} file
+// @Failure 410 {object} response.Response{} "possible codes: file_not_found"
+// @Router /statics/avatars/{name} [get]
+func (h *Controller) generatedAvatar(c *gin.Context) {
+	name, ok := strings.CutSuffix(c.Param("name"), ".png")
+	if !ok {
+		_ = c.Error(apperrors.FileNotFound)
+		return
+	}
+	rawId, rawSize, sized := strings.Cut(name, "_")
+	userId, err := uuid.Parse(rawId)
+	if err != nil {
+		_ = c.Error(apperrors.FileNotFound)
+		return
+	}
+	size := 0
+	if sized {
+		if size, err = strconv.Atoi(rawSize); err != nil {
+			_ = c.Error(apperrors.FileNotFound)
+			return
+		}
+	}
+
+	content, err := h.userService.GeneratedAvatar(userId, size)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.Header(constants.ETagHeader, `"`+name+`"`)
+	c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	c.Header("X-Content-Type-Options", "nosniff")
+	http.ServeContent(c.Writer, c.Request, name+".png", time.Time{}, bytes.NewReader(content))
+}
+
 // @Summary get_user_by_id
-// @Description получить юзера по айди
+// @Description получить юзера по айди. imgUrl и avatars - адреса аватарки, у пользователей без своей аватарки сгенерированной
 // @Tags user
 // @Produce json
 // @Param id path string true "User ID"
@@ -107,3 +194,65 @@ func (h *Controller) getUserById(c *gin.Context) {
 
 	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, user))
 }
+
+// @Summary get_storage_usage
+// @Description занятое место и квота текущего пользователя по аватаркам, вложениям и экспортам
+// @Tags user
+// @Produce json
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.StorageUsage}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: invalid_X-Request-Id"
+// @Router /wn/api/v1/user/storage [get]
+func (h *Controller) getStorageUsage(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	usage, err := h.userService.GetStorageUsage(ctx, userId)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, usage))
+}
+
+// @Summary set_storage_quota
+// @Description задать пользователю индивидуальную квоту, null возвращает квоту по умолчанию. Только для администраторов
+// @Tags user
+// @Produce json
+// @Param data body request.SetStorageQuotaRequest true "data"
+// @Param X-Request-Id header string true "Request id identity"
+// @Param Authorization header string true "auth token"
+// @Success 200 {object} response.Response{data=dto.StorageUsage}
+// @Failure 400 {object} response.Response{} "possible codes: invalid_token, invalid_authorization_header"
+// @Failure 400 {object} response.Response{} "possible codes: bind_body, invalid_X-Request-Id, bad_quota"
+// @Failure 403 {object} response.Response{} "possible codes: admin_only"
+// @Failure 422 {object} response.Response{} "possible codes: user_not_found"
+// @Router /wn/api/v1/user/storage/quota [post]
+func (h *Controller) setStorageQuota(c *gin.Context) {
+	ctx := c.Request.Context()
+	var req request.SetStorageQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		_ = c.Error(apperror.NewBadRequestError(err.Error(), constants.BindBodyError))
+		return
+	}
+	userId, err := util.GetUserId(ctx)
+	if err != nil {
+		_ = c.Error(apperrors.InvalidAuthorizationHeader)
+		return
+	}
+
+	usage, err := h.userService.SetStorageQuota(ctx, userId, req)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+
+	c.AbortWithStatusJSON(h.builder.BuildSuccessResponseBody(ctx, usage))
+}
@@ -7,6 +7,7 @@ import (
 	"wn/pkg/response"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -43,7 +44,6 @@ func (k *Kernel) Init() *gin.Engine {
 
 	router.StaticFile("/swagger.json", "./docs/swagger.json")
 	router.StaticFile("/swagger.yaml", "./docs/swagger.yaml")
-	router.Static("/statics/images", "./statics/images")
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(
 		swaggerFiles.Handler,
 		ginSwagger.URL("/swagger.json"),
@@ -52,6 +52,7 @@ func (k *Kernel) Init() *gin.Engine {
 	router.HEAD("/healthz", func(c *gin.Context) {
 		c.Status(200)
 	})
+	router.GET("/metrics", MetricsHandler(prometheus.DefaultGatherer))
 
 	router.Use(
 		gin.Recovery(),
@@ -61,6 +62,7 @@ func (k *Kernel) Init() *gin.Engine {
 		ErrorHandler(k.builder),
 	)
 
+	k.dispatcher.InitStatics(router.Group("/statics", OptionalAuthorizationHandler()))
 	k.initApi(router.Group("/wn", RequestIdValidationHandler), router.Group("/wn"))
 	return router
 }
@@ -68,6 +68,24 @@ func AuthorizationHandler() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthorizationHandler кладет пользователя в контекст, если передан токен, но не требует его.
+// Нужен для ресурсов, которые открываются и по подписанной ссылке, и с авторизацией
+func OptionalAuthorizationHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		headerParts := strings.Fields(c.GetHeader(constants.AuthorizationHeader))
+		if len(headerParts) != 2 || len(headerParts[1]) == 0 {
+			return
+		}
+		claims, err := token.ParseTokenWithoutKeyCheck(headerParts[1])
+		if err != nil {
+			return
+		}
+		ctx := context.WithValue(c.Request.Context(), constants.UserRoleCtx, token.GetUserRole(claims))
+		ctx = context.WithValue(ctx, constants.UserIdCtx, token.GetUserId(claims).String())
+		c.Request = c.Request.WithContext(ctx)
+	}
+}
+
 func LoggerHandler(logger applogger.Logger, logInputParamOnErr bool) gin.HandlerFunc {
 
 	return func(c *gin.Context) {
@@ -125,7 +143,9 @@ func ErrorHandler(builder *response.Builder) gin.HandlerFunc {
 		} else {
 			appError = apperror.NewInternalError(err)
 		}
-		c.AbortWithStatusJSON(status, builder.BuildErrorResponse(c.Request.Context(), appError.Message, appError.Code, err))
+		resp := builder.BuildErrorResponse(c.Request.Context(), appError.Message, appError.Code, err)
+		resp.Data = appError.Data
+		c.AbortWithStatusJSON(status, resp)
 	}
 }
 
@@ -137,13 +157,16 @@ func CORSHandler() gin.HandlerFunc {
 			return true
 		},
 		//Методы которые могут кидать к нам
-		AllowMethods: []string{"DELETE", "GET", "POST", "PUT", "OPTIONS"},
+		AllowMethods: []string{"DELETE", "GET", "POST", "PUT", "OPTIONS", "PATCH", "HEAD"},
 		//Хедеры которые могут кидать к нам
-		AllowHeaders: []string{"Origin", "Content-Type", constants.AuthorizationHeader, constants.RequestIdHeader, constants.RefreshHeader},
+		AllowHeaders: []string{"Origin", "Content-Type", constants.AuthorizationHeader, constants.RequestIdHeader, constants.RefreshHeader, constants.IfMatchHeader,
+			constants.TusResumableHeader, constants.UploadLengthHeader, constants.UploadOffsetHeader, constants.UploadMetadataHeader},
 		//Допустимы параметры авторизации в куках
 		AllowCredentials: true,
 		//хедеры которые я могу прокинуть клиенту
-		ExposeHeaders: []string{"Content-Length", "Content-Type", constants.AuthorizationHeader, constants.RequestIdHeader, constants.RefreshHeader},
+		ExposeHeaders: []string{"Content-Length", "Content-Type", constants.AuthorizationHeader, constants.RequestIdHeader, constants.RefreshHeader, constants.ETagHeader,
+			constants.LocationHeader, constants.TusResumableHeader, constants.TusVersionHeader, constants.TusExtensionHeader, constants.TusMaxSizeHeader,
+			constants.UploadLengthHeader, constants.UploadOffsetHeader, constants.UploadExpiresHeader, constants.UploadFileUrlHeader},
 		//время хранения префлайт запрсоов
 		MaxAge: 12 * time.Hour,
 	})
@@ -0,0 +1,39 @@
+package changes
+
+import (
+	"context"
+	"time"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/util"
+)
+
+type changesService interface {
+	PruneChanges(ctx context.Context, now time.Time) (int64, error)
+}
+
+type Cron struct {
+	logger         applogger.Logger
+	changesService changesService
+}
+
+func NewCron(logger applogger.Logger, changesService changesService) *Cron {
+	return &Cron{
+		logger:         logger,
+		changesService: changesService,
+	}
+}
+
+// PruneChanges удаляет из ленты синхронизации изменения старше срока хранения
+func (c *Cron) PruneChanges() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "PruneChanges")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	pruned, err := c.changesService.PruneChanges(ctx, util.GetCurrentUTCTime())
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("PruneChanges: %s", err.Error())
+	}
+	if pruned > 0 {
+		c.logger.WithCtx(ctx).Infof("PruneChanges: deleted %d changes", pruned)
+	}
+}
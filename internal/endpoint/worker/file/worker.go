@@ -8,7 +8,10 @@ import (
 )
 
 type fileService interface {
-	GenerateStatics(ctx context.Context) error
+	MoveLegacyFiles(ctx context.Context) (int, error)
+	ProcessImages(ctx context.Context) (int, error)
+	ScanFiles(ctx context.Context) (int, error)
+	CollectGarbage(ctx context.Context) (int, int, error)
 }
 
 type Cron struct {
@@ -23,13 +26,51 @@ func NewCron(logger applogger.Logger, fileService fileService) *Cron {
 	}
 }
 
-func (c *Cron) GenerateStatics() {
+// MoveLegacyFiles разовый перенос файлов из колонки files.file_data в хранилище.
+// Запускается при старте, когда переносить нечего - сразу завершается
+func (c *Cron) MoveLegacyFiles() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "MoveLegacyFiles")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	moved, err := c.fileService.MoveLegacyFiles(ctx)
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("MoveLegacyFiles: %s", err.Error())
+	}
+	if moved > 0 {
+		c.logger.WithCtx(ctx).Infof("MoveLegacyFiles: moved %d files", moved)
+	}
+}
 
+// ProcessImages перекодирует загруженные изображения и строит миниатюры
+func (c *Cron) ProcessImages() {
 	ctx := context.Background()
-	ctx = context.WithValue(ctx, constants.ApiNameCtx, "GenerateStatics")
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "ProcessImages")
 	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
-	err := c.fileService.GenerateStatics(ctx)
+	if _, err := c.fileService.ProcessImages(ctx); err != nil {
+		c.logger.WithCtx(ctx).Warnf("ProcessImages: %s", err.Error())
+	}
+}
+
+// ScanFiles проверяет антивирусом загруженные вложения
+func (c *Cron) ScanFiles() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "ScanFiles")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	if _, err := c.fileService.ScanFiles(ctx); err != nil {
+		c.logger.WithCtx(ctx).Warnf("ScanFiles: %s", err.Error())
+	}
+}
+
+// CollectGarbage удаляет файлы и содержимое, на которые давно никто не ссылается
+func (c *Cron) CollectGarbage() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "CollectGarbage")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	files, blobs, err := c.fileService.CollectGarbage(ctx)
 	if err != nil {
-		c.logger.WithCtx(ctx).Warnf("GenerateStatics: %s", err.Error())
+		c.logger.WithCtx(ctx).Warnf("CollectGarbage: %s", err.Error())
+	}
+	if files > 0 || blobs > 0 {
+		c.logger.WithCtx(ctx).Infof("CollectGarbage: deleted %d files, %d blobs", files, blobs)
 	}
 }
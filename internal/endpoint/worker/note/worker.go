@@ -0,0 +1,55 @@
+package note
+
+import (
+	"context"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/util"
+)
+
+type noteService interface {
+	IndexFileRefs(ctx context.Context) (int, error)
+	IndexSearch(ctx context.Context) (int, error)
+}
+
+type Cron struct {
+	logger      applogger.Logger
+	noteService noteService
+}
+
+func NewCron(logger applogger.Logger, noteService noteService) *Cron {
+	return &Cron{
+		logger:      logger,
+		noteService: noteService,
+	}
+}
+
+// IndexFileRefs разовый сбор ссылок на файлы из заметок, созданных до появления индекса.
+// Запускается при старте, когда индексировать нечего - сразу завершается
+func (c *Cron) IndexFileRefs() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "IndexFileRefs")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	indexed, err := c.noteService.IndexFileRefs(ctx)
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("IndexFileRefs: %s", err.Error())
+	}
+	if indexed > 0 {
+		c.logger.WithCtx(ctx).Infof("IndexFileRefs: indexed %d notes", indexed)
+	}
+}
+
+// IndexSearch разовая индексация для поиска заметок, созданных до появления индекса или до смены ключа.
+// Запускается при старте, когда индексировать нечего - сразу завершается
+func (c *Cron) IndexSearch() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "IndexSearch")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	indexed, err := c.noteService.IndexSearch(ctx)
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("IndexSearch: %s", err.Error())
+	}
+	if indexed > 0 {
+		c.logger.WithCtx(ctx).Infof("IndexSearch: indexed %d notes", indexed)
+	}
+}
@@ -0,0 +1,40 @@
+package reminder
+
+import (
+	"context"
+	"time"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/util"
+)
+
+type reminderService interface {
+	FireReminders(ctx context.Context, now time.Time) (int, error)
+}
+
+type Cron struct {
+	logger          applogger.Logger
+	reminderService reminderService
+}
+
+func NewCron(logger applogger.Logger, reminderService reminderService) *Cron {
+	return &Cron{
+		logger:          logger,
+		reminderService: reminderService,
+	}
+}
+
+// FireReminders отправляет напоминания, время которых подошло. Запускается на всех репликах,
+// каждое напоминание достается одной
+func (c *Cron) FireReminders() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "FireReminders")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	fired, err := c.reminderService.FireReminders(ctx, util.GetCurrentUTCTime())
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("FireReminders: %s", err.Error())
+	}
+	if fired > 0 {
+		c.logger.WithCtx(ctx).Infof("FireReminders: sent %d reminders", fired)
+	}
+}
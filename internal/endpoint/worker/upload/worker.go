@@ -0,0 +1,38 @@
+package upload
+
+import (
+	"context"
+	"wn/pkg/applogger"
+	"wn/pkg/constants"
+	"wn/pkg/util"
+)
+
+type uploadService interface {
+	ExpireUploads(ctx context.Context) (int, error)
+}
+
+type Cron struct {
+	logger        applogger.Logger
+	uploadService uploadService
+}
+
+func NewCron(logger applogger.Logger, uploadService uploadService) *Cron {
+	return &Cron{
+		logger:        logger,
+		uploadService: uploadService,
+	}
+}
+
+// ExpireUploads удаляет брошенные загрузки по частям вместе с полученными кусками
+func (c *Cron) ExpireUploads() {
+	ctx := context.Background()
+	ctx = context.WithValue(ctx, constants.ApiNameCtx, "ExpireUploads")
+	ctx = context.WithValue(ctx, constants.RequestIdCtx, util.NewUUID().String())
+	expired, err := c.uploadService.ExpireUploads(ctx)
+	if err != nil {
+		c.logger.WithCtx(ctx).Warnf("ExpireUploads: %s", err.Error())
+	}
+	if expired > 0 {
+		c.logger.WithCtx(ctx).Infof("ExpireUploads: deleted %d uploads", expired)
+	}
+}
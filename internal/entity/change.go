@@ -0,0 +1,36 @@
+package entity
+
+import (
+	"time"
+	"wn/internal/domain/enum"
+
+	"github.com/google/uuid"
+)
+
+// Change запись в ленте изменений. SecondId заполнен только у связей.
+// Txid транзакция, записавшая изменение, лента упорядочена по (Txid, Seq)
+type Change struct {
+	Seq       int64
+	Txid      int64
+	Kind      enum.SyncEntityKind
+	EntityId  uuid.UUID
+	SecondId  uuid.UUID
+	LayoutId  uuid.UUID
+	Operation enum.SyncOperation
+	CreatedAt time.Time
+}
+
+func NewChange(kind enum.SyncEntityKind, operation enum.SyncOperation, entityId, layoutId uuid.UUID) *Change {
+	return &Change{
+		Kind:      kind,
+		EntityId:  entityId,
+		LayoutId:  layoutId,
+		Operation: operation,
+	}
+}
+
+func NewLinkChange(operation enum.SyncOperation, firstNoteId, secondNoteId, layoutId uuid.UUID) *Change {
+	c := NewChange(enum.SyncEntityKindLink, operation, firstNoteId, layoutId)
+	c.SecondId = secondNoteId
+	return c
+}
@@ -0,0 +1,86 @@
+package entity
+
+import (
+	"time"
+	"wn/internal/domain/enum"
+
+	"github.com/google/uuid"
+)
+
+// File метаданные загруженного файла, само содержимое лежит в хранилище по StorageKey
+type File struct {
+	Name        string
+	StorageKey  string
+	ContentType string
+	Size        int64
+	Hash        string
+	CreatedAt   time.Time
+
+	// OwnerId пустой у файлов, загруженных до появления владельцев, такие файлы публичные
+	OwnerId uuid.UUID
+	// NoteId заметка, к которой прикреплен файл
+	NoteId  uuid.UUID
+	Purpose enum.FilePurpose
+
+	// ParentName и Variant заполнены у миниатюр: имя оригинала и размер большей стороны
+	ParentName string
+	Variant    int
+	// Orientation EXIF ориентация оригинала, применяется при обработке
+	Orientation int
+	ImageState  enum.ImageState
+	// ScanState результат антивирусной проверки, миниатюры повторяют состояние оригинала
+	ScanState enum.ScanState
+	// ScanSignature имя найденной сигнатуры у зараженных файлов
+	ScanSignature string
+
+	// OrphanedAt когда на файл перестали ссылаться, нулевое у используемых файлов
+	OrphanedAt time.Time
+
+	// Variants миниатюры, созданные вместе с файлом. В базе не хранится
+	Variants []*File
+	// References где используется файл, заполняется только по запросу
+	References *FileReferences
+}
+
+// FileReferences где используется файл
+type FileReferences struct {
+	// Avatar файл стоит аватаркой у пользователя
+	Avatar bool
+	// AttachedTo заметка, к которой прикреплен файл
+	AttachedTo uuid.UUID
+	// Notes заметки, в тексте или черновике которых есть ссылка на файл
+	Notes []uuid.UUID
+}
+
+// InNotes на файл ссылается хотя бы одна заметка
+func (r *FileReferences) InNotes() bool {
+	return r.AttachedTo != uuid.Nil || len(r.Notes) > 0
+}
+
+// Public файл доступен по ссылке без проверки прав
+func (f *File) Public() bool {
+	return f.Purpose == enum.FilePurposeAvatar || f.OwnerId == uuid.Nil
+}
+
+// Settled содержимое файла больше не изменится, его можно кэшировать навсегда
+func (f *File) Settled() bool {
+	return f.ImageState != enum.ImageStatePending && f.ImageState != enum.ImageStateProcessing
+}
+
+// StorageUsage сколько места занимают файлы пользователя одного назначения, миниатюры не считаются
+type StorageUsage struct {
+	Purpose enum.FilePurpose
+	Files   int
+	Bytes   int64
+}
+
+// Blob содержимое в хранилище. Одинаковые по sha256 загрузки ссылаются на один blob
+type Blob struct {
+	StorageKey  string
+	Hash        string
+	Size        int64
+	ContentType string
+	CreatedAt   time.Time
+	// OrphanedAt когда на blob перестали ссылаться файлы
+	OrphanedAt time.Time
+}
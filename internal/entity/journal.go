@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Journal настройки журнала пользователя. StartedOn - день, от которого записи раскладываются по графу
+type Journal struct {
+	UserId      uuid.UUID
+	LayoutId    uuid.UUID
+	TitleFormat string
+	TemplateId  *uuid.UUID
+	StartedOn   time.Time
+}
+
+// JournalEntry заметка журнала за день Day (полночь UTC)
+type JournalEntry struct {
+	LayoutId uuid.UUID
+	Day      time.Time
+	NoteId   uuid.UUID
+}
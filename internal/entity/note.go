@@ -16,6 +16,8 @@ type Note struct {
 	HaveAccess []uuid.UUID `json:"haveAccess" db:"have_access"`
 	Draft      string      `json:"draft" db:"draft"`
 	LayoutId   uuid.UUID   `json:"layoutId"`
+	Version    int64       `json:"version" db:"version"`
+	UpdatedAt  time.Time   `json:"updatedAt" db:"updated_at"`
 }
 
 func (n Note) GetId() uuid.UUID {
@@ -31,6 +33,12 @@ func (n NoteWithPosition) GetId() uuid.UUID {
 	return n.Id
 }
 
+// FoundNote заметка из поиска с рангом
+type FoundNote struct {
+	Note
+	Rank string
+}
+
 type NotePosition struct {
 	NoteId    uuid.UUID `json:"noteId" db:"note_id"`
 	XPosition float64   `json:"xPosition" db:"x_position"`
@@ -44,11 +52,14 @@ type Layout struct {
 	HaveAccess []uuid.UUID `json:"haveAccess" db:"have_access"`
 	IsMain     bool        `json:"isMain"`
 	Color      string      `json:"color"`
+	Version    int64       `json:"version"`
 }
 
 type Link struct {
 	FirstNoteId  uuid.UUID `json:"firstNoteId"`
 	SecondNoteId uuid.UUID `json:"secondNoteId"`
+	// Auto связь из wiki-ссылки в тексте первой заметки
+	Auto bool `json:"auto"`
 }
 
 // EncryptNote шифрует поля Payload и Draft
@@ -0,0 +1,31 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reminder напоминание о заметке. RemindAt - первое срабатывание, RRule - правило повторения
+// в часовом поясе Timezone, пустое для разового. NextFireAt nil, когда напоминание отработало
+type Reminder struct {
+	Id          uuid.UUID
+	UserId      uuid.UUID
+	NoteId      uuid.UUID
+	RemindAt    time.Time
+	RRule       string
+	Timezone    string
+	NextFireAt  *time.Time
+	LastFiredAt *time.Time
+	CreatedAt   time.Time
+}
+
+// ReminderWithNote напоминание вместе с заголовком заметки и адресом владельца
+type ReminderWithNote struct {
+	Reminder
+	NoteTitle string
+	LayoutId  uuid.UUID
+	Email     string
+	// Readable пользователь еще может читать заметку
+	Readable bool
+}
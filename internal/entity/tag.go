@@ -0,0 +1,22 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tag метка пользователя. Метки личные: на общей заметке каждый видит только свои
+type Tag struct {
+	Id        uuid.UUID
+	OwnerId   uuid.UUID
+	Name      string
+	Color     string
+	CreatedAt time.Time
+}
+
+// TagCount метка и сколько заметок ею отмечено
+type TagCount struct {
+	Tag
+	Notes int
+}
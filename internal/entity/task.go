@@ -0,0 +1,26 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Task пункт чек-листа "- [ ]" или "- [x]" из текста заметки. Line - номер строки в payload с нуля,
+// Assignees - имена пользователей из @user без @ в нижнем регистре
+type Task struct {
+	NoteId    uuid.UUID
+	Line      int
+	Text      string
+	Done      bool
+	Due       *time.Time
+	Assignees []string
+}
+
+// FoundTask задача вместе с заметкой, в которой она записана
+type FoundTask struct {
+	Task
+	Title       string
+	LayoutId    uuid.UUID
+	NoteVersion int64
+}
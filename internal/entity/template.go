@@ -0,0 +1,19 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Template шаблон заметки. Без LayoutId - личный шаблон владельца,
+// с LayoutId - общий для всех, кто может читать лейаут
+type Template struct {
+	Id        uuid.UUID
+	OwnerId   uuid.UUID
+	LayoutId  *uuid.UUID
+	Name      string
+	Title     string
+	Payload   string
+	CreatedAt time.Time
+}
@@ -0,0 +1,27 @@
+package entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Upload загрузка по частям. Куски хранятся отдельными объектами, пока загрузка не завершится
+type Upload struct {
+	Id       uuid.UUID
+	OwnerId  uuid.UUID
+	NoteId   uuid.UUID
+	FileName string
+	Length   int64
+	Offset   int64
+	// ChunkKeys ключи кусков в хранилище по порядку
+	ChunkKeys []string
+	// ResultName имя файла, созданного из завершенной загрузки
+	ResultName string
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
+
+func (u *Upload) Completed() bool {
+	return u.ResultName != ""
+}
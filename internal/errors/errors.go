@@ -26,10 +26,62 @@ var (
 
 	RecordNotFound = apperror.NewInvalidDataError("record not found", "record_not_found")
 
+	TagNotFound      = apperror.NewInvalidDataError("tag not found", "tag_not_found")
+	TagAlreadyExists = apperror.NewInvalidDataError("tag already exists", "tag_already_exists")
+	BadTagName       = apperror.NewBadRequestError("bad tag name", "bad_tag_name")
+	BadTagMode       = apperror.NewBadRequestError("tag mode must be and or or", "bad_tag_mode")
+
+	BadSort   = apperror.NewBadRequestError("sort must be relevance, created or updated", "bad_sort")
+	BadCursor = apperror.NewBadRequestError("bad cursor", "bad_cursor")
+
+	TemplateNotFound      = apperror.NewInvalidDataError("template not found", "template_not_found")
+	BadTemplateName       = apperror.NewBadRequestError("bad template name", "bad_template_name")
+	TemplateValuesMissing = apperror.NewInvalidDataError("template prompts without values", "template_values_missing")
+	BadTimezone           = apperror.NewBadRequestError("bad timezone", "bad_timezone")
+
+	JournalNotConfigured = apperror.NewInvalidDataError("journal not configured", "journal_not_configured")
+	BadJournalTitle      = apperror.NewBadRequestError("bad journal title format", "bad_journal_title")
+	BadDate              = apperror.NewBadRequestError("date must be YYYY-MM-DD", "bad_date")
+	BadMonth             = apperror.NewBadRequestError("month must be YYYY-MM", "bad_month")
+
+	TaskNotFound  = apperror.NewInvalidDataError("no task on this line", "task_not_found")
+	BadTaskStatus = apperror.NewBadRequestError("status must be open, done or all", "bad_task_status")
+
+	ReminderNotFound     = apperror.NewInvalidDataError("reminder not found", "reminder_not_found")
+	ReminderInPast       = apperror.NewInvalidDataError("reminder never fires in the future", "reminder_in_past")
+	BadRecurrence        = apperror.NewBadRequestError("unsupported recurrence rule", "bad_recurrence")
+	ReminderFeedNotFound = apperror.NewNotFoundError("reminder feed not found", "reminder_feed_not_found")
+
 	PermissionsNotEnough = apperror.NewInvalidDataError("permissions not enough", "premissions_not_enough")
+	AdminOnly            = apperror.NewAccessDeniedError("admin only", "admin_only")
 	BadKind              = apperror.NewBadRequestError("bad kind", "bad_kind")
 	AlreadyExist         = apperror.NewInvalidDataError("already exist", "already_exist")
 	CantApply            = apperror.NewInvalidDataError("cant apply", "cant_apply")
+
+	SyncConflict    = apperror.NewConflictError("entity changed on server", "sync_conflict")
+	VersionConflict = apperror.NewConflictError("version conflict", "version_conflict")
+	BadOperation    = apperror.NewBadRequestError("bad operation", "bad_operation")
+
+	FileNotFound            = apperror.NewNotFoundError("file not found", "file_not_found")
+	FileEmpty               = apperror.NewInvalidDataError("file is empty", "file_empty")
+	FileTooLarge            = apperror.NewInvalidDataError("file too large", "file_too_large")
+	FileExtensionNotAllowed = apperror.NewInvalidDataError("file extension not allowed", "file_extension_not_allowed")
+	FileTypeMismatch        = apperror.NewInvalidDataError("file content does not match extension", "file_type_mismatch")
+	FileUnsafe              = apperror.NewInvalidDataError("file contains active content", "file_unsafe")
+	FileAccessDenied        = apperror.NewAccessDeniedError("file access denied", "file_access_denied")
+	FileNotAttachable       = apperror.NewInvalidDataError("file can not be attached", "file_not_attachable")
+	FileAlreadyAttached     = apperror.NewInvalidDataError("file attached to another note", "file_already_attached")
+	FileInUse               = apperror.NewConflictError("file is still in use", "file_in_use")
+	FileScanPending         = apperror.NewConflictError("file is not scanned yet", "file_scan_pending")
+	FileQuarantined         = apperror.NewAccessDeniedError("file is quarantined", "file_quarantined")
+	StorageQuotaExceeded    = apperror.NewInvalidDataError("storage quota exceeded", "storage_quota_exceeded")
+	BadQuota                = apperror.NewBadRequestError("quota must not be negative", "bad_quota")
+
+	UploadNotFound       = apperror.NewNotFoundError("upload not found", "upload_not_found")
+	UploadOffsetMismatch = apperror.NewConflictError("upload offset mismatch", "upload_offset_mismatch")
+	UploadTooLong        = apperror.NewBadRequestError("chunk exceeds upload length", "upload_too_long")
+	UploadCompleted      = apperror.NewConflictError("upload already completed", "upload_completed")
+	BadUploadRequest     = apperror.NewBadRequestError("bad upload request", "bad_upload_request")
 )
 
 // коды динамических ошибок:
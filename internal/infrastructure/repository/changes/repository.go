@@ -0,0 +1,206 @@
+package changes
+
+import (
+	"context"
+	"time"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/database/postgres"
+	"wn/pkg/util"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+// RecordChange должен вызываться в той же транзакции, что и само изменение.
+// audience фиксирует, кто имел доступ к лейауту в момент изменения,
+// чтобы удаления дошли и до тех, у кого доступ уже отозвали
+func (repo *Repository) RecordChange(ctx context.Context, item *entity.Change) error {
+	query := `
+		insert into changes (entity_kind, entity_id, second_id, layout_id, operation, audience, created_at)
+		values ($1, $2, $3, $4, $5,
+			array(
+				select l.owner_id from layouts l where l.id = $4
+				union
+				select p.to_user_id from permissions p where p.target_id = $4 and p.to_user_id is not null
+			),
+			$6
+		)
+		returning seq, txid::text::bigint
+	`
+	err := repo.conn.QueryRow(ctx, query,
+		item.Kind,
+		item.EntityId,
+		item.SecondId,
+		item.LayoutId,
+		item.Operation,
+		util.GetCurrentUTCTime(),
+	).Scan(&item.Seq, &item.Txid)
+	if err != nil {
+		return errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return nil
+}
+
+// GetChanges возвращает изменения после since в лейаутах layoutIds
+// или в тех, где пользователь был в audience на момент изменения.
+// Отдаются только транзакции старше горизонта снимка: все они уже завершены,
+// и ни одна запись не закоммитится позже с позицией до выданного курсора.
+// Условия разнесены по двум веткам, чтобы каждая шла по своему индексу
+func (repo *Repository) GetChanges(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID, since dto.SyncCursor, limit int) ([]entity.Change, error) {
+	query := `
+		with h as (select pg_snapshot_xmin(pg_current_snapshot()) as xmin)
+		select c.seq, c.txid::text::bigint, c.entity_kind, c.entity_id, c.second_id, c.layout_id, c.operation, c.created_at
+		from (
+			(
+				select c.*
+				from changes c, h
+				where c.layout_id = any($3)
+					and (c.txid, c.seq) > ($1::bigint::text::xid8, $2)
+					and c.txid < h.xmin
+				order by c.txid, c.seq
+				limit $5
+			)
+			union
+			(
+				select c.*
+				from changes c, h
+				where c.audience @> array[$4::uuid]
+					and (c.txid, c.seq) > ($1::bigint::text::xid8, $2)
+					and c.txid < h.xmin
+				order by c.txid, c.seq
+				limit $5
+			)
+		) c
+		order by c.txid, c.seq
+		limit $5
+	`
+	rows, err := repo.conn.Query(ctx, query, since.Txid, since.Seq, layoutIds, userId, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var changes []entity.Change
+	for rows.Next() {
+		var item entity.Change
+		err := rows.Scan(
+			&item.Seq,
+			&item.Txid,
+			&item.Kind,
+			&item.EntityId,
+			&item.SecondId,
+			&item.LayoutId,
+			&item.Operation,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		changes = append(changes, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return changes, nil
+}
+
+// GetLatestChange последнее изменение сущности
+func (repo *Repository) GetLatestChange(ctx context.Context, item *entity.Change) (*entity.Change, error) {
+	query := `
+		select c.seq, c.txid::text::bigint, c.entity_kind, c.entity_id, c.second_id, c.layout_id, c.operation, c.created_at
+		from changes c
+		where c.entity_kind = $1 and c.entity_id = $2 and c.second_id = $3
+		order by c.seq desc
+		limit 1
+	`
+	var latest entity.Change
+	err := repo.conn.QueryRow(ctx, query, item.Kind, item.EntityId, item.SecondId).Scan(
+		&latest.Seq,
+		&latest.Txid,
+		&latest.Kind,
+		&latest.EntityId,
+		&latest.SecondId,
+		&latest.LayoutId,
+		&latest.Operation,
+		&latest.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, apperrors.RecordNotFound
+		}
+		return nil, errors.Wrap(err, "scan")
+	}
+	return &latest, nil
+}
+
+// GetLatestForeignSeq seq последнего изменения сущности, записанного не текущей транзакцией, 0 если таких нет.
+// Вызывается после записи: блокировки строк сущности к этому моменту держит текущая транзакция,
+// поэтому чужое изменение либо уже закоммичено и видно, либо ждет нашего коммита
+func (repo *Repository) GetLatestForeignSeq(ctx context.Context, item *entity.Change) (int64, error) {
+	query := `
+		select coalesce(max(c.seq), 0)
+		from changes c
+		where c.entity_kind = $1 and c.entity_id = $2 and c.second_id = $3
+			and c.txid <> pg_current_xact_id()
+	`
+	var seq int64
+	err := repo.conn.QueryRow(ctx, query, item.Kind, item.EntityId, item.SecondId).Scan(&seq)
+	if err != nil {
+		return 0, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return seq, nil
+}
+
+// GetPruned позиция последнего удаленного изменения, нулевая если лента не чистилась
+func (repo *Repository) GetPruned(ctx context.Context) (dto.SyncCursor, error) {
+	var cursor dto.SyncCursor
+	err := repo.conn.QueryRow(ctx, `select txid::text::bigint, seq from changes_pruned`).Scan(&cursor.Txid, &cursor.Seq)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return cursor, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return cursor, nil
+}
+
+// GetHead позиция, с которой продолжает клиент после полной синхронизации:
+// все изменения до нее уже видны в текущем состоянии
+func (repo *Repository) GetHead(ctx context.Context) (dto.SyncCursor, error) {
+	var cursor dto.SyncCursor
+	err := repo.conn.QueryRow(ctx, `select pg_snapshot_xmin(pg_current_snapshot())::text::bigint`).Scan(&cursor.Txid)
+	if err != nil {
+		return cursor, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return cursor, nil
+}
+
+// PruneChanges удаляет изменения старше before и сдвигает позицию удаленных вперед
+func (repo *Repository) PruneChanges(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		with deleted as (
+			delete from changes where created_at < $1 returning txid, seq
+		), mark as (
+			insert into changes_pruned (id, txid, seq)
+			select true, d.txid, d.seq from deleted d order by d.txid desc, d.seq desc limit 1
+			on conflict (id) do update set txid = excluded.txid, seq = excluded.seq
+			where (excluded.txid, excluded.seq) > (changes_pruned.txid, changes_pruned.seq)
+		)
+		select count(*) from deleted
+	`
+	var deleted int64
+	err := repo.conn.QueryRow(ctx, query, before).Scan(&deleted)
+	if err != nil {
+		return 0, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return deleted, nil
+}
@@ -2,7 +2,10 @@ package common
 
 import (
 	"errors"
+	"fmt"
 
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
@@ -11,3 +14,20 @@ func IsUniqueErr(err error) bool {
 	return errors.As(err, &pgErr) &&
 		pgErr.Code == "23505"
 }
+
+// readableNotes условие ReadableNotes, %[1]s - пользователь
+const readableNotes = `(n.owner_id = %[1]s or l.owner_id = %[1]s or exists (
+	select 1 from permissions p
+	where p.target_id = n.layout_id and p.to_user_id = %[1]s and p.can_read
+))`
+
+// ReadableNotes заметки n, которые пользователь может читать: свои, из своих лейаутов
+// и из лейаутов, доступных ему на чтение. Нужен join layouts l
+func ReadableNotes(userId uuid.UUID) sq.Sqlizer {
+	return sq.Expr(fmt.Sprintf(readableNotes, "?"), userId, userId, userId)
+}
+
+// ReadableNotesOf то же условие для пользователя из колонки запроса, например r.user_id
+func ReadableNotesOf(userColumn string) string {
+	return fmt.Sprintf(readableNotes, userColumn)
+}
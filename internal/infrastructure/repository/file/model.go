@@ -1,7 +1,7 @@
 package file
 
+// StaticFile файл, содержимое которого еще хранится в базе в base64
 type StaticFile struct {
-	Filename     string `db:"file_path"`
+	Filename     string `db:"file_name"`
 	FileAsString string `db:"file_data"`
-	File         []byte
 }
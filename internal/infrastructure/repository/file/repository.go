@@ -2,10 +2,16 @@ package file
 
 import (
 	"context"
-	"fmt"
+	"strings"
+	"time"
+	"wn/internal/domain/enum"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
 	"wn/pkg/database/postgres"
 
 	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/pkg/errors"
 )
 
@@ -17,8 +23,164 @@ func NewRepository(conn postgres.Connection) *Repository {
 	return &Repository{conn: conn}
 }
 
-func (repo *Repository) CreateFile(ctx context.Context, filename string, encodedFile string) error {
-	query, args, err := squirrel.Insert("files").Values(filename, encodedFile).PlaceholderFormat(squirrel.Dollar).ToSql()
+// fileColumns колонки для scanFile
+var fileColumns = []string{
+	"file_name", "storage_key", "content_type", "size", "hash", "created_at",
+	"coalesce(parent_name, '')", "coalesce(variant, 0)", "orientation", "image_state",
+	"coalesce(owner_id, '00000000-0000-0000-0000-000000000000')",
+	"coalesce(note_id, '00000000-0000-0000-0000-000000000000')", "purpose", "orphaned_at",
+	"scan_state", "coalesce(scan_signature, '')",
+}
+
+func scanFile(row pgx.Row) (*entity.File, error) {
+	var item entity.File
+	var state, purpose, scanState string
+	var orphanedAt *time.Time
+	err := row.Scan(
+		&item.Name, &item.StorageKey, &item.ContentType, &item.Size, &item.Hash, &item.CreatedAt,
+		&item.ParentName, &item.Variant, &item.Orientation, &state,
+		&item.OwnerId, &item.NoteId, &purpose, &orphanedAt,
+		&scanState, &item.ScanSignature,
+	)
+	item.ImageState = enum.ImageStateFromString(state)
+	item.ScanState = enum.ScanStateFromString(scanState)
+	item.Purpose = enum.FilePurposeFromString(purpose)
+	if orphanedAt != nil {
+		item.OrphanedAt = *orphanedAt
+	}
+	return &item, err
+}
+
+func (repo *Repository) CreateFile(ctx context.Context, item *entity.File) error {
+	var parentName, variant any
+	if item.ParentName != "" {
+		parentName, variant = item.ParentName, item.Variant
+	}
+	query, args, err := squirrel.Insert("files").
+		Columns("file_name", "storage_key", "content_type", "size", "hash", "parent_name", "variant", "orientation", "image_state",
+			"owner_id", "note_id", "purpose", "scan_state").
+		Values(item.Name, item.StorageKey, item.ContentType, item.Size, item.Hash, parentName, variant, item.Orientation, item.ImageState.String(),
+			nullableId(item.OwnerId), nullableId(item.NoteId), item.Purpose.String(), item.ScanState.String()).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func (repo *Repository) GetFile(ctx context.Context, name string) (*entity.File, error) {
+	query, args, err := squirrel.Select(fileColumns...).
+		From("files").
+		Where(squirrel.Eq{"file_name": name}).
+		Where(squirrel.NotEq{"storage_key": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	item, err := scanFile(repo.conn.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.FileNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return item, nil
+}
+
+func (repo *Repository) GetVariants(ctx context.Context, parentName string) ([]*entity.File, error) {
+	query, args, err := squirrel.Select(fileColumns...).
+		From("files").
+		Where(squirrel.Eq{"parent_name": parentName}).
+		OrderBy("variant").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+	return repo.queryFiles(ctx, query, args...)
+}
+
+func (repo *Repository) queryFiles(ctx context.Context, query string, args ...any) ([]*entity.File, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var files []*entity.File
+	for rows.Next() {
+		item, err := scanFile(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		files = append(files, item)
+	}
+	return files, rows.Err()
+}
+
+// UpdateFileBlob переключает запись на новое содержимое
+func (repo *Repository) UpdateFileBlob(ctx context.Context, item *entity.File) error {
+	query, args, err := squirrel.Update("files").
+		Set("storage_key", item.StorageKey).
+		Set("content_type", item.ContentType).
+		Set("size", item.Size).
+		Set("hash", item.Hash).
+		Where(squirrel.Eq{"file_name": item.Name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+// ClaimImages забирает в обработку ожидающие изображения и зависшие дольше staleBefore.
+// Непроверенные антивирусом файлы не декодируются.
+// skip locked позволяет нескольким инстансам разбирать очередь параллельно
+func (repo *Repository) ClaimImages(ctx context.Context, limit uint64, staleBefore time.Time) ([]*entity.File, error) {
+	query := `update files set image_state = $1, image_state_at = now()
+		where file_name in (
+			select file_name from files
+			where parent_name is null and scan_state = $5
+				and (image_state = $2 or (image_state = $1 and image_state_at < $3))
+			order by image_state_at
+			limit $4
+			for update skip locked
+		)
+		returning ` + strings.Join(fileColumns, ", ")
+	return repo.queryFiles(ctx, query,
+		enum.ImageStateProcessing.String(), enum.ImageStatePending.String(), staleBefore, limit, enum.ScanStateClean.String())
+}
+
+// ClaimScans забирает на проверку ожидающие файлы и зависшие дольше staleBefore
+func (repo *Repository) ClaimScans(ctx context.Context, limit uint64, staleBefore time.Time) ([]*entity.File, error) {
+	query := `update files set scan_state = $1, scan_state_at = now()
+		where file_name in (
+			select file_name from files
+			where parent_name is null and (scan_state = $2 or (scan_state = $1 and scan_state_at < $3))
+			order by scan_state_at
+			limit $4
+			for update skip locked
+		)
+		returning ` + strings.Join(fileColumns, ", ")
+	return repo.queryFiles(ctx, query,
+		enum.ScanStateScanning.String(), enum.ScanStatePending.String(), staleBefore, limit)
+}
+
+// SetScanState сохраняет результат проверки у файла и его миниатюр
+func (repo *Repository) SetScanState(ctx context.Context, name string, state enum.ScanState, signature string) error {
+	var sig any
+	if signature != "" {
+		sig = signature
+	}
+	query, args, err := squirrel.Update("files").
+		Set("scan_state", state.String()).
+		Set("scan_state_at", squirrel.Expr("now()")).
+		Set("scan_signature", sig).
+		Where(squirrel.Or{squirrel.Eq{"file_name": name}, squirrel.Eq{"parent_name": name}}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
 	if err != nil {
 		return errors.Wrap(err, "squirrel.ToSql")
 	}
@@ -27,9 +189,28 @@ func (repo *Repository) CreateFile(ctx context.Context, filename string, encoded
 	return err
 }
 
-func (repo *Repository) GetAllFiles(ctx context.Context) ([]StaticFile, error) {
+func (repo *Repository) SetImageState(ctx context.Context, name string, state enum.ImageState) error {
+	query, args, err := squirrel.Update("files").
+		Set("image_state", state.String()).
+		Set("image_state_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"file_name": name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
 
-	query, args, err := squirrel.Select("file_name, file_data").From("files").PlaceholderFormat(squirrel.Dollar).ToSql()
+// GetLegacyFiles файлы, содержимое которых еще хранится в базе
+func (repo *Repository) GetLegacyFiles(ctx context.Context, limit uint64) ([]StaticFile, error) {
+	query, args, err := squirrel.Select("file_name", "coalesce(file_data, '')").
+		From("files").
+		Where(squirrel.Eq{"storage_key": nil}).
+		OrderBy("file_name").
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
 	if err != nil {
 		return nil, errors.Wrap(err, "squirrel.ToSql")
 	}
@@ -43,9 +224,355 @@ func (repo *Repository) GetAllFiles(ctx context.Context) ([]StaticFile, error) {
 	for rows.Next() {
 		var f StaticFile
 		if err := rows.Scan(&f.Filename, &f.FileAsString); err != nil {
-			return nil, fmt.Errorf("failed to scan row: %w", err)
+			return nil, errors.Wrap(err, "rows.Scan")
 		}
 		files = append(files, f)
 	}
-	return files, nil
+	return files, rows.Err()
+}
+
+// MarkFileMoved сохраняет метаданные перенесенного в хранилище файла и очищает file_data
+func (repo *Repository) MarkFileMoved(ctx context.Context, item *entity.File) error {
+	query, args, err := squirrel.Update("files").
+		Set("storage_key", item.StorageKey).
+		Set("content_type", item.ContentType).
+		Set("size", item.Size).
+		Set("hash", item.Hash).
+		Set("file_data", nil).
+		Where(squirrel.Eq{"file_name": item.Name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+// GetNoteAttachments оригиналы файлов, прикрепленных к заметке
+func (repo *Repository) GetNoteAttachments(ctx context.Context, noteId uuid.UUID) ([]*entity.File, error) {
+	query, args, err := squirrel.Select(fileColumns...).
+		From("files").
+		Where(squirrel.Eq{"note_id": noteId, "parent_name": nil}).
+		OrderBy("created_at").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+	return repo.queryFiles(ctx, query, args...)
+}
+
+// SetNoteId прикрепляет файл вместе с миниатюрами к заметке, uuid.Nil открепляет
+func (repo *Repository) SetNoteId(ctx context.Context, name string, noteId uuid.UUID) error {
+	query, args, err := squirrel.Update("files").
+		Set("note_id", nullableId(noteId)).
+		Where(squirrel.Or{squirrel.Eq{"file_name": name}, squirrel.Eq{"parent_name": name}}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func nullableId(id uuid.UUID) any {
+	if id == uuid.Nil {
+		return nil
+	}
+	return id
+}
+
+// RegisterBlob регистрирует содержимое и возвращает ключ, под которым оно хранится.
+// Если такое содержимое уже есть, возвращается ключ существующего blob, а сам он перестает считаться мусором.
+// Строка blob остается заблокированной до конца транзакции, поэтому сборщик не удалит его параллельно
+func (repo *Repository) RegisterBlob(ctx context.Context, blob *entity.Blob) (string, error) {
+	query := `insert into blobs(storage_key, hash, size, content_type) values ($1, $2, $3, $4)
+		on conflict (hash) do update set orphaned_at = null
+		returning storage_key`
+	var storageKey string
+	err := repo.conn.QueryRow(ctx, query, blob.StorageKey, blob.Hash, blob.Size, blob.ContentType).Scan(&storageKey)
+	if err != nil {
+		return "", errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return storageKey, nil
+}
+
+// orphanReferenced файл используется: прикреплен к заметке, стоит аватаркой или упоминается в заметке.
+// Пока ссылки из старых заметок не собраны, используемыми считаются все файлы
+const orphanReferenced = `(f.note_id is not null
+	or exists (select 1 from users u where u.img_url = f.file_name)
+	or exists (select 1 from file_refs r where r.file_key = split_part(f.file_name, '.', 1))
+	or exists (select 1 from file_refs_pending))`
+
+// orphanCandidates сборщик трогает только оригиналы с владельцем, миниатюры удаляются вместе с ними.
+// Файлы без владельца загружены до появления учета и могут использоваться где угодно
+const orphanCandidates = `f.parent_name is null and f.owner_id is not null and f.storage_key is not null`
+
+// MarkOrphanFiles проставляет orphaned_at файлам, на которые больше никто не ссылается, и снимает у используемых
+func (repo *Repository) MarkOrphanFiles(ctx context.Context) error {
+	query := `with state as (
+		select f.file_name, ` + orphanReferenced + ` as referenced
+		from files f
+		where ` + orphanCandidates + `
+	)
+	update files f set orphaned_at = case when s.referenced then null else now() end
+	from state s
+	where f.file_name = s.file_name and (f.orphaned_at is null) <> s.referenced`
+	_, err := repo.conn.Exec(ctx, query)
+	return err
+}
+
+// GetOrphanFiles файлы без ссылок, в том числе еще не помеченные. Ничего не меняет
+func (repo *Repository) GetOrphanFiles(ctx context.Context, limit uint64) ([]*entity.File, error) {
+	query := `select ` + strings.Join(fileColumns, ", ") + `
+	from files f
+	where ` + orphanCandidates + ` and not ` + orphanReferenced + `
+	order by f.orphaned_at nulls last, f.created_at
+	limit $1`
+	return repo.queryFiles(ctx, query, limit)
+}
+
+// DeleteOrphanFiles удаляет файлы, помеченные раньше before, миниатюры удаляются каскадом.
+// Ссылки перепроверяются, файл мог снова понадобиться после пометки
+func (repo *Repository) DeleteOrphanFiles(ctx context.Context, before time.Time, limit uint64) ([]*entity.File, error) {
+	query := `delete from files f
+	where f.file_name in (
+		select file_name from files
+		where parent_name is null and orphaned_at < $1
+		order by orphaned_at
+		limit $2
+		for update skip locked
+	) and not ` + orphanReferenced + `
+	returning ` + strings.Join(fileColumns, ", ")
+	return repo.queryFiles(ctx, query, before, limit)
+}
+
+// MarkOrphanBlobs проставляет orphaned_at blob'ам, на которые не ссылается ни один файл
+func (repo *Repository) MarkOrphanBlobs(ctx context.Context) error {
+	referenced := `exists (select 1 from files f where f.storage_key = b.storage_key)`
+	query := `update blobs b set orphaned_at = case when ` + referenced + ` then null else now() end
+	where (b.orphaned_at is null) <> ` + referenced
+	_, err := repo.conn.Exec(ctx, query)
+	return err
+}
+
+// GetOrphanBlobs blob'ы без ссылок, в том числе еще не помеченные. Ничего не меняет
+func (repo *Repository) GetOrphanBlobs(ctx context.Context, limit uint64) ([]*entity.Blob, error) {
+	query := `select ` + strings.Join(blobColumns, ", ") + `
+	from blobs b
+	where not exists (select 1 from files f where f.storage_key = b.storage_key)
+	order by b.orphaned_at nulls last, b.created_at
+	limit $1`
+	return repo.queryBlobs(ctx, query, limit)
+}
+
+// DeleteOrphanBlobs удаляет строки blob'ов, помеченных раньше before.
+// Вызывается в транзакции: содержимое удаляется из хранилища до коммита, при ошибке строки возвращаются
+func (repo *Repository) DeleteOrphanBlobs(ctx context.Context, before time.Time, limit uint64) ([]*entity.Blob, error) {
+	query := `delete from blobs b
+	where b.storage_key in (
+		select storage_key from blobs
+		where orphaned_at < $1
+		order by orphaned_at
+		limit $2
+		for update skip locked
+	) and not exists (select 1 from files f where f.storage_key = b.storage_key)
+	returning ` + strings.Join(blobColumns, ", ")
+	return repo.queryBlobs(ctx, query, before, limit)
+}
+
+// DeleteUnusedBlobs удаляет строки blob'ов из storageKeys, на которые не ссылается ни один файл, без ожидания сборщика.
+// Вызывается в транзакции: содержимое удаляется из хранилища до коммита, при ошибке строки возвращаются
+func (repo *Repository) DeleteUnusedBlobs(ctx context.Context, storageKeys []string) ([]*entity.Blob, error) {
+	query := `delete from blobs b
+	where b.storage_key = any($1)
+		and not exists (select 1 from files f where f.storage_key = b.storage_key)
+	returning ` + strings.Join(blobColumns, ", ")
+	return repo.queryBlobs(ctx, query, storageKeys)
+}
+
+var blobColumns = []string{"b.storage_key", "b.hash", "b.size", "b.content_type", "b.created_at", "b.orphaned_at"}
+
+func (repo *Repository) queryBlobs(ctx context.Context, query string, args ...any) ([]*entity.Blob, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var blobs []*entity.Blob
+	for rows.Next() {
+		var blob entity.Blob
+		var orphanedAt *time.Time
+		if err := rows.Scan(&blob.StorageKey, &blob.Hash, &blob.Size, &blob.ContentType, &blob.CreatedAt, &orphanedAt); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		if orphanedAt != nil {
+			blob.OrphanedAt = *orphanedAt
+		}
+		blobs = append(blobs, &blob)
+	}
+	return blobs, rows.Err()
+}
+
+// GetStorageUsage размер оригиналов пользователя по назначениям
+func (repo *Repository) GetStorageUsage(ctx context.Context, ownerId uuid.UUID) ([]entity.StorageUsage, error) {
+	query, args, err := squirrel.Select("purpose", "count(*)", "coalesce(sum(size), 0)").
+		From("files").
+		Where(squirrel.Eq{"owner_id": ownerId, "parent_name": nil}).
+		GroupBy("purpose").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var usage []entity.StorageUsage
+	for rows.Next() {
+		var part entity.StorageUsage
+		var purpose string
+		if err := rows.Scan(&purpose, &part.Files, &part.Bytes); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		part.Purpose = enum.FilePurposeFromString(purpose)
+		usage = append(usage, part)
+	}
+	return usage, rows.Err()
+}
+
+// GetStorageQuota индивидуальный лимит пользователя, false если его нет
+func (repo *Repository) GetStorageQuota(ctx context.Context, userId uuid.UUID) (int64, bool, error) {
+	query, args, err := squirrel.Select("quota").
+		From("storage_quotas").
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, false, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	var quota int64
+	err = repo.conn.QueryRow(ctx, query, args...).Scan(&quota)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return quota, true, nil
+}
+
+func (repo *Repository) SetStorageQuota(ctx context.Context, userId uuid.UUID, quota int64) error {
+	query, args, err := squirrel.Insert("storage_quotas").
+		Columns("user_id", "quota").
+		Values(userId, quota).
+		Suffix("on conflict (user_id) do update set quota = excluded.quota, updated_at = now()").
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func (repo *Repository) DeleteStorageQuota(ctx context.Context, userId uuid.UUID) error {
+	query, args, err := squirrel.Delete("storage_quotas").
+		Where(squirrel.Eq{"user_id": userId}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+// LockOwner блокировка загрузок пользователя до конца транзакции
+func (repo *Repository) LockOwner(ctx context.Context, ownerId uuid.UUID) error {
+	_, err := repo.conn.Exec(ctx, "select pg_advisory_xact_lock(hashtextextended($1::text, 0))", ownerId)
+	return err
+}
+
+// GetOwnerFiles оригиналы пользователя, новые первыми
+func (repo *Repository) GetOwnerFiles(ctx context.Context, ownerId uuid.UUID, offset, limit int) ([]*entity.File, error) {
+	query, args, err := squirrel.Select(fileColumns...).
+		From("files").
+		Where(squirrel.Eq{"owner_id": ownerId, "parent_name": nil}).
+		Where(squirrel.NotEq{"storage_key": nil}).
+		OrderBy("created_at desc", "file_name").
+		Offset(uint64(offset)).
+		Limit(uint64(limit)).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+	return repo.queryFiles(ctx, query, args...)
+}
+
+func (repo *Repository) CountOwnerFiles(ctx context.Context, ownerId uuid.UUID) (int, error) {
+	query, args, err := squirrel.Select("count(*)").
+		From("files").
+		Where(squirrel.Eq{"owner_id": ownerId, "parent_name": nil}).
+		Where(squirrel.NotEq{"storage_key": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	var count int
+	if err := repo.conn.QueryRow(ctx, query, args...).Scan(&count); err != nil {
+		return 0, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return count, nil
+}
+
+// GetFileReferences аватарки и заметки, которые ссылаются на файлы. Прикрепление хранится в самом файле
+func (repo *Repository) GetFileReferences(ctx context.Context, names []string) (map[string]*entity.FileReferences, error) {
+	query := `select f.file_name, null::uuid
+	from files f
+	where f.file_name = any($1) and exists (select 1 from users u where u.img_url = f.file_name)
+	union all
+	select f.file_name, r.note_id
+	from files f
+	join file_refs r on r.file_key = split_part(f.file_name, '.', 1)
+	where f.file_name = any($1)`
+	rows, err := repo.conn.Query(ctx, query, names)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	refs := make(map[string]*entity.FileReferences, len(names))
+	for _, name := range names {
+		refs[name] = &entity.FileReferences{}
+	}
+	for rows.Next() {
+		var name string
+		var noteId *uuid.UUID
+		if err := rows.Scan(&name, &noteId); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		if noteId == nil {
+			refs[name].Avatar = true
+			continue
+		}
+		refs[name].Notes = append(refs[name].Notes, *noteId)
+	}
+	return refs, rows.Err()
+}
+
+// DeleteFile удаляет файл, миниатюры удаляются каскадом
+func (repo *Repository) DeleteFile(ctx context.Context, name string) error {
+	query, args, err := squirrel.Delete("files").
+		Where(squirrel.Eq{"file_name": name}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
 }
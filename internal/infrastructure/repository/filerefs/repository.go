@@ -0,0 +1,89 @@
+package filerefs
+
+import (
+	"context"
+	"wn/internal/entity"
+	"wn/pkg/database/postgres"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+// SetNoteFileRefs заменяет ссылки заметки на файлы. Заметка убирается из очереди индексации первой,
+// так запись дожидается воркера, который индексирует эту же заметку
+func (repo *Repository) SetNoteFileRefs(ctx context.Context, noteId uuid.UUID, keys []string) error {
+	query := `
+		delete from file_refs_pending
+		where note_id = $1
+	`
+	if _, err := repo.conn.Exec(ctx, query, noteId); err != nil {
+		return errors.Wrap(err, "repo.conn.Exec pending")
+	}
+	query = `
+		delete from file_refs
+		where note_id = $1
+	`
+	if _, err := repo.conn.Exec(ctx, query, noteId); err != nil {
+		return errors.Wrap(err, "repo.conn.Exec delete")
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	query = `
+		insert into file_refs(note_id, file_key)
+		select $1, unnest($2::varchar[])
+		on conflict do nothing
+	`
+	_, err := repo.conn.Exec(ctx, query, noteId, keys)
+	return err
+}
+
+// GetPendingNotes заметки, ссылки которых еще не собраны. payload и draft зашифрованы
+func (repo *Repository) GetPendingNotes(ctx context.Context, limit uint64) ([]entity.Note, error) {
+	query := `
+		select n.id, coalesce(n.payload, ''), coalesce(n.draft, '')
+		from file_refs_pending p
+		join notes n on n.id = p.note_id
+		order by p.note_id
+		limit $1
+	`
+	rows, err := repo.conn.Query(ctx, query, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var notes []entity.Note
+	for rows.Next() {
+		var n entity.Note
+		if err := rows.Scan(&n.Id, &n.Payload, &n.Draft); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// LockPendingNote блокирует заметку в очереди до конца транзакции, false если ее уже проиндексировали
+func (repo *Repository) LockPendingNote(ctx context.Context, noteId uuid.UUID) (bool, error) {
+	query := `
+		select note_id
+		from file_refs_pending
+		where note_id = $1
+		for update
+	`
+	rows, err := repo.conn.Query(ctx, query, noteId)
+	if err != nil {
+		return false, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	locked := rows.Next()
+	return locked, rows.Err()
+}
@@ -0,0 +1,136 @@
+package journal
+
+import (
+	"context"
+	"time"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/database/postgres"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (repo *Repository) GetJournal(ctx context.Context, userId uuid.UUID) (*entity.Journal, error) {
+	query := `
+		select user_id, layout_id, title_format, template_id, started_on
+		from journals
+		where user_id = $1
+	`
+	var item entity.Journal
+	err := repo.conn.QueryRow(ctx, query, userId).Scan(
+		&item.UserId,
+		&item.LayoutId,
+		&item.TitleFormat,
+		&item.TemplateId,
+		&item.StartedOn,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.JournalNotConfigured
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "scan")
+	}
+	return &item, nil
+}
+
+// SetJournal создает или меняет настройки. StartedOn сохраняется, пока журнал остается в том же лейауте
+func (repo *Repository) SetJournal(ctx context.Context, item *entity.Journal) error {
+	query := `
+		insert into journals(user_id, layout_id, title_format, template_id, started_on)
+		values ($1, $2, $3, $4, $5)
+		on conflict (user_id) do update set
+			layout_id = excluded.layout_id,
+			title_format = excluded.title_format,
+			template_id = excluded.template_id,
+			started_on = case
+				when journals.layout_id = excluded.layout_id then journals.started_on
+				else excluded.started_on
+			end
+	`
+	_, err := repo.conn.Exec(ctx, query, item.UserId, item.LayoutId, item.TitleFormat, item.TemplateId, item.StartedOn)
+	return err
+}
+
+// CreateEntry false, если за этот день в лейауте запись уже есть
+func (repo *Repository) CreateEntry(ctx context.Context, item *entity.JournalEntry) (bool, error) {
+	query := `
+		insert into journal_entries(layout_id, day, note_id)
+		values ($1, $2, $3)
+		on conflict do nothing
+	`
+	tag, err := repo.conn.Exec(ctx, query, item.LayoutId, item.Day, item.NoteId)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// GetEntry запись за день, RecordNotFound если ее нет
+func (repo *Repository) GetEntry(ctx context.Context, layoutId uuid.UUID, day time.Time) (*entity.JournalEntry, error) {
+	return repo.getEntry(ctx, `
+		select layout_id, day, note_id
+		from journal_entries
+		where layout_id = $1 and day = $2
+	`, layoutId, day)
+}
+
+// GetPreviousEntry ближайшая запись раньше дня day, RecordNotFound если ее нет
+func (repo *Repository) GetPreviousEntry(ctx context.Context, layoutId uuid.UUID, day time.Time) (*entity.JournalEntry, error) {
+	return repo.getEntry(ctx, `
+		select layout_id, day, note_id
+		from journal_entries
+		where layout_id = $1 and day < $2
+		order by day desc
+		limit 1
+	`, layoutId, day)
+}
+
+func (repo *Repository) getEntry(ctx context.Context, query string, args ...any) (*entity.JournalEntry, error) {
+	var item entity.JournalEntry
+	err := repo.conn.QueryRow(ctx, query, args...).Scan(&item.LayoutId, &item.Day, &item.NoteId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.RecordNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "scan")
+	}
+	return &item, nil
+}
+
+// GetEntries записи лейаута с from включительно до to не включая, по возрастанию дня
+func (repo *Repository) GetEntries(ctx context.Context, layoutId uuid.UUID, from, to time.Time) ([]entity.JournalEntry, error) {
+	query := `
+		select layout_id, day, note_id
+		from journal_entries
+		where layout_id = $1 and day >= $2 and day < $3
+		order by day
+	`
+	rows, err := repo.conn.Query(ctx, query, layoutId, from, to)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var items []entity.JournalEntry
+	for rows.Next() {
+		var item entity.JournalEntry
+		if err := rows.Scan(&item.LayoutId, &item.Day, &item.NoteId); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return items, nil
+}
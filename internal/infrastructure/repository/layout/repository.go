@@ -2,6 +2,7 @@ package layout
 
 import (
 	"context"
+	"wn/internal/domain/dto"
 	"wn/internal/entity"
 	apperrors "wn/internal/errors"
 	"wn/internal/infrastructure/repository/common"
@@ -73,6 +74,7 @@ func (repo *Repository) GetAvailableLayouts(ctx context.Context, userId uuid.UUI
 			&layout.HaveAccess,
 			&layout.IsMain,
 			&layout.Color,
+			&layout.Version,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
@@ -87,27 +89,50 @@ func (repo *Repository) GetAvailableLayouts(ctx context.Context, userId uuid.UUI
 	return layouts, nil
 }
 
-func (repo *Repository) UpdateLayout(ctx context.Context, userId, layoutId uuid.UUID, color, title string) (int, error) {
-	builder := squirrel.Update("layouts ").
+// UpdateLayout меняет только поля из params и увеличивает version, возвращает новую версию
+func (repo *Repository) UpdateLayout(ctx context.Context, layoutId uuid.UUID, params *dto.UpdateLayoutParams) (int64, error) {
+	builder := squirrel.Update("layouts").
+		Set("version", squirrel.Expr("version + 1")).
 		Where(squirrel.Eq{"id": layoutId}).
+		Suffix("returning version").
 		PlaceholderFormat(squirrel.Dollar)
 
-	if color != "" {
-		builder = builder.Set("color", color)
+	if params.Color != nil {
+		builder = builder.Set("color", *params.Color)
 	}
-	if title != "" {
-		builder = builder.Set("title", title)
+	if params.Title != nil {
+		builder = builder.Set("title", *params.Title)
+	}
+	if params.Version != nil {
+		builder = builder.Where(squirrel.Eq{"version": *params.Version})
 	}
 
 	sql, args, err := builder.ToSql()
 	if err != nil {
 		return 0, errors.Wrap(err, "builder.ToSql")
 	}
-	res, err := repo.conn.Exec(ctx, sql, args...)
+
+	var version int64
+	err = repo.conn.QueryRow(ctx, sql, args...).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repo.versionMismatch(ctx, layoutId)
+		}
+		return 0, errors.Wrap(err, "scan")
+	}
+	return version, nil
+}
+
+func (repo *Repository) versionMismatch(ctx context.Context, layoutId uuid.UUID) error {
+	var current int64
+	err := repo.conn.QueryRow(ctx, `select version from layouts where id = $1`, layoutId).Scan(&current)
 	if err != nil {
-		return 0, errors.Wrap(err, "repo.conn.Exec")
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperrors.LayoutNotFound
+		}
+		return errors.Wrap(err, "scan")
 	}
-	return int(res.RowsAffected()), nil
+	return apperrors.VersionConflict.WithData(dto.VersionConflict{CurrentVersion: current})
 }
 
 func (repo *Repository) GetByOwnerId(ctx context.Context, ownerId, layoutId uuid.UUID) (*entity.Layout, error) {
@@ -130,6 +155,7 @@ func (repo *Repository) GetByOwnerId(ctx context.Context, ownerId, layoutId uuid
 		&item.HaveAccess,
 		&item.IsMain,
 		&item.Color,
+		&item.Version,
 	)
 
 	if err != nil {
@@ -161,6 +187,7 @@ func (repo *Repository) GetById(ctx context.Context, layoutId uuid.UUID) (*entit
 		&item.HaveAccess,
 		&item.IsMain,
 		&item.Color,
+		&item.Version,
 	)
 
 	if err != nil {
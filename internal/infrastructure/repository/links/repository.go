@@ -17,10 +17,12 @@ func NewRepository(conn postgres.Connection) *Repository {
 	return &Repository{conn: conn}
 }
 
+// LinkNotes ручная связь. Связь из wiki-ссылки становится ручной и больше не пересобирается
 func (repo *Repository) LinkNotes(ctx context.Context, firstNoteId, secondNoteId uuid.UUID) error {
 	query := `
-		insert into links
+		insert into links (first_note_id, second_note_id)
 		values ($1, $2)
+		on conflict (first_note_id, second_note_id) do update set auto = false
 	`
 	_, err := repo.conn.Exec(ctx, query, firstNoteId, secondNoteId)
 	return err
@@ -50,11 +52,25 @@ func (repo *Repository) DeleteLinksByLayoutId(ctx context.Context, layoutId uuid
 
 func (repo *Repository) GetAllLinks(ctx context.Context, noteIds []uuid.UUID) ([]entity.Link, error) {
 	query := `
-		select first_note_id, second_note_id
+		select first_note_id, second_note_id, auto
 		from links
 		where first_note_id = ANY($1) or second_note_id = ANY($1)
 	`
-	rows, err := repo.conn.Query(ctx, query, noteIds)
+	return repo.getLinks(ctx, query, noteIds)
+}
+
+// GetBacklinks связи, которые ведут в заметку
+func (repo *Repository) GetBacklinks(ctx context.Context, noteId uuid.UUID) ([]entity.Link, error) {
+	query := `
+		select first_note_id, second_note_id, auto
+		from links
+		where second_note_id = $1
+	`
+	return repo.getLinks(ctx, query, noteId)
+}
+
+func (repo *Repository) getLinks(ctx context.Context, query string, args ...any) ([]entity.Link, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "repo.conn.Query")
 	}
@@ -66,6 +82,7 @@ func (repo *Repository) GetAllLinks(ctx context.Context, noteIds []uuid.UUID) ([
 		err := rows.Scan(
 			&link.FirstNoteId,
 			&link.SecondNoteId,
+			&link.Auto,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
@@ -87,3 +104,50 @@ func (repo *Repository) DeleteLink(ctx context.Context, noteId1, noteId2 uuid.UU
 	_, err := repo.conn.Exec(ctx, query, noteId1, noteId2)
 	return err
 }
+
+// SetAutoLinks заменяет связи заметки из wiki-ссылок на targets. Ручные связи остаются как есть.
+// Возвращает заметки, связи с которыми появились и пропали
+func (repo *Repository) SetAutoLinks(ctx context.Context, noteId uuid.UUID, targets []uuid.UUID) ([]uuid.UUID, []uuid.UUID, error) {
+	if targets == nil {
+		targets = []uuid.UUID{}
+	}
+	removed, err := repo.collectIds(ctx, `
+		delete from links
+		where first_note_id = $1 and auto and not second_note_id = any($2)
+		returning second_note_id
+	`, noteId, targets)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "delete")
+	}
+	added, err := repo.collectIds(ctx, `
+		insert into links (first_note_id, second_note_id, auto)
+		select $1, t, true from unnest($2::uuid[]) t
+		on conflict do nothing
+		returning second_note_id
+	`, noteId, targets)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "insert")
+	}
+	return added, removed, nil
+}
+
+func (repo *Repository) collectIds(ctx context.Context, query string, args ...any) ([]uuid.UUID, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return ids, nil
+}
@@ -3,10 +3,13 @@ package note
 import (
 	"context"
 	"wn/internal/domain/dto"
+	"wn/internal/domain/enum"
 	"wn/internal/entity"
 	apperrors "wn/internal/errors"
 	"wn/internal/infrastructure/repository/common"
 	"wn/pkg/database/postgres"
+	"wn/pkg/textindex"
+	"wn/pkg/util"
 
 	sq "github.com/Masterminds/squirrel"
 	"github.com/google/uuid"
@@ -15,6 +18,25 @@ import (
 	"github.com/pkg/errors"
 )
 
+// noteColumns колонки заметки n в порядке noteFields
+const noteColumns = "n.id, n.title, n.payload, n.created_at, n.owner_id, n.have_access, n.layout_id, n.draft, n.version, n.updated_at"
+
+// noteFields куда сканировать noteColumns
+func noteFields(item *entity.Note) []any {
+	return []any{
+		&item.Id,
+		&item.Title,
+		&item.Payload,
+		&item.CreatedAt,
+		&item.OwnerId,
+		&item.HaveAccess,
+		&item.LayoutId,
+		&item.Draft,
+		&item.Version,
+		&item.UpdatedAt,
+	}
+}
+
 type Repository struct {
 	conn postgres.Connection
 }
@@ -33,6 +55,7 @@ func (repo *Repository) CreateNote(ctx context.Context, item *entity.Note) (uuid
 		"have_access",
 		"draft",
 		"layout_id",
+		"updated_at",
 	).Values(
 		item.Id,
 		item.Title,
@@ -42,7 +65,11 @@ func (repo *Repository) CreateNote(ctx context.Context, item *entity.Note) (uuid
 		item.HaveAccess,
 		item.Draft,
 		item.LayoutId,
+		item.CreatedAt,
 	).PlaceholderFormat(sq.Dollar).ToSql()
+	if err != nil {
+		return uuid.Nil, errors.Wrap(err, "builder.ToSql")
+	}
 
 	_, err = repo.conn.Exec(ctx, query, args...)
 	if err != nil {
@@ -51,7 +78,7 @@ func (repo *Repository) CreateNote(ctx context.Context, item *entity.Note) (uuid
 		}
 		return uuid.Nil, err
 	}
-	return item.Id, err
+	return item.Id, nil
 }
 
 func (repo *Repository) DeleteNoteById(ctx context.Context, noteId uuid.UUID) error {
@@ -82,65 +109,141 @@ func (repo *Repository) DeleteNotesByLayoutId(ctx context.Context, layoutId uuid
 	return nil
 }
 
-func (repo *Repository) UpdateNote(ctx context.Context, newItem *entity.Note) error {
-	query := `
-		UPDATE notes
-		SET 
-		title = $1,
-		payload = $2,
-		layout_id = $3,
-		draft = $4
-		WHERE id = $5
-	`
-	res, err := repo.conn.Exec(ctx, query, newItem.Title, newItem.Payload, newItem.LayoutId, newItem.Draft, newItem.Id)
+// UpdateNote меняет только поля из params, увеличивает version и обновляет updated_at, возвращает новую версию
+func (repo *Repository) UpdateNote(ctx context.Context, noteId uuid.UUID, params *dto.UpdateNoteParams) (int64, error) {
+	builder := sq.Update("notes").
+		Set("version", sq.Expr("version + 1")).
+		Set("updated_at", util.GetCurrentUTCTime()).
+		Where(sq.Eq{"id": noteId}).
+		Suffix("returning version").
+		PlaceholderFormat(sq.Dollar)
+
+	if params.Title != nil {
+		builder = builder.Set("title", *params.Title)
+	}
+	if params.Payload != nil {
+		builder = builder.Set("payload", *params.Payload)
+	}
+	if params.LayoutId != nil {
+		builder = builder.Set("layout_id", *params.LayoutId)
+	}
+	if params.Version != nil {
+		builder = builder.Where(sq.Eq{"version": *params.Version})
+	}
+
+	query, args, err := builder.ToSql()
 	if err != nil {
-		return err
+		return 0, errors.Wrap(err, "builder.ToSql")
 	}
-	if res.RowsAffected() == 0 {
-		return apperrors.NoteNotFound
+
+	var version int64
+	err = repo.conn.QueryRow(ctx, query, args...).Scan(&version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repo.versionMismatch(ctx, noteId)
+		}
+		return 0, errors.Wrap(err, "scan")
 	}
-	return nil
+	return version, nil
 }
 
-func (repo *Repository) GetNoteCountInLayout(ctx context.Context, layoutId uuid.UUID) (int, error) {
-	query := `
-		select count(*) from notes where layout_id = $1
-	`
+// versionMismatch объясняет, почему update не затронул строк
+func (repo *Repository) versionMismatch(ctx context.Context, noteId uuid.UUID) error {
+	var current int64
+	err := repo.conn.QueryRow(ctx, `select version from notes where id = $1`, noteId).Scan(&current)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return apperrors.NoteNotFound
+		}
+		return errors.Wrap(err, "scan")
+	}
+	return apperrors.VersionConflict.WithData(dto.VersionConflict{CurrentVersion: current})
+}
+
+// tagCondition отбирает заметки по меткам пользователя из фильтра
+func tagCondition(filter *dto.TagFilter) sq.Sqlizer {
+	const tagged = `select 1 from note_tags nt
+		join tags t on t.id = nt.tag_id
+		where nt.note_id = n.id and t.owner_id = ? and lower(t.name) = any(?)`
+	if filter.MatchAll {
+		// имена уникальны у пользователя, поэтому совпавших строк столько же, сколько имен
+		return sq.Expr(`(select count(*) from (`+tagged+`) m) = ?`, filter.UserId, filter.Names, len(filter.Names))
+	}
+	return sq.Expr(`exists (`+tagged+`)`, filter.UserId, filter.Names)
+}
+
+// GetNoteCountInLayout filter может быть nil
+func (repo *Repository) GetNoteCountInLayout(ctx context.Context, layoutId uuid.UUID, filter *dto.TagFilter) (int, error) {
+	builder := sq.Select("count(*)").
+		From("notes n").
+		Where(sq.Eq{"n.layout_id": layoutId}).
+		PlaceholderFormat(sq.Dollar)
+	if filter != nil {
+		builder = builder.Where(tagCondition(filter))
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "builder.ToSql")
+	}
 	var n int
-	err := repo.conn.QueryRow(ctx, query, layoutId).Scan(&n)
+	err = repo.conn.QueryRow(ctx, query, args...).Scan(&n)
 	return n, err
 }
 
-func (repo *Repository) UpdateDraftById(ctx context.Context, noteId uuid.UUID, newDraft string) error {
+// UpdateDraftById меняет черновик и увеличивает version, version nil - без проверки версии
+func (repo *Repository) UpdateDraftById(ctx context.Context, noteId uuid.UUID, newDraft string, version *int64) (int64, error) {
 	query := `
-		update notes 
-		set draft = $1
-		where id = $2
+		update notes
+		set draft = $1, version = version + 1
+		where id = $2 and ($3::bigint is null or version = $3)
+		returning version
 	`
-	_, err := repo.conn.Exec(ctx, query, newDraft, noteId)
-	return err
+	var newVersion int64
+	err := repo.conn.QueryRow(ctx, query, newDraft, noteId, version).Scan(&newVersion)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repo.versionMismatch(ctx, noteId)
+		}
+		return 0, errors.Wrap(err, "scan")
+	}
+	return newVersion, nil
 }
 
-func (repo *Repository) CommitDraft(ctx context.Context, noteId uuid.UUID) error {
+// CommitDraft заменяет текст черновиком и увеличивает version, version nil - без проверки версии
+func (repo *Repository) CommitDraft(ctx context.Context, noteId uuid.UUID, version *int64) (int64, error) {
 	query := `
-		update notes 
-		set payload = draft, draft = ''
-		where id = $1
+		update notes
+		set payload = draft, draft = '', version = version + 1, updated_at = $3
+		where id = $1 and ($2::bigint is null or version = $2)
+		returning version
 	`
-	_, err := repo.conn.Exec(ctx, query, noteId)
-	return err
+	var newVersion int64
+	err := repo.conn.QueryRow(ctx, query, noteId, version, util.GetCurrentUTCTime()).Scan(&newVersion)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repo.versionMismatch(ctx, noteId)
+		}
+		return 0, errors.Wrap(err, "scan")
+	}
+	return newVersion, nil
 }
 
 // todo check access to layout
-func (repo *Repository) GetNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID, offset, limit int) ([]entity.Note, error) {
-	query := `
-		select n.* from notes n
-		where n.layout_id = $1
-		order by created_at desc, layout_id 
-		offset $2
-		limit $3
-	`
-	rows, err := repo.conn.Query(ctx, query, layoutId, offset, limit)
+func (repo *Repository) GetNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID, offset, limit int, filter *dto.TagFilter) ([]entity.Note, error) {
+	builder := sq.Select(noteColumns).
+		From("notes n").
+		Where(sq.Eq{"n.layout_id": layoutId}).
+		OrderBy("created_at desc", "layout_id").
+		Suffix("offset ? limit ?", offset, limit).
+		PlaceholderFormat(sq.Dollar)
+	if filter != nil {
+		builder = builder.Where(tagCondition(filter))
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "builder.ToSql")
+	}
+	rows, err := repo.conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "repo.conn.Query")
 	}
@@ -149,16 +252,7 @@ func (repo *Repository) GetNotesByLayoutId(ctx context.Context, layoutId, userId
 	var notes []entity.Note
 	for rows.Next() {
 		var item entity.Note
-		err := rows.Scan(
-			&item.Id,
-			&item.Title,
-			&item.Payload,
-			&item.CreatedAt,
-			&item.OwnerId,
-			&item.HaveAccess,
-			&item.LayoutId,
-			&item.Draft,
-		)
+		err := rows.Scan(noteFields(&item)...)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
 		}
@@ -173,7 +267,7 @@ func (repo *Repository) GetNotesByLayoutId(ctx context.Context, layoutId, userId
 
 func (repo *Repository) GetNotesWithoutPosition(ctx context.Context, layoutId, userId uuid.UUID) ([]entity.Note, error) {
 	query := `
-		select n.* from notes n
+		select ` + noteColumns + ` from notes n
 		join positions p on p.note_id = n.id
 		where n.layout_id = $1 and x_position is null and y_position is null
 	`
@@ -186,16 +280,7 @@ func (repo *Repository) GetNotesWithoutPosition(ctx context.Context, layoutId, u
 	var notes []entity.Note
 	for rows.Next() {
 		var item entity.Note
-		err := rows.Scan(
-			&item.Id,
-			&item.Title,
-			&item.Payload,
-			&item.CreatedAt,
-			&item.OwnerId,
-			&item.HaveAccess,
-			&item.LayoutId,
-			&item.Draft,
-		)
+		err := rows.Scan(noteFields(&item)...)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
 		}
@@ -210,7 +295,7 @@ func (repo *Repository) GetNotesWithoutPosition(ctx context.Context, layoutId, u
 
 func (repo *Repository) GetNotesWithPosition(ctx context.Context, userId uuid.UUID, layoutIds []uuid.UUID) ([]entity.NoteWithPosition, error) {
 	builder := sq.Select(
-		"n.*",
+		noteColumns,
 		"p.note_id",
 		"p.x_position",
 		"p.y_position",
@@ -234,19 +319,11 @@ func (repo *Repository) GetNotesWithPosition(ctx context.Context, userId uuid.UU
 	var notes []entity.NoteWithPosition
 	for rows.Next() {
 		var item entity.NoteWithPosition
-		err := rows.Scan(
-			&item.Id,
-			&item.Title,
-			&item.Payload,
-			&item.CreatedAt,
-			&item.OwnerId,
-			&item.HaveAccess,
-			&item.LayoutId,
-			&item.Draft,
+		err := rows.Scan(append(noteFields(&item.Note),
 			&item.NoteId,
 			&item.XPosition,
 			&item.YPosition,
-		)
+		)...)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
 		}
@@ -259,18 +336,27 @@ func (repo *Repository) GetNotesWithPosition(ctx context.Context, userId uuid.UU
 	return notes, nil
 }
 
-func (repo *Repository) GetFullNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID) ([]dto.Note, error) {
-	query := `
+const fullNotesQuery = `
     select 
-    n.id, n.title, n.payload, n.owner_id, n.have_access, n.layout_id, n.draft,
+    n.id, n.title, n.payload, n.owner_id, n.have_access, n.layout_id, n.draft, n.version,
     p.x_position, p.y_position,
     COALESCE(array((select second_note_id from links l where l.first_note_id = n.id)), '{}'),
     COALESCE(array((select first_note_id from links l where l.second_note_id = n.id)), '{}')
     from notes n
     left join positions p on p.note_id = n.id
-    where n.layout_id = $1
     `
-	rows, err := repo.conn.Query(ctx, query, layoutId)
+
+func (repo *Repository) GetFullNotesByLayoutId(ctx context.Context, layoutId, userId uuid.UUID) ([]dto.Note, error) {
+	return repo.getFullNotes(ctx, fullNotesQuery+"where n.layout_id = $1", layoutId)
+}
+
+// GetFullNotesByIds заметки вместе с позициями и связями, payload и draft зашифрованы
+func (repo *Repository) GetFullNotesByIds(ctx context.Context, noteIds []uuid.UUID) ([]dto.Note, error) {
+	return repo.getFullNotes(ctx, fullNotesQuery+"where n.id = any($1)", noteIds)
+}
+
+func (repo *Repository) getFullNotes(ctx context.Context, query string, args ...any) ([]dto.Note, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "repo.conn.Query")
 	}
@@ -292,6 +378,7 @@ func (repo *Repository) GetFullNotesByLayoutId(ctx context.Context, layoutId, us
 			&item.HaveAccess,
 			&item.LayoutId,
 			&item.Draft,
+			&item.Version,
 			&xPos, // Сканируем во временную переменную
 			&yPos, // Сканируем во временную переменную
 			&in,
@@ -338,13 +425,169 @@ func (repo *Repository) DeleteLinkNotes(ctx context.Context, layoutId, firstNote
 	return err
 }
 
-func (repo *Repository) SearchNotes(ctx context.Context, userId uuid.UUID, search string) ([]entity.Note, error) {
-	query := `
-		select n.* from notes n
-		where $1 = ANY(n.have_access) or $1 = any(select to_user_id from permissions p where p.target_id = n.id)
-			AND (n.title ilike '%' || $2 || '%' or n.payload ilike '%' || $2 || '%')
-	`
-	rows, err := repo.conn.Query(ctx, query, userId, search)
+// searchRank заметки, где нашлись все слова запроса, с рангом. Слово засчитывается по лучшему совпадению:
+// по основе (с ExactBoost) или по префиксу, частые слова не перевешивают редкие за счет логарифма.
+// Ранг округляется, чтобы на следующей странице курсор сравнивался с тем же значением
+func searchRank(terms []textindex.Term) sq.Sqlizer {
+	ids := make([]int32, 0, len(terms)*2)
+	tokens := make([][]byte, 0, len(terms)*2)
+	boosts := make([]float64, 0, len(terms)*2)
+	for i, term := range terms {
+		ids = append(ids, int32(i))
+		tokens = append(tokens, term.Exact)
+		boosts = append(boosts, textindex.ExactBoost)
+		if term.Prefix != nil {
+			ids = append(ids, int32(i))
+			tokens = append(tokens, term.Prefix)
+			boosts = append(boosts, 1)
+		}
+	}
+	return sq.Expr(`join (
+		select h.note_id, round(sum(h.score)::numeric, 6) as rank
+		from (
+			select st.note_id, q.term, max(ln(1 + st.weight) * q.boost) as score
+			from unnest(?::int4[], ?::bytea[], ?::float8[]) as q(term, token, boost)
+			join note_search_tokens st on st.token = q.token
+			group by st.note_id, q.term
+		) h
+		group by h.note_id
+		having count(*) = ?
+	) r on r.note_id = n.id`, ids, tokens, boosts, len(terms))
+}
+
+// searchFilter заметки, которые пользователь может читать и которые подходят под запрос и фильтры.
+// Пустой запрос не фильтрует по тексту
+func searchFilter(builder sq.SelectBuilder, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) sq.SelectBuilder {
+	builder = builder.From("notes n").
+		Join("layouts l on l.id = n.layout_id").
+		Where(common.ReadableNotes(userId)).
+		PlaceholderFormat(sq.Dollar)
+	if len(terms) > 0 {
+		builder = builder.JoinClause(searchRank(terms))
+	}
+	if len(params.LayoutIds) > 0 {
+		builder = builder.Where("n.layout_id = any(?)", params.LayoutIds)
+	}
+	if params.OwnerId != nil {
+		builder = builder.Where(sq.Eq{"n.owner_id": *params.OwnerId})
+	}
+	if params.CreatedFrom != nil {
+		builder = builder.Where(sq.GtOrEq{"n.created_at": *params.CreatedFrom})
+	}
+	if params.CreatedTo != nil {
+		builder = builder.Where(sq.Lt{"n.created_at": *params.CreatedTo})
+	}
+	if params.UpdatedFrom != nil {
+		builder = builder.Where(sq.GtOrEq{"n.updated_at": *params.UpdatedFrom})
+	}
+	if params.UpdatedTo != nil {
+		builder = builder.Where(sq.Lt{"n.updated_at": *params.UpdatedTo})
+	}
+	if params.HasDraft != nil {
+		builder = builder.Where(sq.Expr("(coalesce(n.draft, '') <> '') = ?", *params.HasDraft))
+	}
+	if params.LinkedTo != nil {
+		builder = builder.Where(sq.Expr(`exists (
+			select 1 from links lk
+			where (lk.first_note_id = n.id and lk.second_note_id = ?)
+				or (lk.second_note_id = n.id and lk.first_note_id = ?)
+		)`, *params.LinkedTo, *params.LinkedTo))
+	}
+	if params.Tags != nil {
+		builder = builder.Where(tagCondition(params.Tags))
+	}
+	return builder
+}
+
+// searchOrder порядок и условие "после курсора" для сортировки. Без слов в запросе ранг у всех 0
+func searchOrder(builder sq.SelectBuilder, rank string, params *dto.NoteSearch) sq.SelectBuilder {
+	after := params.After
+	switch params.Sort {
+	case enum.NoteSortCreated:
+		if after != nil {
+			builder = builder.Where("(n.created_at, n.id) < (?, ?)", after.Time, after.Id)
+		}
+		return builder.OrderBy("n.created_at desc", "n.id desc")
+	case enum.NoteSortUpdated:
+		if after != nil {
+			builder = builder.Where("(n.updated_at, n.id) < (?, ?)", after.Time, after.Id)
+		}
+		return builder.OrderBy("n.updated_at desc", "n.id desc")
+	default:
+		if after != nil {
+			builder = builder.Where("("+rank+", n.created_at, n.id) < (?::numeric, ?, ?)", after.Rank, after.Time, after.Id)
+		}
+		return builder.OrderBy(rank+" desc", "n.created_at desc", "n.id desc")
+	}
+}
+
+// SearchNotes страница поиска, params.Limit заметок после params.After
+func (repo *Repository) SearchNotes(ctx context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) ([]entity.FoundNote, error) {
+	rank := "0::numeric"
+	if len(terms) > 0 {
+		rank = "r.rank"
+	}
+	builder := searchFilter(sq.Select(noteColumns, rank+"::text"), userId, terms, params)
+	builder = searchOrder(builder, rank, params).Limit(params.Limit)
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "builder.ToSql")
+	}
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var notes []entity.FoundNote
+	for rows.Next() {
+		var item entity.FoundNote
+		err := rows.Scan(append(noteFields(&item.Note), &item.Rank)...)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		notes = append(notes, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return notes, nil
+}
+
+// CountSearchNotes сколько всего заметок подходит под запрос, без учета курсора
+func (repo *Repository) CountSearchNotes(ctx context.Context, userId uuid.UUID, terms []textindex.Term, params *dto.NoteSearch) (int, error) {
+	query, args, err := searchFilter(sq.Select("count(*)"), userId, terms, params).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "builder.ToSql")
+	}
+	var n int
+	err = repo.conn.QueryRow(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// GetReadableNotes заметки с данными id или названиями (без учета регистра), которые пользователь может читать
+func (repo *Repository) GetReadableNotes(ctx context.Context, userId uuid.UUID, ids []uuid.UUID, titles []string) ([]entity.Note, error) {
+	builder := sq.Select(noteColumns).
+		From("notes n").
+		Join("layouts l on l.id = n.layout_id").
+		Where(sq.Or{
+			sq.Expr("n.id = any(?)", ids),
+			sq.Expr("lower(n.title) = any(?)", titles),
+		}).
+		Where(common.ReadableNotes(userId)).
+		OrderBy("n.created_at").
+		PlaceholderFormat(sq.Dollar)
+	return repo.selectNotes(ctx, builder)
+}
+
+func (repo *Repository) selectNotes(ctx context.Context, builder sq.SelectBuilder) ([]entity.Note, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "builder.ToSql")
+	}
+	rows, err := repo.conn.Query(ctx, query, args...)
 	if err != nil {
 		return nil, errors.Wrap(err, "repo.conn.Query")
 	}
@@ -353,16 +596,7 @@ func (repo *Repository) SearchNotes(ctx context.Context, userId uuid.UUID, searc
 	var notes []entity.Note
 	for rows.Next() {
 		var item entity.Note
-		err := rows.Scan(
-			&item.Id,
-			&item.Title,
-			&item.Payload,
-			&item.CreatedAt,
-			&item.OwnerId,
-			&item.HaveAccess,
-			&item.LayoutId,
-			&item.Draft,
-		)
+		err := rows.Scan(noteFields(&item)...)
 		if err != nil {
 			return nil, errors.Wrap(err, "rows.Scan")
 		}
@@ -377,7 +611,7 @@ func (repo *Repository) SearchNotes(ctx context.Context, userId uuid.UUID, searc
 
 func (repo *Repository) GetByOwnerId(ctx context.Context, ownerId, noteId uuid.UUID) (*entity.Note, error) {
 	sql, args, err := sq.
-		Select("n.*").
+		Select(noteColumns).
 		From("notes n").
 		Where(sq.Eq{"owner_id": ownerId}).
 		Where(sq.Eq{"id": noteId}).
@@ -388,16 +622,7 @@ func (repo *Repository) GetByOwnerId(ctx context.Context, ownerId, noteId uuid.U
 	}
 
 	var item entity.Note
-	err = repo.conn.QueryRow(ctx, sql, args...).Scan(
-		&item.Id,
-		&item.Title,
-		&item.Payload,
-		&item.CreatedAt,
-		&item.OwnerId,
-		&item.HaveAccess,
-		&item.LayoutId,
-		&item.Draft,
-	)
+	err = repo.conn.QueryRow(ctx, sql, args...).Scan(noteFields(&item)...)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -411,7 +636,7 @@ func (repo *Repository) GetByOwnerId(ctx context.Context, ownerId, noteId uuid.U
 
 func (repo *Repository) GetById(ctx context.Context, noteId uuid.UUID) (*entity.Note, error) {
 	sql, args, err := sq.
-		Select("n.*").
+		Select(noteColumns).
 		From("notes n").
 		Where(sq.Eq{"id": noteId}).
 		PlaceholderFormat(sq.Dollar).
@@ -421,16 +646,7 @@ func (repo *Repository) GetById(ctx context.Context, noteId uuid.UUID) (*entity.
 	}
 
 	var item entity.Note
-	err = repo.conn.QueryRow(ctx, sql, args...).Scan(
-		&item.Id,
-		&item.Title,
-		&item.Payload,
-		&item.CreatedAt,
-		&item.OwnerId,
-		&item.HaveAccess,
-		&item.LayoutId,
-		&item.Draft,
-	)
+	err = repo.conn.QueryRow(ctx, sql, args...).Scan(noteFields(&item)...)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
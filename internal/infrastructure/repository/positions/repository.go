@@ -2,7 +2,9 @@ package positions
 
 import (
 	"context"
+	"time"
 	"wn/pkg/database/postgres"
+	"wn/pkg/util"
 
 	"github.com/google/uuid"
 )
@@ -27,13 +29,29 @@ func (repo *Repository) CreateNotePosition(ctx context.Context, noteId uuid.UUID
 func (repo *Repository) UpdateNotePosition(ctx context.Context, noteId uuid.UUID, xPos, yPos *float64) error {
 	query := `
 		update positions p
-		set x_position = $1, y_position = $2
-		where p.note_id = $3
+		set x_position = $1, y_position = $2, updated_at = $3
+		where p.note_id = $4
 	`
-	_, err := repo.conn.Exec(ctx, query, xPos, yPos, noteId)
+	_, err := repo.conn.Exec(ctx, query, xPos, yPos, util.GetCurrentUTCTime(), noteId)
 	return err
 }
 
+// UpdateMovedNotePosition пишет позицию, полученную при перетаскивании в movedAt, если с тех пор
+// позицию никто не записал и заметку не унесли из лейаута. false, если запись устарела
+func (repo *Repository) UpdateMovedNotePosition(ctx context.Context, noteId, layoutId uuid.UUID, xPos, yPos *float64, movedAt time.Time) (bool, error) {
+	query := `
+		update positions p
+		set x_position = $1, y_position = $2, updated_at = $3
+		where p.note_id = $4 and p.updated_at <= $3
+			and exists (select 1 from notes n where n.id = p.note_id and n.layout_id = $5)
+	`
+	tag, err := repo.conn.Exec(ctx, query, xPos, yPos, movedAt.UTC(), noteId, layoutId)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
 func (repo *Repository) DeleteNotesPositionsByLayoutId(ctx context.Context, layoutId uuid.UUID) error {
 	query := `
 		delete from positions p
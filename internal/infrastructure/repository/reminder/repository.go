@@ -0,0 +1,168 @@
+package reminder
+
+import (
+	"context"
+	"time"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/infrastructure/repository/common"
+	"wn/pkg/database/postgres"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// reminderColumns колонки для queryReminders: напоминание, заметка, владелец и доступ владельца к заметке.
+// Нужны join notes n, layouts l и users u
+var reminderColumns = `
+	r.id, r.user_id, r.note_id, r.remind_at, coalesce(r.rrule, ''), r.timezone,
+	r.next_fire_at, r.last_fired_at, r.created_at,
+	coalesce(n.title, ''), n.layout_id, u.email, ` + common.ReadableNotesOf("r.user_id")
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (repo *Repository) CreateReminder(ctx context.Context, item *entity.Reminder) error {
+	query := `
+		insert into reminders(id, user_id, note_id, remind_at, rrule, timezone, next_fire_at, created_at)
+		values ($1, $2, $3, $4, nullif($5, ''), $6, $7, $8)
+	`
+	_, err := repo.conn.Exec(ctx, query,
+		item.Id, item.UserId, item.NoteId, item.RemindAt, item.RRule, item.Timezone, item.NextFireAt, item.CreatedAt)
+	return err
+}
+
+// DeleteReminder ReminderNotFound, если у пользователя нет такого напоминания
+func (repo *Repository) DeleteReminder(ctx context.Context, reminderId, userId uuid.UUID) error {
+	tag, err := repo.conn.Exec(ctx, `delete from reminders where id = $1 and user_id = $2`, reminderId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return apperrors.ReminderNotFound
+	}
+	return nil
+}
+
+// GetReminders напоминания пользователя по заметкам, которые он еще может читать, noteId nil - по всем заметкам.
+// Сначала ближайшие, отработавшие в конце
+func (repo *Repository) GetReminders(ctx context.Context, userId uuid.UUID, noteId *uuid.UUID) ([]entity.ReminderWithNote, error) {
+	query := `
+		select ` + reminderColumns + `
+		from reminders r
+		join notes n on n.id = r.note_id
+		join layouts l on l.id = n.layout_id
+		join users u on u.id = r.user_id
+		where r.user_id = $1 and ($2::uuid is null or r.note_id = $2) and ` + common.ReadableNotesOf("r.user_id") + `
+		order by r.next_fire_at nulls last, r.created_at
+	`
+	return repo.queryReminders(ctx, query, userId, noteId)
+}
+
+// ClaimDueReminders блокирует до конца транзакции напоминания, которые пора отправить.
+// Напоминания, уже взятые другой транзакцией, пропускаются, поэтому реплики не отправляют одно и то же.
+// Напоминания по заметкам, к которым у пользователя больше нет доступа, тоже берутся с Readable false
+func (repo *Repository) ClaimDueReminders(ctx context.Context, now time.Time, limit uint64) ([]entity.ReminderWithNote, error) {
+	query := `
+		select ` + reminderColumns + `
+		from reminders r
+		join notes n on n.id = r.note_id
+		join layouts l on l.id = n.layout_id
+		join users u on u.id = r.user_id
+		where r.next_fire_at <= $1
+		order by r.next_fire_at
+		limit $2
+		for update of r skip locked
+	`
+	return repo.queryReminders(ctx, query, now, limit)
+}
+
+// SetFired записывает срабатывание и следующее время, next nil - напоминание отработало
+func (repo *Repository) SetFired(ctx context.Context, reminderId uuid.UUID, firedAt time.Time, next *time.Time) error {
+	query := `
+		update reminders
+		set last_fired_at = $2, next_fire_at = $3
+		where id = $1
+	`
+	_, err := repo.conn.Exec(ctx, query, reminderId, firedAt, next)
+	return err
+}
+
+func (repo *Repository) queryReminders(ctx context.Context, query string, args ...any) ([]entity.ReminderWithNote, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var items []entity.ReminderWithNote
+	for rows.Next() {
+		var item entity.ReminderWithNote
+		err := rows.Scan(
+			&item.Id,
+			&item.UserId,
+			&item.NoteId,
+			&item.RemindAt,
+			&item.RRule,
+			&item.Timezone,
+			&item.NextFireAt,
+			&item.LastFiredAt,
+			&item.CreatedAt,
+			&item.NoteTitle,
+			&item.LayoutId,
+			&item.Email,
+			&item.Readable,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return items, nil
+}
+
+// GetFeedToken ReminderFeedNotFound, если ссылку на календарь еще не выдавали
+func (repo *Repository) GetFeedToken(ctx context.Context, userId uuid.UUID) (string, error) {
+	var token string
+	err := repo.conn.QueryRow(ctx, `select token from reminder_feeds where user_id = $1`, userId).Scan(&token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", apperrors.ReminderFeedNotFound
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "scan")
+	}
+	return token, nil
+}
+
+// SetFeedToken заменяет секрет ссылки, старая ссылка перестает работать
+func (repo *Repository) SetFeedToken(ctx context.Context, userId uuid.UUID, token string) error {
+	query := `
+		insert into reminder_feeds(user_id, token)
+		values ($1, $2)
+		on conflict (user_id) do update set token = excluded.token
+	`
+	_, err := repo.conn.Exec(ctx, query, userId, token)
+	return err
+}
+
+// GetFeedOwner владелец ссылки на календарь, ReminderFeedNotFound для неизвестного секрета
+func (repo *Repository) GetFeedOwner(ctx context.Context, token string) (uuid.UUID, error) {
+	var userId uuid.UUID
+	err := repo.conn.QueryRow(ctx, `select user_id from reminder_feeds where token = $1`, token).Scan(&userId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return uuid.Nil, apperrors.ReminderFeedNotFound
+	}
+	if err != nil {
+		return uuid.Nil, errors.Wrap(err, "scan")
+	}
+	return userId, nil
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"wn/internal/entity"
+	"wn/pkg/database/postgres"
+	"wn/pkg/textindex"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+// SetNoteTokens заменяет поисковые токены заметки и убирает ее из очереди индексации
+func (repo *Repository) SetNoteTokens(ctx context.Context, noteId uuid.UUID, tokens []textindex.Token) error {
+	query := `
+		delete from search_index_pending
+		where note_id = $1
+	`
+	if _, err := repo.conn.Exec(ctx, query, noteId); err != nil {
+		return errors.Wrap(err, "repo.conn.Exec pending")
+	}
+	query = `
+		delete from note_search_tokens
+		where note_id = $1
+	`
+	if _, err := repo.conn.Exec(ctx, query, noteId); err != nil {
+		return errors.Wrap(err, "repo.conn.Exec delete")
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+	hashes := make([][]byte, 0, len(tokens))
+	weights := make([]float64, 0, len(tokens))
+	for _, token := range tokens {
+		hashes = append(hashes, token.Hash)
+		weights = append(weights, token.Weight)
+	}
+	query = `
+		insert into note_search_tokens(note_id, token, weight)
+		select $1, t.token, t.weight from unnest($2::bytea[], $3::float8[]) as t(token, weight)
+	`
+	_, err := repo.conn.Exec(ctx, query, noteId, hashes, weights)
+	return err
+}
+
+// GetPendingNotes заметки, которые еще не проиндексированы, с id больше after. payload зашифрован
+func (repo *Repository) GetPendingNotes(ctx context.Context, after uuid.UUID, limit uint64) ([]entity.Note, error) {
+	query := `
+		select n.id, coalesce(n.title, ''), coalesce(n.payload, '')
+		from search_index_pending p
+		join notes n on n.id = p.note_id
+		where p.note_id > $1
+		order by p.note_id
+		limit $2
+	`
+	rows, err := repo.conn.Query(ctx, query, after, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var notes []entity.Note
+	for rows.Next() {
+		var n entity.Note
+		if err := rows.Scan(&n.Id, &n.Title, &n.Payload); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+// LockPendingNote блокирует заметку в очереди до конца транзакции, false если ее уже проиндексировали
+func (repo *Repository) LockPendingNote(ctx context.Context, noteId uuid.UUID) (bool, error) {
+	query := `
+		select note_id
+		from search_index_pending
+		where note_id = $1
+		for update
+	`
+	rows, err := repo.conn.Query(ctx, query, noteId)
+	if err != nil {
+		return false, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	locked := rows.Next()
+	return locked, rows.Err()
+}
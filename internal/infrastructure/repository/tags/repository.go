@@ -0,0 +1,209 @@
+package tags
+
+import (
+	"context"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/internal/infrastructure/repository/common"
+	"wn/pkg/database/postgres"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (repo *Repository) CreateTag(ctx context.Context, item *entity.Tag) error {
+	query := `
+		insert into tags(id, owner_id, name, color, created_at)
+		values ($1, $2, $3, $4, $5)
+	`
+	_, err := repo.conn.Exec(ctx, query, item.Id, item.OwnerId, item.Name, item.Color, item.CreatedAt)
+	if common.IsUniqueErr(err) {
+		return apperrors.TagAlreadyExists
+	}
+	return err
+}
+
+// EnsureTag возвращает id метки с таким именем, создает ее, если нет. Цвет существующей метки заменяется непустым
+func (repo *Repository) EnsureTag(ctx context.Context, item *entity.Tag) (uuid.UUID, error) {
+	query := `
+		insert into tags(id, owner_id, name, color, created_at)
+		values ($1, $2, $3, $4, $5)
+		on conflict (owner_id, lower(name)) do update
+		set color = case when excluded.color = '' then tags.color else excluded.color end
+		returning id
+	`
+	var id uuid.UUID
+	err := repo.conn.QueryRow(ctx, query, item.Id, item.OwnerId, item.Name, item.Color, item.CreatedAt).Scan(&id)
+	return id, err
+}
+
+func (repo *Repository) GetTag(ctx context.Context, tagId uuid.UUID) (*entity.Tag, error) {
+	query := `
+		select id, owner_id, name, color, created_at
+		from tags
+		where id = $1
+	`
+	var item entity.Tag
+	err := repo.conn.QueryRow(ctx, query, tagId).Scan(&item.Id, &item.OwnerId, &item.Name, &item.Color, &item.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.TagNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "scan")
+	}
+	return &item, nil
+}
+
+// UpdateTag меняет заданные поля
+func (repo *Repository) UpdateTag(ctx context.Context, tagId uuid.UUID, name, color *string) error {
+	builder := sq.Update("tags").
+		Where(sq.Eq{"id": tagId}).
+		PlaceholderFormat(sq.Dollar)
+	if name != nil {
+		builder = builder.Set("name", *name)
+	}
+	if color != nil {
+		builder = builder.Set("color", *color)
+	}
+	if name == nil && color == nil {
+		return nil
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "builder.ToSql")
+	}
+
+	res, err := repo.conn.Exec(ctx, query, args...)
+	if common.IsUniqueErr(err) {
+		return apperrors.TagAlreadyExists
+	}
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return apperrors.TagNotFound
+	}
+	return nil
+}
+
+// DeleteTag удаляет метку, со всех заметок она снимается каскадом
+func (repo *Repository) DeleteTag(ctx context.Context, tagId uuid.UUID) error {
+	res, err := repo.conn.Exec(ctx, `delete from tags where id = $1`, tagId)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return apperrors.TagNotFound
+	}
+	return nil
+}
+
+// GetTags метки пользователя со счетчиками заметок
+func (repo *Repository) GetTags(ctx context.Context, ownerId uuid.UUID) ([]entity.TagCount, error) {
+	query := `
+		select t.id, t.owner_id, t.name, t.color, t.created_at, count(nt.note_id)
+		from tags t
+		left join note_tags nt on nt.tag_id = t.id
+		where t.owner_id = $1
+		group by t.id
+		order by lower(t.name)
+	`
+	return repo.queryCounts(ctx, query, ownerId)
+}
+
+// GetLayoutTagCounts метки пользователя, которыми отмечены заметки лейаута
+func (repo *Repository) GetLayoutTagCounts(ctx context.Context, ownerId, layoutId uuid.UUID) ([]entity.TagCount, error) {
+	query := `
+		select t.id, t.owner_id, t.name, t.color, t.created_at, count(*)
+		from tags t
+		join note_tags nt on nt.tag_id = t.id
+		join notes n on n.id = nt.note_id
+		where t.owner_id = $1 and n.layout_id = $2
+		group by t.id
+		order by count(*) desc, lower(t.name)
+	`
+	return repo.queryCounts(ctx, query, ownerId, layoutId)
+}
+
+func (repo *Repository) queryCounts(ctx context.Context, query string, args ...any) ([]entity.TagCount, error) {
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var items []entity.TagCount
+	for rows.Next() {
+		var item entity.TagCount
+		err := rows.Scan(&item.Id, &item.OwnerId, &item.Name, &item.Color, &item.CreatedAt, &item.Notes)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (repo *Repository) AttachTag(ctx context.Context, noteId, tagId uuid.UUID) error {
+	query := `
+		insert into note_tags(note_id, tag_id)
+		values ($1, $2)
+		on conflict do nothing
+	`
+	_, err := repo.conn.Exec(ctx, query, noteId, tagId)
+	return err
+}
+
+func (repo *Repository) DetachTag(ctx context.Context, noteId, tagId uuid.UUID) error {
+	_, err := repo.conn.Exec(ctx, `delete from note_tags where note_id = $1 and tag_id = $2`, noteId, tagId)
+	return err
+}
+
+// CopyNoteTags отмечает toNoteId теми же метками пользователя, что и fromNoteId
+func (repo *Repository) CopyNoteTags(ctx context.Context, ownerId, fromNoteId, toNoteId uuid.UUID) error {
+	query := `
+		insert into note_tags(note_id, tag_id)
+		select $3, nt.tag_id
+		from note_tags nt
+		join tags t on t.id = nt.tag_id
+		where t.owner_id = $1 and nt.note_id = $2
+		on conflict do nothing
+	`
+	_, err := repo.conn.Exec(ctx, query, ownerId, fromNoteId, toNoteId)
+	return err
+}
+
+// GetNotesTags имена меток пользователя на заметках
+func (repo *Repository) GetNotesTags(ctx context.Context, ownerId uuid.UUID, noteIds []uuid.UUID) (map[uuid.UUID][]string, error) {
+	query := `
+		select nt.note_id, t.name
+		from note_tags nt
+		join tags t on t.id = nt.tag_id
+		where t.owner_id = $1 and nt.note_id = any($2)
+		order by lower(t.name)
+	`
+	rows, err := repo.conn.Query(ctx, query, ownerId, noteIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	tags := make(map[uuid.UUID][]string)
+	for rows.Next() {
+		var noteId uuid.UUID
+		var name string
+		if err := rows.Scan(&noteId, &name); err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		tags[noteId] = append(tags[noteId], name)
+	}
+	return tags, rows.Err()
+}
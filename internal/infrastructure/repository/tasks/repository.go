@@ -0,0 +1,129 @@
+package tasks
+
+import (
+	"context"
+	"wn/internal/domain/dto"
+	"wn/internal/entity"
+	"wn/internal/infrastructure/repository/common"
+	"wn/pkg/database/postgres"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+// SetNoteTasks заменяет задачи заметки, текст задач уже зашифрован
+func (repo *Repository) SetNoteTasks(ctx context.Context, noteId uuid.UUID, tasks []entity.Task) error {
+	if _, err := repo.conn.Exec(ctx, `delete from note_tasks where note_id = $1`, noteId); err != nil {
+		return errors.Wrap(err, "repo.conn.Exec delete")
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	builder := sq.Insert("note_tasks").
+		Columns("note_id", "line", "text", "done", "due", "assignees").
+		PlaceholderFormat(sq.Dollar)
+	for _, t := range tasks {
+		assignees := t.Assignees
+		if assignees == nil {
+			assignees = []string{}
+		}
+		builder = builder.Values(noteId, t.Line, t.Text, t.Done, t.Due, assignees)
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "builder.ToSql")
+	}
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func taskFilter(builder sq.SelectBuilder, userId uuid.UUID, params *dto.TaskFilter) sq.SelectBuilder {
+	builder = builder.From("note_tasks t").
+		Join("notes n on n.id = t.note_id").
+		Join("layouts l on l.id = n.layout_id").
+		Where(common.ReadableNotes(userId)).
+		PlaceholderFormat(sq.Dollar)
+	if len(params.LayoutIds) > 0 {
+		builder = builder.Where("n.layout_id = any(?)", params.LayoutIds)
+	}
+	if params.NoteId != nil {
+		builder = builder.Where(sq.Eq{"t.note_id": *params.NoteId})
+	}
+	if params.Done != nil {
+		builder = builder.Where(sq.Eq{"t.done": *params.Done})
+	}
+	if params.DueFrom != nil {
+		builder = builder.Where(sq.GtOrEq{"t.due": *params.DueFrom})
+	}
+	if params.DueTo != nil {
+		builder = builder.Where(sq.Lt{"t.due": *params.DueTo})
+	}
+	if params.Assignee != "" {
+		builder = builder.Where("t.assignees @> array[?]::varchar[]", params.Assignee)
+	}
+	return builder
+}
+
+// GetTasks страница задач: сначала с ближайшим сроком, задачи без срока в конце,
+// внутри заметки по порядку строк. Текст задач зашифрован
+func (repo *Repository) GetTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) ([]entity.FoundTask, error) {
+	builder := taskFilter(sq.Select(
+		"t.note_id", "t.line", "t.text", "t.done", "t.due", "t.assignees",
+		"coalesce(n.title, '')", "n.layout_id", "n.version",
+	), userId, params).
+		OrderBy("t.due nulls last", "n.created_at", "t.note_id", "t.line").
+		Limit(params.Limit).
+		Offset(params.Offset)
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "builder.ToSql")
+	}
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	items := []entity.FoundTask{}
+	for rows.Next() {
+		var item entity.FoundTask
+		err := rows.Scan(
+			&item.NoteId,
+			&item.Line,
+			&item.Text,
+			&item.Done,
+			&item.Due,
+			&item.Assignees,
+			&item.Title,
+			&item.LayoutId,
+			&item.NoteVersion,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return items, nil
+}
+
+func (repo *Repository) CountTasks(ctx context.Context, userId uuid.UUID, params *dto.TaskFilter) (int, error) {
+	query, args, err := taskFilter(sq.Select("count(*)"), userId, params).ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, "builder.ToSql")
+	}
+	var count int
+	err = repo.conn.QueryRow(ctx, query, args...).Scan(&count)
+	return count, errors.Wrap(err, "scan")
+}
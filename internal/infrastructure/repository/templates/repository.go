@@ -0,0 +1,136 @@
+package templates
+
+import (
+	"context"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/database/postgres"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+func (repo *Repository) CreateTemplate(ctx context.Context, item *entity.Template) error {
+	query := `
+		insert into templates(id, owner_id, layout_id, name, title, payload, created_at)
+		values ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err := repo.conn.Exec(ctx, query, item.Id, item.OwnerId, item.LayoutId, item.Name, item.Title, item.Payload, item.CreatedAt)
+	return err
+}
+
+func (repo *Repository) GetTemplate(ctx context.Context, templateId uuid.UUID) (*entity.Template, error) {
+	query := `
+		select id, owner_id, layout_id, name, title, payload, created_at
+		from templates
+		where id = $1
+	`
+	var item entity.Template
+	err := repo.conn.QueryRow(ctx, query, templateId).Scan(
+		&item.Id,
+		&item.OwnerId,
+		&item.LayoutId,
+		&item.Name,
+		&item.Title,
+		&item.Payload,
+		&item.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.TemplateNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "scan")
+	}
+	return &item, nil
+}
+
+// GetTemplates личные шаблоны пользователя и шаблоны лейаутов layoutIds
+func (repo *Repository) GetTemplates(ctx context.Context, ownerId uuid.UUID, layoutIds []uuid.UUID) ([]entity.Template, error) {
+	query := `
+		select id, owner_id, layout_id, name, title, payload, created_at
+		from templates
+		where (layout_id is null and owner_id = $1) or layout_id = any($2)
+		order by lower(name), created_at
+	`
+	rows, err := repo.conn.Query(ctx, query, ownerId, layoutIds)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+
+	var items []entity.Template
+	for rows.Next() {
+		var item entity.Template
+		err := rows.Scan(
+			&item.Id,
+			&item.OwnerId,
+			&item.LayoutId,
+			&item.Name,
+			&item.Title,
+			&item.Payload,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows.Err")
+	}
+	return items, nil
+}
+
+// UpdateTemplate меняет заданные поля
+func (repo *Repository) UpdateTemplate(ctx context.Context, templateId uuid.UUID, name, title, payload *string) error {
+	if name == nil && title == nil && payload == nil {
+		return nil
+	}
+	builder := sq.Update("templates").
+		Where(sq.Eq{"id": templateId}).
+		PlaceholderFormat(sq.Dollar)
+	if name != nil {
+		builder = builder.Set("name", *name)
+	}
+	if title != nil {
+		builder = builder.Set("title", *title)
+	}
+	if payload != nil {
+		builder = builder.Set("payload", *payload)
+	}
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return errors.Wrap(err, "builder.ToSql")
+	}
+
+	res, err := repo.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return apperrors.TemplateNotFound
+	}
+	return nil
+}
+
+func (repo *Repository) DeleteTemplate(ctx context.Context, templateId uuid.UUID) error {
+	res, err := repo.conn.Exec(ctx, `delete from templates where id = $1`, templateId)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return apperrors.TemplateNotFound
+	}
+	return nil
+}
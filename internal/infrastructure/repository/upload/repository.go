@@ -0,0 +1,182 @@
+package upload
+
+import (
+	"context"
+	"time"
+	"wn/internal/entity"
+	apperrors "wn/internal/errors"
+	"wn/pkg/database/postgres"
+
+	"github.com/Masterminds/squirrel"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+type Repository struct {
+	conn postgres.Connection
+}
+
+func NewRepository(conn postgres.Connection) *Repository {
+	return &Repository{conn: conn}
+}
+
+var uploadColumns = []string{
+	"id", "owner_id", "coalesce(note_id, '00000000-0000-0000-0000-000000000000')", "file_name",
+	"upload_length", "upload_offset", "chunk_keys", "coalesce(result_name, '')", "expires_at", "created_at",
+}
+
+func scanUpload(row pgx.Row) (*entity.Upload, error) {
+	var item entity.Upload
+	err := row.Scan(
+		&item.Id, &item.OwnerId, &item.NoteId, &item.FileName,
+		&item.Length, &item.Offset, &item.ChunkKeys, &item.ResultName, &item.ExpiresAt, &item.CreatedAt,
+	)
+	return &item, err
+}
+
+func (repo *Repository) CreateUpload(ctx context.Context, item *entity.Upload) error {
+	var noteId any
+	if item.NoteId != uuid.Nil {
+		noteId = item.NoteId
+	}
+	query, args, err := squirrel.Insert("uploads").
+		Columns("id", "owner_id", "note_id", "file_name", "upload_length", "expires_at").
+		Values(item.Id, item.OwnerId, noteId, item.FileName, item.Length, item.ExpiresAt).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func (repo *Repository) GetUpload(ctx context.Context, id uuid.UUID) (*entity.Upload, error) {
+	query, args, err := squirrel.Select(uploadColumns...).
+		From("uploads").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	item, err := scanUpload(repo.conn.QueryRow(ctx, query, args...))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apperrors.UploadNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.QueryRow")
+	}
+	return item, nil
+}
+
+// AppendChunk добавляет кусок, только если позиция не сдвинулась. false - кусок уже записал другой запрос
+func (repo *Repository) AppendChunk(ctx context.Context, id uuid.UUID, offset int64, key string, size int64, expiresAt time.Time) (bool, error) {
+	query, args, err := squirrel.Update("uploads").
+		Set("upload_offset", squirrel.Expr("upload_offset + ?", size)).
+		Set("chunk_keys", squirrel.Expr("array_append(chunk_keys, ?)", key)).
+		Set("expires_at", expiresAt).
+		Where(squirrel.Eq{"id": id, "upload_offset": offset, "result_name": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	tag, err := repo.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ClaimAssembly отдает сборку загрузки одному запросу. false - загрузка не получена целиком, уже собрана
+// или ее собирает другой запрос, начавший позже staleBefore
+func (repo *Repository) ClaimAssembly(ctx context.Context, id uuid.UUID, staleBefore time.Time) (bool, error) {
+	query, args, err := squirrel.Update("uploads").
+		Set("assembling_at", squirrel.Expr("now()")).
+		Where(squirrel.Eq{"id": id, "result_name": nil}).
+		Where("upload_offset = upload_length").
+		Where(squirrel.Or{squirrel.Eq{"assembling_at": nil}, squirrel.Lt{"assembling_at": staleBefore}}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	tag, err := repo.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// ReleaseAssembly снимает сборку после временной ошибки, чтобы ее сразу повторил следующий запрос
+func (repo *Repository) ReleaseAssembly(ctx context.Context, id uuid.UUID) error {
+	query, args, err := squirrel.Update("uploads").
+		Set("assembling_at", nil).
+		Where(squirrel.Eq{"id": id, "result_name": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+// CompleteUpload запоминает созданный файл, куски больше не нужны. false - загрузку уже завершил другой запрос
+func (repo *Repository) CompleteUpload(ctx context.Context, id uuid.UUID, resultName string) (bool, error) {
+	query, args, err := squirrel.Update("uploads").
+		Set("result_name", resultName).
+		Set("chunk_keys", squirrel.Expr("'{}'")).
+		Where(squirrel.Eq{"id": id, "result_name": nil}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return false, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	tag, err := repo.conn.Exec(ctx, query, args...)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (repo *Repository) DeleteUpload(ctx context.Context, id uuid.UUID) error {
+	query, args, err := squirrel.Delete("uploads").
+		Where(squirrel.Eq{"id": id}).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	_, err = repo.conn.Exec(ctx, query, args...)
+	return err
+}
+
+func (repo *Repository) GetExpiredUploads(ctx context.Context, before time.Time, limit uint64) ([]*entity.Upload, error) {
+	query, args, err := squirrel.Select(uploadColumns...).
+		From("uploads").
+		Where(squirrel.Lt{"expires_at": before}).
+		OrderBy("expires_at").
+		Limit(limit).
+		PlaceholderFormat(squirrel.Dollar).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "squirrel.ToSql")
+	}
+
+	rows, err := repo.conn.Query(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo.conn.Query")
+	}
+	defer rows.Close()
+	var items []*entity.Upload
+	for rows.Next() {
+		item, err := scanUpload(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "rows.Scan")
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
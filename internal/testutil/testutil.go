@@ -0,0 +1,25 @@
+// Package testutil общие заготовки для тестов сервисов
+package testutil
+
+import (
+	"context"
+	"testing"
+	"wn/pkg/applogger"
+)
+
+// NoTx выполняет функцию без транзакции, для сервисов с хранилищем в памяти
+type NoTx struct{}
+
+func (NoTx) Transaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+// Logger пишет только ошибки, чтобы не засорять вывод тестов
+func Logger(t testing.TB) applogger.Logger {
+	t.Helper()
+	lgr, err := applogger.NewLogger("ERROR")
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+	return lgr
+}
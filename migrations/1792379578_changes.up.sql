@@ -0,0 +1,14 @@
+create table if not exists changes (
+    seq bigserial primary key,
+    entity_kind varchar not null,
+    entity_id uuid not null,
+    second_id uuid not null default '00000000-0000-0000-0000-000000000000',
+    layout_id uuid not null,
+    operation varchar not null,
+    audience uuid[] not null default '{}',
+    created_at timestamptz not null default now()
+);
+
+create index if not exists changes_entity_idx on changes (entity_kind, entity_id, second_id, seq);
+create index if not exists changes_layout_idx on changes (layout_id, seq);
+create index if not exists changes_audience_idx on changes using gin (audience);
@@ -0,0 +1,2 @@
+alter table notes add column if not exists version bigint not null default 1;
+alter table layouts add column if not exists version bigint not null default 1;
@@ -0,0 +1,8 @@
+alter table files add column if not exists storage_key varchar;
+alter table files add column if not exists content_type varchar not null default 'application/octet-stream';
+alter table files add column if not exists size bigint not null default 0;
+alter table files add column if not exists hash varchar not null default '';
+alter table files add column if not exists created_at timestamp not null default now();
+
+-- строки без storage_key еще хранят содержимое в file_data и ждут переноса в хранилище
+create index if not exists files_not_moved_idx on files(file_name) where storage_key is null;
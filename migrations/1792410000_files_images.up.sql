@@ -0,0 +1,8 @@
+alter table files add column if not exists parent_name varchar references files(file_name) on delete cascade;
+alter table files add column if not exists variant int;
+alter table files add column if not exists orientation int not null default 1;
+alter table files add column if not exists image_state varchar not null default 'NONE';
+alter table files add column if not exists image_state_at timestamp not null default now();
+
+create index if not exists files_parent_name_idx on files(parent_name) where parent_name is not null;
+create index if not exists files_image_queue_idx on files(image_state_at) where image_state in ('PENDING', 'PROCESSING');
@@ -0,0 +1,9 @@
+alter table files add column if not exists owner_id uuid references users(id) on delete cascade;
+alter table files add column if not exists note_id uuid references notes(id) on delete set null;
+alter table files add column if not exists purpose varchar not null default 'ATTACHMENT';
+
+-- аватарки остаются публичными
+update files set purpose = 'AVATAR' where file_name in (select img_url from users);
+
+create index if not exists files_owner_id_idx on files(owner_id);
+create index if not exists files_note_id_idx on files(note_id) where note_id is not null;
@@ -0,0 +1,29 @@
+create table if not exists blobs(
+    storage_key varchar primary key,
+    hash varchar not null,
+    size bigint not null default 0,
+    content_type varchar not null default 'application/octet-stream',
+    created_at timestamp not null default now(),
+    orphaned_at timestamp
+);
+
+create unique index if not exists blobs_hash_idx on blobs(hash);
+create index if not exists blobs_orphaned_at_idx on blobs(orphaned_at) where orphaned_at is not null;
+
+-- одно содержимое - один blob, остальные строки переключаются на него.
+-- Лишние копии в хранилище остаются, на них больше никто не ссылается
+insert into blobs(storage_key, hash, size, content_type, created_at)
+select distinct on (hash) storage_key, hash, size, content_type, created_at
+from files
+where storage_key is not null and hash <> ''
+order by hash, created_at
+on conflict do nothing;
+
+update files f set storage_key = b.storage_key
+from blobs b
+where f.hash = b.hash and f.storage_key <> b.storage_key;
+
+alter table files add column if not exists orphaned_at timestamp;
+
+create index if not exists files_storage_key_idx on files(storage_key);
+create index if not exists files_orphaned_at_idx on files(orphaned_at) where orphaned_at is not null;
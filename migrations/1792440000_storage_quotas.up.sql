@@ -0,0 +1,6 @@
+-- индивидуальные лимиты, у остальных пользователей действует storage.defaultQuota
+create table if not exists storage_quotas(
+    user_id uuid primary key references users(id) on delete cascade,
+    quota bigint not null,
+    updated_at timestamp not null default now()
+);
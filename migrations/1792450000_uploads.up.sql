@@ -0,0 +1,15 @@
+-- незавершенные загрузки по протоколу tus, куски лежат в хранилище под uploads/<id>/
+create table if not exists uploads(
+    id uuid primary key,
+    owner_id uuid not null references users(id) on delete cascade,
+    note_id uuid references notes(id) on delete set null,
+    file_name varchar not null,
+    upload_length bigint not null,
+    upload_offset bigint not null default 0,
+    chunk_keys text[] not null default '{}',
+    result_name varchar,
+    expires_at timestamp not null,
+    created_at timestamp not null default now()
+);
+
+create index if not exists uploads_expires_at_idx on uploads(expires_at);
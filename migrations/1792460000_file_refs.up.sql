@@ -0,0 +1,19 @@
+-- ссылки на файлы из текстов и черновиков заметок. Тексты зашифрованы,
+-- поэтому ссылки собирает сервис заметок при каждой записи
+create table if not exists file_refs(
+    note_id uuid not null references notes(id) on delete cascade,
+    -- имя файла без расширения, ссылки на миниатюры тоже ведут на оригинал
+    file_key varchar not null,
+    primary key (note_id, file_key)
+);
+
+create index if not exists file_refs_file_key_idx on file_refs(file_key);
+
+-- заметки, ссылки которых еще не собраны. Существующие заметки индексирует воркер при старте
+create table if not exists file_refs_pending(
+    note_id uuid primary key references notes(id) on delete cascade
+);
+
+insert into file_refs_pending(note_id)
+select id from notes
+on conflict do nothing;
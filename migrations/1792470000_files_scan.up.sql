@@ -0,0 +1,6 @@
+-- файлы, загруженные до появления проверки, считаются чистыми
+alter table files add column if not exists scan_state varchar not null default 'CLEAN';
+alter table files add column if not exists scan_state_at timestamp not null default now();
+alter table files add column if not exists scan_signature varchar;
+
+create index if not exists files_scan_queue_idx on files(scan_state_at) where scan_state in ('PENDING', 'SCANNING');
@@ -0,0 +1,18 @@
+-- метки личные: у каждого пользователя свой набор, имена без учета регистра
+create table if not exists tags(
+    id uuid primary key,
+    owner_id uuid not null references users(id) on delete cascade,
+    name varchar not null,
+    color varchar not null default '',
+    created_at timestamp not null default now()
+);
+
+create unique index if not exists tags_owner_name_idx on tags(owner_id, lower(name));
+
+create table if not exists note_tags(
+    note_id uuid not null references notes(id) on delete cascade,
+    tag_id uuid not null references tags(id) on delete cascade,
+    primary key (note_id, tag_id)
+);
+
+create index if not exists note_tags_tag_id_idx on note_tags(tag_id);
@@ -0,0 +1,3 @@
+-- связи из [[wiki-ссылок]] в тексте заметки. Их пересобирает сервис заметок при записи текста,
+-- ручные связи (auto = false) он не трогает
+alter table links add column if not exists auto boolean not null default false;
@@ -0,0 +1,19 @@
+-- слепой поисковый индекс: тексты заметок зашифрованы, поэтому сервис заметок хранит
+-- HMAC основ слов и их префиксов, по которым ищется запрос. Вес - сколько раз и в каком поле встретилось слово
+create table if not exists note_search_tokens(
+    note_id uuid not null references notes(id) on delete cascade,
+    token bytea not null,
+    weight real not null,
+    primary key (note_id, token)
+);
+
+create index if not exists note_search_tokens_token_idx on note_search_tokens(token);
+
+-- заметки, которые еще не проиндексированы. Существующие заметки индексирует воркер при старте
+create table if not exists search_index_pending(
+    note_id uuid primary key references notes(id) on delete cascade
+);
+
+insert into search_index_pending(note_id)
+select id from notes
+on conflict do nothing;
@@ -0,0 +1,18 @@
+-- время последнего изменения заметки (заголовок, текст, коммит черновика, перенос в другой лейаут)
+-- для фильтров и сортировки поиска. Отдельной таблицей, чтобы не менять состав колонок notes
+create table if not exists note_updates(
+    note_id uuid primary key references notes(id) on delete cascade,
+    updated_at timestamptz not null
+);
+
+create index if not exists note_updates_updated_at_idx on note_updates(updated_at);
+
+insert into note_updates(note_id, updated_at)
+select n.id, coalesce(
+    (select max(c.created_at) from changes c
+     where c.entity_kind = 'NOTE' and c.entity_id = n.id and c.operation = 'UPSERT'),
+    n.created_at,
+    now()
+)
+from notes n
+on conflict do nothing;
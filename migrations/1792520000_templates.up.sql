@@ -0,0 +1,13 @@
+-- шаблоны заметок: личные (layout_id null) и общие для лейаута. payload зашифрован, как у заметок
+create table if not exists templates(
+    id uuid primary key,
+    owner_id uuid not null references users(id) on delete cascade,
+    layout_id uuid references layouts(id) on delete cascade,
+    name varchar not null,
+    title varchar not null default '',
+    payload text not null default '',
+    created_at timestamp not null default now()
+);
+
+create index if not exists templates_owner_idx on templates(owner_id) where layout_id is null;
+create index if not exists templates_layout_idx on templates(layout_id);
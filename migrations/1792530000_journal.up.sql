@@ -0,0 +1,19 @@
+-- журнал пользователя: лейаут, в котором живут заметки по дням, формат заголовка и шаблон новой записи.
+-- started_on - день, от которого отсчитывается положение записей на графе
+create table if not exists journals(
+    user_id uuid primary key references users(id) on delete cascade,
+    layout_id uuid not null references layouts(id) on delete cascade,
+    title_format varchar not null,
+    template_id uuid references templates(id) on delete set null,
+    started_on date not null
+);
+
+-- записи журнала: одна заметка на день в лейауте
+create table if not exists journal_entries(
+    layout_id uuid not null references layouts(id) on delete cascade,
+    day date not null,
+    note_id uuid not null references notes(id) on delete cascade,
+    primary key (layout_id, day)
+);
+
+create unique index if not exists journal_entries_note_idx on journal_entries(note_id);
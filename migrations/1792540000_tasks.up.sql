@@ -0,0 +1,19 @@
+-- задачи из чек-листов "- [ ]" и "- [x]" в текстах заметок. Текст задачи зашифрован, как payload.
+-- line - номер строки в payload, по нему переключается отметка. assignees - имена из @user без @
+create table if not exists note_tasks(
+    note_id uuid not null references notes(id) on delete cascade,
+    line int not null,
+    text text not null,
+    done boolean not null,
+    due date,
+    assignees varchar[] not null default '{}',
+    primary key (note_id, line)
+);
+
+create index if not exists note_tasks_open_due_idx on note_tasks(due) where not done;
+create index if not exists note_tasks_assignees_idx on note_tasks using gin(assignees);
+
+-- задачи уже существующих заметок собирает воркер поискового индекса
+insert into search_index_pending(note_id)
+select id from notes
+on conflict do nothing;
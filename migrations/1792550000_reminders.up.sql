@@ -0,0 +1,22 @@
+-- напоминания о заметках. remind_at - первое срабатывание, rrule - повторение в часовом поясе timezone,
+-- null для разового. next_fire_at - ближайшее срабатывание, null когда напоминание отработало
+create table if not exists reminders(
+    id uuid primary key,
+    user_id uuid not null references users(id) on delete cascade,
+    note_id uuid not null references notes(id) on delete cascade,
+    remind_at timestamptz not null,
+    rrule varchar(256),
+    timezone varchar(64) not null,
+    next_fire_at timestamptz,
+    last_fired_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists reminders_due_idx on reminders(next_fire_at) where next_fire_at is not null;
+create index if not exists reminders_user_idx on reminders(user_id, note_id);
+
+-- секрет в ссылке на календарь напоминаний: календарные приложения не умеют передавать токен авторизации
+create table if not exists reminder_feeds(
+    user_id uuid primary key references users(id) on delete cascade,
+    token varchar(64) not null unique
+);
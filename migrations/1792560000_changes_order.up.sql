@@ -0,0 +1,8 @@
+-- лента упорядочена по транзакции, записавшей изменение: клиенту отдаются только изменения
+-- транзакций старше горизонта снимка, поэтому курсор не перепрыгнет незакоммиченную запись.
+-- Существующие записи получают txid миграции и остаются в порядке seq
+alter table changes add column if not exists txid xid8 not null default pg_current_xact_id();
+
+create index if not exists changes_order_idx on changes (txid, seq);
+create index if not exists changes_layout_order_idx on changes (layout_id, txid, seq);
+drop index if exists changes_layout_idx;
@@ -0,0 +1,8 @@
+-- позиция последнего удаленного из ленты изменения: курсоры до нее устарели, клиенту нужна полная синхронизация
+create table if not exists changes_pruned (
+    id bool primary key default true check (id),
+    txid xid8 not null,
+    seq bigint not null
+);
+
+create index if not exists changes_created_at_idx on changes (created_at);
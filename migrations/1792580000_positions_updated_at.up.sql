@@ -0,0 +1,2 @@
+-- время последней записи позиции: отложенное сохранение перетаскивания не затирает более новую запись
+alter table positions add column if not exists updated_at timestamptz not null default now();
@@ -0,0 +1,18 @@
+-- время последнего изменения заметки (заголовок, текст, коммит черновика, перенос в другой лейаут)
+-- для фильтров и сортировки поиска, переносится из note_updates
+alter table notes add column if not exists updated_at timestamptz;
+
+update notes n
+set updated_at = coalesce(
+    (select u.updated_at from note_updates u where u.note_id = n.id),
+    n.created_at,
+    now()
+)
+where n.updated_at is null;
+
+alter table notes alter column updated_at set default now();
+alter table notes alter column updated_at set not null;
+
+create index if not exists notes_updated_at_idx on notes(updated_at);
+
+drop table if exists note_updates;
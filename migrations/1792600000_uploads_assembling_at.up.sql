@@ -0,0 +1,2 @@
+-- сборку загрузки в файл начинает только один запрос: PATCH последнего куска и HEAD могут прийти одновременно
+alter table uploads add column if not exists assembling_at timestamp;
@@ -24,6 +24,7 @@ type AppError struct {
 	Type    ErrType `json:"type"`
 	Message string  `json:"message,omitempty"`
 	Code    string  `json:"code"`
+	Data    any     `json:"data,omitempty"`
 }
 
 func (e *AppError) Error() string {
@@ -42,6 +43,13 @@ func (e *AppError) WithCode(code string) *AppError {
 	return e
 }
 
+// WithData возвращает копию ошибки с данными для тела ответа, исходная ошибка не меняется
+func (e *AppError) WithData(data any) *AppError {
+	cp := *e
+	cp.Data = data
+	return &cp
+}
+
 func NewAppError(err error, message string) *AppError {
 	return &AppError{
 		Err:     err,
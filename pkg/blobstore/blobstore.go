@@ -0,0 +1,20 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// UnknownSize передается в Put, когда размер потока заранее неизвестен
+const UnknownSize int64 = -1
+
+// BlobStore хранилище содержимого файлов. Ключи - пути через "/"
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open возвращает объект с произвольным доступом, чтобы отдавать range запросы
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	Delete(ctx context.Context, key string) error
+}
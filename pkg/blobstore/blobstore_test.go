@@ -0,0 +1,189 @@
+package blobstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wn/pkg/blobstore"
+)
+
+// fakeS3 минимальная замена MinIO: объекты, range GET и multipart загрузка
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string]map[int][]byte
+	nextId  int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		objects: map[string][]byte{},
+		uploads: map[string]map[int][]byte{},
+	}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	key := r.URL.Path
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextId++
+		id := strconv.Itoa(f.nextId)
+		f.uploads[id] = map[int][]byte{}
+		fmt.Fprintf(w, "<InitiateMultipartUploadResult><UploadId>%s</UploadId></InitiateMultipartUploadResult>", id)
+	case r.Method == http.MethodPut && query.Has("partNumber"):
+		n, _ := strconv.Atoi(query.Get("partNumber"))
+		body, _ := io.ReadAll(r.Body)
+		f.uploads[query.Get("uploadId")][n] = body
+		w.Header().Set("ETag", `"part`+strconv.Itoa(n)+`"`)
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts := f.uploads[query.Get("uploadId")]
+		numbers := make([]int, 0, len(parts))
+		for n := range parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		var data []byte
+		for _, n := range numbers {
+			data = append(data, parts[n]...)
+		}
+		f.objects[key] = data
+		delete(f.uploads, query.Get("uploadId"))
+		_ = xml.NewEncoder(w).Encode(struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		}{})
+	case r.Method == http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		f.objects[key] = body
+	case r.Method == http.MethodHead || r.Method == http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		http.ServeContent(w, r, key, time.Time{}, bytes.NewReader(data))
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func checkStore(t *testing.T, store blobstore.BlobStore, data []byte, size int64) {
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "files/a/blob.bin", bytes.NewReader(data), size, "application/octet-stream"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	obj, err := store.Open(ctx, "files/a/blob.bin")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	got, err := io.ReadAll(obj)
+	if err != nil {
+		t.Fatalf("ReadAll() error = %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("read %d bytes, want %d", len(got), len(data))
+	}
+
+	end, err := obj.Seek(0, io.SeekEnd)
+	if err != nil || end != int64(len(data)) {
+		t.Fatalf("Seek(end) = %d, %v, want %d", end, err, len(data))
+	}
+	if _, err := obj.Seek(3, io.SeekStart); err != nil {
+		t.Fatalf("Seek(3) error = %v", err)
+	}
+	part := make([]byte, 4)
+	if _, err := io.ReadFull(obj, part); err != nil {
+		t.Fatalf("ReadFull() error = %v", err)
+	}
+	if !bytes.Equal(part, data[3:7]) {
+		t.Errorf("read after seek = %q, want %q", part, data[3:7])
+	}
+	obj.Close()
+
+	if err := store.Delete(ctx, "files/a/blob.bin"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := store.Open(ctx, "files/a/blob.bin"); !errors.Is(err, blobstore.ErrNotFound) {
+		t.Errorf("Open() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := store.Delete(ctx, "files/a/blob.bin"); err != nil {
+		t.Errorf("second Delete() error = %v", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	store, err := blobstore.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal() error = %v", err)
+	}
+
+	t.Run("put, open with seek and delete", func(t *testing.T) {
+		checkStore(t, store, []byte("hello blob store"), blobstore.UnknownSize)
+	})
+
+	t.Run("rejects keys escaping root", func(t *testing.T) {
+		err := store.Put(context.Background(), "../escape", strings.NewReader("x"), 1, "")
+		if err == nil {
+			t.Error("Put(../escape) error = nil")
+		}
+	})
+}
+
+func TestS3(t *testing.T) {
+	server := httptest.NewServer(newFakeS3())
+	defer server.Close()
+
+	store, err := blobstore.NewS3(blobstore.S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "wn",
+		AccessKey: "key",
+		SecretKey: "secret",
+		PathStyle: true,
+	}, server.Client())
+	if err != nil {
+		t.Fatalf("NewS3() error = %v", err)
+	}
+
+	t.Run("single put with known size", func(t *testing.T) {
+		checkStore(t, store, []byte("hello blob store"), 16)
+	})
+
+	t.Run("small stream of unknown size", func(t *testing.T) {
+		checkStore(t, store, []byte("hello blob store"), blobstore.UnknownSize)
+	})
+
+	t.Run("large stream goes through multipart upload", func(t *testing.T) {
+		data := bytes.Repeat([]byte("0123456789"), 600_000)
+		checkStore(t, store, data, blobstore.UnknownSize)
+	})
+
+	t.Run("rejects config without bucket", func(t *testing.T) {
+		if _, err := blobstore.NewS3(blobstore.S3Config{Endpoint: server.URL}, nil); err == nil {
+			t.Error("NewS3() error = nil")
+		}
+	})
+}
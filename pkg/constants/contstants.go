@@ -5,6 +5,22 @@ const (
 	AuthorizationHeader = "Authorization"
 	RequestIdHeader     = "X-REQUEST-ID"
 	RefreshHeader       = "X-REFRESH-TOKEN"
+	IfMatchHeader       = "If-Match"
+	ETagHeader          = "ETag"
+)
+
+// tus 1.0 https://tus.io/protocols/resumable-upload
+const (
+	TusResumableHeader   = "Tus-Resumable"
+	TusVersionHeader     = "Tus-Version"
+	TusExtensionHeader   = "Tus-Extension"
+	TusMaxSizeHeader     = "Tus-Max-Size"
+	UploadLengthHeader   = "Upload-Length"
+	UploadOffsetHeader   = "Upload-Offset"
+	UploadMetadataHeader = "Upload-Metadata"
+	UploadExpiresHeader  = "Upload-Expires"
+	UploadFileUrlHeader  = "Upload-File-Url"
+	LocationHeader       = "Location"
 )
 
 // Roles
@@ -26,6 +42,7 @@ const (
 	TraceIdCtx   = "traceId"
 	SpanIdCtx    = "spanId"
 	ApiNameCtx   = "apiName"
+	ConnIdCtx    = "connectionId"
 )
 
 // Errors
@@ -37,4 +54,22 @@ const (
 
 const (
 	PageSize = 50
+	// SearchMaxPageSize больше заметок за одну страницу поиска не отдается
+	SearchMaxPageSize = 200
+	// TasksMaxPageSize больше задач за одну страницу не отдается
+	TasksMaxPageSize = 500
+)
+
+// Avatars
+const (
+	// LegacyDefaultAvatar аватарка по умолчанию у старых пользователей, показывается сгенерированная
+	LegacyDefaultAvatar = "base.png"
+	// GeneratedAvatarsPath адреса сгенерированных аватарок /statics/avatars/<id>.png и <id>_<size>.png
+	GeneratedAvatarsPath = "/statics/avatars/"
+)
+
+// Sync
+const (
+	SyncPageSize     = 500
+	SyncPushMaxItems = 500
 )
@@ -0,0 +1,88 @@
+package identicon
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// grid клеток по каждой стороне, левая половина зеркалится направо
+const grid = 5
+
+var background = color.RGBA{R: 0xf0, G: 0xf0, B: 0xf0, A: 0xff}
+
+// Render рисует квадратную картинку size x size. Одинаковый seed всегда дает одинаковую картинку
+func Render(seed []byte, size int) *image.RGBA {
+	sum := sha256.Sum256(seed)
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+
+	// по половине клетки отступа с каждой стороны
+	cell := size * 2 / (grid*2 + 1)
+	if cell == 0 {
+		return img
+	}
+	offset := (size - cell*grid) / 2
+	fill := &image.Uniform{C: Color(seed)}
+	half := (grid + 1) / 2
+	for row := 0; row < grid; row++ {
+		for col := 0; col < half; col++ {
+			bit := row*half + col
+			if sum[2+bit/8]>>(bit%8)&1 == 0 {
+				continue
+			}
+			for _, x := range []int{col, grid - 1 - col} {
+				rect := image.Rect(offset+x*cell, offset+row*cell, offset+(x+1)*cell, offset+(row+1)*cell)
+				draw.Draw(img, rect, fill, image.Point{}, draw.Src)
+			}
+		}
+	}
+	return img
+}
+
+// Color цвет рисунка: оттенок из seed, насыщенность и яркость фиксированы, чтобы рисунок читался на светлом фоне
+func Color(seed []byte) color.RGBA {
+	sum := sha256.Sum256(seed)
+	hue := float64(uint16(sum[0])<<8|uint16(sum[1])) / 65536 * 360
+	return hsl(hue, 0.55, 0.5)
+}
+
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - abs(2*l-1)) * s
+	hp := h / 60
+	x := c * (1 - abs(mod2(hp)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g = c, x
+	case hp < 2:
+		r, g = x, c
+	case hp < 3:
+		g, b = c, x
+	case hp < 4:
+		g, b = x, c
+	case hp < 5:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	m := l - c/2
+	return color.RGBA{R: channel(r + m), G: channel(g + m), B: channel(b + m), A: 0xff}
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// mod2 остаток от деления на 2 для неотрицательных значений
+func mod2(v float64) float64 {
+	return v - float64(int(v/2))*2
+}
+
+func channel(v float64) uint8 {
+	return uint8(v*255 + 0.5)
+}
@@ -0,0 +1,41 @@
+package identicon_test
+
+import (
+	"bytes"
+	"testing"
+	"wn/pkg/identicon"
+)
+
+func TestRender(t *testing.T) {
+	t.Run("deterministic", func(t *testing.T) {
+		a, b := identicon.Render([]byte("user"), 64), identicon.Render([]byte("user"), 64)
+		if !bytes.Equal(a.Pix, b.Pix) {
+			t.Errorf("same seed renders different images")
+		}
+		other := identicon.Render([]byte("other user"), 64)
+		if bytes.Equal(a.Pix, other.Pix) {
+			t.Errorf("different seeds render the same image")
+		}
+	})
+
+	t.Run("symmetric", func(t *testing.T) {
+		img := identicon.Render([]byte("user"), 110)
+		size := img.Bounds().Dx()
+		if size != 110 || img.Bounds().Dy() != 110 {
+			t.Fatalf("size = %v, want 110x110", img.Bounds())
+		}
+		for y := 0; y < size; y++ {
+			for x := 0; x < size/2; x++ {
+				if img.RGBAAt(x, y) != img.RGBAAt(size-1-x, y) {
+					t.Fatalf("pixel (%d, %d) is not mirrored", x, y)
+				}
+			}
+		}
+	})
+
+	t.Run("colour", func(t *testing.T) {
+		if identicon.Color([]byte("user")) != identicon.Color([]byte("user")) {
+			t.Errorf("Color() is not deterministic")
+		}
+	})
+}
@@ -0,0 +1,183 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+	"wn/pkg/imaging"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 10), G: uint8(y * 10), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+// exifSegment APP1 с ориентацией и фейковым GPS тегом
+func exifSegment(orientation uint16) []byte {
+	tiff := bytes.NewBuffer(nil)
+	tiff.WriteString("II\x2a\x00")
+	_ = binary.Write(tiff, binary.LittleEndian, uint32(8))
+	_ = binary.Write(tiff, binary.LittleEndian, uint16(2))
+	for _, entry := range [][3]uint16{{0x0112, 3, orientation}, {0x8825, 4, 0}} {
+		_ = binary.Write(tiff, binary.LittleEndian, entry[0])
+		_ = binary.Write(tiff, binary.LittleEndian, entry[1])
+		_ = binary.Write(tiff, binary.LittleEndian, uint32(1))
+		_ = binary.Write(tiff, binary.LittleEndian, uint32(entry[2]))
+	}
+	_ = binary.Write(tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString("GPS 55.7558N 37.6173E")
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+func strip(t *testing.T, data []byte, format imaging.Format) ([]byte, int) {
+	s := imaging.NewStripReader(bytes.NewReader(data), format)
+	defer s.Close()
+	out, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatalf("strip %s error = %v", format, err)
+	}
+	return out, s.Orientation()
+}
+
+func TestStripReader(t *testing.T) {
+	t.Run("jpeg keeps only orientation", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, testImage(8, 4), nil); err != nil {
+			t.Fatalf("jpeg.Encode() error = %v", err)
+		}
+		src := buf.Bytes()
+		comment := []byte{0xFF, 0xFE, 0, 9, 's', 'e', 'c', 'r', 'e', 't', '!'}
+		withExif := append(append(append([]byte{}, src[:2]...), exifSegment(6)...), comment...)
+		withExif = append(withExif, src[2:]...)
+
+		out, orientation := strip(t, withExif, imaging.FormatJPEG)
+		if orientation != 6 {
+			t.Errorf("Orientation() = %d, want 6", orientation)
+		}
+		if bytes.Contains(out, []byte("GPS")) || bytes.Contains(out, []byte("secret")) {
+			t.Error("metadata left in stripped jpeg")
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+			t.Errorf("stripped jpeg does not decode: %v", err)
+		}
+	})
+
+	t.Run("jpeg without exif has default orientation", func(t *testing.T) {
+		var buf bytes.Buffer
+		_ = jpeg.Encode(&buf, testImage(4, 4), nil)
+		_, orientation := strip(t, buf.Bytes(), imaging.FormatJPEG)
+		if orientation != 1 {
+			t.Errorf("Orientation() = %d, want 1", orientation)
+		}
+	})
+
+	t.Run("png drops text chunks", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, testImage(4, 4)); err != nil {
+			t.Fatalf("png.Encode() error = %v", err)
+		}
+		src := buf.Bytes()
+		text := []byte("tEXtComment\x00secret")
+		chunk := make([]byte, 4, 4+len(text)+4)
+		binary.BigEndian.PutUint32(chunk, uint32(len(text)-4))
+		chunk = append(chunk, text...)
+		chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(text))
+		// после сигнатуры (8) и IHDR (25)
+		withText := append(append(append([]byte{}, src[:33]...), chunk...), src[33:]...)
+		if _, err := png.Decode(bytes.NewReader(withText)); err != nil {
+			t.Fatalf("test png broken: %v", err)
+		}
+
+		out, _ := strip(t, withText, imaging.FormatPNG)
+		if bytes.Contains(out, []byte("secret")) {
+			t.Error("tEXt chunk left in stripped png")
+		}
+		if !bytes.Equal(out, src) {
+			t.Error("stripped png differs from original without chunk")
+		}
+	})
+
+	t.Run("webp drops exif chunk and fixes sizes", func(t *testing.T) {
+		chunk := func(kind string, data []byte) []byte {
+			out := append([]byte(kind), 0, 0, 0, 0)
+			binary.LittleEndian.PutUint32(out[4:], uint32(len(data)))
+			out = append(out, data...)
+			if len(data)%2 == 1 {
+				out = append(out, 0)
+			}
+			return out
+		}
+		body := append([]byte("WEBP"), chunk("VP8X", []byte{0x08, 0, 0, 0, 0, 0, 0, 0, 0, 0})...)
+		body = append(body, chunk("VP8L", []byte{1, 2, 3})...)
+		body = append(body, chunk("EXIF", []byte("GPS data"))...)
+		src := append([]byte("RIFF\x00\x00\x00\x00"), body...)
+		binary.LittleEndian.PutUint32(src[4:], uint32(len(body)))
+
+		out, _ := strip(t, src, imaging.FormatWebP)
+		if bytes.Contains(out, []byte("GPS")) {
+			t.Error("EXIF chunk left in stripped webp")
+		}
+		if got := binary.LittleEndian.Uint32(out[4:]); int(got) != len(out)-8 {
+			t.Errorf("RIFF size = %d, want %d", got, len(out)-8)
+		}
+		if out[20]&0x08 != 0 {
+			t.Error("VP8X exif flag left set")
+		}
+	})
+
+	t.Run("rejects garbage", func(t *testing.T) {
+		s := imaging.NewStripReader(bytes.NewReader([]byte("not an image")), imaging.FormatJPEG)
+		defer s.Close()
+		if _, err := io.ReadAll(s); err == nil {
+			t.Error("strip garbage error = nil")
+		}
+	})
+}
+
+func TestTransform(t *testing.T) {
+	t.Run("orientation 6 rotates clockwise", func(t *testing.T) {
+		src := testImage(3, 2)
+		dst := imaging.ApplyOrientation(src, 6)
+		if dst.Bounds().Dx() != 2 || dst.Bounds().Dy() != 3 {
+			t.Fatalf("bounds = %v, want 2x3", dst.Bounds())
+		}
+		// левый верхний угол уходит в правый верхний
+		if dst.At(1, 0) != src.At(0, 0) {
+			t.Errorf("At(1, 0) = %v, want %v", dst.At(1, 0), src.At(0, 0))
+		}
+	})
+
+	t.Run("fit keeps aspect and never upscales", func(t *testing.T) {
+		if got := imaging.Fit(testImage(400, 100), 64).Bounds(); got.Dx() != 64 || got.Dy() != 16 {
+			t.Errorf("Fit(400x100, 64) = %v", got)
+		}
+		if got := imaging.Fit(testImage(10, 20), 64).Bounds(); got.Dx() != 10 || got.Dy() != 20 {
+			t.Errorf("Fit(10x20, 64) = %v", got)
+		}
+	})
+
+	t.Run("resize averages pixels", func(t *testing.T) {
+		src := image.NewRGBA(image.Rect(0, 0, 2, 1))
+		src.Set(0, 0, color.RGBA{A: 255})
+		src.Set(1, 0, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+		got := imaging.Resize(src, 1, 1).RGBAAt(0, 0)
+		if got.R != 100 || got.A != 255 {
+			t.Errorf("Resize() pixel = %v, want gray 100", got)
+		}
+	})
+}
@@ -0,0 +1,305 @@
+package imaging
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatGIF  Format = "gif"
+	FormatWebP Format = "webp"
+)
+
+var ErrBadImage = errors.New("bad image")
+
+// StripReader отдает изображение без метаданных (EXIF, XMP, текстовые чанки).
+// Orientation из EXIF становится известна после того, как поток прочитан до конца
+type StripReader struct {
+	pr          *io.PipeReader
+	orientation int
+}
+
+func NewStripReader(r io.Reader, format Format) *StripReader {
+	pr, pw := io.Pipe()
+	s := &StripReader{pr: pr, orientation: 1}
+	go func() {
+		var err error
+		switch format {
+		case FormatJPEG:
+			err = stripJPEG(pw, r, &s.orientation)
+		case FormatPNG:
+			err = stripPNG(pw, r)
+		case FormatWebP:
+			err = stripWebP(pw, r)
+		default:
+			_, err = io.Copy(pw, r)
+		}
+		pw.CloseWithError(err)
+	}()
+	return s
+}
+
+func (s *StripReader) Read(p []byte) (int, error) {
+	return s.pr.Read(p)
+}
+
+// Close останавливает фоновую вычитку, если поток не дочитан
+func (s *StripReader) Close() error {
+	return s.pr.Close()
+}
+
+// Orientation значение тега EXIF Orientation (1..8), валидно после io.EOF
+func (s *StripReader) Orientation() int {
+	return s.orientation
+}
+
+const (
+	jpegSOI  = 0xD8
+	jpegEOI  = 0xD9
+	jpegSOS  = 0xDA
+	jpegAPP0 = 0xE0
+	jpegAPP1 = 0xE1
+	jpegAPP2 = 0xE2
+	jpegAPPE = 0xEE
+	jpegAPPF = 0xEF
+	jpegCOM  = 0xFE
+)
+
+var exifHeader = []byte("Exif\x00\x00")
+
+func stripJPEG(w io.Writer, src io.Reader, orientation *int) error {
+	r := bufio.NewReader(src)
+	var soi [2]byte
+	if _, err := io.ReadFull(r, soi[:]); err != nil || soi[0] != 0xFF || soi[1] != jpegSOI {
+		return ErrBadImage
+	}
+	if _, err := w.Write(soi[:]); err != nil {
+		return err
+	}
+
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return ErrBadImage
+		}
+		if b != 0xFF {
+			return ErrBadImage
+		}
+		marker, err := r.ReadByte()
+		for err == nil && marker == 0xFF {
+			marker, err = r.ReadByte()
+		}
+		if err != nil {
+			return ErrBadImage
+		}
+
+		// маркеры без длины
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			if _, err := w.Write([]byte{0xFF, marker}); err != nil {
+				return err
+			}
+			continue
+		}
+		if marker == jpegEOI {
+			_, err := w.Write([]byte{0xFF, marker})
+			return err
+		}
+
+		var lenBuf [2]byte
+		if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+			return ErrBadImage
+		}
+		length := int(binary.BigEndian.Uint16(lenBuf[:]))
+		if length < 2 {
+			return ErrBadImage
+		}
+		segment := make([]byte, length-2)
+		if _, err := io.ReadFull(r, segment); err != nil {
+			return ErrBadImage
+		}
+
+		keep := true
+		switch {
+		case marker == jpegAPP1:
+			keep = false
+			if bytes.HasPrefix(segment, exifHeader) {
+				*orientation = exifOrientation(segment[len(exifHeader):])
+				if *orientation != 1 {
+					// оставляем только ориентацию, чтобы браузер не показал фото боком до обработки
+					segment = minimalExif(*orientation)
+					keep = true
+				}
+			}
+		case marker == jpegAPP0, marker == jpegAPP2, marker == jpegAPPE:
+			// JFIF, ICC профиль и Adobe нужны для правильных цветов
+		case marker > jpegAPP2 && marker <= jpegAPPF, marker == jpegCOM:
+			keep = false
+		}
+		if keep {
+			binary.BigEndian.PutUint16(lenBuf[:], uint16(len(segment)+2))
+			if _, err := w.Write([]byte{0xFF, marker, lenBuf[0], lenBuf[1]}); err != nil {
+				return err
+			}
+			if _, err := w.Write(segment); err != nil {
+				return err
+			}
+		}
+
+		if marker == jpegSOS {
+			// дальше только данные изображения, метаданных там нет
+			_, err := io.Copy(w, r)
+			return err
+		}
+	}
+}
+
+// exifOrientation достает тег 0x0112 из IFD0 TIFF структуры
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	offset := int(order.Uint32(tiff[4:8]))
+	if offset < 8 || offset+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 && order.Uint16(tiff[entry+2:]) == 3 {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value >= 1 && value <= 8 {
+				return value
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// minimalExif APP1 сегмент с единственным тегом Orientation
+func minimalExif(orientation int) []byte {
+	buf := bytes.NewBuffer(nil)
+	buf.Write(exifHeader)
+	buf.WriteString("MM\x00\x2a")
+	_ = binary.Write(buf, binary.BigEndian, uint32(8))
+	_ = binary.Write(buf, binary.BigEndian, uint16(1))
+	_ = binary.Write(buf, binary.BigEndian, uint16(0x0112))
+	_ = binary.Write(buf, binary.BigEndian, uint16(3))
+	_ = binary.Write(buf, binary.BigEndian, uint32(1))
+	_ = binary.Write(buf, binary.BigEndian, uint16(orientation))
+	_ = binary.Write(buf, binary.BigEndian, uint16(0))
+	_ = binary.Write(buf, binary.BigEndian, uint32(0))
+	return buf.Bytes()
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngDropChunks чанки с метаданными, на отрисовку они не влияют
+var pngDropChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(w io.Writer, src io.Reader) error {
+	r := bufio.NewReader(src)
+	sig := make([]byte, len(pngSignature))
+	if _, err := io.ReadFull(r, sig); err != nil || !bytes.Equal(sig, pngSignature) {
+		return ErrBadImage
+	}
+	if _, err := w.Write(sig); err != nil {
+		return err
+	}
+	var header [8]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return ErrBadImage
+		}
+		length := int64(binary.BigEndian.Uint32(header[:4]))
+		chunkType := string(header[4:])
+		// данные и crc
+		body := io.LimitReader(r, length+4)
+		if pngDropChunks[chunkType] {
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				return ErrBadImage
+			}
+			continue
+		}
+		if _, err := w.Write(header[:]); err != nil {
+			return err
+		}
+		n, err := io.Copy(w, body)
+		if err != nil {
+			return err
+		}
+		if n != length+4 {
+			return ErrBadImage
+		}
+		if chunkType == "IEND" {
+			return nil
+		}
+	}
+}
+
+// stripWebP убирает чанки EXIF и XMP. Размер RIFF в заголовке меняется, поэтому файл читается целиком
+func stripWebP(w io.Writer, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return err
+	}
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return ErrBadImage
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for pos := 12; pos < len(data); {
+		if pos+8 > len(data) {
+			return ErrBadImage
+		}
+		chunkType := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4 : pos+8]))
+		end := pos + 8 + size + size%2
+		if end > len(data) {
+			return ErrBadImage
+		}
+		chunk := data[pos:end]
+		pos = end
+		switch chunkType {
+		case "EXIF", "XMP ":
+			continue
+		case "VP8X":
+			if len(chunk) > 8 {
+				chunk = append([]byte(nil), chunk...)
+				// флаги наличия EXIF и XMP
+				chunk[8] &^= 0x08 | 0x04
+			}
+		}
+		out.Write(chunk)
+	}
+	result := out.Bytes()
+	binary.LittleEndian.PutUint32(result[4:8], uint32(len(result)-8))
+	_, err = w.Write(result)
+	return err
+}
@@ -0,0 +1,118 @@
+package imaging
+
+import (
+	"image"
+	"image/draw"
+)
+
+// ApplyOrientation поворачивает и отражает изображение согласно EXIF Orientation,
+// чтобы результат можно было сохранять без метаданных
+func ApplyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	// 5-8 меняют стороны местами
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2:
+				dx, dy = w-1-x, y
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dx, dy = x, h-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, src.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// Fit уменьшает изображение так, чтобы большая сторона была не больше maxSide.
+// Маленькие изображения не увеличиваются
+func Fit(src image.Image, maxSide int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return src
+	}
+	dw, dh := maxSide, maxSide
+	if w > h {
+		dh = max(1, h*maxSide/w)
+	} else {
+		dw = max(1, w*maxSide/h)
+	}
+	return Resize(src, dw, dh)
+}
+
+// Resize масштабирует усреднением по площади, это дает чистый результат при сильном уменьшении
+func Resize(src image.Image, dw, dh int) *image.RGBA {
+	b := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || b.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	}
+	tmp := resizeAxis(rgba, dw, true)
+	return resizeAxis(tmp, dh, false)
+}
+
+// resizeAxis масштабирует по одной оси, веса крайних пикселей пропорциональны покрытию
+func resizeAxis(src *image.RGBA, size int, horizontal bool) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	srcLen, other := sh, sw
+	dst := image.NewRGBA(image.Rect(0, 0, sw, size))
+	if horizontal {
+		srcLen, other = sw, sh
+		dst = image.NewRGBA(image.Rect(0, 0, size, sh))
+	}
+	scale := float64(srcLen) / float64(size)
+
+	offset := func(img *image.RGBA, along, across int) int {
+		if horizontal {
+			return across*img.Stride + along*4
+		}
+		return along*img.Stride + across*4
+	}
+
+	for i := 0; i < size; i++ {
+		start := float64(i) * scale
+		end := start + scale
+		for j := 0; j < other; j++ {
+			var sum [4]float64
+			var total float64
+			for k := int(start); k < srcLen && float64(k) < end; k++ {
+				weight := min(end, float64(k+1)) - max(start, float64(k))
+				if weight <= 0 {
+					continue
+				}
+				p := offset(src, k, j)
+				for c := 0; c < 4; c++ {
+					sum[c] += float64(src.Pix[p+c]) * weight
+				}
+				total += weight
+			}
+			p := offset(dst, i, j)
+			for c := 0; c < 4; c++ {
+				dst.Pix[p+c] = uint8(sum[c]/total + 0.5)
+			}
+		}
+	}
+	return dst
+}
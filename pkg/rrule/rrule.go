@@ -0,0 +1,227 @@
+package rrule
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Freq частота повторения
+type Freq string
+
+const (
+	Daily   Freq = "DAILY"
+	Weekly  Freq = "WEEKLY"
+	Monthly Freq = "MONTHLY"
+	Yearly  Freq = "YEARLY"
+)
+
+const (
+	// maxInterval больше интервал не нужен и только замедляет перебор
+	maxInterval = 1000
+	// maxSteps ограничение перебора периодов, чтобы правило без совпадений не зациклилось
+	maxSteps = 100000
+
+	untilFormat     = "20060102T150405Z"
+	untilDateFormat = "20060102"
+)
+
+var weekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// Rule подмножество RRULE из RFC 5545: FREQ, INTERVAL, COUNT, UNTIL и BYDAY без номеров для WEEKLY.
+// Первое повторение - время начала, остальные считаются в его часовом поясе, поэтому
+// ежедневное напоминание в 9:00 остается в 9:00 после перевода часов.
+// Дни, которых нет в месяце (31 число, 29 февраля), пропускаются
+type Rule struct {
+	Freq     Freq
+	Interval int
+	// Count сколько всего повторений вместе с первым, 0 без ограничения
+	Count int
+	// Until последнее возможное повторение включительно
+	Until *time.Time
+	// ByDay дни недели начиная с понедельника
+	ByDay []time.Weekday
+}
+
+// Parse разбирает правило, префикс RRULE: необязателен
+func Parse(s string) (*Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	r := Rule{Interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("bad part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = Freq(strings.ToUpper(value))
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly && r.Freq != Yearly {
+				return nil, fmt.Errorf("unsupported FREQ %q", value)
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err != nil || r.Interval < 1 || r.Interval > maxInterval {
+				return nil, fmt.Errorf("bad INTERVAL %q", value)
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err != nil || r.Count < 1 {
+				return nil, fmt.Errorf("bad COUNT %q", value)
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return nil, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			seen := map[time.Weekday]bool{}
+			for _, day := range strings.Split(strings.ToUpper(value), ",") {
+				wd, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("unsupported BYDAY %q", day)
+				}
+				if !seen[wd] {
+					seen[wd] = true
+					r.ByDay = append(r.ByDay, wd)
+				}
+			}
+			sort.Slice(r.ByDay, func(i, j int) bool { return fromMonday(r.ByDay[i]) < fromMonday(r.ByDay[j]) })
+		default:
+			return nil, fmt.Errorf("unsupported part %q", key)
+		}
+	}
+	if r.Freq == "" {
+		return nil, fmt.Errorf("FREQ required")
+	}
+	if r.Count > 0 && r.Until != nil {
+		return nil, fmt.Errorf("COUNT and UNTIL are exclusive")
+	}
+	if len(r.ByDay) > 0 && r.Freq != Weekly {
+		return nil, fmt.Errorf("BYDAY supported only with FREQ=WEEKLY")
+	}
+	return &r, nil
+}
+
+// parseUntil UNTIL в UTC или дата, дата включает весь день по UTC
+func parseUntil(value string) (time.Time, error) {
+	if t, err := time.Parse(untilFormat, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(untilDateFormat, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("bad UNTIL %q", value)
+	}
+	return t.Add(24*time.Hour - time.Second), nil
+}
+
+// String правило в каноническом виде, без префикса RRULE:
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			days = append(days, strings.ToUpper(wd.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next первое повторение позже after. false, если правило закончилось
+func (r *Rule) Next(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	found := false
+	r.each(start, func(t time.Time) bool {
+		if t.After(after) {
+			next, found = t, true
+			return false
+		}
+		return true
+	})
+	return next, found
+}
+
+// each вызывает fn для повторений по порядку, пока fn возвращает true и правило не закончилось
+func (r *Rule) each(start time.Time, fn func(time.Time) bool) {
+	emitted := 0
+	emit := func(t time.Time) bool {
+		if r.Until != nil && t.After(*r.Until) || r.Count > 0 && emitted >= r.Count {
+			return false
+		}
+		emitted++
+		return fn(t)
+	}
+	if !emit(start) {
+		return
+	}
+	for step := 0; step < maxSteps; step++ {
+		for _, t := range r.period(start, step) {
+			if !t.After(start) {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// period повторения из step-го периода правила по возрастанию, время суток берется из start
+func (r *Rule) period(start time.Time, step int) []time.Time {
+	y, m, d := start.Date()
+	hour, minute, sec := start.Clock()
+	loc := start.Location()
+	k := step * r.Interval
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, sec, 0, loc)
+	}
+	switch r.Freq {
+	case Daily:
+		return []time.Time{at(y, m, d+k)}
+	case Weekly:
+		if len(r.ByDay) == 0 {
+			return []time.Time{at(y, m, d+7*k)}
+		}
+		monday := d - fromMonday(start.Weekday()) + 7*k
+		out := make([]time.Time, 0, len(r.ByDay))
+		for _, wd := range r.ByDay {
+			out = append(out, at(y, m, monday+fromMonday(wd)))
+		}
+		return out
+	case Monthly:
+		if t := at(y, m+time.Month(k), d); t.Day() == d {
+			return []time.Time{t}
+		}
+	case Yearly:
+		if t := at(y+k, m, d); t.Day() == d {
+			return []time.Time{t}
+		}
+	}
+	return nil
+}
+
+// fromMonday номер дня недели, понедельник - 0
+func fromMonday(wd time.Weekday) int {
+	return (int(wd) + 6) % 7
+}
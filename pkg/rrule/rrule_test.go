@@ -0,0 +1,78 @@
+package rrule_test
+
+import (
+	"testing"
+	"time"
+	"wn/pkg/rrule"
+)
+
+func TestParse(t *testing.T) {
+	r, err := rrule.Parse("RRULE:freq=weekly;byday=FR,MO,MO;interval=2;count=3")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if got, want := r.String(), "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3"; got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+	for _, bad := range []string{
+		"",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;INTERVAL=0",
+		"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+		"FREQ=DAILY;BYDAY=MO",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYMONTHDAY=1",
+	} {
+		if _, err := rrule.Parse(bad); err == nil {
+			t.Errorf("Parse(%q) error = nil", bad)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	date := func(s string) time.Time {
+		t.Helper()
+		d, err := time.ParseInLocation("2006-01-02 15:04", s, berlin)
+		if err != nil {
+			t.Fatalf("ParseInLocation(%q) error = %v", s, err)
+		}
+		return d
+	}
+	cases := []struct {
+		rule, start, after, want string
+	}{
+		{"FREQ=DAILY", "2026-10-01 09:00", "2026-09-01 00:00", "2026-10-01 09:00"},
+		// после перевода часов 25 октября время суток сохраняется
+		{"FREQ=DAILY", "2026-10-24 09:00", "2026-10-24 09:00", "2026-10-25 09:00"},
+		{"FREQ=DAILY;INTERVAL=3", "2026-10-01 09:00", "2026-10-05 00:00", "2026-10-07 09:00"},
+		// 2026-10-19 - понедельник
+		{"FREQ=WEEKLY;BYDAY=WE,FR", "2026-10-19 18:30", "2026-10-19 18:30", "2026-10-21 18:30"},
+		{"FREQ=WEEKLY;BYDAY=MO,FR;INTERVAL=2", "2026-10-21 08:00", "2026-10-23 08:00", "2026-11-02 08:00"},
+		{"FREQ=MONTHLY", "2026-01-31 10:00", "2026-01-31 10:00", "2026-03-31 10:00"},
+		{"FREQ=YEARLY", "2024-02-29 10:00", "2024-03-01 00:00", "2028-02-29 10:00"},
+		{"FREQ=DAILY;COUNT=3", "2026-10-01 09:00", "2026-10-02 10:00", "2026-10-03 09:00"},
+		{"FREQ=DAILY;COUNT=3", "2026-10-01 09:00", "2026-10-03 09:00", ""},
+		{"FREQ=WEEKLY;UNTIL=20261015", "2026-10-01 09:00", "2026-10-08 09:00", "2026-10-15 09:00"},
+		{"FREQ=WEEKLY;UNTIL=20261014T000000Z", "2026-10-01 09:00", "2026-10-08 09:00", ""},
+	}
+	for _, tc := range cases {
+		r, err := rrule.Parse(tc.rule)
+		if err != nil {
+			t.Fatalf("Parse(%q) error = %v", tc.rule, err)
+		}
+		got, ok := r.Next(date(tc.start), date(tc.after))
+		if tc.want == "" {
+			if ok {
+				t.Errorf("%s from %s after %s = %v, want none", tc.rule, tc.start, tc.after, got)
+			}
+			continue
+		}
+		if want := date(tc.want); !ok || !got.Equal(want) {
+			t.Errorf("%s from %s after %s = %v, %v, want %v", tc.rule, tc.start, tc.after, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// chunkSize размер куска INSTREAM, должен быть меньше StreamMaxLength в clamd.conf
+const chunkSize = 64 << 10
+
+var ErrScanFailed = errors.New("scan failed")
+
+// Clamd клиент демона ClamAV по протоколу clamd (команда INSTREAM)
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd address вида tcp://host:3310 или unix:///var/run/clamav/clamd.ctl.
+// timeout ограничивает одну проверку целиком
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "/"):
+		network = "unix"
+	default:
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+	return &Clamd{network: network, address: address, timeout: timeout}
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return nil, errors.Wrap(err, "dial clamd")
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, errors.Wrap(err, "conn.SetDeadline")
+		}
+	}
+
+	w := bufio.NewWriterSize(conn, chunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return nil, errors.Wrap(err, "write command")
+	}
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := w.Write(size); err != nil {
+				return nil, errors.Wrap(err, "write chunk size")
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return nil, errors.Wrap(err, "write chunk")
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, errors.Wrap(readErr, "read content")
+		}
+	}
+	// кусок нулевой длины завершает поток
+	if _, err := w.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, errors.Wrap(err, "write end of stream")
+	}
+	if err := w.Flush(); err != nil {
+		return nil, errors.Wrap(err, "flush")
+	}
+
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "read reply")
+	}
+	return parseReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseReply ответ вида "stream: OK", "stream: Eicar-Signature FOUND" или "... ERROR"
+func parseReply(reply string) (*Result, error) {
+	verdict := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case verdict == "OK":
+		return &Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return &Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return nil, errors.Wrap(ErrScanFailed, fmt.Sprintf("clamd: %q", reply))
+	}
+}
@@ -0,0 +1,98 @@
+package scanner_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"wn/pkg/scanner"
+)
+
+// fakeClamd принимает INSTREAM и отвечает FOUND, если в потоке есть "EICAR"
+func fakeClamd(t *testing.T, reply func(content string) string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("net.Listen() error = %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				command, err := r.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var content strings.Builder
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(r, size); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&content, r, int64(n)); err != nil {
+						return
+					}
+				}
+				_, _ = conn.Write([]byte(reply(content.String()) + "\x00"))
+			}(conn)
+		}
+	}()
+	return "tcp://" + ln.Addr().String()
+}
+
+func TestClamd(t *testing.T) {
+	ctx := context.Background()
+	address := fakeClamd(t, func(content string) string {
+		switch {
+		case strings.Contains(content, "EICAR"):
+			return "stream: Eicar-Test-Signature FOUND"
+		case strings.Contains(content, "huge"):
+			return "INSTREAM size limit exceeded. ERROR"
+		default:
+			return "stream: OK"
+		}
+	})
+	clamd := scanner.NewClamd(address, time.Second)
+
+	t.Run("clean", func(t *testing.T) {
+		// больше одного куска
+		result, err := clamd.Scan(ctx, strings.NewReader(strings.Repeat("a", 200<<10)))
+		if err != nil || result.Infected {
+			t.Fatalf("Scan() = %+v, %v, want clean", result, err)
+		}
+	})
+
+	t.Run("infected", func(t *testing.T) {
+		result, err := clamd.Scan(ctx, strings.NewReader("X5O!P%@AP EICAR test"))
+		if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+			t.Fatalf("Scan() = %+v, %v, want Eicar-Test-Signature", result, err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		if _, err := clamd.Scan(ctx, strings.NewReader("huge")); !errors.Is(err, scanner.ErrScanFailed) {
+			t.Fatalf("Scan() error = %v, want %v", err, scanner.ErrScanFailed)
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		if _, err := scanner.NewClamd("tcp://127.0.0.1:1", time.Second).Scan(ctx, strings.NewReader("a")); err == nil {
+			t.Fatalf("Scan() without daemon succeeded")
+		}
+	})
+}
@@ -0,0 +1,24 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Result вердикт антивируса. Signature - имя найденной сигнатуры
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+type Scanner interface {
+	// Scan читает поток целиком и проверяет его
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
+
+// Noop считает чистым любое содержимое, когда проверка выключена
+type Noop struct{}
+
+func (Noop) Scan(context.Context, io.Reader) (*Result, error) {
+	return &Result{}, nil
+}
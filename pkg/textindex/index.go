@@ -0,0 +1,112 @@
+package textindex
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"sort"
+	"unicode"
+)
+
+const (
+	// minPrefixLength короткие префиксы дают слишком много совпадений и не индексируются
+	minPrefixLength = 3
+	// maxPrefixLength префиксы длиннее не хранятся, длинный запрос ищется по первым символам
+	maxPrefixLength = 12
+	// tokenSize байт HMAC в токене
+	tokenSize = 16
+
+	// prefixWeight доля веса слова, которая достается его префиксам
+	prefixWeight = 0.5
+	// ExactBoost во столько раз совпадение по основе слова важнее совпадения по префиксу
+	ExactBoost = 2.0
+)
+
+// Field поле документа с весом, например заголовок важнее текста
+type Field struct {
+	Text   string
+	Weight float64
+}
+
+// Token слепой токен: HMAC основы слова или префикса, без ключа по нему не восстановить слово
+type Token struct {
+	Hash   []byte
+	Weight float64
+}
+
+// Term слово запроса: документ подходит, если содержит Exact или Prefix
+type Term struct {
+	Exact  []byte
+	Prefix []byte
+}
+
+// Indexer строит слепой индекс для зашифрованных текстов.
+// Один и тот же ключ нужен и для индексации, и для поиска
+type Indexer struct {
+	key []byte
+}
+
+func NewIndexer(key string) *Indexer {
+	sum := sha256.Sum256([]byte("textindex:" + key))
+	return &Indexer{key: sum[:]}
+}
+
+// Stem основа слова для русского или английского, остальные слова не меняются
+func Stem(word string) string {
+	latin, cyrillic := true, true
+	for _, r := range word {
+		latin = latin && r >= 'a' && r <= 'z'
+		cyrillic = cyrillic && unicode.Is(unicode.Cyrillic, r)
+	}
+	switch {
+	case latin:
+		return stemEn(word)
+	case cyrillic:
+		return stemRu(word)
+	default:
+		return word
+	}
+}
+
+// Index токены документа. Вес токена - сумма весов полей по всем вхождениям
+func (ix *Indexer) Index(fields ...Field) []Token {
+	weights := map[string]float64{}
+	for _, f := range fields {
+		for _, word := range Words(f.Text) {
+			weights[string(ix.hash("w:", Stem(word)))] += f.Weight
+			runes := []rune(word)
+			for n := minPrefixLength; n <= len(runes) && n <= maxPrefixLength; n++ {
+				weights[string(ix.hash("p:", string(runes[:n])))] += f.Weight * prefixWeight
+			}
+		}
+	}
+	tokens := make([]Token, 0, len(weights))
+	for hash, weight := range weights {
+		tokens = append(tokens, Token{Hash: []byte(hash), Weight: weight})
+	}
+	sort.Slice(tokens, func(i, j int) bool { return string(tokens[i].Hash) < string(tokens[j].Hash) })
+	return tokens
+}
+
+// Query слова поискового запроса без повторов
+func (ix *Indexer) Query(text string) []Term {
+	seen := map[string]bool{}
+	var terms []Term
+	for _, word := range Words(text) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		term := Term{Exact: ix.hash("w:", Stem(word))}
+		if runes := []rune(word); len(runes) >= minPrefixLength {
+			term.Prefix = ix.hash("p:", string(runes[:min(len(runes), maxPrefixLength)]))
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+func (ix *Indexer) hash(kind, value string) []byte {
+	mac := hmac.New(sha256.New, ix.key)
+	mac.Write([]byte(kind + value))
+	return mac.Sum(nil)[:tokenSize]
+}
@@ -0,0 +1,102 @@
+package textindex_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"wn/pkg/textindex"
+)
+
+func TestStem(t *testing.T) {
+	cases := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"running":        "run",
+		"hopping":        "hop",
+		"generalization": "general",
+		"connection":     "connect",
+		"meetings":       "meet",
+		"effective":      "effect",
+		"книги":          "книг",
+		"заметки":        "заметк",
+		"заметка":        "заметк",
+		"красивая":       "красив",
+		"важнейший":      "важн",
+		"бегающий":       "бега",
+		"сделавшись":     "сдела",
+		"длинный":        "длин",
+		"x86":            "x86",
+	}
+	for word, want := range cases {
+		if got := textindex.Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, want %q", word, got, want)
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	got := textindex.Words("The ПЛАН на Ёлку: [[Meeting|alias]], a/b x2")
+	want := []string{"план", "елку", "meeting", "alias", "x2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Words() = %v, want %v", got, want)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix := textindex.NewIndexer("secret")
+	tokens := ix.Index(
+		textindex.Field{Text: "Планирование", Weight: 3},
+		textindex.Field{Text: "обсудили планы встречи", Weight: 1},
+	)
+	weights := map[string]float64{}
+	for _, token := range tokens {
+		weights[string(token.Hash)] = token.Weight
+	}
+	match := func(term textindex.Term) (float64, bool) {
+		if w, ok := weights[string(term.Exact)]; ok {
+			return w * textindex.ExactBoost, true
+		}
+		w, ok := weights[string(term.Prefix)]
+		return w, ok
+	}
+
+	t.Run("stem", func(t *testing.T) {
+		terms := ix.Query("встреча")
+		if len(terms) != 1 {
+			t.Fatalf("Query() = %d terms, want 1", len(terms))
+		}
+		if _, ok := match(terms[0]); !ok {
+			t.Fatal("встреча does not match встречи")
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		terms := ix.Query("планир")
+		score, ok := match(terms[0])
+		if !ok {
+			t.Fatal("планир does not match планирование")
+		}
+		if exact, _ := match(ix.Query("план")[0]); exact <= score {
+			t.Fatalf("exact score %v <= prefix score %v", exact, score)
+		}
+		if _, ok := match(ix.Query("встретились")[0]); ok {
+			t.Fatal("встретились matched")
+		}
+	})
+
+	t.Run("stop words and duplicates", func(t *testing.T) {
+		if terms := ix.Query("и в на"); len(terms) != 0 {
+			t.Fatalf("Query() = %d terms, want 0", len(terms))
+		}
+		if terms := ix.Query("план План"); len(terms) != 1 {
+			t.Fatalf("Query() = %d terms, want 1", len(terms))
+		}
+	})
+
+	t.Run("key", func(t *testing.T) {
+		other := textindex.NewIndexer("other").Query("встречи")[0]
+		if bytes.Equal(other.Exact, ix.Query("встречи")[0].Exact) {
+			t.Fatal("tokens do not depend on key")
+		}
+	})
+}
@@ -0,0 +1,246 @@
+package textindex
+
+import "strings"
+
+// Стеммер Porter2 (Snowball english): https://snowballstem.org/algorithms/english/stemmer.html
+// без списков исключений
+
+type enRule struct {
+	suffix  string
+	replace string
+}
+
+var (
+	enStep2Rules = []enRule{
+		{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+		{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+		{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"},
+		{"fulli", "ful"},
+		{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"}, {"alli", "al"},
+		{"bli", "ble"}, {"ogi", "og"},
+		{"li", ""},
+	}
+	enStep3Rules = []enRule{
+		{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+		{"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+	}
+	enStep4Suffixes = []string{
+		"ement", "ance", "ence", "able", "ible", "ment",
+		"ant", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion",
+		"al", "er", "ic",
+	}
+)
+
+func isEnVowel(b byte) bool {
+	switch b {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// stemEn слово из строчных латинских букв
+func stemEn(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	w := []byte(strings.TrimPrefix(word, "'"))
+	// y в роли согласной помечается Y
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isEnVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+	r1, r2 := enRegions(w)
+
+	w = enStep0(w)
+	w = enStep1a(w)
+	w = enStep1b(w, r1)
+	w = enStep1c(w)
+	w = enApply(w, r1, enStep2Rules, enStep2Cond)
+	w = enApply(w, r1, enStep3Rules, func(_ []byte, start int, suffix string) bool {
+		return suffix != "ative" || start >= r2
+	})
+	w = enStep4(w, r2)
+	w = enStep5(w, r1, r2)
+	return strings.ReplaceAll(string(w), "Y", "y")
+}
+
+func enRegions(w []byte) (int, int) {
+	r1 := enNextRegion(w, 0)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+		}
+	}
+	return r1, enNextRegion(w, r1)
+}
+
+func enNextRegion(w []byte, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isEnVowel(w[i]) && isEnVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func hasVowel(w []byte) bool {
+	for _, b := range w {
+		if isEnVowel(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// endsShortSyllable согласная-гласная-согласная (не w, x, Y) в конце или гласная-согласная в начале слова
+func endsShortSyllable(w []byte) bool {
+	n := len(w)
+	if n == 2 {
+		return isEnVowel(w[0]) && !isEnVowel(w[1])
+	}
+	if n < 3 {
+		return false
+	}
+	c := w[n-1]
+	return !isEnVowel(w[n-3]) && isEnVowel(w[n-2]) && !isEnVowel(c) && c != 'w' && c != 'x' && c != 'Y'
+}
+
+func isDouble(w []byte) bool {
+	n := len(w)
+	if n < 2 || w[n-1] != w[n-2] {
+		return false
+	}
+	return strings.IndexByte("bdfgmnprt", w[n-1]) >= 0
+}
+
+func enStep0(w []byte) []byte {
+	for _, s := range []string{"'s'", "'s", "'"} {
+		if strings.HasSuffix(string(w), s) {
+			return w[:len(w)-len(s)]
+		}
+	}
+	return w
+}
+
+func enStep1a(w []byte) []byte {
+	s := string(w)
+	switch {
+	case strings.HasSuffix(s, "sses"):
+		return w[:len(w)-2]
+	case strings.HasSuffix(s, "ied"), strings.HasSuffix(s, "ies"):
+		if len(w) > 4 {
+			return append(w[:len(w)-3], 'i')
+		}
+		return append(w[:len(w)-3], 'i', 'e')
+	case strings.HasSuffix(s, "us"), strings.HasSuffix(s, "ss"):
+		return w
+	case strings.HasSuffix(s, "s"):
+		if len(w) > 2 && hasVowel(w[:len(w)-2]) {
+			return w[:len(w)-1]
+		}
+	}
+	return w
+}
+
+func enStep1b(w []byte, r1 int) []byte {
+	s := string(w)
+	for _, suffix := range []string{"eedly", "eed"} {
+		if strings.HasSuffix(s, suffix) {
+			if len(w)-len(suffix) >= r1 {
+				return append(w[:len(w)-len(suffix)], 'e', 'e')
+			}
+			return w
+		}
+	}
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !strings.HasSuffix(s, suffix) {
+			continue
+		}
+		stem := w[:len(w)-len(suffix)]
+		if !hasVowel(stem) {
+			return w
+		}
+		st := string(stem)
+		switch {
+		case strings.HasSuffix(st, "at"), strings.HasSuffix(st, "bl"), strings.HasSuffix(st, "iz"):
+			return append(stem, 'e')
+		case isDouble(stem):
+			return stem[:len(stem)-1]
+		case endsShortSyllable(stem) && r1 >= len(stem):
+			return append(stem, 'e')
+		}
+		return stem
+	}
+	return w
+}
+
+func enStep1c(w []byte) []byte {
+	n := len(w)
+	if n > 2 && (w[n-1] == 'y' || w[n-1] == 'Y') && !isEnVowel(w[n-2]) {
+		w[n-1] = 'i'
+	}
+	return w
+}
+
+// enStep2Cond ogi удаляется только после l, li - после c, d, e, g, h, k, m, n, r, t
+func enStep2Cond(w []byte, start int, suffix string) bool {
+	switch suffix {
+	case "ogi":
+		return start > 0 && w[start-1] == 'l'
+	case "li":
+		return start > 0 && strings.IndexByte("cdeghkmnrt", w[start-1]) >= 0
+	}
+	return true
+}
+
+// enApply заменяет самое длинное подходящее окончание, если оно в области r
+func enApply(w []byte, r int, rules []enRule, cond func(w []byte, start int, suffix string) bool) []byte {
+	s := string(w)
+	best := -1
+	for i, rule := range rules {
+		if strings.HasSuffix(s, rule.suffix) && (best < 0 || len(rule.suffix) > len(rules[best].suffix)) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return w
+	}
+	start := len(w) - len(rules[best].suffix)
+	if start < r || !cond(w, start, rules[best].suffix) {
+		return w
+	}
+	return append(w[:start], rules[best].replace...)
+}
+
+func enStep4(w []byte, r2 int) []byte {
+	s := string(w)
+	best := ""
+	for _, suffix := range enStep4Suffixes {
+		if strings.HasSuffix(s, suffix) && len(suffix) > len(best) {
+			best = suffix
+		}
+	}
+	start := len(w) - len(best)
+	if best == "" || start < r2 {
+		return w
+	}
+	if best == "ion" && (start == 0 || (w[start-1] != 's' && w[start-1] != 't')) {
+		return w
+	}
+	return w[:start]
+}
+
+func enStep5(w []byte, r1, r2 int) []byte {
+	n := len(w)
+	switch {
+	case n > 0 && w[n-1] == 'e':
+		if n-1 >= r2 || (n-1 >= r1 && !endsShortSyllable(w[:n-1])) {
+			return w[:n-1]
+		}
+	case n > 1 && w[n-1] == 'l' && w[n-2] == 'l' && n-1 >= r2:
+		return w[:n-1]
+	}
+	return w
+}
@@ -0,0 +1,160 @@
+package textindex
+
+// Стеммер Snowball для русского языка: https://snowballstem.org/algorithms/russian/stemmer.html
+
+var (
+	ruPerfectiveGerund1 = []string{"вшись", "вши", "в"}
+	ruPerfectiveGerund2 = []string{"ившись", "ывшись", "ивши", "ывши", "ив", "ыв"}
+	ruAdjective         = []string{
+		"ими", "ыми", "его", "ого", "ему", "ому",
+		"ее", "ие", "ые", "ое", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом",
+		"их", "ых", "ую", "юю", "ая", "яя", "ою", "ею",
+	}
+	ruParticiple1 = []string{"ем", "нн", "вш", "ющ", "щ"}
+	ruParticiple2 = []string{"ивш", "ывш", "ующ"}
+	ruReflexive   = []string{"ся", "сь"}
+	ruVerb1       = []string{
+		"ете", "йте", "ешь", "нно",
+		"ла", "на", "ли", "ем", "ло", "но", "ет", "ют", "ны", "ть",
+		"й", "л", "н",
+	}
+	ruVerb2 = []string{
+		"ейте", "уйте",
+		"ила", "ыла", "ена", "ите", "или", "ыли", "ило", "ыло", "ено", "ует", "уют", "ены", "ить", "ыть", "ишь",
+		"ей", "уй", "ил", "ыл", "им", "ым", "ен", "ят", "ит", "ыт", "ую",
+		"ю",
+	}
+	ruNoun = []string{
+		"иями",
+		"ями", "ами", "ией", "иям", "ием", "иях",
+		"ев", "ов", "ие", "ье", "еи", "ии", "ей", "ой", "ий", "ям", "ем", "ам", "ом", "ах", "ях", "ию", "ью", "ия", "ья",
+		"а", "е", "и", "й", "о", "у", "ы", "ь", "ю", "я",
+	}
+	ruSuperlative  = []string{"ейше", "ейш"}
+	ruDerivational = []string{"ость", "ост"}
+)
+
+func isRuVowel(r rune) bool {
+	switch r {
+	case 'а', 'е', 'и', 'о', 'у', 'ы', 'э', 'ю', 'я':
+		return true
+	}
+	return false
+}
+
+// stemRu слово в нижнем регистре, ё уже заменена на е
+func stemRu(word string) string {
+	w := []rune(word)
+	rv, r2 := ruRegions(w)
+	if rv >= len(w) {
+		return word
+	}
+
+	// шаг 1
+	if end, ok := ruRemoveGrouped(w, rv, ruPerfectiveGerund1, ruPerfectiveGerund2); ok {
+		w = w[:end]
+	} else {
+		if end, ok := ruSuffix(w, rv, ruReflexive); ok {
+			w = w[:end]
+		}
+		if end, ok := ruRemoveAdjectival(w, rv); ok {
+			w = w[:end]
+		} else if end, ok := ruRemoveGrouped(w, rv, ruVerb1, ruVerb2); ok {
+			w = w[:end]
+		} else if end, ok := ruSuffix(w, rv, ruNoun); ok {
+			w = w[:end]
+		}
+	}
+
+	// шаг 2
+	if len(w) > rv && w[len(w)-1] == 'и' {
+		w = w[:len(w)-1]
+	}
+
+	// шаг 3
+	if end, ok := ruSuffix(w, r2, ruDerivational); ok {
+		w = w[:end]
+	}
+
+	// шаг 4
+	superlative := false
+	if end, ok := ruSuffix(w, rv, ruSuperlative); ok {
+		w = w[:end]
+		superlative = true
+	}
+	switch {
+	case len(w)-2 >= rv && w[len(w)-1] == 'н' && w[len(w)-2] == 'н':
+		w = w[:len(w)-1]
+	case !superlative && len(w) > rv && w[len(w)-1] == 'ь':
+		w = w[:len(w)-1]
+	}
+	return string(w)
+}
+
+// ruRegions RV - после первой гласной, R2 - R1 от R1
+func ruRegions(w []rune) (int, int) {
+	rv := len(w)
+	for i, r := range w {
+		if isRuVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	r1 := ruNextRegion(w, 0)
+	return rv, ruNextRegion(w, r1)
+}
+
+// ruNextRegion начало области после первой согласной, следующей за гласной
+func ruNextRegion(w []rune, from int) int {
+	for i := from + 1; i < len(w); i++ {
+		if !isRuVowel(w[i]) && isRuVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+// ruSuffix самое длинное окончание из списка, целиком лежащее в области с начала region
+func ruSuffix(w []rune, region int, suffixes []string) (int, bool) {
+	best := -1
+	for _, s := range suffixes {
+		sr := []rune(s)
+		start := len(w) - len(sr)
+		if start < region || (best >= 0 && start >= best) {
+			continue
+		}
+		if string(w[start:]) == s {
+			best = start
+		}
+	}
+	return best, best >= 0
+}
+
+// ruRemoveGrouped окончания первой группы удаляются только после а или я
+func ruRemoveGrouped(w []rune, region int, group1, group2 []string) (int, bool) {
+	end1, ok1 := ruSuffix(w, region, group1)
+	if ok1 && (end1 == 0 || end1-1 < region || (w[end1-1] != 'а' && w[end1-1] != 'я')) {
+		ok1 = false
+	}
+	end2, ok2 := ruSuffix(w, region, group2)
+	switch {
+	case ok1 && ok2:
+		return min(end1, end2), true
+	case ok1:
+		return end1, true
+	default:
+		return end2, ok2
+	}
+}
+
+// ruRemoveAdjectival прилагательное, возможно с причастием перед ним
+func ruRemoveAdjectival(w []rune, region int) (int, bool) {
+	end, ok := ruSuffix(w, region, ruAdjective)
+	if !ok {
+		return 0, false
+	}
+	if pEnd, ok := ruRemoveGrouped(w[:end], region, ruParticiple1, ruParticiple2); ok {
+		return pEnd, true
+	}
+	return end, true
+}
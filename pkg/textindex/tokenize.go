@@ -0,0 +1,52 @@
+package textindex
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// minWordLength слова короче не индексируются
+	minWordLength = 2
+	// maxWordLength слова длиннее (ссылки, хеши) обрезаются
+	maxWordLength = 40
+)
+
+var stopWords = map[string]bool{
+	// english
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "by": true,
+	"for": true, "from": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "was": true, "with": true,
+	// русский
+	"и": true, "в": true, "во": true, "не": true, "что": true, "он": true, "на": true, "я": true,
+	"с": true, "со": true, "как": true, "а": true, "то": true, "все": true, "она": true, "так": true,
+	"его": true, "но": true, "да": true, "ты": true, "к": true, "у": true, "же": true, "вы": true,
+	"за": true, "бы": true, "по": true, "ее": true, "мне": true, "из": true, "ли": true, "или": true,
+	"о": true, "об": true, "от": true, "до": true, "для": true, "это": true,
+}
+
+// Words разбивает текст на нормализованные слова: нижний регистр, ё как е, без стоп-слов
+func Words(text string) []string {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := make([]string, 0, len(fields))
+	for _, f := range fields {
+		w := normalize(f)
+		if utf8.RuneCountInString(w) < minWordLength || stopWords[w] {
+			continue
+		}
+		words = append(words, w)
+	}
+	return words
+}
+
+func normalize(word string) string {
+	word = strings.ToLower(word)
+	word = strings.ReplaceAll(word, "ё", "е")
+	if utf8.RuneCountInString(word) > maxWordLength {
+		word = string([]rune(word)[:maxWordLength])
+	}
+	return word
+}
@@ -0,0 +1,55 @@
+package urlsign
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	ExpiresParam   = "exp"
+	SignatureParam = "sig"
+)
+
+// Signer подписывает короткоживущие ссылки на ресурсы
+type Signer struct {
+	key []byte
+	ttl time.Duration
+}
+
+func NewSigner(key string, ttl time.Duration) *Signer {
+	sum := sha256.Sum256([]byte("urlsign:" + key))
+	return &Signer{key: sum[:], ttl: ttl}
+}
+
+// Sign возвращает query параметры подписи ресурса, действительные ttl
+func (s *Signer) Sign(resource string, now time.Time) url.Values {
+	exp := strconv.FormatInt(now.Add(s.ttl).Unix(), 10)
+	return url.Values{
+		ExpiresParam:   {exp},
+		SignatureParam: {s.signature(resource, exp)},
+	}
+}
+
+// Verify проверяет подпись и срок действия
+func (s *Signer) Verify(resource string, query url.Values, now time.Time) bool {
+	exp := query.Get(ExpiresParam)
+	sig := query.Get(SignatureParam)
+	if exp == "" || sig == "" {
+		return false
+	}
+	expUnix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || now.Unix() > expUnix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signature(resource, exp)))
+}
+
+func (s *Signer) signature(resource, exp string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(resource + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,42 @@
+package urlsign_test
+
+import (
+	"testing"
+	"time"
+	"wn/pkg/urlsign"
+)
+
+func TestSigner(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	signer := urlsign.NewSigner("secret", time.Minute)
+
+	t.Run("accepts own signature before expiry", func(t *testing.T) {
+		query := signer.Sign("a.png", now)
+		if !signer.Verify("a.png", query, now.Add(59*time.Second)) {
+			t.Error("Verify() = false, want true")
+		}
+	})
+
+	t.Run("rejects expired, foreign and tampered signatures", func(t *testing.T) {
+		query := signer.Sign("a.png", now)
+		if signer.Verify("a.png", query, now.Add(2*time.Minute)) {
+			t.Error("expired signature accepted")
+		}
+		if signer.Verify("b.png", query, now) {
+			t.Error("signature for another resource accepted")
+		}
+		if urlsign.NewSigner("other", time.Minute).Verify("a.png", query, now) {
+			t.Error("signature with another key accepted")
+		}
+		query.Set(urlsign.ExpiresParam, "9999999999")
+		if signer.Verify("a.png", query, now) {
+			t.Error("signature with extended expiry accepted")
+		}
+	})
+
+	t.Run("rejects missing params", func(t *testing.T) {
+		if signer.Verify("a.png", nil, now) {
+			t.Error("empty query accepted")
+		}
+	})
+}
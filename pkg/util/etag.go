@@ -0,0 +1,26 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseIfMatch достает версию из If-Match. Принимает "3", W/"3" и 3, пустой заголовок - nil
+func ParseIfMatch(header string) (*int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	header = strings.TrimPrefix(header, "W/")
+	header = strings.Trim(header, `"`)
+
+	version, err := strconv.ParseInt(header, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+func ETag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
@@ -0,0 +1,51 @@
+package util_test
+
+import (
+	"testing"
+	"wn/pkg/util"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	t.Run("empty and wildcard mean no condition", func(t *testing.T) {
+		for _, header := range []string{"", "*", "  "} {
+			version, err := util.ParseIfMatch(header)
+			if err != nil {
+				t.Fatalf("ParseIfMatch(%q) error = %v", header, err)
+			}
+			if version != nil {
+				t.Errorf("ParseIfMatch(%q) = %d, want nil", header, *version)
+			}
+		}
+	})
+
+	t.Run("parses bare, quoted and weak versions", func(t *testing.T) {
+		cases := map[string]int64{
+			"3":      3,
+			`"3"`:    3,
+			`W/"12"`: 12,
+			` "7" `:  7,
+		}
+		for header, want := range cases {
+			version, err := util.ParseIfMatch(header)
+			if err != nil {
+				t.Fatalf("ParseIfMatch(%q) error = %v", header, err)
+			}
+			if version == nil || *version != want {
+				t.Errorf("ParseIfMatch(%q) = %v, want %d", header, version, want)
+			}
+		}
+	})
+
+	t.Run("rejects non numeric etag", func(t *testing.T) {
+		if _, err := util.ParseIfMatch(`"abc"`); err == nil {
+			t.Error("expected error for non numeric etag")
+		}
+	})
+
+	t.Run("round trip with ETag", func(t *testing.T) {
+		version, err := util.ParseIfMatch(util.ETag(42))
+		if err != nil || version == nil || *version != 42 {
+			t.Fatalf("ParseIfMatch(ETag(42)) = %v, %v", version, err)
+		}
+	})
+}
@@ -20,3 +20,12 @@ func ConvertStringToTime(timeStr string) (*time.Time, error) {
 	}
 	return &t, nil
 }
+
+// GetCurrentLocalTime текущее время в часовом поясе IANA, пустой пояс - UTC
+func GetCurrentLocalTime(timezone string) (time.Time, error) {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unknown timezone {%s}", timezone)
+	}
+	return GetCurrentUTCTime().In(loc), nil
+}
@@ -15,3 +15,16 @@ func CheckUUIDIsZero(id uuid.UUID) bool {
 func UUIDFromString(id string) (uuid.UUID, error) {
 	return uuid.Parse(id)
 }
+
+// UniqueUUIDs id без повторов в исходном порядке
+func UniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	out := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}